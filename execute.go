@@ -4,15 +4,11 @@ import (
 	"bytes"
 	"fmt"
 	"github.com/PPTide/gojdk/parse"
+	"github.com/PPTide/gojdk/parse/verify"
+	"io"
 	"strings"
 )
 
-const (
-	methodFlagAccPublic = 0x0001
-	methodFlagAccStatic = 0x0008
-	methodFlagAccNative = 0x0100
-)
-
 type variable struct {
 	valType       string
 	val           interface{}
@@ -50,8 +46,19 @@ func createAsReferenceAndAddToHeap(referenceType string, reference interface{},
 }
 
 type state struct {
-	frames []frame
-	files  []parse.ClassFile
+	frames   []frame
+	files    []parse.ClassFile
+	verifier *verify.Verifier               // nil if loaded classes aren't verified
+	statics  map[string]map[string]variable // static fields of the initialized classes
+	out      io.Writer                      // written to by System.out
+
+	fieldAccess map[fieldRef]error // result of the access checks of the field references executed so far
+}
+
+// fieldRef is the ConstantFieldrefInfo at index in the constant pool of class
+type fieldRef struct {
+	class string
+	index int
 }
 
 type frame struct {
@@ -78,7 +85,34 @@ type class struct {
 	vars      map[string]variable
 }
 
-func execute(file parse.ClassFile) error {
+// classObject is the java.lang.Class object of the class called name
+type classObject struct {
+	name string
+}
+
+// printStream is the java.io.PrintStream object of System.out, it writes to w
+type printStream struct {
+	w io.Writer
+}
+
+// recordComponent is a java.lang.reflect.RecordComponent, one of the components Class.getRecordComponents returns
+type recordComponent struct {
+	name       string
+	descriptor string
+	signature  string // generic signature of the component type, "" if it isn't generic
+	accessor   string // name of the accessor method, it takes no arguments and returns the descriptor type
+}
+
+// execute runs the main method of file, System.out writes to out
+func execute(file parse.ClassFile, verifier *verify.Verifier, out io.Writer) error {
+	if file.Module != nil {
+		if file.Module.MainClass != "" {
+			return fmt.Errorf("module-info describes the module %s and can't be run, run its main class %s instead",
+				file.Module.Name, file.Module.MainClass)
+		}
+		return fmt.Errorf("module-info describes the module %s and can't be run", file.Module.Name)
+	}
+
 	var mainMethod parse.MethodInfo
 	for _, method := range file.Methods {
 		if file.ConstantPool[method.NameIndex-1].(parse.ConstantUtf8Info).Text == "main" {
@@ -89,70 +123,41 @@ func execute(file parse.ClassFile) error {
 	return fmt.Errorf("no main method found")
 mainFound:
 	descriptor := file.ConstantPool[mainMethod.DescriptorIndex-1].(parse.ConstantUtf8Info).Text
-	if !(descriptor == "([Ljava/lang/String;)V" && mainMethod.AccessFlags == methodFlagAccPublic|methodFlagAccStatic) {
+	if !(descriptor == "([Ljava/lang/String;)V" && mainMethod.AccessFlags.IsPublic() && mainMethod.AccessFlags.IsStatic()) {
 		return fmt.Errorf("main method not formated corectly")
 	}
-	var mainMethodCodeAttribute parse.AttributeInfo
-	for _, attribute := range mainMethod.Attributes {
-		if file.ConstantPool[attribute.AttributeNameIndex-1].(parse.ConstantUtf8Info).Text == "Code" {
-			mainMethodCodeAttribute = attribute
-			goto codeFound
-		}
-	}
-	return fmt.Errorf("code attribute not found in main")
-codeFound:
-	reader := (*parse.ClassFileReader)(bytes.NewReader(mainMethodCodeAttribute.Info))
-
-	maxStack, err := reader.ReadU2() // maxStack
-	if err != nil {
-		return err
-	}
-	_ = maxStack
-	maxLocals, err := reader.ReadU2() // maxLocals
-	if err != nil {
-		return err
-	}
-
-	codeLength, err := reader.ReadU4()
-	if err != nil {
-		return err
-	}
-	code := make([]byte, codeLength)
-	_, err = reader.Read(code)
-	if err != nil {
-		return err
-	}
-
-	exceptionTableLength, _ := reader.ReadU2()
-	exceptionTable := make([]byte, exceptionTableLength)
-	_, err = reader.Read(exceptionTable) //TODO: Parse the exception table
-	if err != nil {
-		return err
-	}
-
-	_, _, err = reader.ReadAttributes() // Attributes
-	if err != nil {
-		return err
+	if mainMethod.Code == nil {
+		return fmt.Errorf("code attribute not found in main")
 	}
 
 	// ------------------- Code Execution ---------------------
 	operandStack := make(varSlice, 0)
-	localVariable := make(varSlice, maxLocals)
+	localVariable := make(varSlice, mainMethod.Code.MaxLocals)
 	heap := make([]interface{}, 0)
 	f := frame{
-		codeReader:    (*parse.ClassFileReader)(bytes.NewReader(code)),
+		codeReader:    (*parse.ClassFileReader)(bytes.NewReader(mainMethod.Code.Code)),
 		operandStack:  &operandStack,
 		localVariable: &localVariable,
 		file:          file,
 		heap:          &heap,
 	}
 	s := state{
-		frames: make([]frame, 0),
-		files:  make([]parse.ClassFile, 0),
+		frames:   make([]frame, 0),
+		files:    make([]parse.ClassFile, 0),
+		verifier: verifier,
+		statics:  make(map[string]map[string]variable),
+		out:      out,
+
+		fieldAccess: make(map[fieldRef]error),
 	}
 	s.frames = append(s.frames, f)
 	s.files = append(s.files, file)
 
+	err := checkPermittedSubclass(file, &s)
+	if err != nil {
+		return err
+	}
+
 	for f.codeReader.Len() > 0 {
 		b, err := f.codeReader.ReadByte()
 		if err != nil {
@@ -203,7 +208,14 @@ methodFound:
 		return fmt.Errorf("method not formated as expected corectly: %s != %s", descriptor, methodDescriptor)
 	}
 
-	if mainMethod.AccessFlags&methodFlagAccNative != 0 {
+	if mainMethod.AccessFlags.IsPrivate() && len(s.frames) > 0 {
+		err := checkPrivateAccess(s.frames[len(s.frames)-1].file, file, methodName, s)
+		if err != nil {
+			return err
+		}
+	}
+
+	if mainMethod.AccessFlags.IsNative() {
 		if methodName == "registerNatives" {
 			// TODO: implement native methods correctly
 			return nil
@@ -212,59 +224,19 @@ methodFound:
 		}
 	}
 
-	var mainMethodCodeAttribute parse.AttributeInfo
-	for _, attribute := range mainMethod.Attributes {
-		if file.ConstantPool[attribute.AttributeNameIndex-1].(parse.ConstantUtf8Info).Text == "Code" {
-			mainMethodCodeAttribute = attribute
-			goto codeFound
-		}
-	}
-	return fmt.Errorf("code attribute not found in main")
-codeFound:
-	reader := (*parse.ClassFileReader)(bytes.NewReader(mainMethodCodeAttribute.Info))
-
-	maxStack, err := reader.ReadU2() // maxStack
-	if err != nil {
-		return err
-	}
-	_ = maxStack
-	maxLocals, err := reader.ReadU2() // maxLocals
-	if err != nil {
-		return err
-	}
-	_ = maxLocals
-
-	codeLength, err := reader.ReadU4()
-	if err != nil {
-		return err
-	}
-	code := make([]byte, codeLength)
-	_, err = reader.Read(code)
-	if err != nil {
-		return err
-	}
-
-	exceptionTableLength, _ := reader.ReadU2()             // FIXME: fuck I'm reading exception tables wrong...
-	exceptionTable := make([]byte, exceptionTableLength*8) // each entry is 8 bytes long so im just reading them away here... hope it works :)
-	_, err = reader.Read(exceptionTable)                   //TODO: Parse the exception table
-	if err != nil {
-		return err
-	}
-
-	_, _, err = reader.ReadAttributes() // TODO: Parse these while creating the class file to get quicker :)
-	if err != nil {
-		return err
+	if mainMethod.Code == nil {
+		return fmt.Errorf("code attribute not found in %s", methodName)
 	}
 
 	// ------------------- Code Execution ---------------------
 	operandStack := make(varSlice, 0)
-	localVariable := make(varSlice, maxLocals)
+	localVariable := make(varSlice, mainMethod.Code.MaxLocals)
 	heap := make([]interface{}, 0)
 	for i, arg := range args {
 		localVariable[i] = arg
 	}
 	f := frame{
-		codeReader:    (*parse.ClassFileReader)(bytes.NewReader(code)),
+		codeReader:    (*parse.ClassFileReader)(bytes.NewReader(mainMethod.Code.Code)),
 		operandStack:  &operandStack,
 		localVariable: &localVariable,
 		file:          file,
@@ -5,7 +5,9 @@ import (
 	"errors"
 	"fmt"
 	"github.com/PPTide/gojdk/parse"
+	"github.com/PPTide/gojdk/parse/verify"
 	"io"
+	"io/fs"
 	"reflect"
 	"strconv"
 	"strings"
@@ -61,6 +63,14 @@ func getInstruction(instruction byte) (func(s *state, f frame) error, error) {
 			})
 			return nil
 		}, nil
+	case 8:
+		return func(s *state, f frame) error { // iconst_5
+			*f.operandStack = append(*f.operandStack, variable{
+				valType: "int",
+				val:     5,
+			})
+			return nil
+		}, nil
 	case 16:
 		return func(s *state, f frame) error { // bipush
 			b, err := f.codeReader.ReadByte()
@@ -85,18 +95,23 @@ func getInstruction(instruction byte) (func(s *state, f frame) error, error) {
 			case parse.ConstantStringInfo:
 				*f.heap = append(*f.heap, (*t.String).(parse.ConstantUtf8Info).Text)
 				*f.operandStack = append(*f.operandStack, variable{
-					valType: "reference",
-					val:     &((*f.heap)[len(*f.heap)-1]),
+					valType:       "reference",
+					referenceType: "Ljava/lang/String;",
+					val:           &((*f.heap)[len(*f.heap)-1]),
 				})
 				return nil
 			case parse.ConstantIntegerInfo:
-				*f.operandStack = append(*f.operandStack, variable{
-					valType: "int",
-					val:     t.Integer,
-				})
+				*f.operandStack = append(*f.operandStack, asIntVariable(int(int32(t.Integer))))
 				return nil
+			case parse.ConstantClassInfo:
+				name := (*t.Name).(parse.ConstantUtf8Info).Text
+				*f.operandStack = append(*f.operandStack, createAsReferenceAndAddToHeap("Ljava/lang/Class;", classObject{name: name}, f))
+				return nil
+			case parse.ConstantDynamicInfo:
+				return fmt.Errorf("ldc (18) of dynamically-computed constants (class file version 55.0) is not supported")
+			case parse.ConstantMethodHandleInfo, parse.ConstantMethodTypeInfo:
+				return fmt.Errorf("ldc (18) of method handles and method types (class file version 51.0) is not supported")
 			default:
-				_ = t
 				return fmt.Errorf("ldc (18) not implemented for %s", reflect.TypeOf(f.file.ConstantPool[idx-1]))
 			}
 		}, nil
@@ -608,21 +623,63 @@ func getInstruction(instruction byte) (func(s *state, f frame) error, error) {
 			fieldName := (*(*t.NameAndType).(parse.ConstantNameAndTypeInfo).Name).(parse.ConstantUtf8Info).Text
 			fieldDescriptor := (*(*t.NameAndType).(parse.ConstantNameAndTypeInfo).Descriptor).(parse.ConstantUtf8Info).Text
 
-			ref, err := initializeClass(className, s)
+			if className == "java/lang/System" && fieldName == "out" && fieldDescriptor == "Ljava/io/PrintStream;" {
+				*f.operandStack = append(*f.operandStack,
+					createAsReferenceAndAddToHeap("Ljava/io/PrintStream;", printStream{w: s.out}, f))
+				return nil
+			}
+
+			err = checkFieldAccess(f, index, className, fieldName, fieldDescriptor, s)
 			if err != nil {
 				return err
 			}
 
-			classContent := (*ref.expectReferenceOfType("L" + className)).(class)
-			field := classContent.vars[fieldName]
+			vars, err := staticFields(className, s)
+			if err != nil {
+				return err
+			}
+			field := vars[fieldName]
 
-			if !(field.valType == fieldDescriptor ||
-				field.referenceType == fieldDescriptor) {
+			if !hasFieldType(field, fieldDescriptor) {
 				return fmt.Errorf("fieldDescriptor doesn't match field type in getstatic")
 			}
 
 			*f.operandStack = append(*f.operandStack, field)
 
+			return nil
+		}, nil
+	case 179: // putstatic
+		return func(s *state, f frame) error {
+			index, err := f.codeReader.ReadU2()
+			if err != nil {
+				return err
+			}
+
+			val := f.file.ConstantPool[index-1]
+			t, ok := val.(parse.ConstantFieldrefInfo)
+			if !ok {
+				return fmt.Errorf("type %s not of type ConstantFieldrefInfo int putstatic", reflect.TypeOf(val))
+			}
+			className := (*(*t.Class).(parse.ConstantClassInfo).Name).(parse.ConstantUtf8Info).Text
+			fieldName := (*(*t.NameAndType).(parse.ConstantNameAndTypeInfo).Name).(parse.ConstantUtf8Info).Text
+			fieldDescriptor := (*(*t.NameAndType).(parse.ConstantNameAndTypeInfo).Descriptor).(parse.ConstantUtf8Info).Text
+
+			err = checkFieldAccess(f, index, className, fieldName, fieldDescriptor, s)
+			if err != nil {
+				return err
+			}
+
+			vars, err := staticFields(className, s)
+			if err != nil {
+				return err
+			}
+
+			value := f.operandStack.pop()
+			if !hasFieldType(value, fieldDescriptor) {
+				return fmt.Errorf("fieldDescriptor doesn't match value type in putstatic")
+			}
+			vars[fieldName] = value
+
 			return nil
 		}, nil
 	case 180: // getfield
@@ -637,12 +694,17 @@ func getInstruction(instruction byte) (func(s *state, f frame) error, error) {
 			fieldName := (*(*field.NameAndType).(parse.ConstantNameAndTypeInfo).Name).(parse.ConstantUtf8Info).Text
 			fieldDescriptor := (*(*field.NameAndType).(parse.ConstantNameAndTypeInfo).Descriptor).(parse.ConstantUtf8Info).Text
 
-			objectref := f.operandStack.pop().expectReferenceOfType("L" + fieldClassName)
+			err = checkFieldAccess(f, index, fieldClassName, fieldName, fieldDescriptor, s)
+			if err != nil {
+				return err
+			}
+
+			objectref := f.operandStack.pop().expectReferenceOfType(parse.ClassType(fieldClassName).String())
 			resolvedObjectref := (*objectref).(class)
 
 			value := resolvedObjectref.vars[fieldName]
 
-			if fieldDescriptor != value.referenceType { // or valType but different xD maybe "L"+referenceType?
+			if fieldDescriptor != value.referenceType {
 				return fmt.Errorf("field and value types don't match: %s != %s", fieldDescriptor, value.referenceType)
 			}
 
@@ -663,11 +725,16 @@ func getInstruction(instruction byte) (func(s *state, f frame) error, error) {
 			fieldName := (*(*field.NameAndType).(parse.ConstantNameAndTypeInfo).Name).(parse.ConstantUtf8Info).Text
 			fieldDescriptor := (*(*field.NameAndType).(parse.ConstantNameAndTypeInfo).Descriptor).(parse.ConstantUtf8Info).Text
 
+			err = checkFieldAccess(f, index, fieldClassName, fieldName, fieldDescriptor, s)
+			if err != nil {
+				return err
+			}
+
 			value := f.operandStack.pop()
-			objectref := f.operandStack.pop().expectReferenceOfType("L" + fieldClassName)
+			objectref := f.operandStack.pop().expectReferenceOfType(parse.ClassType(fieldClassName).String())
 			resolvedObjectref := (*objectref).(class)
 
-			if fieldDescriptor != value.referenceType { // or valType but different xD maybe "L"+referenceType?
+			if fieldDescriptor != value.referenceType {
 				return fmt.Errorf("field and value types don't match: %s != %s", fieldDescriptor, value.referenceType)
 			}
 
@@ -687,6 +754,12 @@ func getInstruction(instruction byte) (func(s *state, f frame) error, error) {
 
 			methodClass := (*method.Class).(parse.ConstantClassInfo)
 			className := (*methodClass.Name).(parse.ConstantUtf8Info).Text
+			if className == "java/lang/Class" {
+				return invokeClassMethod(method, s, f)
+			}
+			if className == "java/io/PrintStream" {
+				return invokePrintStreamMethod(method, f)
+			}
 			currentClass := f.file.ConstantPool[f.file.ThisClass-1].(parse.ConstantClassInfo)
 			currentClassName := (*currentClass.Name).(parse.ConstantUtf8Info).Text
 			if className != currentClassName {
@@ -700,7 +773,7 @@ func getInstruction(instruction byte) (func(s *state, f frame) error, error) {
 					}
 				}
 				// File is not loaded yet
-				file, err := parse.Parse(className + ".class")
+				file, err := loadClass(className, s)
 				if err != nil {
 					return err
 				}
@@ -713,16 +786,19 @@ func getInstruction(instruction byte) (func(s *state, f frame) error, error) {
 			name := (*methodNameAndType.Name).(parse.ConstantUtf8Info).Text
 			descriptor := (*methodNameAndType.Descriptor).(parse.ConstantUtf8Info).Text
 
-			des, err := parseDescriptor(descriptor)
+			des, err := parse.ParseMethodDescriptor(descriptor)
+			if err != nil {
+				return err
+			}
 			args := make([]variable, 0)
-			pTypes := des.parameterTypes
+			pTypes := des.Parameters
 			for i, j := 0, len(pTypes)-1; i < j; i, j = i+1, j-1 {
 				pTypes[i], pTypes[j] = pTypes[j], pTypes[i]
 			}
-			for _, parameterType := range des.parameterTypes {
+			for _, parameterType := range des.Parameters {
 				var arg variable
 				lastVal := f.operandStack.pop()
-				switch parameterType {
+				switch parameterType.String() {
 				case "I":
 					_ = lastVal.expectType("int")
 					arg = lastVal
@@ -731,8 +807,8 @@ func getInstruction(instruction byte) (func(s *state, f frame) error, error) {
 						panic("Expected val of int or boolean but got " + lastVal.valType)
 					}
 					arg = lastVal
-				case "Ljava/lang/String":
-					_ = lastVal.expectReferenceOfType("Ljava/lang/String")
+				case "Ljava/lang/String;":
+					_ = lastVal.expectReferenceOfType("Ljava/lang/String;")
 					arg = lastVal
 				case "[C":
 					_ = lastVal.expectReferenceOfType("[C")
@@ -754,7 +830,7 @@ func getInstruction(instruction byte) (func(s *state, f frame) error, error) {
 
 			name = className + "." + name
 
-			err = getClassFile(className, s)
+			_, err = getClassFile(className, s)
 			if err != nil {
 				return err
 			}
@@ -799,7 +875,7 @@ func getInstruction(instruction byte) (func(s *state, f frame) error, error) {
 			className := (*methodClass.Name).(parse.ConstantUtf8Info).Text
 			currentClassName := (*currentClass.Name).(parse.ConstantUtf8Info).Text
 			if className != currentClassName {
-				err := getClassFile(className, s)
+				_, err := getClassFile(className, s)
 				if err != nil {
 					return err
 				}
@@ -809,22 +885,25 @@ func getInstruction(instruction byte) (func(s *state, f frame) error, error) {
 			name := (*methodNameAndType.Name).(parse.ConstantUtf8Info).Text
 			descriptor := (*methodNameAndType.Descriptor).(parse.ConstantUtf8Info).Text
 
-			des, err := parseDescriptor(descriptor)
+			des, err := parse.ParseMethodDescriptor(descriptor)
+			if err != nil {
+				return err
+			}
 			args := make([]variable, 0)
-			pTypes := des.parameterTypes
+			pTypes := des.Parameters
 			for i, j := 0, len(pTypes)-1; i < j; i, j = i+1, j-1 {
 				pTypes[i], pTypes[j] = pTypes[j], pTypes[i]
 			}
-			for _, parameterType := range des.parameterTypes {
+			for _, parameterType := range des.Parameters {
 				var arg variable
 				lastVal := (*f.operandStack)[len(*f.operandStack)-1]
 				*f.operandStack = (*f.operandStack)[:len(*f.operandStack)-1]
-				switch parameterType {
+				switch parameterType.String() {
 				case "I":
 					_ = lastVal.expectType("int")
 					arg = lastVal
-				case "Ljava/lang/String":
-					_ = lastVal.expectReferenceOfType("Ljava/lang/String")
+				case "Ljava/lang/String;":
+					_ = lastVal.expectReferenceOfType("Ljava/lang/String;")
 					arg = lastVal
 				case "[C":
 					_ = lastVal.expectReferenceOfType("[C")
@@ -918,7 +997,8 @@ func getInstruction(instruction byte) (func(s *state, f frame) error, error) {
 			bootstrapMethod := bootstrapMethods[DynamicInfo.BootstrapMethodAttrIndex]
 
 			if (*(*DynamicInfo.NameAndType).(parse.ConstantNameAndTypeInfo).Name).(parse.ConstantUtf8Info).Text != "makeConcatWithConstants" {
-				return fmt.Errorf(`unknown Dynamic funtion "%s"`, (*(*DynamicInfo.NameAndType).(parse.ConstantNameAndTypeInfo).Name).(parse.ConstantUtf8Info).Text)
+				// e.g. lambdas (LambdaMetafactory) or the generated methods of records (ObjectMethods)
+				return fmt.Errorf(`invokedynamic call site "%s" is not supported, only string concatenation is`, (*(*DynamicInfo.NameAndType).(parse.ConstantNameAndTypeInfo).Name).(parse.ConstantUtf8Info).Text)
 			}
 
 			// FIXME: expects only makeConcatWithConstants
@@ -928,7 +1008,7 @@ func getInstruction(instruction byte) (func(s *state, f frame) error, error) {
 			string2Index := bootstrapMethod.bootstrapArguments[0]
 			string2 := (*f.file.ConstantPool[string2Index-1].(parse.ConstantStringInfo).String).(parse.ConstantUtf8Info).Text
 
-			*f.operandStack = append(*f.operandStack, createAsReferenceAndAddToHeap("Ljava/lang/String", string2+lastVal, f))
+			*f.operandStack = append(*f.operandStack, createAsReferenceAndAddToHeap("Ljava/lang/String;", string2+lastVal, f))
 
 			return nil
 		}, nil
@@ -969,6 +1049,20 @@ func getInstruction(instruction byte) (func(s *state, f frame) error, error) {
 				return fmt.Errorf("unkown atype %d in new array", atype)
 			}
 
+			return nil
+		}, nil
+	case 190: // arraylength
+		return func(s *state, f frame) error {
+			arrayref := f.operandStack.pop()
+			if arrayref.reference == nil {
+				return fmt.Errorf("NullPointerException: arraylength of null")
+			}
+			if !strings.HasPrefix(arrayref.referenceType, "[") {
+				return fmt.Errorf("arraylength of %s which isn't an array", arrayref.referenceType)
+			}
+
+			*f.operandStack = append(*f.operandStack, asIntVariable(reflect.ValueOf(*arrayref.reference).Len()))
+
 			return nil
 		}, nil
 	case 194: // monitorenter
@@ -979,120 +1073,376 @@ func getInstruction(instruction byte) (func(s *state, f frame) error, error) {
 
 			return nil
 		}, nil
+	case 200: // goto_w
+		return func(s *state, f frame) error {
+			branchOffsetTmp, err := f.codeReader.ReadU4()
+			if err != nil {
+				return err
+			}
+
+			branchOffset := int32(branchOffsetTmp)
+
+			branchOffset = branchOffset - 5 // get the offset without the branch bytes
+
+			_, err = f.codeReader.Seek(int64(branchOffset), io.SeekCurrent)
+			return err
+		}, nil
 	}
 	return nil, fmt.Errorf(`unknown instruction "%d"`, instruction)
 }
 
-type descriptor struct {
-	parameterTypes []string
-	returnType     string
+// initializeClass initializes the class name if that didn't happen yet and creates a local representation of a new
+// instance of it
+func initializeClass(name string, s *state) (variable, error) {
+	_, err := staticFields(name, s)
+	if err != nil {
+		return variable{}, err
+	}
+
+	heap := make([]interface{}, 0) // FIXME: the heap should be in state anyway lol
+	f := frame{heap: &heap}
+
+	ref := createAsReferenceAndAddToHeap(parse.ClassType(name).String(), class{
+		name: parse.ClassType(name).String(),
+		vars: make(map[string]variable),
+	}, f)
+
+	return ref, nil
 }
 
-func parseDescriptor(descriptorString string) (des descriptor, err error) {
-	r := strings.NewReader(descriptorString)
-	if c, _, err := r.ReadRune(); c != '(' {
-		if err != nil {
-			return des, err
+// staticFields returns the static fields of the class name, the first call initializes the class (JVMS §5.5)
+//
+// The fields are stored before <clinit> runs, so the getstatic and putstatic instructions of <clinit> see them.
+func staticFields(name string, s *state) (map[string]variable, error) {
+	if vars, ok := s.statics[name]; ok {
+		return vars, nil
+	}
+
+	file, err := getClassFile(name, s)
+	if err != nil {
+		return nil, err
+	}
+
+	heap := make([]interface{}, 0) // FIXME: the heap should be in state anyway lol
+	vars := make(map[string]variable)
+	err = prepareClass(file, vars, frame{heap: &heap})
+	if err != nil {
+		return nil, err
+	}
+	s.statics[name] = vars
+
+	// a class whose static fields are all constants has no <clinit>
+	for _, method := range file.Methods {
+		if file.ConstantPool[method.NameIndex-1].(parse.ConstantUtf8Info).Text == "<clinit>" {
+			return vars, runMethod(name+".<clinit>", "()V", s, []variable{})
 		}
-		err = fmt.Errorf("error parsing first letter of descriptor")
-		return des, err
 	}
 
-	for {
-		c, err := parseType(r)
-		if err != nil {
-			return des, err
+	return vars, nil
+}
+
+// prepareClass initializes the static final fields with a ConstantValue attribute (JVMS §5.4.2)
+func prepareClass(file parse.ClassFile, vars map[string]variable, f frame) error {
+	for _, field := range file.Fields {
+		if !field.AccessFlags.IsStatic() || !field.AccessFlags.IsFinal() || field.ConstantValue == nil {
+			continue
 		}
-		if c == ")" {
-			break
+
+		switch t := field.ConstantValue.(type) {
+		case parse.ConstantIntegerInfo:
+			vars[field.Name] = asIntVariable(int(int32(t.Integer)))
+		case parse.ConstantLongInfo:
+			vars[field.Name] = variable{valType: "long", val: t.Long}
+		case parse.ConstantFloatInfo:
+			vars[field.Name] = variable{valType: "float", val: t.Float}
+		case parse.ConstantDoubleInfo:
+			vars[field.Name] = variable{valType: "double", val: t.Double}
+		case parse.ConstantStringInfo:
+			// typed like the strings ldc pushes, so getstatic and hasFieldType see the same value either way
+			if field.Descriptor != "Ljava/lang/String;" {
+				return fmt.Errorf("String ConstantValue for field %s of type %s", field.Name, field.Descriptor)
+			}
+			*f.heap = append(*f.heap, (*t.String).(parse.ConstantUtf8Info).Text)
+			vars[field.Name] = variable{
+				valType:       "reference",
+				val:           &((*f.heap)[len(*f.heap)-1]),
+				referenceType: "Ljava/lang/String;",
+			}
+		default:
+			return fmt.Errorf("unsupported ConstantValue %s for field %s", reflect.TypeOf(t), field.Name)
 		}
+	}
+
+	return nil
+}
 
-		des.parameterTypes = append(des.parameterTypes, c)
+// hasFieldType reports if v can be the value of a field with the descriptor fieldDescriptor
+func hasFieldType(v variable, fieldDescriptor string) bool {
+	switch fieldDescriptor {
+	case "B", "C", "I", "S", "Z":
+		return v.valType == "int" || v.valType == "char" || v.valType == "boolean"
+	case "J":
+		return v.valType == "long"
+	case "F":
+		return v.valType == "float"
+	case "D":
+		return v.valType == "double"
 	}
+	return v.valType == fieldDescriptor || v.referenceType == fieldDescriptor
+}
+
+// getClassFile looks if the class got parsed and added to the list and adds and parses it if it didn't
+func getClassFile(className string, s *state) (parse.ClassFile, error) {
+	for _, f := range s.files {
+		fileClass := f.ConstantPool[f.ThisClass-1].(parse.ConstantClassInfo)
+		fileClassName := (*fileClass.Name).(parse.ConstantUtf8Info).Text
 
-	c, err := parseType(r)
+		if fileClassName == className {
+			return f, nil
+		}
+	}
+	// File is not loaded yet
+	file, err := loadClass(className, s)
 	if err != nil {
-		return des, err
+		return file, err
 	}
+	s.files = append(s.files, file)
 
-	des.returnType = c
+	err = checkPermittedSubclass(file, s)
+	if err != nil {
+		return file, err
+	}
 
-	return
+	return file, nil
 }
 
-func parseType(r *strings.Reader) (string, error) {
-	c, _, err := r.ReadRune()
-	if err != nil {
-		return "", err
-	}
+// classFileName returns the name of the class file defines
+func classFileName(file parse.ClassFile) string {
+	return (*file.ConstantPool[file.ThisClass-1].(parse.ConstantClassInfo).Name).(parse.ConstantUtf8Info).Text
+}
 
-	if c == 'L' {
-		out := []rune{c}
-		for {
-			c, _, err := r.ReadRune()
-			if c == ';' {
-				break
-			}
-			if err != nil {
-				return "", err
-			}
+// checkPermittedSubclass makes sure that every sealed direct superclass and superinterface of file permits it (JVMS §5.3.5)
+func checkPermittedSubclass(file parse.ClassFile, s *state) error {
+	supers := append([]int{}, file.Interfaces...)
+	if file.SuperClass != 0 {
+		supers = append(supers, file.SuperClass)
+	}
 
-			out = append(out, c)
+	name := classFileName(file)
+	for _, index := range supers {
+		superName := (*file.ConstantPool[index-1].(parse.ConstantClassInfo).Name).(parse.ConstantUtf8Info).Text
+		superFile, err := getClassFile(superName, s)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue // classes of the standard library aren't available as class files
 		}
-		return string(out), nil
-	}
-	if c == '[' {
-		c2, err := parseType(r)
 		if err != nil {
-			return "", err
+			return err
+		}
+		if superFile.PermittedSubclasses == nil {
+			continue
 		}
 
-		return "[" + c2, nil
+		permitted := false
+		for _, subclass := range superFile.PermittedSubclasses {
+			permitted = permitted || subclass == name
+		}
+		if !permitted {
+			return fmt.Errorf("IncompatibleClassChangeError: %s is not a permitted subclass of the sealed class %s", name, superName)
+		}
 	}
 
-	return string([]rune{c}), nil
+	return nil
 }
 
-// initializeClass takes the name of a class creates a local representation and runs the <init> method
-func initializeClass(name string, s *state) (variable, error) {
-	heap := make([]interface{}, 0) // FIXME: the heap should be in state anyway lol
-	f := frame{heap: &heap}
-
-	ref := createAsReferenceAndAddToHeap("L"+name, class{
-		name: "L" + name,
-		vars: make(map[string]variable),
-	}, f)
+// nestHost returns the name of the nest host of file (JVMS §5.4.4)
+//
+// A class whose host can't be loaded or doesn't list it as a nest member is the host of its own nest.
+func nestHost(file parse.ClassFile, s *state) string {
+	name := classFileName(file)
+	if file.NestHost == "" {
+		return name
+	}
 
-	err := getClassFile(name, s)
+	host, err := getClassFile(file.NestHost, s)
 	if err != nil {
-		return variable{}, err
+		return name
 	}
+	for _, member := range host.NestMembers {
+		if member == name {
+			return file.NestHost
+		}
+	}
+	return name
+}
 
-	//err = runMethod(name+".<clinit>", "()V", s, []variable{ref})
-	err = runMethod(name+".<clinit>", "()V", s, []variable{})
-
-	return ref, err
+// checkPrivateAccess makes sure that code in caller may access the private member called name of target
+func checkPrivateAccess(caller parse.ClassFile, target parse.ClassFile, name string, s *state) error {
+	if classFileName(caller) == classFileName(target) || nestHost(caller, s) == nestHost(target, s) {
+		return nil
+	}
+	return fmt.Errorf("IllegalAccessError: %s can't access the private member %s of %s",
+		classFileName(caller), name, classFileName(target))
 }
 
-// getClassFile looks if the class got parsed and added to the list and adds and parses it if it didn't
-func getClassFile(className string, s *state) error {
-	var file parse.ClassFile
+// invokeClassMethod runs the method of java.lang.Class that invokevirtual calls, there's no class file for it
+func invokeClassMethod(method parse.ConstantMethodrefInfo, s *state, f frame) error {
+	methodNameAndType := (*method.NameAndType).(parse.ConstantNameAndTypeInfo)
+	name := (*methodNameAndType.Name).(parse.ConstantUtf8Info).Text
+	descriptor := (*methodNameAndType.Descriptor).(parse.ConstantUtf8Info).Text
 
-	for _, f := range s.files {
-		fileClass := f.ConstantPool[f.ThisClass-1].(parse.ConstantClassInfo)
-		fileClassName := (*fileClass.Name).(parse.ConstantUtf8Info).Text
-
-		if fileClassName == className {
-			file = f
+	switch name + descriptor {
+	case "getRecordComponents()[Ljava/lang/reflect/RecordComponent;":
+		receiver := (*f.operandStack.pop().expectReferenceOfType("Ljava/lang/Class;")).(classObject)
+		components, err := getRecordComponents(receiver.name, s)
+		if err != nil {
+			return err
+		}
+		if components == nil {
+			*f.operandStack = append(*f.operandStack, variable{valType: "reference"}) // null
 			return nil
 		}
+		*f.operandStack = append(*f.operandStack,
+			createAsReferenceAndAddToHeap("[Ljava/lang/reflect/RecordComponent;", components, f))
+		return nil
 	}
-	// File is not loaded yet
-	file, err := parse.Parse(className + ".class")
+	return fmt.Errorf("java/lang/Class.%s%s is not supported", name, descriptor)
+}
+
+// invokePrintStreamMethod runs the print and println methods of the java.io.PrintStream of System.out, whose class
+// file needs native methods that aren't supported
+func invokePrintStreamMethod(method parse.ConstantMethodrefInfo, f frame) error {
+	methodNameAndType := (*method.NameAndType).(parse.ConstantNameAndTypeInfo)
+	name := (*methodNameAndType.Name).(parse.ConstantUtf8Info).Text
+	descriptor := (*methodNameAndType.Descriptor).(parse.ConstantUtf8Info).Text
+	if name != "print" && name != "println" {
+		return fmt.Errorf("java/io/PrintStream.%s%s is not supported", name, descriptor)
+	}
+
+	var text string
+	switch descriptor {
+	case "()V":
+		if name == "print" {
+			return fmt.Errorf("java/io/PrintStream.print()V doesn't exist")
+		}
+	case "(I)V":
+		text = strconv.Itoa(f.operandStack.pop().expectType("int").(int))
+	case "(J)V":
+		text = strconv.FormatInt(f.operandStack.pop().expectType("long").(int64), 10)
+	case "(Z)V":
+		switch value := f.operandStack.pop().val.(type) {
+		case bool:
+			text = strconv.FormatBool(value)
+		case int:
+			text = strconv.FormatBool(value != 0)
+		}
+	case "(C)V":
+		switch value := f.operandStack.pop().val.(type) {
+		case rune:
+			text = string(value)
+		case int:
+			text = string(rune(value))
+		}
+	case "(Ljava/lang/String;)V":
+		value := f.operandStack.pop()
+		text = "null"
+		if reference, ok := value.val.(*interface{}); ok && reference != nil {
+			text = (*reference).(string)
+		}
+	default:
+		return fmt.Errorf("java/io/PrintStream.%s%s is not supported", name, descriptor)
+	}
+	if name == "println" {
+		text += "\n"
+	}
+
+	stream := (*f.operandStack.pop().expectReferenceOfType("Ljava/io/PrintStream;")).(printStream)
+	_, err := io.WriteString(stream.w, text)
+	return err
+}
+
+// getRecordComponents returns the components of the class className in the order they are declared like
+// Class.getRecordComponents does, nil if the class isn't a record: a final subclass of java/lang/Record with a
+// Record attribute
+func getRecordComponents(className string, s *state) ([]recordComponent, error) {
+	file, err := getClassFile(className, s)
 	if err != nil {
+		return nil, err
+	}
+	if file.Record == nil || !file.AccessFlags.IsFinal() || file.SuperClass == 0 ||
+		(*file.ConstantPool[file.SuperClass-1].(parse.ConstantClassInfo).Name).(parse.ConstantUtf8Info).Text != "java/lang/Record" {
+		return nil, nil
+	}
+
+	components := make([]recordComponent, 0, len(file.Record))
+	for _, info := range file.Record {
+		component := recordComponent{
+			name:       info.Name,
+			descriptor: info.Descriptor,
+			accessor:   info.Name,
+		}
+		if info.Signature != nil {
+			component.signature = info.Signature.String()
+		}
+		components = append(components, component)
+	}
+	return components, nil
+}
+
+// checkFieldAccess makes sure that code in f may access the field fieldName of className, which the Fieldref at index
+// in the constant pool of f refers to
+//
+// The decision is made once for each Fieldref, later executions get the same result (JVMS §5.4.3).
+func checkFieldAccess(f frame, index int, className string, fieldName string, fieldDescriptor string, s *state) error {
+	ref := fieldRef{class: classFileName(f.file), index: index}
+	if err, ok := s.fieldAccess[ref]; ok {
 		return err
 	}
-	s.files = append(s.files, file)
 
+	err := resolveFieldAccess(f, className, fieldName, fieldDescriptor, s)
+	s.fieldAccess[ref] = err
+	return err
+}
+
+// resolveFieldAccess loads className and checks the access to its field fieldName from code in f
+func resolveFieldAccess(f frame, className string, fieldName string, fieldDescriptor string, s *state) error {
+	file, err := getClassFile(className, s)
+	if err != nil {
+		return err
+	}
+	for _, info := range file.Fields {
+		if info.Name == fieldName && info.Descriptor == fieldDescriptor && info.AccessFlags.IsPrivate() {
+			return checkPrivateAccess(f.file, file, fieldName, s)
+		}
+	}
 	return nil
 }
+
+// loadClass parses the class file of className and verifies its methods before they can be run
+//
+// Like HotSpot does by default the classes of the standard library are trusted and not verified.
+func loadClass(className string, s *state) (parse.ClassFile, error) {
+	file, err := parse.Parse(className + ".class")
+	if err != nil || s.verifier == nil || isStandardLibraryClass(className) {
+		return file, err
+	}
+
+	return file, s.verifier.Class(file)
+}
+
+// newVerifier returns a verifier that loads the classes it needs to check the class hierarchy from the class files
+// in the working directory, without verifying them.
+func newVerifier() *verify.Verifier {
+	return &verify.Verifier{Load: func(name string) (parse.ClassFile, error) {
+		return parse.Parse(name + ".class")
+	}}
+}
+
+// isStandardLibraryClass reports if className belongs to the Java standard library
+func isStandardLibraryClass(className string) bool {
+	for _, prefix := range []string{"java/", "javax/", "jdk/", "sun/"} {
+		if strings.HasPrefix(className, prefix) {
+			return true
+		}
+	}
+	return false
+}
@@ -1,35 +1,63 @@
 package main
 
 import (
-	"errors"
+	"flag"
 	"fmt"
 	"github.com/PPTide/gojdk/parse"
+	"github.com/PPTide/gojdk/parse/jasmin"
+	"github.com/PPTide/gojdk/parse/verify"
 	"log"
-	"os/exec"
+	"os"
+	"path/filepath"
 )
 
+// main runs the class given as argument. Jasmin sources (.j) are assembled and class files are run directly, without
+// needing a JDK. Java sources have to be compiled with javac first.
+//
+// The bytecode of the classes is verified before it's run, unless -noverify is given.
 func main() {
 	//defer profile.Start().Stop()
-	cmd := exec.Command("/Library/Java/JavaVirtualMachines/zulu-8.jdk/Contents/Home/bin/javac", `main.java`)
-	if err := cmd.Run(); err != nil {
-		out, _ := cmd.CombinedOutput()
-		log.Fatal(errors.Join(err, errors.New(string(out))))
+	noVerify := flag.Bool("noverify", false, "don't verify the bytecode of the classes before running them")
+	flag.Parse()
+	if flag.NArg() != 1 {
+		log.Fatal("usage: gojdk [-noverify] File.j|File.class")
 	}
+	name := flag.Arg(0)
 
-	res, err := parse.Parse("Main.class")
+	res, err := load(name)
 	//pp.Println(res)
 	if err != nil {
 		//niceShow(res)
 		panic(fmt.Sprintf("Error: %+v", err))
 	}
 
-	if res.MajorVersion > 52 {
-		panic("This version of the interpreter only recognizes class files until version 52.0")
-	}
 	//niceShow(res)
 
-	err = execute(res)
+	var verifier *verify.Verifier
+	if !*noVerify {
+		verifier = newVerifier()
+		err = verifier.Class(res)
+		if err != nil {
+			panic(err)
+		}
+	}
+
+	err = execute(res, verifier, os.Stdout)
 	if err != nil {
 		panic(err)
 	}
 }
+
+func load(name string) (parse.ClassFile, error) {
+	switch filepath.Ext(name) {
+	case ".j":
+		b, err := jasmin.AssembleFile(name)
+		if err != nil {
+			return parse.ClassFile{}, err
+		}
+		return parse.ParseBytes(b)
+	case ".class":
+		return parse.Parse(name)
+	}
+	return parse.ClassFile{}, fmt.Errorf("can't run %s, expected a Jasmin source (.j) or a class file (.class)", name)
+}
@@ -0,0 +1,335 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/PPTide/gojdk/parse"
+	"github.com/PPTide/gojdk/parse/jasmin"
+)
+
+// runJasmin assembles sources into class files in a temporary working directory, where the interpreter loads classes
+// from, and runs the main method of the first one with verification. It returns what the program printed.
+func runJasmin(t *testing.T, sources ...string) (string, error) {
+	t.Helper()
+	dir := t.TempDir()
+	var classes []parse.ClassFile
+	for _, src := range sources {
+		content, err := jasmin.Assemble([]byte(src))
+		if err != nil {
+			t.Fatalf("assembling failed: %v", err)
+		}
+		class, err := parse.ParseBytes(content)
+		if err != nil {
+			t.Fatalf("parsing the assembled class failed: %v", err)
+		}
+		name := filepath.Join(dir, filepath.FromSlash(classFileName(class))+".class")
+		if err := os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(name, content, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		classes = append(classes, class)
+	}
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := os.Chdir(wd); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	verifier := newVerifier()
+	if err := verifier.Class(classes[0]); err != nil {
+		return "", err
+	}
+	var out bytes.Buffer
+	err = execute(classes[0], verifier, &out)
+	return out.String(), err
+}
+
+func TestMainJ(t *testing.T) {
+	res, err := load("main.j")
+	if err != nil {
+		t.Fatalf("loading main.j failed: %v", err)
+	}
+	var out bytes.Buffer
+	if err := execute(res, newVerifier(), &out); err != nil {
+		t.Fatalf("running main.j failed: %v", err)
+	}
+	if out.String() != "2\n" {
+		t.Errorf("main.j printed %q, want \"2\\n\"", out.String())
+	}
+}
+
+func TestExecute(t *testing.T) {
+	tests := []struct {
+		name    string
+		sources []string
+		want    string
+	}{
+		{"print constants", []string{`
+.class Main
+.method public static main([Ljava/lang/String;)V
+    getstatic java/lang/System/out Ljava/io/PrintStream;
+    ldc "Hello, World!"
+    invokevirtual java/io/PrintStream/println(Ljava/lang/String;)V
+    getstatic java/lang/System/out Ljava/io/PrintStream;
+    ldc 100000
+    invokevirtual java/io/PrintStream/print(I)V
+    getstatic java/lang/System/out Ljava/io/PrintStream;
+    ldc -7
+    invokevirtual java/io/PrintStream/println(I)V
+    getstatic java/lang/System/out Ljava/io/PrintStream;
+    invokevirtual java/io/PrintStream/println()V
+    return
+.end method
+`}, "Hello, World!\n100000-7\n\n"},
+		{"loop", []string{`
+.class Main
+; prints the sum of 1 to 10
+.method public static main([Ljava/lang/String;)V
+    iconst_0
+    istore_1
+    iconst_1
+    istore_2
+Loop:
+    iload_2
+    bipush 10
+    if_icmpgt Done
+    iload_1
+    iload_2
+    iadd
+    istore_1
+    iinc 2 1
+    goto Loop
+Done:
+    getstatic java/lang/System/out Ljava/io/PrintStream;
+    iload_1
+    invokevirtual java/io/PrintStream/println(I)V
+    return
+.end method
+`}, "55\n"},
+		{"static methods of another class", []string{`
+.class Main
+.method public static main([Ljava/lang/String;)V
+    getstatic java/lang/System/out Ljava/io/PrintStream;
+    iconst_3
+    invokestatic Square/square(I)I
+    invokestatic Square/times2(I)I
+    invokevirtual java/io/PrintStream/println(I)V
+    return
+.end method
+`, `
+.class Square
+.method static square(I)I
+    iload_0
+    iload_0
+    imul
+    ireturn
+.end method
+
+.method static times2(I)I
+    iload_0
+    iconst_2
+    imul
+    ireturn
+.end method
+`}, "18\n"},
+		{"static fields", []string{`
+.class Main
+.field static count I
+.field static final GREETING Ljava/lang/String; = "hi"
+
+.method static <clinit>()V
+    iconst_5
+    putstatic Main/count I
+    return
+.end method
+
+.method public static main([Ljava/lang/String;)V
+    getstatic Main/count I
+    iconst_1
+    isub
+    putstatic Main/count I
+    getstatic java/lang/System/out Ljava/io/PrintStream;
+    getstatic Main/GREETING Ljava/lang/String;
+    invokevirtual java/io/PrintStream/print(Ljava/lang/String;)V
+    getstatic java/lang/System/out Ljava/io/PrintStream;
+    getstatic Main/count I
+    invokevirtual java/io/PrintStream/println(I)V
+    return
+.end method
+`}, "hi4\n"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := runJasmin(t, test.sources...)
+			if err != nil {
+				t.Fatalf("running failed: %v", err)
+			}
+			if got != test.want {
+				t.Errorf("printed %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestExecuteErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		sources []string
+		reason  string
+	}{
+		{"no main method", []string{`
+.class Main
+.method static run()V
+    return
+.end method
+`}, "no main method found"},
+		{"missing method", []string{`
+.class Main
+.method public static main([Ljava/lang/String;)V
+    invokestatic Other/missing()V
+    return
+.end method
+`, `
+.class Other
+`}, `method "missing" not found`},
+		{"missing class", []string{`
+.class Main
+.method public static main([Ljava/lang/String;)V
+    invokestatic Other/run()V
+    return
+.end method
+`}, "Other.class"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := runJasmin(t, test.sources...)
+			if err == nil || !strings.Contains(err.Error(), test.reason) {
+				t.Errorf("got error %v, want one containing %q", err, test.reason)
+			}
+		})
+	}
+}
+
+func TestNestmateAndSealedAccess(t *testing.T) {
+	// Main reads the private field of Main$Secret, whose nest host is Main
+	readSecret := `
+.bytecode 61.0
+.class Main
+%s
+.method public static main([Ljava/lang/String;)V
+    getstatic java/lang/System/out Ljava/io/PrintStream;
+    getstatic Main$Secret/value I
+    invokevirtual java/io/PrintStream/println(I)V
+    return
+.end method
+`
+	secret := `
+.bytecode 61.0
+.class Main$Secret
+.nesthost Main
+.field private static final value I = 42
+`
+	// Main calls the static method area of a subclass of Shape, which only permits Circle
+	callShape := `
+.bytecode 61.0
+.class Main
+.method public static main([Ljava/lang/String;)V
+    getstatic java/lang/System/out Ljava/io/PrintStream;
+    invokestatic %s/area()I
+    invokevirtual java/io/PrintStream/println(I)V
+    return
+.end method
+`
+	shape := `
+.bytecode 61.0
+.class abstract Shape
+.permittedsubclasses Circle
+`
+	subclass := `
+.bytecode 61.0
+.class final %s
+.super Shape
+.method public static area()I
+    iconst_3
+    ireturn
+.end method
+`
+
+	tests := []struct {
+		name    string
+		sources []string
+		want    string // printed output, "" if running fails
+		reason  string
+	}{
+		{"nestmate", []string{fmt.Sprintf(readSecret, ".nestmembers Main$Secret"), secret}, "42\n", ""},
+		{"host doesn't list the member", []string{fmt.Sprintf(readSecret, ""), secret}, "",
+			"IllegalAccessError: Main can't access the private member value of Main$Secret"},
+		{"permitted subclass", []string{fmt.Sprintf(callShape, "Circle"), shape, fmt.Sprintf(subclass, "Circle")},
+			"3\n", ""},
+		{"subclass isn't permitted", []string{fmt.Sprintf(callShape, "Square"), shape, fmt.Sprintf(subclass, "Square")},
+			"", "IncompatibleClassChangeError: Square is not a permitted subclass of the sealed class Shape"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := runJasmin(t, test.sources...)
+			if test.reason == "" && err != nil {
+				t.Fatalf("running failed: %v", err)
+			}
+			if test.reason != "" && (err == nil || !strings.Contains(err.Error(), test.reason)) {
+				t.Errorf("got error %v, want one containing %q", err, test.reason)
+			}
+			if got != test.want {
+				t.Errorf("printed %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestFieldAccessCache(t *testing.T) {
+	var files []parse.ClassFile
+	for _, src := range []string{".class Main\n", ".class Other\n.field private static value I\n"} {
+		content, err := jasmin.Assemble([]byte(src))
+		if err != nil {
+			t.Fatalf("assembling failed: %v", err)
+		}
+		file, err := parse.ParseBytes(content)
+		if err != nil {
+			t.Fatalf("parsing the assembled class failed: %v", err)
+		}
+		files = append(files, file)
+	}
+	s := &state{files: files, fieldAccess: make(map[fieldRef]error)}
+	f := frame{file: files[0]}
+
+	const denied = "IllegalAccessError: Main can't access the private member value of Other"
+	if err := checkFieldAccess(f, 7, "Other", "value", "I", s); err == nil || err.Error() != denied {
+		t.Fatalf("first access returned %v, want %q", err, denied)
+	}
+
+	// Other can't be loaded again, so only the cached decision gives the same result
+	s.files = files[:1]
+	if err := checkFieldAccess(f, 7, "Other", "value", "I", s); err == nil || err.Error() != denied {
+		t.Errorf("second access returned %v, want %q", err, denied)
+	}
+	if err := checkFieldAccess(f, 8, "Other", "value", "I", s); err == nil || err.Error() == denied {
+		t.Errorf("access through another Fieldref returned %v, want an error loading Other", err)
+	}
+}
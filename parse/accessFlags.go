@@ -0,0 +1,161 @@
+package parse
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ClassAccessFlags are the access_flags of a class (JVMS §4.1, table 4.1-B).
+type ClassAccessFlags uint16
+
+const (
+	ClassAccPublic     ClassAccessFlags = 0x0001
+	ClassAccFinal      ClassAccessFlags = 0x0010
+	ClassAccSuper      ClassAccessFlags = 0x0020
+	ClassAccInterface  ClassAccessFlags = 0x0200
+	ClassAccAbstract   ClassAccessFlags = 0x0400
+	ClassAccSynthetic  ClassAccessFlags = 0x1000
+	ClassAccAnnotation ClassAccessFlags = 0x2000
+	ClassAccEnum       ClassAccessFlags = 0x4000
+	ClassAccModule     ClassAccessFlags = 0x8000
+)
+
+// FieldAccessFlags are the access_flags of a field (JVMS §4.5, table 4.5-A).
+type FieldAccessFlags uint16
+
+const (
+	FieldAccPublic    FieldAccessFlags = 0x0001
+	FieldAccPrivate   FieldAccessFlags = 0x0002
+	FieldAccProtected FieldAccessFlags = 0x0004
+	FieldAccStatic    FieldAccessFlags = 0x0008
+	FieldAccFinal     FieldAccessFlags = 0x0010
+	FieldAccVolatile  FieldAccessFlags = 0x0040
+	FieldAccTransient FieldAccessFlags = 0x0080
+	FieldAccSynthetic FieldAccessFlags = 0x1000
+	FieldAccEnum      FieldAccessFlags = 0x4000
+)
+
+// MethodAccessFlags are the access_flags of a method (JVMS §4.6, table 4.6-A).
+type MethodAccessFlags uint16
+
+const (
+	MethodAccPublic       MethodAccessFlags = 0x0001
+	MethodAccPrivate      MethodAccessFlags = 0x0002
+	MethodAccProtected    MethodAccessFlags = 0x0004
+	MethodAccStatic       MethodAccessFlags = 0x0008
+	MethodAccFinal        MethodAccessFlags = 0x0010
+	MethodAccSynchronized MethodAccessFlags = 0x0020
+	MethodAccBridge       MethodAccessFlags = 0x0040
+	MethodAccVarargs      MethodAccessFlags = 0x0080
+	MethodAccNative       MethodAccessFlags = 0x0100
+	MethodAccAbstract     MethodAccessFlags = 0x0400
+	MethodAccStrict       MethodAccessFlags = 0x0800
+	MethodAccSynthetic    MethodAccessFlags = 0x1000
+)
+
+// accessFlagName is the name of a flag in the JVMS, in the order javap prints them.
+type accessFlagName[F ~uint16] struct {
+	flag F
+	name string
+}
+
+var classAccessFlagNames = []accessFlagName[ClassAccessFlags]{{ClassAccPublic, "ACC_PUBLIC"},
+	{ClassAccFinal, "ACC_FINAL"}, {ClassAccSuper, "ACC_SUPER"}, {ClassAccInterface, "ACC_INTERFACE"},
+	{ClassAccAbstract, "ACC_ABSTRACT"}, {ClassAccSynthetic, "ACC_SYNTHETIC"}, {ClassAccAnnotation, "ACC_ANNOTATION"},
+	{ClassAccEnum, "ACC_ENUM"}, {ClassAccModule, "ACC_MODULE"}}
+
+var fieldAccessFlagNames = []accessFlagName[FieldAccessFlags]{{FieldAccPublic, "ACC_PUBLIC"},
+	{FieldAccPrivate, "ACC_PRIVATE"}, {FieldAccProtected, "ACC_PROTECTED"}, {FieldAccStatic, "ACC_STATIC"},
+	{FieldAccFinal, "ACC_FINAL"}, {FieldAccVolatile, "ACC_VOLATILE"}, {FieldAccTransient, "ACC_TRANSIENT"},
+	{FieldAccSynthetic, "ACC_SYNTHETIC"}, {FieldAccEnum, "ACC_ENUM"}}
+
+var methodAccessFlagNames = []accessFlagName[MethodAccessFlags]{{MethodAccPublic, "ACC_PUBLIC"},
+	{MethodAccPrivate, "ACC_PRIVATE"}, {MethodAccProtected, "ACC_PROTECTED"}, {MethodAccStatic, "ACC_STATIC"},
+	{MethodAccFinal, "ACC_FINAL"}, {MethodAccSynchronized, "ACC_SYNCHRONIZED"}, {MethodAccBridge, "ACC_BRIDGE"},
+	{MethodAccVarargs, "ACC_VARARGS"}, {MethodAccNative, "ACC_NATIVE"}, {MethodAccAbstract, "ACC_ABSTRACT"},
+	{MethodAccStrict, "ACC_STRICT"}, {MethodAccSynthetic, "ACC_SYNTHETIC"}}
+
+// flagNames returns the names of the flags set in flags, never nil. Unknown flags are left out.
+func flagNames[F ~uint16](flags F, names []accessFlagName[F]) []string {
+	res := make([]string, 0)
+	for _, f := range names {
+		if flags&f.flag != 0 {
+			res = append(res, f.name)
+		}
+	}
+	return res
+}
+
+// flagString formats flags like javap, e.g. "(0x0021) ACC_PUBLIC, ACC_SUPER".
+func flagString[F ~uint16](flags F, names []accessFlagName[F]) string {
+	return strings.TrimSpace(fmt.Sprintf("(0x%04x) %s", uint16(flags), strings.Join(flagNames(flags, names), ", ")))
+}
+
+// ------------------- Class flags ---------------------
+
+func (f ClassAccessFlags) IsPublic() bool     { return f&ClassAccPublic != 0 }
+func (f ClassAccessFlags) IsFinal() bool      { return f&ClassAccFinal != 0 }
+func (f ClassAccessFlags) IsSuper() bool      { return f&ClassAccSuper != 0 }
+func (f ClassAccessFlags) IsInterface() bool  { return f&ClassAccInterface != 0 }
+func (f ClassAccessFlags) IsAbstract() bool   { return f&ClassAccAbstract != 0 }
+func (f ClassAccessFlags) IsSynthetic() bool  { return f&ClassAccSynthetic != 0 }
+func (f ClassAccessFlags) IsAnnotation() bool { return f&ClassAccAnnotation != 0 }
+func (f ClassAccessFlags) IsEnum() bool       { return f&ClassAccEnum != 0 }
+func (f ClassAccessFlags) IsModule() bool     { return f&ClassAccModule != 0 }
+
+// Names returns the names of the set flags, e.g. ["ACC_PUBLIC", "ACC_SUPER"].
+func (f ClassAccessFlags) Names() []string {
+	return flagNames(f, classAccessFlagNames)
+}
+
+// String formats the flags like javap -v, e.g. "(0x0021) ACC_PUBLIC, ACC_SUPER".
+func (f ClassAccessFlags) String() string {
+	return flagString(f, classAccessFlagNames)
+}
+
+// ------------------- Field flags ---------------------
+
+func (f FieldAccessFlags) IsPublic() bool    { return f&FieldAccPublic != 0 }
+func (f FieldAccessFlags) IsPrivate() bool   { return f&FieldAccPrivate != 0 }
+func (f FieldAccessFlags) IsProtected() bool { return f&FieldAccProtected != 0 }
+func (f FieldAccessFlags) IsStatic() bool    { return f&FieldAccStatic != 0 }
+func (f FieldAccessFlags) IsFinal() bool     { return f&FieldAccFinal != 0 }
+func (f FieldAccessFlags) IsVolatile() bool  { return f&FieldAccVolatile != 0 }
+func (f FieldAccessFlags) IsTransient() bool { return f&FieldAccTransient != 0 }
+func (f FieldAccessFlags) IsSynthetic() bool { return f&FieldAccSynthetic != 0 }
+func (f FieldAccessFlags) IsEnum() bool      { return f&FieldAccEnum != 0 }
+
+// Names returns the names of the set flags, e.g. ["ACC_PRIVATE", "ACC_FINAL"].
+func (f FieldAccessFlags) Names() []string {
+	return flagNames(f, fieldAccessFlagNames)
+}
+
+// String formats the flags like javap -v, e.g. "(0x0012) ACC_PRIVATE, ACC_FINAL".
+func (f FieldAccessFlags) String() string {
+	return flagString(f, fieldAccessFlagNames)
+}
+
+// ------------------- Method flags ---------------------
+
+func (f MethodAccessFlags) IsPublic() bool       { return f&MethodAccPublic != 0 }
+func (f MethodAccessFlags) IsPrivate() bool      { return f&MethodAccPrivate != 0 }
+func (f MethodAccessFlags) IsProtected() bool    { return f&MethodAccProtected != 0 }
+func (f MethodAccessFlags) IsStatic() bool       { return f&MethodAccStatic != 0 }
+func (f MethodAccessFlags) IsFinal() bool        { return f&MethodAccFinal != 0 }
+func (f MethodAccessFlags) IsSynchronized() bool { return f&MethodAccSynchronized != 0 }
+func (f MethodAccessFlags) IsBridge() bool       { return f&MethodAccBridge != 0 }
+func (f MethodAccessFlags) IsVarargs() bool      { return f&MethodAccVarargs != 0 }
+func (f MethodAccessFlags) IsNative() bool       { return f&MethodAccNative != 0 }
+func (f MethodAccessFlags) IsAbstract() bool     { return f&MethodAccAbstract != 0 }
+func (f MethodAccessFlags) IsStrict() bool       { return f&MethodAccStrict != 0 }
+func (f MethodAccessFlags) IsSynthetic() bool    { return f&MethodAccSynthetic != 0 }
+
+// Names returns the names of the set flags, e.g. ["ACC_PUBLIC", "ACC_STATIC"].
+func (f MethodAccessFlags) Names() []string {
+	return flagNames(f, methodAccessFlagNames)
+}
+
+// String formats the flags like javap -v, e.g. "(0x0009) ACC_PUBLIC, ACC_STATIC".
+func (f MethodAccessFlags) String() string {
+	return flagString(f, methodAccessFlagNames)
+}
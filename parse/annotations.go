@@ -0,0 +1,473 @@
+package parse
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// Annotations holds the decoded annotation attributes of a class, field or method.
+type Annotations struct {
+	VisibleAnnotations       []Annotation     // decoded "RuntimeVisibleAnnotations" attribute
+	InvisibleAnnotations     []Annotation     // decoded "RuntimeInvisibleAnnotations" attribute
+	VisibleTypeAnnotations   []TypeAnnotation // decoded "RuntimeVisibleTypeAnnotations" attribute
+	InvisibleTypeAnnotations []TypeAnnotation // decoded "RuntimeInvisibleTypeAnnotations" attribute
+}
+
+// FindAnnotation returns the visible or invisible annotation with the type typeDescriptor, e.g. "Ljava/lang/Deprecated;".
+func (a Annotations) FindAnnotation(typeDescriptor string) (Annotation, bool) {
+	for _, annotation := range a.VisibleAnnotations {
+		if annotation.Type == typeDescriptor {
+			return annotation, true
+		}
+	}
+	for _, annotation := range a.InvisibleAnnotations {
+		if annotation.Type == typeDescriptor {
+			return annotation, true
+		}
+	}
+	return Annotation{}, false
+}
+
+// Annotation is one annotation (JVMS §4.7.16).
+type Annotation struct {
+	TypeIndex            int    // u2
+	Type                 string // field descriptor of the annotation interface
+	NumElementValuePairs int    // u2
+	ElementValuePairs    []ElementValuePair
+}
+
+// Element returns the value of the element called name, elements with default values are not included.
+func (a Annotation) Element(name string) (ElementValue, bool) {
+	for _, pair := range a.ElementValuePairs {
+		if pair.ElementName == name {
+			return pair.Value, true
+		}
+	}
+	return ElementValue{}, false
+}
+
+// ElementValuePair is one element of an Annotation with its value.
+type ElementValuePair struct {
+	ElementNameIndex int // u2
+	ElementName      string
+	Value            ElementValue
+}
+
+// ElementValue is the value of an annotation element (JVMS §4.7.16.1).
+//
+// Tag decides which fields are used:
+//   - B C D F I J S Z s: ConstValueIndex and Const, which is a ConstantIntegerInfo, ConstantFloatInfo,
+//     ConstantLongInfo, ConstantDoubleInfo or for s a ConstantUtf8Info
+//   - e: TypeNameIndex, TypeName, ConstNameIndex and ConstName of the enum constant
+//   - c: ClassInfoIndex and ClassInfo, the return descriptor of the class, e.g. "Ljava/lang/String;" or "V"
+//   - @: AnnotationValue
+//   - [: NumValues and Values
+type ElementValue struct {
+	Tag             byte
+	ConstValueIndex int // u2
+	Const           CpInfo
+	TypeNameIndex   int // u2
+	TypeName        string
+	ConstNameIndex  int // u2
+	ConstName       string
+	ClassInfoIndex  int // u2
+	ClassInfo       string
+	AnnotationValue *Annotation
+	NumValues       int // u2
+	Values          []ElementValue
+}
+
+// TypeAnnotation is one annotation on a use of a type (JVMS §4.7.20).
+//
+// TargetType decides which of the target fields are used:
+//   - 0x00, 0x01: TypeParameterIndex
+//   - 0x10: SupertypeIndex, 65535 for the superclass
+//   - 0x11, 0x12: TypeParameterIndex and BoundIndex
+//   - 0x13, 0x14, 0x15: none
+//   - 0x16: FormalParameterIndex
+//   - 0x17: ThrowsTypeIndex
+//   - 0x40, 0x41: LocalVariableTargets
+//   - 0x42: ExceptionTableIndex
+//   - 0x43 to 0x46: Offset
+//   - 0x47 to 0x4B: Offset and TypeArgumentIndex
+type TypeAnnotation struct {
+	TargetType           int // u1
+	TypeParameterIndex   int // u1
+	SupertypeIndex       int // u2
+	BoundIndex           int // u1
+	FormalParameterIndex int // u1
+	ThrowsTypeIndex      int // u2
+	LocalVariableTargets []LocalVariableTarget
+	ExceptionTableIndex  int // u2
+	Offset               int // u2
+	TypeArgumentIndex    int // u1
+	TypePath             []TypePathEntry
+	Annotation
+}
+
+// LocalVariableTarget is the range of code in which a local variable with an annotated type has a value.
+type LocalVariableTarget struct {
+	StartPc int // u2
+	Length  int // u2
+	Index   int // u2
+}
+
+// TypePathEntry is one step to the annotated part of a type.
+type TypePathEntry struct {
+	TypePathKind      int // u1 0: deeper in an array, 1: deeper in a nested type, 2: wildcard bound, 3: type argument
+	TypeArgumentIndex int // u1
+}
+
+// ReadAnnotations reads the content of a "RuntimeVisibleAnnotations" or "RuntimeInvisibleAnnotations" attribute.
+func (r *ClassFileReader) ReadAnnotations() (annotations []Annotation, err error) {
+	size, err := r.ReadU2()
+	if err != nil {
+		return
+	}
+
+	for i := 0; i < size; i++ {
+		var annotation Annotation
+		annotation, err = r.ReadAnnotation()
+		if err != nil {
+			return
+		}
+		annotations = append(annotations, annotation)
+	}
+
+	return
+}
+
+// ReadParameterAnnotations reads the content of a "RuntimeVisibleParameterAnnotations" or
+// "RuntimeInvisibleParameterAnnotations" attribute.
+func (r *ClassFileReader) ReadParameterAnnotations() (parameters [][]Annotation, err error) {
+	size, err := r.ReadByte()
+	if err != nil {
+		return
+	}
+
+	parameters = make([][]Annotation, size)
+	for i := range parameters {
+		parameters[i], err = r.ReadAnnotations()
+		if err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+// ReadAnnotation reads one annotation.
+func (r *ClassFileReader) ReadAnnotation() (annotation Annotation, err error) {
+	annotation.TypeIndex, err = r.ReadU2()
+	if err != nil {
+		return
+	}
+
+	annotation.NumElementValuePairs, err = r.ReadU2()
+	if err != nil {
+		return
+	}
+
+	for i := 0; i < annotation.NumElementValuePairs; i++ {
+		var pair ElementValuePair
+		pair.ElementNameIndex, err = r.ReadU2()
+		if err != nil {
+			return
+		}
+		pair.Value, err = r.ReadElementValue()
+		if err != nil {
+			return
+		}
+		annotation.ElementValuePairs = append(annotation.ElementValuePairs, pair)
+	}
+
+	return
+}
+
+// ReadElementValue reads one element_value.
+func (r *ClassFileReader) ReadElementValue() (value ElementValue, err error) {
+	value.Tag, err = r.ReadByte()
+	if err != nil {
+		return
+	}
+
+	switch value.Tag {
+	case 'B', 'C', 'D', 'F', 'I', 'J', 'S', 'Z', 's':
+		value.ConstValueIndex, err = r.ReadU2()
+	case 'e':
+		value.TypeNameIndex, err = r.ReadU2()
+		if err != nil {
+			return
+		}
+		value.ConstNameIndex, err = r.ReadU2()
+	case 'c':
+		value.ClassInfoIndex, err = r.ReadU2()
+	case '@':
+		var annotation Annotation
+		annotation, err = r.ReadAnnotation()
+		value.AnnotationValue = &annotation
+	case '[':
+		value.NumValues, err = r.ReadU2()
+		for i := 0; i < value.NumValues && err == nil; i++ {
+			var element ElementValue
+			element, err = r.ReadElementValue()
+			value.Values = append(value.Values, element)
+		}
+	default:
+		err = fmt.Errorf("unknown element value tag %q", value.Tag)
+	}
+
+	return
+}
+
+// ReadTypeAnnotations reads the content of a "RuntimeVisibleTypeAnnotations" or "RuntimeInvisibleTypeAnnotations"
+// attribute.
+func (r *ClassFileReader) ReadTypeAnnotations() (annotations []TypeAnnotation, err error) {
+	size, err := r.ReadU2()
+	if err != nil {
+		return
+	}
+
+	for i := 0; i < size; i++ {
+		var annotation TypeAnnotation
+		annotation, err = r.ReadTypeAnnotation()
+		if err != nil {
+			return
+		}
+		annotations = append(annotations, annotation)
+	}
+
+	return
+}
+
+// ReadTypeAnnotation reads one type_annotation.
+func (r *ClassFileReader) ReadTypeAnnotation() (annotation TypeAnnotation, err error) {
+	targetType, err := r.ReadByte()
+	if err != nil {
+		return
+	}
+	annotation.TargetType = int(targetType)
+
+	readU1 := func(dst *int) {
+		if err != nil {
+			return
+		}
+		var b byte
+		b, err = r.ReadByte()
+		*dst = int(b)
+	}
+	readU2 := func(dst *int) {
+		if err != nil {
+			return
+		}
+		*dst, err = r.ReadU2()
+	}
+
+	switch annotation.TargetType {
+	case 0x00, 0x01: // type_parameter_target
+		readU1(&annotation.TypeParameterIndex)
+	case 0x10: // supertype_target
+		readU2(&annotation.SupertypeIndex)
+	case 0x11, 0x12: // type_parameter_bound_target
+		readU1(&annotation.TypeParameterIndex)
+		readU1(&annotation.BoundIndex)
+	case 0x13, 0x14, 0x15: // empty_target
+	case 0x16: // formal_parameter_target
+		readU1(&annotation.FormalParameterIndex)
+	case 0x17: // throws_target
+		readU2(&annotation.ThrowsTypeIndex)
+	case 0x40, 0x41: // localvar_target
+		var length int
+		readU2(&length)
+		for i := 0; i < length && err == nil; i++ {
+			var target LocalVariableTarget
+			readU2(&target.StartPc)
+			readU2(&target.Length)
+			readU2(&target.Index)
+			annotation.LocalVariableTargets = append(annotation.LocalVariableTargets, target)
+		}
+	case 0x42: // catch_target
+		readU2(&annotation.ExceptionTableIndex)
+	case 0x43, 0x44, 0x45, 0x46: // offset_target
+		readU2(&annotation.Offset)
+	case 0x47, 0x48, 0x49, 0x4A, 0x4B: // type_argument_target
+		readU2(&annotation.Offset)
+		readU1(&annotation.TypeArgumentIndex)
+	default:
+		err = fmt.Errorf("unknown type annotation target type 0x%02x", annotation.TargetType)
+	}
+
+	var pathLength int
+	readU1(&pathLength)
+	for i := 0; i < pathLength && err == nil; i++ {
+		var entry TypePathEntry
+		readU1(&entry.TypePathKind)
+		readU1(&entry.TypeArgumentIndex)
+		annotation.TypePath = append(annotation.TypePath, entry)
+	}
+	if err != nil {
+		return
+	}
+
+	annotation.Annotation, err = r.ReadAnnotation()
+	return
+}
+
+// decodeAnnotations decodes the annotation attribute called name into into and reports if name is one.
+func (f *ClassFile) decodeAnnotations(name string, attribute AttributeInfo, into *Annotations) (bool, error) {
+	reader := (*ClassFileReader)(bytes.NewReader(attribute.Info))
+
+	var err error
+	switch name {
+	case "RuntimeVisibleAnnotations":
+		into.VisibleAnnotations, err = f.readResolvedAnnotations(reader)
+	case "RuntimeInvisibleAnnotations":
+		into.InvisibleAnnotations, err = f.readResolvedAnnotations(reader)
+	case "RuntimeVisibleTypeAnnotations":
+		into.VisibleTypeAnnotations, err = f.readResolvedTypeAnnotations(reader)
+	case "RuntimeInvisibleTypeAnnotations":
+		into.InvisibleTypeAnnotations, err = f.readResolvedTypeAnnotations(reader)
+	default:
+		return false, nil
+	}
+	if err != nil {
+		return true, fmt.Errorf("%s attribute: %w", name, err)
+	}
+	if reader.Len() != 0 {
+		return true, fmt.Errorf("couldn't fully read the %s attribute", name)
+	}
+	return true, nil
+}
+
+// decodeParameterAnnotations decodes a "RuntimeVisibleParameterAnnotations" or "RuntimeInvisibleParameterAnnotations"
+// attribute.
+func (f *ClassFile) decodeParameterAnnotations(name string, attribute AttributeInfo) ([][]Annotation, error) {
+	reader := (*ClassFileReader)(bytes.NewReader(attribute.Info))
+	parameters, err := reader.ReadParameterAnnotations()
+	if err != nil {
+		return nil, fmt.Errorf("%s attribute: %w", name, err)
+	}
+	if reader.Len() != 0 {
+		return nil, fmt.Errorf("couldn't fully read the %s attribute", name)
+	}
+	for _, annotations := range parameters {
+		for i := range annotations {
+			err = f.resolveAnnotation(&annotations[i])
+			if err != nil {
+				return nil, fmt.Errorf("%s attribute: %w", name, err)
+			}
+		}
+	}
+	return parameters, nil
+}
+
+// decodeAnnotationDefault decodes an "AnnotationDefault" attribute.
+func (f *ClassFile) decodeAnnotationDefault(attribute AttributeInfo) (*ElementValue, error) {
+	reader := (*ClassFileReader)(bytes.NewReader(attribute.Info))
+	value, err := reader.ReadElementValue()
+	if err == nil {
+		err = f.resolveElementValue(&value)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("AnnotationDefault attribute: %w", err)
+	}
+	if reader.Len() != 0 {
+		return nil, fmt.Errorf("couldn't fully read the AnnotationDefault attribute")
+	}
+	return &value, nil
+}
+
+func (f *ClassFile) readResolvedAnnotations(reader *ClassFileReader) ([]Annotation, error) {
+	annotations, err := reader.ReadAnnotations()
+	if err != nil {
+		return nil, err
+	}
+	for i := range annotations {
+		err = f.resolveAnnotation(&annotations[i])
+		if err != nil {
+			return nil, err
+		}
+	}
+	return annotations, nil
+}
+
+func (f *ClassFile) readResolvedTypeAnnotations(reader *ClassFileReader) ([]TypeAnnotation, error) {
+	annotations, err := reader.ReadTypeAnnotations()
+	if err != nil {
+		return nil, err
+	}
+	for i := range annotations {
+		err = f.resolveAnnotation(&annotations[i].Annotation)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return annotations, nil
+}
+
+// resolveAnnotation fills in the strings and constants the indexes of annotation point to.
+func (f *ClassFile) resolveAnnotation(annotation *Annotation) (err error) {
+	annotation.Type, err = f.utf8Text(annotation.TypeIndex)
+	if err != nil {
+		return
+	}
+
+	for i := range annotation.ElementValuePairs {
+		pair := &annotation.ElementValuePairs[i]
+		pair.ElementName, err = f.utf8Text(pair.ElementNameIndex)
+		if err != nil {
+			return
+		}
+		err = f.resolveElementValue(&pair.Value)
+		if err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+func (f *ClassFile) resolveElementValue(value *ElementValue) (err error) {
+	switch value.Tag {
+	case 'B', 'C', 'D', 'F', 'I', 'J', 'S', 'Z', 's':
+		if value.ConstValueIndex < 1 || value.ConstValueIndex > len(f.ConstantPool) {
+			return fmt.Errorf("constant pool index %d out of range", value.ConstValueIndex)
+		}
+		value.Const = f.ConstantPool[value.ConstValueIndex-1]
+
+		var ok bool
+		switch value.Tag {
+		case 'D':
+			_, ok = value.Const.(ConstantDoubleInfo)
+		case 'F':
+			_, ok = value.Const.(ConstantFloatInfo)
+		case 'J':
+			_, ok = value.Const.(ConstantLongInfo)
+		case 's':
+			_, ok = value.Const.(ConstantUtf8Info)
+		default:
+			_, ok = value.Const.(ConstantIntegerInfo)
+		}
+		if !ok {
+			return fmt.Errorf("constant pool entry %d doesn't match element value tag %q", value.ConstValueIndex, value.Tag)
+		}
+	case 'e':
+		value.TypeName, err = f.utf8Text(value.TypeNameIndex)
+		if err != nil {
+			return
+		}
+		value.ConstName, err = f.utf8Text(value.ConstNameIndex)
+	case 'c':
+		value.ClassInfo, err = f.utf8Text(value.ClassInfoIndex)
+	case '@':
+		err = f.resolveAnnotation(value.AnnotationValue)
+	case '[':
+		for i := range value.Values {
+			err = f.resolveElementValue(&value.Values[i])
+			if err != nil {
+				return
+			}
+		}
+	}
+	return
+}
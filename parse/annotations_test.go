@@ -0,0 +1,299 @@
+package parse
+
+import (
+	"bytes"
+	"errors"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// concat concatenates parts of attribute content.
+func concat(parts ...[]byte) []byte {
+	var b []byte
+	for _, part := range parts {
+		b = append(b, part...)
+	}
+	return b
+}
+
+// u2 returns v as a big endian u2.
+func u2(v int) []byte {
+	return []byte{byte(v >> 8), byte(v)}
+}
+
+// annotationBytes returns an annotation of the type at typeIndex with the element_value_pairs pairs.
+func annotationBytes(typeIndex int, pairs ...[]byte) []byte {
+	return concat(u2(typeIndex), u2(len(pairs)), concat(pairs...))
+}
+
+// pairBytes returns an element_value_pair of the element called name.
+func pairBytes(p *ConstantPoolBuilder, name string, value []byte) []byte {
+	return concat(u2(p.Utf8(name)), value)
+}
+
+// constBytes returns an element_value with tag that points to the constant at index.
+func constBytes(tag byte, index int) []byte {
+	return concat([]byte{tag}, u2(index))
+}
+
+// annotationsBytes returns the content of a "RuntimeVisibleAnnotations" or "RuntimeInvisibleAnnotations" attribute.
+func annotationsBytes(annotations ...[]byte) []byte {
+	return concat(u2(len(annotations)), concat(annotations...))
+}
+
+// namedAttribute is an attribute before its name is added to the constant pool.
+type namedAttribute struct {
+	name string
+	info []byte
+}
+
+// buildAnnotated builds the abstract version 52.0 class T with the field f and the abstract method m(I)V and parses
+// it. attributes returns the attributes of the class, the field and the method, their constants have to be added to p.
+func buildAnnotated(attributes func(p *ConstantPoolBuilder) (class, field, method []namedAttribute)) (ClassFile, error) {
+	b := NewClassBuilder("T").Version(52, 0).Access(ClassAccPublic|ClassAccAbstract).Field(FieldAccPublic, "f", "I")
+	class, field, method := attributes(b.Pool())
+	for _, attribute := range class {
+		b.Attribute(attribute.name, attribute.info)
+	}
+	m := b.Method(MethodAccPublic|MethodAccAbstract, "m", "(I)V")
+	for _, attribute := range method {
+		m.Attribute(attribute.name, attribute.info)
+	}
+	var fieldAttributes []AttributeInfo
+	for _, attribute := range field {
+		fieldAttributes = append(fieldAttributes, AttributeInfo{AttributeNameIndex: b.Pool().Utf8(attribute.name), Info: attribute.info})
+	}
+
+	cf, err := b.ClassFile()
+	if err != nil {
+		return ClassFile{}, err
+	}
+	cf.Fields[0].Attributes = fieldAttributes
+	var out bytes.Buffer
+	if err := Write(&out, cf); err != nil {
+		return ClassFile{}, err
+	}
+	return ParseBytes(out.Bytes())
+}
+
+func TestDecodeElementValues(t *testing.T) {
+	cf, err := buildAnnotated(func(p *ConstantPoolBuilder) (class, field, method []namedAttribute) {
+		nested := annotationBytes(p.Utf8("LB;"), pairBytes(p, "x", constBytes('I', p.Integer(42))))
+		a := annotationBytes(p.Utf8("LA;"),
+			pairBytes(p, "b", constBytes('B', p.Integer(-1))),
+			pairBytes(p, "c", constBytes('C', p.Integer('x'))),
+			pairBytes(p, "d", constBytes('D', p.Double(2.5))),
+			pairBytes(p, "f", constBytes('F', p.Float(float32(math.Inf(1))))),
+			pairBytes(p, "i", constBytes('I', p.Integer(-7))),
+			pairBytes(p, "j", constBytes('J', p.Long(1<<40))),
+			pairBytes(p, "s", constBytes('S', p.Integer(3))),
+			pairBytes(p, "z", constBytes('Z', p.Integer(1))),
+			pairBytes(p, "string", constBytes('s', p.Utf8("text"))),
+			pairBytes(p, "enum", concat([]byte{'e'}, u2(p.Utf8("Ljava/lang/annotation/RetentionPolicy;")),
+				u2(p.Utf8("RUNTIME")))),
+			pairBytes(p, "class", constBytes('c', p.Utf8("Ljava/lang/String;"))),
+			pairBytes(p, "void", constBytes('c', p.Utf8("V"))),
+			pairBytes(p, "annotation", concat([]byte{'@'}, nested)),
+			pairBytes(p, "array", concat([]byte{'['}, u2(3), constBytes('I', p.Integer(42)),
+				concat([]byte{'@'}, annotationBytes(p.Utf8("LB;"))), concat([]byte{'['}, u2(0)))),
+		)
+		return []namedAttribute{{"RuntimeVisibleAnnotations", annotationsBytes(a)}}, nil, nil
+	})
+	if err != nil {
+		t.Fatalf("parsing the annotated class failed: %v", err)
+	}
+	a, ok := cf.FindAnnotation("LA;")
+	if !ok {
+		t.Fatalf("annotation LA; not found in %+v", cf.Annotations)
+	}
+	if a.NumElementValuePairs != 14 {
+		t.Errorf("%d element value pairs, want 14", a.NumElementValuePairs)
+	}
+
+	constants := []struct {
+		name string
+		want CpInfo
+	}{
+		{"b", ConstantIntegerInfo{tag: 3, Integer: 0xFFFFFFFF}},
+		{"c", ConstantIntegerInfo{tag: 3, Integer: 'x'}},
+		{"d", ConstantDoubleInfo{tag: 6, Double: 2.5}},
+		{"f", ConstantFloatInfo{tag: 4, Float: float32(math.Inf(1))}},
+		{"i", ConstantIntegerInfo{tag: 3, Integer: 0xFFFFFFF9}},
+		{"j", ConstantLongInfo{tag: 5, Long: 1 << 40}},
+		{"s", ConstantIntegerInfo{tag: 3, Integer: 3}},
+		{"z", ConstantIntegerInfo{tag: 3, Integer: 1}},
+	}
+	for _, c := range constants {
+		value, ok := a.Element(c.name)
+		if !ok || value.Tag != c.name[0]-'a'+'A' || !reflect.DeepEqual(value.Const, c.want) {
+			t.Errorf("element %s is %+v, want tag %c and %+v", c.name, value, c.name[0]-'a'+'A', c.want)
+		}
+	}
+
+	if value, _ := a.Element("string"); value.Tag != 's' || value.Const.(ConstantUtf8Info).Text != "text" {
+		t.Errorf("element string is %+v, want the Utf8 text", value)
+	}
+	if value, _ := a.Element("enum"); value.Tag != 'e' || value.TypeName != "Ljava/lang/annotation/RetentionPolicy;" ||
+		value.ConstName != "RUNTIME" {
+		t.Errorf("element enum is %+v, want RetentionPolicy.RUNTIME", value)
+	}
+	if value, _ := a.Element("class"); value.Tag != 'c' || value.ClassInfo != "Ljava/lang/String;" {
+		t.Errorf("element class is %+v, want Ljava/lang/String;", value)
+	}
+	if value, _ := a.Element("void"); value.Tag != 'c' || value.ClassInfo != "V" {
+		t.Errorf("element void is %+v, want V", value)
+	}
+
+	value, _ := a.Element("annotation")
+	if value.Tag != '@' || value.AnnotationValue == nil || value.AnnotationValue.Type != "LB;" {
+		t.Fatalf("element annotation is %+v, want an LB; annotation", value)
+	}
+	if x, ok := value.AnnotationValue.Element("x"); !ok || x.Const != (ConstantIntegerInfo{tag: 3, Integer: 42}) {
+		t.Errorf("element x of the nested annotation is %+v, want 42", x)
+	}
+
+	value, _ = a.Element("array")
+	if value.Tag != '[' || value.NumValues != 3 || len(value.Values) != 3 {
+		t.Fatalf("element array is %+v, want 3 values", value)
+	}
+	if v := value.Values[0]; v.Tag != 'I' || v.Const != (ConstantIntegerInfo{tag: 3, Integer: 42}) {
+		t.Errorf("first array value is %+v, want 42", v)
+	}
+	if v := value.Values[1]; v.Tag != '@' || v.AnnotationValue.Type != "LB;" || len(v.AnnotationValue.ElementValuePairs) != 0 {
+		t.Errorf("second array value is %+v, want an LB; annotation without elements", v)
+	}
+	if v := value.Values[2]; v.Tag != '[' || v.NumValues != 0 || len(v.Values) != 0 {
+		t.Errorf("third array value is %+v, want an empty array", v)
+	}
+}
+
+func TestDecodeAnnotationAttachments(t *testing.T) {
+	cf, err := buildAnnotated(func(p *ConstantPoolBuilder) (class, field, method []namedAttribute) {
+		deprecated := annotationBytes(p.Utf8("Ljava/lang/Deprecated;"))
+		a, b := annotationBytes(p.Utf8("LA;")), annotationBytes(p.Utf8("LB;"))
+		// a supertype_target for the superclass and an empty_target for the type of the field, with a type path
+		superclass := concat([]byte{0x10}, u2(65535), []byte{0}, a)
+		fieldType := concat([]byte{0x13, 2, 0, 0, 3, 1}, b)
+		return []namedAttribute{
+			{"RuntimeVisibleAnnotations", annotationsBytes(deprecated)},
+			{"RuntimeVisibleTypeAnnotations", annotationsBytes(superclass)},
+		}, []namedAttribute{
+			{"RuntimeInvisibleAnnotations", annotationsBytes(a, b)},
+			{"RuntimeInvisibleTypeAnnotations", annotationsBytes(fieldType)},
+		}, []namedAttribute{
+			{"RuntimeVisibleAnnotations", annotationsBytes(b)},
+			// one parameter with two annotations
+			{"RuntimeVisibleParameterAnnotations", concat([]byte{1}, annotationsBytes(a, b))},
+			{"RuntimeInvisibleParameterAnnotations", concat([]byte{1}, annotationsBytes())},
+			{"AnnotationDefault", constBytes('s', p.Utf8("default"))},
+		}
+	})
+	if err != nil {
+		t.Fatalf("parsing the annotated class failed: %v", err)
+	}
+
+	types := func(annotations []Annotation) []string {
+		var res []string
+		for _, annotation := range annotations {
+			res = append(res, annotation.Type)
+		}
+		return res
+	}
+	if _, ok := cf.FindAnnotation("Ljava/lang/Deprecated;"); !ok {
+		t.Errorf("class annotations %+v, want Deprecated", cf.VisibleAnnotations)
+	}
+	if len(cf.VisibleTypeAnnotations) != 1 || cf.VisibleTypeAnnotations[0].TargetType != 0x10 ||
+		cf.VisibleTypeAnnotations[0].SupertypeIndex != 65535 || cf.VisibleTypeAnnotations[0].Type != "LA;" {
+		t.Errorf("class type annotations %+v, want LA; on the superclass", cf.VisibleTypeAnnotations)
+	}
+
+	field := cf.Fields[0]
+	if got := types(field.InvisibleAnnotations); !reflect.DeepEqual(got, []string{"LA;", "LB;"}) || field.VisibleAnnotations != nil {
+		t.Errorf("field annotations %v, want invisible LA; and LB;", got)
+	}
+	if _, ok := field.FindAnnotation("LB;"); !ok {
+		t.Error("FindAnnotation doesn't find the invisible field annotation LB;")
+	}
+	wantPath := []TypePathEntry{{TypePathKind: 0}, {TypePathKind: 3, TypeArgumentIndex: 1}}
+	if len(field.InvisibleTypeAnnotations) != 1 || field.InvisibleTypeAnnotations[0].TargetType != 0x13 ||
+		!reflect.DeepEqual(field.InvisibleTypeAnnotations[0].TypePath, wantPath) {
+		t.Errorf("field type annotations %+v, want LB; with type path %v", field.InvisibleTypeAnnotations, wantPath)
+	}
+
+	method := cf.Methods[0]
+	if got := types(method.VisibleAnnotations); !reflect.DeepEqual(got, []string{"LB;"}) {
+		t.Errorf("method annotations %v, want LB;", got)
+	}
+	if len(method.VisibleParameterAnnotations) != 1 ||
+		!reflect.DeepEqual(types(method.VisibleParameterAnnotations[0]), []string{"LA;", "LB;"}) {
+		t.Errorf("visible parameter annotations %+v, want LA; and LB; on the first parameter", method.VisibleParameterAnnotations)
+	}
+	if len(method.InvisibleParameterAnnotations) != 1 || len(method.InvisibleParameterAnnotations[0]) != 0 {
+		t.Errorf("invisible parameter annotations %+v, want none on the first parameter", method.InvisibleParameterAnnotations)
+	}
+	if d := method.AnnotationDefault; d == nil || d.Tag != 's' || d.Const.(ConstantUtf8Info).Text != "default" {
+		t.Errorf("annotation default %+v, want the string default", d)
+	}
+}
+
+func TestDecodeAnnotationErrors(t *testing.T) {
+	tests := []struct {
+		name       string
+		attributes func(p *ConstantPoolBuilder) (class, field, method []namedAttribute)
+		path       string
+		reason     string
+	}{
+		{"truncated annotation", func(p *ConstantPoolBuilder) (class, field, method []namedAttribute) {
+			a := annotationBytes(p.Utf8("LA;"), pairBytes(p, "x", constBytes('I', p.Integer(1))))
+			return []namedAttribute{{"RuntimeVisibleAnnotations", annotationsBytes(a[:len(a)-1])}}, nil, nil
+		}, "attributes[RuntimeVisibleAnnotations]", "couldn't read 2 bytes"},
+		{"trailing bytes", func(p *ConstantPoolBuilder) (class, field, method []namedAttribute) {
+			a := annotationBytes(p.Utf8("LA;"))
+			return []namedAttribute{{"RuntimeVisibleAnnotations", concat(annotationsBytes(a), []byte{0})}}, nil, nil
+		}, "attributes[RuntimeVisibleAnnotations]", "couldn't fully read the RuntimeVisibleAnnotations attribute"},
+		{"constant index out of range", func(p *ConstantPoolBuilder) (class, field, method []namedAttribute) {
+			a := annotationBytes(p.Utf8("LA;"), pairBytes(p, "x", constBytes('I', 60000)))
+			return nil, []namedAttribute{{"RuntimeVisibleAnnotations", annotationsBytes(a)}}, nil
+		}, "fields[0].attributes[RuntimeVisibleAnnotations]", "constant pool index 60000 out of range"},
+		{"constant doesn't match the tag", func(p *ConstantPoolBuilder) (class, field, method []namedAttribute) {
+			a := annotationBytes(p.Utf8("LA;"), pairBytes(p, "x", constBytes('J', p.Integer(1))))
+			return nil, nil, []namedAttribute{{"RuntimeInvisibleAnnotations", annotationsBytes(a)}}
+		}, "methods[0].attributes[RuntimeInvisibleAnnotations]", "doesn't match element value tag 'J'"},
+		{"type isn't a Utf8", func(p *ConstantPoolBuilder) (class, field, method []namedAttribute) {
+			return []namedAttribute{{"RuntimeVisibleAnnotations", annotationsBytes(annotationBytes(p.Class("A")))}}, nil, nil
+		}, "attributes[RuntimeVisibleAnnotations]", "not of type ConstantUtf8Info"},
+		{"unknown element value tag", func(p *ConstantPoolBuilder) (class, field, method []namedAttribute) {
+			a := annotationBytes(p.Utf8("LA;"), pairBytes(p, "x", constBytes('X', p.Integer(1))))
+			return []namedAttribute{{"RuntimeVisibleAnnotations", annotationsBytes(a)}}, nil, nil
+		}, "attributes[RuntimeVisibleAnnotations]", "unknown element value tag 'X'"},
+		{"enum name out of range", func(p *ConstantPoolBuilder) (class, field, method []namedAttribute) {
+			a := annotationBytes(p.Utf8("LA;"), pairBytes(p, "x", concat([]byte{'e'}, u2(p.Utf8("LE;")), u2(0))))
+			return []namedAttribute{{"RuntimeVisibleAnnotations", annotationsBytes(a)}}, nil, nil
+		}, "attributes[RuntimeVisibleAnnotations]", "out of range"},
+		{"unknown type annotation target", func(p *ConstantPoolBuilder) (class, field, method []namedAttribute) {
+			a := concat([]byte{0x20, 0}, annotationBytes(p.Utf8("LA;")))
+			return []namedAttribute{{"RuntimeVisibleTypeAnnotations", annotationsBytes(a)}}, nil, nil
+		}, "attributes[RuntimeVisibleTypeAnnotations]", "unknown type annotation target type 0x20"},
+		{"truncated parameter annotations", func(p *ConstantPoolBuilder) (class, field, method []namedAttribute) {
+			return nil, nil, []namedAttribute{{"RuntimeVisibleParameterAnnotations", concat([]byte{2}, annotationsBytes())}}
+		}, "methods[0].attributes[RuntimeVisibleParameterAnnotations]", "couldn't read 2 bytes"},
+		{"annotation default out of range", func(p *ConstantPoolBuilder) (class, field, method []namedAttribute) {
+			return nil, nil, []namedAttribute{{"AnnotationDefault", constBytes('c', 60000)}}
+		}, "methods[0].attributes[AnnotationDefault]", "out of range"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := buildAnnotated(test.attributes)
+			var formatError *FormatError
+			if !errors.As(err, &formatError) {
+				t.Fatalf("got %v, want a *FormatError", err)
+			}
+			if formatError.Path != test.path || !strings.Contains(err.Error(), test.reason) {
+				t.Errorf("got %q at %s, want one containing %q at %s", err, formatError.Path, test.reason, test.path)
+			}
+		})
+	}
+}
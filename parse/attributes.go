@@ -1,11 +1,37 @@
 package parse
 
+import "fmt"
+
 type AttributeInfo struct {
 	AttributeNameIndex int
 	attributeLength    int // u4
 	Info               []byte
 }
 
+// CodeAttribute is the decoded content of a "Code" attribute.
+type CodeAttribute struct {
+	MaxStack             int // u2
+	MaxLocals            int // u2
+	CodeLength           int // u4
+	Code                 []byte
+	ExceptionTableLength int // u2
+	ExceptionTable       []ExceptionTableEntry
+	AttributesCount      int // u2
+	Attributes           []AttributeInfo
+
+	LineNumberTable        []LineNumberTableEntry    // decoded "LineNumberTable" attributes
+	LocalVariableTable     []LocalVariableTableEntry // decoded "LocalVariableTable" attributes
+	LocalVariableTypeTable []LocalVariableTableEntry // decoded "LocalVariableTypeTable" attributes
+}
+
+// ExceptionTableEntry is one entry of the exception table of a CodeAttribute.
+type ExceptionTableEntry struct {
+	StartPc   int // u2
+	EndPc     int // u2
+	HandlerPc int // u2
+	CatchType int // u2 index of a ConstantClassInfo or 0 to catch everything
+}
+
 // ReadAttributes reads attributes of a class/method/... and returns the size and all attributes.
 func (r *ClassFileReader) ReadAttributes() (size int, entries []AttributeInfo, err error) {
 	size, err = r.ReadU2()
@@ -18,6 +44,7 @@ func (r *ClassFileReader) ReadAttributes() (size int, entries []AttributeInfo, e
 		entry, err = r.ReadAttributeInfo()
 		entries = append(entries, entry)
 		if err != nil {
+			err = atPath(fmt.Sprintf("attributes[%d]", i), err)
 			return
 		}
 	}
@@ -37,8 +64,78 @@ func (r *ClassFileReader) ReadAttributeInfo() (info AttributeInfo, err error) {
 		return
 	}
 
-	info.Info = make([]byte, info.attributeLength)
-	_, err = r.Read(info.Info)
+	info.Info, err = r.readBytes(info.attributeLength, "attribute")
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+// ReadCodeAttribute reads the content (AttributeInfo.Info) of a "Code" attribute.
+func (r *ClassFileReader) ReadCodeAttribute() (code CodeAttribute, err error) {
+	code.MaxStack, err = r.ReadU2()
+	if err != nil {
+		return
+	}
+
+	code.MaxLocals, err = r.ReadU2()
+	if err != nil {
+		return
+	}
+
+	code.CodeLength, err = r.ReadU4()
+	if err != nil {
+		return
+	}
+
+	code.Code, err = r.readBytes(code.CodeLength, "code")
+	if err != nil {
+		err = atPath("code", err)
+		return
+	}
+
+	code.ExceptionTableLength, err = r.ReadU2()
+	if err != nil {
+		return
+	}
+
+	for i := 0; i < code.ExceptionTableLength; i++ {
+		var entry ExceptionTableEntry
+		entry, err = r.ReadExceptionTableEntry()
+		if err != nil {
+			err = atPath(fmt.Sprintf("exception_table[%d]", i), err)
+			return
+		}
+		code.ExceptionTable = append(code.ExceptionTable, entry)
+	}
+
+	code.AttributesCount, code.Attributes, err = r.ReadAttributes()
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+// ReadExceptionTableEntry reads one entry of the exception table in a "Code" attribute.
+func (r *ClassFileReader) ReadExceptionTableEntry() (entry ExceptionTableEntry, err error) {
+	entry.StartPc, err = r.ReadU2()
+	if err != nil {
+		return
+	}
+
+	entry.EndPc, err = r.ReadU2()
+	if err != nil {
+		return
+	}
+
+	entry.HandlerPc, err = r.ReadU2()
+	if err != nil {
+		return
+	}
+
+	entry.CatchType, err = r.ReadU2()
 	if err != nil {
 		return
 	}
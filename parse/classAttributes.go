@@ -0,0 +1,247 @@
+package parse
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// InnerClassEntry is one class of an "InnerClasses" attribute (JVMS §4.7.6).
+type InnerClassEntry struct {
+	InnerClassInfoIndex   int // u2 index of a ConstantClassInfo
+	InnerClass            string
+	OuterClassInfoIndex   int    // u2 index of a ConstantClassInfo or 0 if the class isn't a member
+	OuterClass            string // "" if OuterClassInfoIndex is 0
+	InnerNameIndex        int    // u2 index of a ConstantUtf8Info or 0 for anonymous classes
+	InnerName             string // "" if InnerNameIndex is 0
+	InnerClassAccessFlags int    // u2
+}
+
+// EnclosingMethod is the decoded content of an "EnclosingMethod" attribute (JVMS §4.7.7).
+type EnclosingMethod struct {
+	ClassIndex       int // u2 index of a ConstantClassInfo
+	Class            string
+	MethodIndex      int    // u2 index of a ConstantNameAndTypeInfo or 0 if the class isn't enclosed by a method
+	MethodName       string // "" if MethodIndex is 0
+	MethodDescriptor string // "" if MethodIndex is 0
+}
+
+// RecordComponentInfo is one component of a "Record" attribute (JVMS §4.7.30).
+type RecordComponentInfo struct {
+	NameIndex       int // u2
+	Name            string
+	DescriptorIndex int // u2
+	Descriptor      string
+	AttributesCount int // u2
+	Attributes      []AttributeInfo
+	Signature       TypeSignature // decoded "Signature" attribute, nil if the component type isn't generic
+	SignatureError  error         // why the "Signature" attribute couldn't be decoded
+	Annotations
+}
+
+// ReadClassList reads the content of a "NestMembers" or "PermittedSubclasses" attribute, a list of ConstantClassInfo
+// indexes.
+func (r *ClassFileReader) ReadClassList() (indexes []int, err error) {
+	return r.readU2List()
+}
+
+// ReadInnerClasses reads the content of an "InnerClasses" attribute.
+func (r *ClassFileReader) ReadInnerClasses() (entries []InnerClassEntry, err error) {
+	size, err := r.ReadU2()
+	if err != nil {
+		return
+	}
+
+	for i := 0; i < size; i++ {
+		var entry InnerClassEntry
+		entry.InnerClassInfoIndex, err = r.ReadU2()
+		if err != nil {
+			return
+		}
+		entry.OuterClassInfoIndex, err = r.ReadU2()
+		if err != nil {
+			return
+		}
+		entry.InnerNameIndex, err = r.ReadU2()
+		if err != nil {
+			return
+		}
+		entry.InnerClassAccessFlags, err = r.ReadU2()
+		if err != nil {
+			return
+		}
+		entries = append(entries, entry)
+	}
+
+	return
+}
+
+// ReadEnclosingMethod reads the content of an "EnclosingMethod" attribute.
+func (r *ClassFileReader) ReadEnclosingMethod() (method EnclosingMethod, err error) {
+	method.ClassIndex, err = r.ReadU2()
+	if err != nil {
+		return
+	}
+
+	method.MethodIndex, err = r.ReadU2()
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+// ReadRecord reads the content of a "Record" attribute.
+func (r *ClassFileReader) ReadRecord() (components []RecordComponentInfo, err error) {
+	size, err := r.ReadU2()
+	if err != nil {
+		return
+	}
+
+	components = make([]RecordComponentInfo, 0, size)
+	for i := 0; i < size; i++ {
+		var component RecordComponentInfo
+		component.NameIndex, err = r.ReadU2()
+		if err != nil {
+			return
+		}
+		component.DescriptorIndex, err = r.ReadU2()
+		if err != nil {
+			return
+		}
+		component.AttributesCount, component.Attributes, err = r.ReadAttributes()
+		if err != nil {
+			return
+		}
+		components = append(components, component)
+	}
+
+	return
+}
+
+// decodeClassAttribute decodes the nest, inner class, record or sealed class attribute called name into f.
+func (f *ClassFile) decodeClassAttribute(name string, attribute AttributeInfo) error {
+	reader := (*ClassFileReader)(bytes.NewReader(attribute.Info))
+
+	var err error
+	switch name {
+	case "InnerClasses":
+		f.InnerClasses, err = reader.ReadInnerClasses()
+		for i := 0; i < len(f.InnerClasses) && err == nil; i++ {
+			err = f.resolveInnerClass(&f.InnerClasses[i])
+		}
+	case "EnclosingMethod":
+		var method EnclosingMethod
+		method, err = reader.ReadEnclosingMethod()
+		if err == nil {
+			err = f.resolveEnclosingMethod(&method)
+		}
+		f.EnclosingMethod = &method
+	case "NestHost":
+		var index int
+		index, err = reader.ReadU2()
+		if err == nil {
+			f.NestHost, err = f.className(index)
+		}
+	case "NestMembers":
+		f.NestMembers, err = f.readClassNames(reader)
+	case "PermittedSubclasses":
+		f.PermittedSubclasses, err = f.readClassNames(reader)
+	case "Record":
+		var components []RecordComponentInfo
+		components, err = reader.ReadRecord()
+		for i := 0; i < len(components) && err == nil; i++ {
+			err = f.decodeRecordComponent(&components[i])
+		}
+		f.Record = components
+	}
+	if err != nil {
+		return fmt.Errorf("%s attribute: %w", name, err)
+	}
+	if reader.Len() != 0 {
+		return fmt.Errorf("couldn't fully read the %s attribute", name)
+	}
+	return nil
+}
+
+// readClassNames reads a list of ConstantClassInfo indexes and returns their names, never nil.
+func (f *ClassFile) readClassNames(reader *ClassFileReader) ([]string, error) {
+	indexes, err := reader.ReadClassList()
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, len(indexes))
+	for i, index := range indexes {
+		names[i], err = f.className(index)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return names, nil
+}
+
+func (f *ClassFile) resolveInnerClass(entry *InnerClassEntry) (err error) {
+	entry.InnerClass, err = f.className(entry.InnerClassInfoIndex)
+	if err != nil {
+		return
+	}
+	if entry.OuterClassInfoIndex != 0 {
+		entry.OuterClass, err = f.className(entry.OuterClassInfoIndex)
+		if err != nil {
+			return
+		}
+	}
+	entry.InnerName, err = f.optionalUtf8Text(entry.InnerNameIndex)
+	return
+}
+
+func (f *ClassFile) resolveEnclosingMethod(method *EnclosingMethod) (err error) {
+	method.Class, err = f.className(method.ClassIndex)
+	if err != nil || method.MethodIndex == 0 {
+		return
+	}
+	if method.MethodIndex > len(f.ConstantPool) {
+		return fmt.Errorf("constant pool index %d out of range", method.MethodIndex)
+	}
+	nameAndType, ok := f.ConstantPool[method.MethodIndex-1].(ConstantNameAndTypeInfo)
+	if !ok {
+		return fmt.Errorf("constant pool entry %d not of type ConstantNameAndTypeInfo", method.MethodIndex)
+	}
+	method.MethodName, err = f.utf8Text(nameAndType.NameIndex)
+	if err != nil {
+		return
+	}
+	method.MethodDescriptor, err = f.utf8Text(nameAndType.DescriptorIndex)
+	return
+}
+
+// decodeRecordComponent resolves the name and descriptor of a record component and decodes its attributes.
+func (f *ClassFile) decodeRecordComponent(component *RecordComponentInfo) (err error) {
+	component.Name, err = f.utf8Text(component.NameIndex)
+	if err != nil {
+		return
+	}
+	component.Descriptor, err = f.utf8Text(component.DescriptorIndex)
+	if err != nil {
+		return
+	}
+
+	for _, attribute := range component.Attributes {
+		var name string
+		name, err = f.attributeName(attribute)
+		if err != nil {
+			return
+		}
+
+		switch name {
+		case "Signature":
+			component.Signature, component.SignatureError = decodeSignature(f, attribute, ParseFieldSignature)
+		default:
+			_, err = f.decodeAnnotations(name, attribute, &component.Annotations)
+		}
+		if err != nil {
+			return
+		}
+	}
+
+	return
+}
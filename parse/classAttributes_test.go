@@ -0,0 +1,153 @@
+package parse
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// u4 returns v as the four bytes of a u4.
+func u4(v int) []byte {
+	return []byte{byte(v >> 24), byte(v >> 16), byte(v >> 8), byte(v)}
+}
+
+// buildClassAttributes builds and parses a class of version major with the class attributes returned by attributes.
+func buildClassAttributes(major int, attributes func(p *ConstantPoolBuilder) []namedAttribute) (ClassFile, error) {
+	b := NewClassBuilder("T").Version(major, 0)
+	for _, attribute := range attributes(b.Pool()) {
+		b.Attribute(attribute.name, attribute.info)
+	}
+	return b.Build()
+}
+
+func TestDecodeClassAttributes(t *testing.T) {
+	var innerIndex, outerIndex, anonymousIndex, nameIndex, enclosingIndex, methodIndex int
+	cf, err := buildClassAttributes(61, func(p *ConstantPoolBuilder) []namedAttribute {
+		innerIndex, outerIndex, anonymousIndex = p.Class("T$Inner"), p.Class("T"), p.Class("T$1")
+		nameIndex = p.Utf8("Inner")
+		enclosingIndex, methodIndex = p.Class("Outer"), p.NameAndType("run", "(I)V")
+		signature := concat(u2(p.Utf8("Signature")), u4(2), u2(p.Utf8("Ljava/util/List<Ljava/lang/String;>;")))
+		return []namedAttribute{
+			{"InnerClasses", concat(u2(2),
+				u2(innerIndex), u2(outerIndex), u2(nameIndex), u2(0x0009), // public static
+				u2(anonymousIndex), u2(0), u2(0), u2(0))},
+			{"EnclosingMethod", concat(u2(enclosingIndex), u2(methodIndex))},
+			{"NestHost", u2(p.Class("Host"))},
+			{"NestMembers", concat(u2(2), u2(p.Class("Host$A")), u2(p.Class("Host$B")))},
+			{"PermittedSubclasses", concat(u2(1), u2(p.Class("Sub")))},
+			{"Record", concat(u2(2),
+				u2(p.Utf8("x")), u2(p.Utf8("I")), u2(0),
+				u2(p.Utf8("names")), u2(p.Utf8("Ljava/util/List;")), u2(1), signature)},
+		}
+	})
+	if err != nil {
+		t.Fatalf("building the class failed: %v", err)
+	}
+
+	wantInner := []InnerClassEntry{
+		{InnerClassInfoIndex: innerIndex, InnerClass: "T$Inner", OuterClassInfoIndex: outerIndex, OuterClass: "T",
+			InnerNameIndex: nameIndex, InnerName: "Inner", InnerClassAccessFlags: 0x0009},
+		{InnerClassInfoIndex: anonymousIndex, InnerClass: "T$1"},
+	}
+	if !reflect.DeepEqual(cf.InnerClasses, wantInner) {
+		t.Errorf("InnerClasses %+v, want %+v", cf.InnerClasses, wantInner)
+	}
+	wantEnclosing := &EnclosingMethod{ClassIndex: enclosingIndex, Class: "Outer", MethodIndex: methodIndex,
+		MethodName: "run", MethodDescriptor: "(I)V"}
+	if !reflect.DeepEqual(cf.EnclosingMethod, wantEnclosing) {
+		t.Errorf("EnclosingMethod %+v, want %+v", cf.EnclosingMethod, wantEnclosing)
+	}
+	if cf.NestHost != "Host" {
+		t.Errorf("NestHost %q, want Host", cf.NestHost)
+	}
+	if !reflect.DeepEqual(cf.NestMembers, []string{"Host$A", "Host$B"}) {
+		t.Errorf("NestMembers %v, want [Host$A Host$B]", cf.NestMembers)
+	}
+	if !reflect.DeepEqual(cf.PermittedSubclasses, []string{"Sub"}) {
+		t.Errorf("PermittedSubclasses %v, want [Sub]", cf.PermittedSubclasses)
+	}
+
+	if len(cf.Record) != 2 {
+		t.Fatalf("%d record components, want 2", len(cf.Record))
+	}
+	x, names := cf.Record[0], cf.Record[1]
+	if x.Name != "x" || x.Descriptor != "I" || x.Signature != nil {
+		t.Errorf("first component %s %s with signature %v, want x I without signature", x.Name, x.Descriptor, x.Signature)
+	}
+	if names.Name != "names" || names.Descriptor != "Ljava/util/List;" || names.Signature == nil ||
+		names.Signature.String() != "Ljava/util/List<Ljava/lang/String;>;" {
+		t.Errorf("second component %s %s with signature %v, want names Ljava/util/List; with signature "+
+			"Ljava/util/List<Ljava/lang/String;>;", names.Name, names.Descriptor, names.Signature)
+	}
+}
+
+func TestDecodeClassAttributesAbsent(t *testing.T) {
+	cf, err := buildClassAttributes(54, func(p *ConstantPoolBuilder) []namedAttribute {
+		// a class declared in an initializer isn't enclosed by a method, NestHost is only recognized from 55.0 on
+		return []namedAttribute{
+			{"EnclosingMethod", concat(u2(p.Class("Outer")), u2(0))},
+			{"NestHost", u2(p.Class("Host"))},
+		}
+	})
+	if err != nil {
+		t.Fatalf("building the class failed: %v", err)
+	}
+
+	if cf.EnclosingMethod == nil || cf.EnclosingMethod.Class != "Outer" || cf.EnclosingMethod.MethodName != "" ||
+		cf.EnclosingMethod.MethodDescriptor != "" {
+		t.Errorf("EnclosingMethod %+v, want Outer without method", cf.EnclosingMethod)
+	}
+	if cf.InnerClasses != nil || cf.NestHost != "" || cf.NestMembers != nil || cf.PermittedSubclasses != nil ||
+		cf.Record != nil {
+		t.Errorf("class without the attributes has InnerClasses %v, NestHost %q, NestMembers %v, "+
+			"PermittedSubclasses %v and Record %v", cf.InnerClasses, cf.NestHost, cf.NestMembers,
+			cf.PermittedSubclasses, cf.Record)
+	}
+}
+
+func TestDecodeClassAttributeErrors(t *testing.T) {
+	tests := []struct {
+		name      string
+		attribute func(p *ConstantPoolBuilder) namedAttribute
+		path      string
+		reason    string
+	}{
+		{"NestHost isn't a Class", func(p *ConstantPoolBuilder) namedAttribute {
+			return namedAttribute{"NestHost", u2(p.Utf8("Host"))}
+		}, "attributes[NestHost]", "is ConstantUtf8Info instead of ConstantClassInfo"},
+		{"NestMembers with a wrong count", func(p *ConstantPoolBuilder) namedAttribute {
+			return namedAttribute{"NestMembers", concat(u2(2), u2(p.Class("A")))}
+		}, "attributes[NestMembers]", "attribute length 4 doesn't match expected length 6"},
+		{"PermittedSubclasses entry isn't a Class", func(p *ConstantPoolBuilder) namedAttribute {
+			return namedAttribute{"PermittedSubclasses", concat(u2(1), u2(p.Utf8("Sub")))}
+		}, "attributes[PermittedSubclasses][0]", "is ConstantUtf8Info instead of ConstantClassInfo"},
+		{"EnclosingMethod method isn't a NameAndType", func(p *ConstantPoolBuilder) namedAttribute {
+			return namedAttribute{"EnclosingMethod", concat(u2(p.Class("Outer")), u2(p.Class("Outer")))}
+		}, "attributes[EnclosingMethod].method_index", "instead of ConstantNameAndTypeInfo"},
+		{"inner name isn't a Utf8", func(p *ConstantPoolBuilder) namedAttribute {
+			return namedAttribute{"InnerClasses", concat(u2(1), u2(p.Class("T$I")), u2(0), u2(p.Class("I")), u2(0))}
+		}, "attributes[InnerClasses]", "not of type ConstantUtf8Info"},
+		{"Record component name isn't a Utf8", func(p *ConstantPoolBuilder) namedAttribute {
+			return namedAttribute{"Record", concat(u2(1), u2(p.Class("x")), u2(p.Utf8("I")), u2(0))}
+		}, "attributes[Record]", "not of type ConstantUtf8Info"},
+		{"Record with trailing bytes", func(p *ConstantPoolBuilder) namedAttribute {
+			return namedAttribute{"Record", concat(u2(1), u2(p.Utf8("x")), u2(p.Utf8("I")), u2(0), u2(0))}
+		}, "attributes[Record]", "couldn't fully read the Record attribute"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := buildClassAttributes(61, func(p *ConstantPoolBuilder) []namedAttribute {
+				return []namedAttribute{test.attribute(p)}
+			})
+			var formatError *FormatError
+			if !errors.As(err, &formatError) {
+				t.Fatalf("ParseBytes returned %v, want a *FormatError", err)
+			}
+			if formatError.Path != test.path || !strings.Contains(err.Error(), test.reason) {
+				t.Errorf("got %q at %s, want one containing %q at %s", err, formatError.Path, test.reason, test.path)
+			}
+		})
+	}
+}
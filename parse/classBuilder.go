@@ -0,0 +1,272 @@
+package parse
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// ClassBuilder creates class files from Go code, e.g. for interpreter tests:
+//
+//	cf, err := parse.NewClassBuilder("Foo").
+//		Method(0x0009, "one", "()I").Code(func(c *parse.CodeBuilder) {
+//			c.IConst(1)
+//			c.IReturn()
+//		}).
+//		Build()
+//
+// Constant pool entries are added with a ConstantPoolBuilder, so every constant is only added once. Errors are
+// collected and returned by Build and Bytes.
+//
+// Classes are version 49.0 by default. Its methods are verified by type inference, so they don't need a
+// StackMapTable. Newer versions need the StackMapTables of verify.StackMapTable.
+type ClassBuilder struct {
+	pool           *ConstantPoolBuilder
+	cf             ClassFile
+	source         string
+	attributes     []AttributeInfo
+	methods        []*MethodBuilder
+	stackMapTables StackMapTableFunc
+	err            error
+}
+
+// StackMapTableFunc returns the content of the StackMapTable attribute of the method info in cf, or nil if it
+// doesn't need one. classIndex returns the index of the ConstantClassInfo of a class or array name.
+type StackMapTableFunc func(cf *ClassFile, info MethodInfo, classIndex func(name string) int) ([]byte, error)
+
+// MethodBuilder adds the exceptions and the code of a method to a ClassBuilder.
+type MethodBuilder struct {
+	class      *ClassBuilder
+	info       MethodInfo
+	name       string
+	descriptor string
+	exceptions []int
+	attributes []AttributeInfo
+}
+
+// NewClassBuilder returns a builder for a public class called name, e.g. "com/example/Foo", that extends
+// java/lang/Object.
+func NewClassBuilder(name string) *ClassBuilder {
+	return newClassBuilder(NewConstantPoolBuilder(nil), name)
+}
+
+func newClassBuilder(pool *ConstantPoolBuilder, name string) *ClassBuilder {
+	b := &ClassBuilder{pool: pool}
+	b.cf = ClassFile{
+		Magic:        0xCAFEBABE,
+		MajorVersion: 49,
+		AccessFlags:  ClassAccPublic | ClassAccSuper,
+		ThisClass:    b.pool.Class(name),
+		SuperClass:   b.pool.Class("java/lang/Object"),
+	}
+	return b
+}
+
+// Pool returns the constant pool of the class.
+func (b *ClassBuilder) Pool() *ConstantPoolBuilder {
+	return b.pool
+}
+
+// Version sets the class file version.
+func (b *ClassBuilder) Version(major, minor int) *ClassBuilder {
+	b.cf.MajorVersion, b.cf.MinorVersion = major, minor
+	return b
+}
+
+// Access sets the access flags of the class.
+func (b *ClassBuilder) Access(flags ClassAccessFlags) *ClassBuilder {
+	b.cf.AccessFlags = flags
+	return b
+}
+
+// Super sets the super class.
+func (b *ClassBuilder) Super(name string) *ClassBuilder {
+	b.cf.SuperClass = b.pool.Class(name)
+	return b
+}
+
+// Implements adds interfaces the class implements.
+func (b *ClassBuilder) Implements(names ...string) *ClassBuilder {
+	for _, name := range names {
+		b.cf.Interfaces = append(b.cf.Interfaces, b.pool.Class(name))
+	}
+	return b
+}
+
+// Source sets the SourceFile attribute.
+func (b *ClassBuilder) Source(file string) *ClassBuilder {
+	b.source = file
+	return b
+}
+
+// Attribute adds a class attribute with its raw content. Constant pool indexes in it have to refer to Pool.
+func (b *ClassBuilder) Attribute(name string, info []byte) *ClassBuilder {
+	b.attributes = append(b.attributes, AttributeInfo{AttributeNameIndex: b.pool.Utf8(name), Info: info})
+	return b
+}
+
+// StackMapTables sets the function that computes the StackMapTables of the methods in class files of version 50.0
+// and newer.
+func (b *ClassBuilder) StackMapTables(compute StackMapTableFunc) *ClassBuilder {
+	b.stackMapTables = compute
+	return b
+}
+
+// Field adds a field.
+func (b *ClassBuilder) Field(flags FieldAccessFlags, name, descriptor string) *ClassBuilder {
+	b.cf.Fields = append(b.cf.Fields, FieldInfo{
+		AccessFlags:     flags,
+		NameIndex:       b.pool.Utf8(name),
+		Name:            name,
+		DescriptorIndex: b.pool.Utf8(descriptor),
+		Descriptor:      descriptor,
+	})
+	return b
+}
+
+// Method adds a method. Abstract and native methods are complete, all others need their Code.
+func (b *ClassBuilder) Method(flags MethodAccessFlags, name, descriptor string) *MethodBuilder {
+	m := &MethodBuilder{
+		class: b,
+		info: MethodInfo{
+			AccessFlags:     flags,
+			NameIndex:       b.pool.Utf8(name),
+			DescriptorIndex: b.pool.Utf8(descriptor),
+		},
+		name:       name,
+		descriptor: descriptor,
+	}
+	b.methods = append(b.methods, m)
+	return m
+}
+
+// Throws adds classes to the Exceptions attribute of the method.
+func (m *MethodBuilder) Throws(classes ...string) *MethodBuilder {
+	for _, class := range classes {
+		m.exceptions = append(m.exceptions, m.class.pool.Class(class))
+	}
+	return m
+}
+
+// Attribute adds a method attribute with its raw content. Constant pool indexes in it have to refer to the pool of
+// the class.
+func (m *MethodBuilder) Attribute(name string, info []byte) *MethodBuilder {
+	m.attributes = append(m.attributes, AttributeInfo{AttributeNameIndex: m.class.pool.Utf8(name), Info: info})
+	return m
+}
+
+// Code sets the code of the method to the instructions generate adds and returns the class to continue with.
+func (m *MethodBuilder) Code(generate func(c *CodeBuilder)) *ClassBuilder {
+	c := m.newCode()
+	generate(c)
+	m.setCode(c)
+	return m.class
+}
+
+func (m *MethodBuilder) newCode() *CodeBuilder {
+	return newCodeBuilder(m.class.pool, m.info.AccessFlags, m.descriptor)
+}
+
+func (m *MethodBuilder) setCode(c *CodeBuilder) {
+	code, err := c.finish()
+	if err != nil && m.class.err == nil {
+		m.class.err = fmt.Errorf("method %s%s: %w", m.name, m.descriptor, err)
+	}
+	m.info.Code = code
+}
+
+// ClassFile returns the class as it will be written. Unlike the result of Build its constant pool entries aren't
+// resolved.
+func (b *ClassBuilder) ClassFile() (ClassFile, error) {
+	if b.err != nil {
+		return ClassFile{}, b.err
+	}
+
+	cf := b.cf
+	cf.Interfaces = append([]int{}, b.cf.Interfaces...)
+	cf.Fields = append([]FieldInfo{}, b.cf.Fields...)
+	cf.Methods = nil
+	for _, m := range b.methods {
+		info := m.info
+		info.Attributes = nil
+		if info.Code != nil {
+			info.Attributes = append(info.Attributes, AttributeInfo{AttributeNameIndex: b.pool.Utf8("Code")})
+		} else if !info.AccessFlags.IsNative() && !info.AccessFlags.IsAbstract() {
+			return ClassFile{}, fmt.Errorf("method %s%s has no code", m.name, m.descriptor)
+		}
+		if len(m.exceptions) != 0 {
+			w := new(ClassFileWriter)
+			_ = w.WriteU2(len(m.exceptions))
+			for _, index := range m.exceptions {
+				_ = w.WriteU2(index)
+			}
+			info.Attributes = append(info.Attributes, AttributeInfo{AttributeNameIndex: b.pool.Utf8("Exceptions"), Info: w.Bytes()})
+		}
+		info.Attributes = append(info.Attributes, m.attributes...)
+		cf.Methods = append(cf.Methods, info)
+	}
+
+	cf.Attributes = nil
+	if b.source != "" {
+		w := new(ClassFileWriter)
+		_ = w.WriteU2(b.pool.Utf8(b.source))
+		cf.Attributes = append(cf.Attributes, AttributeInfo{AttributeNameIndex: b.pool.Utf8("SourceFile"), Info: w.Bytes()})
+	}
+	cf.Attributes = append(cf.Attributes, b.attributes...)
+
+	var err error
+	cf.ConstantPool, err = b.pool.Entries()
+	if err != nil {
+		return ClassFile{}, err
+	}
+
+	if b.stackMapTables != nil && cf.MajorVersion >= 50 {
+		for i, info := range cf.Methods {
+			if info.Code == nil {
+				continue
+			}
+			stackMapTable, err := b.stackMapTables(&cf, info, b.pool.Class)
+			if err != nil {
+				return ClassFile{}, fmt.Errorf("method %s%s: %w", b.methods[i].name, b.methods[i].descriptor, err)
+			}
+			if stackMapTable != nil {
+				code := *info.Code
+				code.Attributes = append(append([]AttributeInfo{}, code.Attributes...),
+					AttributeInfo{AttributeNameIndex: b.pool.Utf8("StackMapTable"), Info: stackMapTable})
+				cf.Methods[i].Code = &code
+			}
+		}
+		cf.ConstantPool, err = b.pool.Entries()
+		if err != nil {
+			return ClassFile{}, err
+		}
+	}
+	cf.ConstantPoolCount = len(cf.ConstantPool) + 1
+	cf.InterfacesCount = len(cf.Interfaces)
+	cf.FieldsCount = len(cf.Fields)
+	cf.MethodsCount = len(cf.Methods)
+	cf.AttributesCount = len(cf.Attributes)
+	return cf, nil
+}
+
+// Bytes returns the class in the .class file format.
+func (b *ClassBuilder) Bytes() ([]byte, error) {
+	cf, err := b.ClassFile()
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	err = Write(&buf, cf)
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Build returns the class like Parse returns it for the written class file, so it can be verified and run.
+func (b *ClassBuilder) Build() (ClassFile, error) {
+	content, err := b.Bytes()
+	if err != nil {
+		return ClassFile{}, err
+	}
+	return ParseBytes(content)
+}
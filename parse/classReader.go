@@ -0,0 +1,634 @@
+package parse
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// ClassReader walks the content of a class file and drives a ClassVisitor, without building a ClassFile for the
+// whole class.
+//
+// Only the header and the constant pool are read up front. Unlike Parse the rest of the class isn't validated,
+// invalid references are reported by Accept when they are visited.
+type ClassReader struct {
+	cf      ClassFile // header and resolved constant pool
+	content []byte
+	offset  int // of access_flags
+}
+
+// NewClassReader reads the header and the constant pool of the class file content.
+func NewClassReader(content []byte) (*ClassReader, error) {
+	r := &ClassReader{content: content}
+	reader := (*ClassFileReader)(bytes.NewReader(content))
+
+	var err error
+	r.cf.Magic, err = reader.ReadU4()
+	if err != nil {
+		return nil, atPath("magic", err)
+	}
+	if r.cf.Magic != 0xCAFEBABE {
+		return nil, &FormatError{Offset: 0, Path: "magic", Err: errors.New("incorrect magic")}
+	}
+
+	r.cf.MinorVersion, err = reader.ReadU2()
+	if err != nil {
+		return nil, atPath("minor_version", err)
+	}
+
+	r.cf.MajorVersion, err = reader.ReadU2()
+	if err != nil {
+		return nil, atPath("major_version", err)
+	}
+
+	r.cf.ConstantPoolCount, r.cf.ConstantPool, err = reader.ReadConstantPool()
+	if err != nil {
+		return nil, err
+	}
+	r.offset = reader.position()
+
+	err = r.cf.resolveIndexes()
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// ConstantPool returns the constant pool of the class. A ClassWriter created for the reader starts with it.
+func (r *ClassReader) ConstantPool() []CpInfo {
+	return r.cf.ConstantPool
+}
+
+// Accept walks the class and calls the methods of v for its parts.
+func (r *ClassReader) Accept(v ClassVisitor) error {
+	reader := (*ClassFileReader)(bytes.NewReader(r.content))
+	_, err := reader.Seek(int64(r.offset), io.SeekStart)
+	if err != nil {
+		return err
+	}
+
+	accessFlags, err := reader.ReadU2()
+	if err != nil {
+		return atPath("access_flags", err)
+	}
+
+	thisClass, err := reader.ReadU2()
+	if err != nil {
+		return atPath("this_class", err)
+	}
+	name, err := r.cf.className(thisClass)
+	if err != nil {
+		return atPath("this_class", err)
+	}
+
+	superClass, err := reader.ReadU2()
+	if err != nil {
+		return atPath("super_class", err)
+	}
+	superName := ""
+	if superClass != 0 {
+		superName, err = r.cf.className(superClass)
+		if err != nil {
+			return atPath("super_class", err)
+		}
+	}
+
+	interfacesCount, err := reader.ReadU2()
+	if err != nil {
+		return atPath("interfaces_count", err)
+	}
+	var interfaces []string
+	for i := 0; i < interfacesCount; i++ {
+		path := fmt.Sprintf("interfaces[%d]", i)
+		index, err := reader.ReadU2()
+		if err != nil {
+			return atPath(path, err)
+		}
+		face, err := r.cf.className(index)
+		if err != nil {
+			return atPath(path, err)
+		}
+		interfaces = append(interfaces, face)
+	}
+
+	v.VisitClass(r.cf.MajorVersion, r.cf.MinorVersion, ClassAccessFlags(accessFlags), name, superName, interfaces)
+
+	fieldsCount, err := reader.ReadU2()
+	if err != nil {
+		return atPath("fields_count", err)
+	}
+	for i := 0; i < fieldsCount; i++ {
+		info, err := reader.ReadFieldInfo()
+		if err == nil {
+			err = r.acceptField(v, info)
+		}
+		if err != nil {
+			return atPath(fmt.Sprintf("fields[%d]", i), err)
+		}
+	}
+
+	methodsCount, err := reader.ReadU2()
+	if err != nil {
+		return atPath("methods_count", err)
+	}
+	for i := 0; i < methodsCount; i++ {
+		info, err := reader.ReadMethodInfo()
+		if err == nil {
+			err = r.acceptMethod(v, info)
+		}
+		if err != nil {
+			return atPath(fmt.Sprintf("methods[%d]", i), err)
+		}
+	}
+
+	_, attributes, err := reader.ReadAttributes()
+	if err != nil {
+		return err
+	}
+	for i, attribute := range attributes {
+		name, err := r.cf.attributeName(attribute)
+		if err != nil {
+			return atPath(fmt.Sprintf("attributes[%d].attribute_name_index", i), err)
+		}
+		switch name {
+		case "":
+		case "SourceFile":
+			file, err := r.cf.signatureText(attribute) // a Signature attribute has the same structure
+			if err != nil {
+				return atPath("attributes[SourceFile]", err)
+			}
+			v.VisitSource(file)
+		default:
+			v.VisitAttribute(name, attribute.Info)
+		}
+	}
+
+	if reader.Len() != 0 {
+		return &FormatError{Offset: reader.position(), Err: errors.New("couldn't fully read the .class file")}
+	}
+
+	v.VisitEnd()
+	return nil
+}
+
+func (r *ClassReader) acceptField(v ClassVisitor, info FieldInfo) error {
+	name, err := r.cf.utf8Text(info.NameIndex)
+	if err != nil {
+		return atPath("name_index", err)
+	}
+	descriptor, err := r.cf.utf8Text(info.DescriptorIndex)
+	if err != nil {
+		return atPath("descriptor_index", err)
+	}
+
+	fv := v.VisitField(info.AccessFlags, name, descriptor)
+	if fv == nil {
+		return nil
+	}
+	for i, attribute := range info.Attributes {
+		name, err := r.cf.attributeName(attribute)
+		if err != nil {
+			return atPath(fmt.Sprintf("attributes[%d].attribute_name_index", i), err)
+		}
+		if name != "" {
+			fv.VisitAttribute(name, attribute.Info)
+		}
+	}
+	fv.VisitEnd()
+	return nil
+}
+
+func (r *ClassReader) acceptMethod(v ClassVisitor, info MethodInfo) error {
+	name, err := r.cf.utf8Text(info.NameIndex)
+	if err != nil {
+		return atPath("name_index", err)
+	}
+	descriptor, err := r.cf.utf8Text(info.DescriptorIndex)
+	if err != nil {
+		return atPath("descriptor_index", err)
+	}
+
+	names := make([]string, len(info.Attributes))
+	var exceptions []string
+	for i, attribute := range info.Attributes {
+		names[i], err = r.cf.attributeName(attribute)
+		if err != nil {
+			return atPath(fmt.Sprintf("attributes[%d].attribute_name_index", i), err)
+		}
+		if names[i] == "Exceptions" {
+			exceptions, err = r.exceptions(attribute.Info)
+			if err != nil {
+				return atPath("attributes[Exceptions]", err)
+			}
+		}
+	}
+
+	mv := v.VisitMethod(info.AccessFlags, name, descriptor, exceptions)
+	if mv == nil {
+		return nil
+	}
+	for i, attribute := range info.Attributes {
+		switch names[i] {
+		case "", "Code", "Exceptions":
+		default:
+			mv.VisitAttribute(names[i], attribute.Info)
+		}
+	}
+	for i, attribute := range info.Attributes {
+		if names[i] != "Code" {
+			continue
+		}
+		code, err := r.cf.decodeCode(attribute)
+		if err == nil {
+			err = r.acceptCode(mv, code)
+		}
+		if err != nil {
+			return atPath("attributes[Code]", err)
+		}
+	}
+	mv.VisitEnd()
+	return nil
+}
+
+// exceptions returns the class names of an "Exceptions" attribute.
+func (r *ClassReader) exceptions(info []byte) ([]string, error) {
+	reader := (*ClassFileReader)(bytes.NewReader(info))
+	count, err := reader.ReadU2()
+	if err != nil {
+		return nil, err
+	}
+	var res []string
+	for i := 0; i < count; i++ {
+		index, err := reader.ReadU2()
+		if err != nil {
+			return nil, err
+		}
+		class, err := r.cf.className(index)
+		if err != nil {
+			return nil, atPath(fmt.Sprintf("exception_index_table[%d]", i), err)
+		}
+		res = append(res, class)
+	}
+	return res, nil
+}
+
+// ------------------- Code ---------------------
+
+// rawInstruction is a decoded instruction in the general form described at MethodVisitor.
+type rawInstruction struct {
+	opcode    byte
+	operand   int     // local variable index, constant pool index, bipush or sipush value or atype
+	increment int     // iinc increment or multianewarray dimensions
+	targets   []int   // pcs of branch targets, for switches the default target comes first
+	keys      []int32 // keys of lookupswitch, low of tableswitch
+}
+
+// decodeInstruction decodes the instruction at pc and returns the pc of the next one.
+func decodeInstruction(code []byte, pc int) (ins rawInstruction, next int, err error) {
+	need := func(n int) error {
+		if pc+n > len(code) {
+			return fmt.Errorf("pc %d: instruction is truncated", pc)
+		}
+		return nil
+	}
+	u1 := func(at int) int { return int(code[at]) }
+	u2 := func(at int) int { return int(code[at])<<8 | int(code[at+1]) }
+	s4 := func(at int) int { return int(int32(uint32(u2(at))<<16 | uint32(u2(at+2)))) }
+
+	op := code[pc]
+	ins.opcode = op
+	length := 1
+	switch {
+	case isNoOperandOpcode(op):
+	case op == 0x10: // bipush
+		length = 2
+	case op == 0x11: // sipush
+		length = 3
+	case op == 0x12: // ldc
+		length = 2
+	case op == 0x13, op == 0x14: // ldc_w, ldc2_w
+		length = 3
+	case op >= 0x15 && op <= 0x19, op >= 0x36 && op <= 0x3a, op == 0xa9: // loads, stores, ret
+		length = 2
+	case op >= 0x1a && op <= 0x2d: // <t>load_<n>
+		ins.opcode, ins.operand = 0x15+(op-0x1a)/4, int(op-0x1a)%4
+		return ins, pc + 1, nil
+	case op >= 0x3b && op <= 0x4e: // <t>store_<n>
+		ins.opcode, ins.operand = 0x36+(op-0x3b)/4, int(op-0x3b)%4
+		return ins, pc + 1, nil
+	case op == 0x84: // iinc
+		length = 3
+	case op >= 0x99 && op <= 0xa8, op == 0xc6, op == 0xc7: // branches
+		length = 3
+	case op == 0xc8, op == 0xc9: // goto_w, jsr_w
+		length = 5
+	case op >= 0xb2 && op <= 0xb8, op == 0xbb, op == 0xbd, op == 0xc0, op == 0xc1:
+		length = 3
+	case op == 0xb9, op == 0xba: // invokeinterface, invokedynamic
+		length = 5
+	case op == 0xbc: // newarray
+		length = 2
+	case op == 0xc5: // multianewarray
+		length = 4
+	case op == 0xaa, op == 0xab: // tableswitch, lookupswitch
+		return decodeSwitch(code, pc)
+	case op == 0xc4: // wide
+		if err = need(4); err != nil {
+			return
+		}
+		ins.opcode, ins.operand = code[pc+1], u2(pc+2)
+		switch {
+		case ins.opcode == 0x84:
+			if err = need(6); err != nil {
+				return
+			}
+			ins.increment = int(int16(u2(pc + 4)))
+			return ins, pc + 6, nil
+		case ins.opcode >= 0x15 && ins.opcode <= 0x19, ins.opcode >= 0x36 && ins.opcode <= 0x3a, ins.opcode == 0xa9:
+			return ins, pc + 4, nil
+		}
+		return ins, 0, fmt.Errorf("pc %d: invalid opcode %d after wide", pc, ins.opcode)
+	default:
+		return ins, 0, fmt.Errorf("pc %d: invalid opcode %d", pc, op)
+	}
+	if err = need(length); err != nil {
+		return
+	}
+
+	switch {
+	case op == 0x10:
+		ins.operand = int(int8(code[pc+1]))
+	case op == 0x11:
+		ins.operand = int(int16(u2(pc + 1)))
+	case op == 0x12, op == 0xbc, op >= 0x15 && op <= 0x19, op >= 0x36 && op <= 0x3a, op == 0xa9:
+		ins.operand = u1(pc + 1)
+	case op == 0x84:
+		ins.operand, ins.increment = u1(pc+1), int(int8(code[pc+2]))
+	case op >= 0x99 && op <= 0xa8, op == 0xc6, op == 0xc7:
+		ins.targets = []int{pc + int(int16(u2(pc+1)))}
+	case op == 0xc8, op == 0xc9:
+		ins.opcode = op - 0xc8 + 0xa7 // goto, jsr
+		ins.targets = []int{pc + s4(pc+1)}
+	case op == 0xc5:
+		ins.operand, ins.increment = u2(pc+1), u1(pc+3)
+	case length >= 3:
+		ins.operand = u2(pc + 1)
+	}
+	return ins, pc + length, nil
+}
+
+// decodeSwitch decodes the tableswitch or lookupswitch at pc.
+func decodeSwitch(code []byte, pc int) (ins rawInstruction, next int, err error) {
+	ins.opcode = code[pc]
+	at := pc + 1 + (4-(pc+1)%4)%4
+	s4 := func() int {
+		v := int(int32(uint32(code[at])<<24 | uint32(code[at+1])<<16 | uint32(code[at+2])<<8 | uint32(code[at+3])))
+		at += 4
+		return v
+	}
+	truncated := fmt.Errorf("pc %d: switch is truncated", pc)
+
+	if at+8 > len(code) {
+		return ins, 0, truncated
+	}
+	ins.targets = []int{pc + s4()}
+	if ins.opcode == 0xaa {
+		if at+8 > len(code) {
+			return ins, 0, truncated
+		}
+		low, high := s4(), s4()
+		if high < low || (high-low+1)*4 > len(code)-at {
+			return ins, 0, truncated
+		}
+		ins.keys = []int32{int32(low)}
+		for k := low; k <= high; k++ {
+			ins.targets = append(ins.targets, pc+s4())
+		}
+		return ins, at, nil
+	}
+
+	pairs := s4()
+	if pairs < 0 || pairs*8 > len(code)-at {
+		return ins, 0, truncated
+	}
+	for k := 0; k < pairs; k++ {
+		ins.keys = append(ins.keys, int32(s4()))
+		ins.targets = append(ins.targets, pc+s4())
+	}
+	return ins, at, nil
+}
+
+func isNoOperandOpcode(op byte) bool {
+	_, ok := stackEffects[op]
+	return ok
+}
+
+// acceptCode visits the instructions and the attributes of code.
+func (r *ClassReader) acceptCode(mv MethodVisitor, code *CodeAttribute) error {
+	labels := map[int]*Label{}
+	label := func(pc int) *Label {
+		if labels[pc] == nil {
+			labels[pc] = NewLabel()
+		}
+		return labels[pc]
+	}
+
+	starts := map[int]bool{len(code.Code): true}
+	for pc := 0; pc < len(code.Code); {
+		ins, next, err := decodeInstruction(code.Code, pc)
+		if err != nil {
+			return err
+		}
+		for _, target := range ins.targets {
+			label(target)
+		}
+		starts[pc] = true
+		pc = next
+	}
+	for _, entry := range code.ExceptionTable {
+		label(entry.StartPc)
+		label(entry.EndPc)
+		label(entry.HandlerPc)
+	}
+	lines := map[int][]int{}
+	for _, entry := range code.LineNumberTable {
+		label(entry.StartPc)
+		lines[entry.StartPc] = append(lines[entry.StartPc], entry.LineNumber)
+	}
+	for _, table := range [][]LocalVariableTableEntry{code.LocalVariableTable, code.LocalVariableTypeTable} {
+		for _, entry := range table {
+			label(entry.StartPc)
+			label(entry.StartPc + entry.Length)
+		}
+	}
+	for pc := range labels {
+		if !starts[pc] {
+			return fmt.Errorf("pc %d is referenced but isn't the start of an instruction", pc)
+		}
+	}
+
+	mv.VisitCode()
+	for _, entry := range code.ExceptionTable {
+		class := ""
+		if entry.CatchType != 0 {
+			var err error
+			class, err = r.cf.className(entry.CatchType)
+			if err != nil {
+				return err
+			}
+		}
+		mv.VisitTryCatchBlock(labels[entry.StartPc], labels[entry.EndPc], labels[entry.HandlerPc], class)
+	}
+
+	for pc := 0; pc < len(code.Code); {
+		if l := labels[pc]; l != nil {
+			mv.VisitLabel(l)
+			for _, line := range lines[pc] {
+				mv.VisitLineNumber(line, l)
+			}
+		}
+		ins, next, _ := decodeInstruction(code.Code, pc)
+		err := r.visitInstruction(mv, ins, labels)
+		if err != nil {
+			return fmt.Errorf("pc %d: %w", pc, err)
+		}
+		pc = next
+	}
+	if l := labels[len(code.Code)]; l != nil {
+		mv.VisitLabel(l)
+	}
+
+	for _, entry := range code.LocalVariableTable {
+		mv.VisitLocalVariable(entry.Name, entry.Descriptor, labels[entry.StartPc], labels[entry.StartPc+entry.Length], entry.Index)
+	}
+	for _, entry := range code.LocalVariableTypeTable {
+		mv.VisitLocalVariableType(entry.Name, entry.Descriptor, labels[entry.StartPc], labels[entry.StartPc+entry.Length], entry.Index)
+	}
+	for i, attribute := range code.Attributes {
+		name, err := r.cf.attributeName(attribute)
+		if err != nil {
+			return atPath(fmt.Sprintf("attributes[%d].attribute_name_index", i), err)
+		}
+		switch name {
+		case "", "LineNumberTable", "LocalVariableTable", "LocalVariableTypeTable", "StackMapTable":
+		default:
+			mv.VisitCodeAttribute(name, attribute.Info)
+		}
+	}
+	mv.VisitMaxs(code.MaxStack, code.MaxLocals)
+	return nil
+}
+
+// visitInstruction calls the method of mv for ins.
+func (r *ClassReader) visitInstruction(mv MethodVisitor, ins rawInstruction, labels map[int]*Label) error {
+	op := ins.opcode
+	switch {
+	case isNoOperandOpcode(op):
+		mv.VisitInsn(op)
+	case op == 0x10, op == 0x11, op == 0xbc: // bipush, sipush, newarray
+		mv.VisitIntInsn(op, ins.operand)
+	case op >= 0x12 && op <= 0x14: // ldc, ldc_w, ldc2_w
+		constant, err := r.cf.entry(ins.operand)
+		if err != nil {
+			return err
+		}
+		switch (*constant).(type) {
+		case ConstantIntegerInfo, ConstantFloatInfo, ConstantLongInfo, ConstantDoubleInfo, ConstantStringInfo,
+			ConstantClassInfo, ConstantMethodHandleInfo, ConstantMethodTypeInfo, ConstantDynamicInfo:
+		default:
+			return fmt.Errorf("constant pool entry %d can't be loaded by ldc", ins.operand)
+		}
+		mv.VisitLdcInsn(*constant)
+	case op >= 0x15 && op <= 0x19, op >= 0x36 && op <= 0x3a, op == 0xa9: // loads, stores, ret
+		mv.VisitVarInsn(op, ins.operand)
+	case op == 0x84: // iinc
+		mv.VisitIincInsn(ins.operand, ins.increment)
+	case op >= 0x99 && op <= 0xa8, op == 0xc6, op == 0xc7: // branches
+		mv.VisitJumpInsn(op, labels[ins.targets[0]])
+	case op == 0xaa, op == 0xab: // tableswitch, lookupswitch
+		targets := make([]*Label, len(ins.targets)-1)
+		for i, target := range ins.targets[1:] {
+			targets[i] = labels[target]
+		}
+		if op == 0xaa {
+			mv.VisitTableSwitchInsn(ins.keys[0], labels[ins.targets[0]], targets)
+		} else {
+			mv.VisitLookupSwitchInsn(labels[ins.targets[0]], ins.keys, targets)
+		}
+	case op >= 0xb2 && op <= 0xb9: // field and method instructions
+		class, name, descriptor, err := r.memberRef(ins.operand)
+		if err != nil {
+			return err
+		}
+		if op <= 0xb5 {
+			mv.VisitFieldInsn(op, class, name, descriptor)
+		} else {
+			mv.VisitMethodInsn(op, class, name, descriptor)
+		}
+	case op == 0xba: // invokedynamic
+		info, err := r.cf.entryOf(ins.operand, ConstantInvokeDynamicInfo{})
+		if err != nil {
+			return err
+		}
+		indy := (*info).(ConstantInvokeDynamicInfo)
+		name, descriptor, err := r.nameAndType(indy.NameAndTypeIndex)
+		if err != nil {
+			return err
+		}
+		mv.VisitInvokeDynamicInsn(indy.BootstrapMethodAttrIndex, name, descriptor)
+	case op == 0xbb, op == 0xbd, op == 0xc0, op == 0xc1: // new, anewarray, checkcast, instanceof
+		class, err := r.cf.className(ins.operand)
+		if err != nil {
+			return err
+		}
+		mv.VisitTypeInsn(op, class)
+	case op == 0xc5: // multianewarray
+		class, err := r.cf.className(ins.operand)
+		if err != nil {
+			return err
+		}
+		mv.VisitMultiANewArrayInsn(class, ins.increment)
+	}
+	return nil
+}
+
+// memberRef returns the class, name and descriptor of the field or method reference at index.
+func (r *ClassReader) memberRef(index int) (class, name, descriptor string, err error) {
+	info, err := r.cf.entry(index)
+	if err != nil {
+		return
+	}
+	var classIndex, nameAndTypeIndex int
+	switch t := (*info).(type) {
+	case ConstantFieldrefInfo:
+		classIndex, nameAndTypeIndex = t.ClassIndex, t.NameAndTypeIndex
+	case ConstantMethodrefInfo:
+		classIndex, nameAndTypeIndex = t.ClassIndex, t.NameAndTypeIndex
+	case ConstantInterfaceMethodrefInfo:
+		classIndex, nameAndTypeIndex = t.ClassIndex, t.NameAndTypeIndex
+	default:
+		return "", "", "", fmt.Errorf("constant pool entry %d isn't a field or method reference", index)
+	}
+	class, err = r.cf.className(classIndex)
+	if err != nil {
+		return
+	}
+	name, descriptor, err = r.nameAndType(nameAndTypeIndex)
+	return
+}
+
+// nameAndType returns the name and descriptor of the ConstantNameAndTypeInfo at index.
+func (r *ClassReader) nameAndType(index int) (name, descriptor string, err error) {
+	info, err := r.cf.entryOf(index, ConstantNameAndTypeInfo{})
+	if err != nil {
+		return
+	}
+	nameAndType := (*info).(ConstantNameAndTypeInfo)
+	name, err = r.cf.utf8Text(nameAndType.NameIndex)
+	if err != nil {
+		return
+	}
+	descriptor, err = r.cf.utf8Text(nameAndType.DescriptorIndex)
+	return
+}
@@ -0,0 +1,261 @@
+package parse
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ClassWriter is the ClassVisitor at the end of a chain that writes the visited class with a ClassBuilder:
+//
+//	reader, err := parse.NewClassReader(content)
+//	...
+//	writer := parse.NewClassWriter(reader).StackMapTables(verify.StackMapTable)
+//	err = reader.Accept(myAdapter{parse.ClassAdapter{Next: writer}})
+//	...
+//	content, err = writer.Bytes()
+//
+// A writer created for a ClassReader starts with its constant pool, so attributes that are copied as raw content
+// stay valid. The code is assembled again, so max_stack and max_locals are recomputed and StackMapTables are
+// dropped unless the writer computes new ones. The other attributes of the code are kept, see
+// MethodVisitor.VisitCodeAttribute.
+type ClassWriter struct {
+	pool           *ConstantPoolBuilder
+	builder        *ClassBuilder
+	method         *methodWriter // visited last
+	stackMapTables StackMapTableFunc
+}
+
+// NewClassWriter returns a writer that shares the constant pool of reader, which may be nil to start with an empty
+// pool.
+func NewClassWriter(reader *ClassReader) *ClassWriter {
+	var entries []CpInfo
+	if reader != nil {
+		entries = reader.ConstantPool()
+	}
+	return &ClassWriter{pool: NewConstantPoolBuilder(entries)}
+}
+
+// StackMapTables sets the function that computes the StackMapTables of the methods, see
+// ClassBuilder.StackMapTables.
+func (w *ClassWriter) StackMapTables(compute StackMapTableFunc) *ClassWriter {
+	w.stackMapTables = compute
+	return w
+}
+
+func (w *ClassWriter) VisitClass(majorVersion, minorVersion int, accessFlags ClassAccessFlags, name, superName string, interfaces []string) {
+	w.builder = newClassBuilder(w.pool, name).
+		Version(majorVersion, minorVersion).
+		Access(accessFlags).
+		Implements(interfaces...).
+		StackMapTables(w.stackMapTables)
+	if superName == "" {
+		w.builder.cf.SuperClass = 0
+	} else {
+		w.builder.Super(superName)
+	}
+}
+
+func (w *ClassWriter) VisitField(accessFlags FieldAccessFlags, name, descriptor string) FieldVisitor {
+	w.builder.Field(accessFlags, name, descriptor)
+	return fieldWriter{builder: w.builder, index: len(w.builder.cf.Fields) - 1}
+}
+
+func (w *ClassWriter) VisitMethod(accessFlags MethodAccessFlags, name, descriptor string, exceptions []string) MethodVisitor {
+	w.method = &methodWriter{method: w.builder.Method(accessFlags, name, descriptor).Throws(exceptions...)}
+	return w.method
+}
+
+func (w *ClassWriter) VisitSource(file string) {
+	w.builder.Source(file)
+}
+
+func (w *ClassWriter) VisitAttribute(name string, info []byte) {
+	w.builder.Attribute(name, info)
+}
+
+func (w *ClassWriter) VisitEnd() {}
+
+// Pool returns the constant pool the class is written with. Constants for VisitLdcInsn and the entries that raw
+// attributes refer to have to be added to it.
+func (w *ClassWriter) Pool() *ConstantPoolBuilder {
+	return w.pool
+}
+
+// Err returns the first error in what was visited so far, like the stack underflow of an instruction, or nil.
+// ClassFile, Bytes and Build return it as well, Err tells which call caused it while a method is visited.
+func (w *ClassWriter) Err() error {
+	if w.builder == nil {
+		return nil
+	}
+	if w.builder.err != nil {
+		return w.builder.err
+	}
+	if m := w.method; m != nil && m.code != nil && m.code.err != nil {
+		return fmt.Errorf("method %s%s: %w", m.method.name, m.method.descriptor, m.code.err)
+	}
+	return nil
+}
+
+// ClassFile returns the written class, see ClassBuilder.ClassFile.
+func (w *ClassWriter) ClassFile() (ClassFile, error) {
+	if w.builder == nil {
+		return ClassFile{}, errors.New("no class was visited")
+	}
+	return w.builder.ClassFile()
+}
+
+// Bytes returns the written class in the .class file format.
+func (w *ClassWriter) Bytes() ([]byte, error) {
+	if w.builder == nil {
+		return nil, errors.New("no class was visited")
+	}
+	return w.builder.Bytes()
+}
+
+// Build returns the written class like Parse returns it.
+func (w *ClassWriter) Build() (ClassFile, error) {
+	if w.builder == nil {
+		return ClassFile{}, errors.New("no class was visited")
+	}
+	return w.builder.Build()
+}
+
+// fieldWriter adds the attributes of the field at index.
+type fieldWriter struct {
+	builder *ClassBuilder
+	index   int
+}
+
+func (w fieldWriter) VisitAttribute(name string, info []byte) {
+	field := &w.builder.cf.Fields[w.index]
+	field.Attributes = append(field.Attributes, AttributeInfo{AttributeNameIndex: w.builder.pool.Utf8(name), Info: info})
+}
+
+func (w fieldWriter) VisitEnd() {}
+
+// methodWriter adds the visited code of a method with a CodeBuilder.
+type methodWriter struct {
+	method *MethodBuilder
+	code   *CodeBuilder
+}
+
+func (w *methodWriter) VisitAttribute(name string, info []byte) {
+	w.method.Attribute(name, info)
+}
+
+func (w *methodWriter) VisitCode() {
+	w.code = w.method.newCode()
+}
+
+func (w *methodWriter) VisitTryCatchBlock(start, end, handler *Label, class string) {
+	w.code.TryCatch(start, end, handler, class)
+}
+
+func (w *methodWriter) VisitLabel(label *Label) {
+	w.code.Mark(label)
+}
+
+func (w *methodWriter) VisitLineNumber(line int, start *Label) {
+	pc := start.pc
+	if pc < 0 {
+		pc = len(w.code.code)
+	}
+	w.code.lines = append(w.code.lines, LineNumberTableEntry{StartPc: pc, LineNumber: line})
+}
+
+func (w *methodWriter) VisitInsn(opcode byte) {
+	w.code.Insn(opcode)
+}
+
+func (w *methodWriter) VisitIntInsn(opcode byte, operand int) {
+	if opcode == 0xbc { // newarray
+		w.code.newArray(byte(operand))
+		return
+	}
+	w.code.IConst(int32(operand))
+}
+
+func (w *methodWriter) VisitVarInsn(opcode byte, index int) {
+	switch opcode {
+	case 0xa9: // ret
+		w.code.ret(index)
+	case 0x16, 0x18, 0x37, 0x39: // lload, dload, lstore, dstore
+		w.code.loadStore(opcode, index, 2)
+	default:
+		w.code.loadStore(opcode, index, 1)
+	}
+}
+
+func (w *methodWriter) VisitTypeInsn(opcode byte, class string) {
+	if opcode == 0xbb { // new
+		w.code.classInsn(opcode, class, 0, 1)
+		return
+	}
+	w.code.classInsn(opcode, class, 1, 1)
+}
+
+func (w *methodWriter) VisitFieldInsn(opcode byte, class, name, descriptor string) {
+	w.code.field(opcode, class, name, descriptor)
+}
+
+func (w *methodWriter) VisitMethodInsn(opcode byte, class, name, descriptor string) {
+	w.code.invoke(opcode, class, name, descriptor)
+}
+
+func (w *methodWriter) VisitInvokeDynamicInsn(bootstrapMethod int, name, descriptor string) {
+	w.code.InvokeDynamic(bootstrapMethod, name, descriptor)
+}
+
+func (w *methodWriter) VisitJumpInsn(opcode byte, target *Label) {
+	switch {
+	case opcode == 0xa8: // jsr
+		w.code.jsr(target)
+	case opcode == 0xa7: // goto
+		w.code.jump(opcode, 0, target)
+	case opcode >= 0x9f && opcode <= 0xa6: // if_icmp<cond>, if_acmp<cond>
+		w.code.jump(opcode, 2, target)
+	default:
+		w.code.jump(opcode, 1, target)
+	}
+}
+
+func (w *methodWriter) VisitLdcInsn(constant CpInfo) {
+	w.code.Ldc(constant)
+}
+
+func (w *methodWriter) VisitIincInsn(index int, increment int) {
+	w.code.IInc(index, increment)
+}
+
+func (w *methodWriter) VisitTableSwitchInsn(low int32, defaultTarget *Label, targets []*Label) {
+	w.code.TableSwitch(low, defaultTarget, targets...)
+}
+
+func (w *methodWriter) VisitLookupSwitchInsn(defaultTarget *Label, keys []int32, targets []*Label) {
+	w.code.LookupSwitch(defaultTarget, keys, targets)
+}
+
+func (w *methodWriter) VisitMultiANewArrayInsn(descriptor string, dimensions int) {
+	w.code.MultiANewArray(descriptor, dimensions)
+}
+
+func (w *methodWriter) VisitLocalVariable(name, descriptor string, start, end *Label, index int) {
+	w.code.LocalVariable(name, descriptor, start, end, index)
+}
+
+func (w *methodWriter) VisitLocalVariableType(name, signature string, start, end *Label, index int) {
+	w.code.LocalVariableType(name, signature, start, end, index)
+}
+
+func (w *methodWriter) VisitCodeAttribute(name string, info []byte) {
+	w.code.Attribute(name, info)
+}
+
+// VisitMaxs ignores the limits, the CodeBuilder computes them.
+func (w *methodWriter) VisitMaxs(maxStack, maxLocals int) {}
+
+func (w *methodWriter) VisitEnd() {
+	if w.code != nil {
+		w.method.setCode(w.code)
+	}
+}
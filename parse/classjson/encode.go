@@ -0,0 +1,672 @@
+package classjson
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+
+	"github.com/PPTide/gojdk/parse"
+	"github.com/PPTide/gojdk/parse/disasm"
+)
+
+// Marshal returns the compact JSON encoding of cf.
+func Marshal(cf *parse.ClassFile) ([]byte, error) {
+	var b bytes.Buffer
+	if err := encode(&b, cf, ""); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(b.Bytes(), []byte("\n")), nil
+}
+
+// Encode writes the JSON encoding of cf to w, indented for humans to read.
+func Encode(w io.Writer, cf *parse.ClassFile) error {
+	return encode(w, cf, "  ")
+}
+
+func encode(w io.Writer, cf *parse.ClassFile, indent string) error {
+	class, err := New(cf)
+	if err != nil {
+		return err
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetEscapeHTML(false) // keep names like <init> readable
+	encoder.SetIndent("", indent)
+	return encoder.Encode(class)
+}
+
+// New converts cf into the schema. cf has to be returned by the parse package, so its names are resolved.
+func New(cf *parse.ClassFile) (*Class, error) {
+	e := encoder{cf: cf}
+	class := &Class{
+		Schema:       SchemaVersion,
+		MinorVersion: cf.MinorVersion,
+		MajorVersion: cf.MajorVersion,
+		AccessFlags:  int(cf.AccessFlags),
+		Flags:        cf.AccessFlags.Names(),
+		Name:         e.className(cf.ThisClass),
+		SuperClass:   e.className(cf.SuperClass),
+		Interfaces:   make([]string, 0, len(cf.Interfaces)),
+		ConstantPool: make([]Constant, 0, len(cf.ConstantPool)),
+		Fields:       make([]Field, 0, len(cf.Fields)),
+		Methods:      make([]Method, 0, len(cf.Methods)),
+
+		EnclosingMethod:     e.enclosingMethod(cf.EnclosingMethod),
+		NestHost:            cf.NestHost,
+		NestMembers:         cf.NestMembers,
+		PermittedSubclasses: cf.PermittedSubclasses,
+		Module:              e.module(cf.Module),
+		Annotations:         e.annotations(cf.Annotations),
+		Attributes:          e.attributes(cf.Attributes),
+	}
+	if cf.Signature != nil {
+		class.Signature = cf.Signature.String()
+	}
+
+	for _, index := range cf.Interfaces {
+		class.Interfaces = append(class.Interfaces, e.className(index))
+	}
+	for i := 0; i < len(cf.ConstantPool); i++ {
+		class.ConstantPool = append(class.ConstantPool, e.constant(i+1))
+		switch cf.ConstantPool[i].(type) {
+		case parse.ConstantLongInfo, parse.ConstantDoubleInfo:
+			i++ // the second index isn't usable
+		}
+	}
+
+	for _, field := range cf.Fields {
+		class.Fields = append(class.Fields, e.field(field))
+	}
+	for _, method := range cf.Methods {
+		m, err := e.method(method)
+		if err != nil {
+			return nil, fmt.Errorf("method %s%s: %w", m.Name, m.Descriptor, err)
+		}
+		class.Methods = append(class.Methods, m)
+	}
+
+	for _, entry := range cf.InnerClasses {
+		class.InnerClasses = append(class.InnerClasses, InnerClass{
+			InnerClass:  entry.InnerClass,
+			OuterClass:  entry.OuterClass,
+			InnerName:   entry.InnerName,
+			AccessFlags: entry.InnerClassAccessFlags,
+			Flags:       flagNames(entry.InnerClassAccessFlags, innerClassFlags),
+		})
+	}
+	for _, component := range cf.Record {
+		c := RecordComponent{
+			Name:        component.Name,
+			Descriptor:  component.Descriptor,
+			Annotations: e.annotations(component.Annotations),
+			Attributes:  e.attributes(component.Attributes),
+		}
+		if component.Signature != nil {
+			c.Signature = component.Signature.String()
+		}
+		class.Record = append(class.Record, c)
+	}
+
+	var err error
+	for _, attribute := range cf.Attributes {
+		r := reader(attribute)
+		switch e.utf8(attribute.AttributeNameIndex) {
+		case "SourceFile":
+			var index int
+			index, err = r.ReadU2()
+			class.SourceFile = e.utf8(index)
+		case "BootstrapMethods":
+			class.BootstrapMethods, err = e.bootstrapMethods(r)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("attribute %s: %w", e.utf8(attribute.AttributeNameIndex), err)
+		}
+	}
+
+	return class, nil
+}
+
+type encoder struct {
+	cf *parse.ClassFile
+}
+
+// reader returns a reader for the content of attribute.
+func reader(attribute parse.AttributeInfo) *parse.ClassFileReader {
+	return (*parse.ClassFileReader)(bytes.NewReader(attribute.Info))
+}
+
+// decodedAttributes are the attributes whose content is part of the schema, so Attribute.Data is omitted for them.
+var decodedAttributes = map[string]bool{
+	"Code":                                 true,
+	"ConstantValue":                        true,
+	"Signature":                            true,
+	"SourceFile":                           true,
+	"Exceptions":                           true,
+	"LineNumberTable":                      true,
+	"LocalVariableTable":                   true,
+	"LocalVariableTypeTable":               true,
+	"InnerClasses":                         true,
+	"EnclosingMethod":                      true,
+	"NestHost":                             true,
+	"NestMembers":                          true,
+	"PermittedSubclasses":                  true,
+	"Record":                               true,
+	"BootstrapMethods":                     true,
+	"MethodParameters":                     true,
+	"Module":                               true,
+	"ModulePackages":                       true,
+	"ModuleMainClass":                      true,
+	"RuntimeVisibleAnnotations":            true,
+	"RuntimeInvisibleAnnotations":          true,
+	"RuntimeVisibleParameterAnnotations":   true,
+	"RuntimeInvisibleParameterAnnotations": true,
+	"RuntimeVisibleTypeAnnotations":        true,
+	"RuntimeInvisibleTypeAnnotations":      true,
+	"AnnotationDefault":                    true,
+}
+
+func (e *encoder) attributes(attributes []parse.AttributeInfo) []Attribute {
+	res := make([]Attribute, 0, len(attributes))
+	for _, attribute := range attributes {
+		a := Attribute{Name: e.utf8(attribute.AttributeNameIndex), Length: len(attribute.Info)}
+		if !decodedAttributes[a.Name] {
+			a.Data = attribute.Info
+		}
+		res = append(res, a)
+	}
+	return res
+}
+
+func (e *encoder) field(field parse.FieldInfo) Field {
+	f := Field{
+		AccessFlags: int(field.AccessFlags),
+		Flags:       field.AccessFlags.Names(),
+		Name:        field.Name,
+		Descriptor:  field.Descriptor,
+		Annotations: e.annotations(field.Annotations),
+		Attributes:  e.attributes(field.Attributes),
+	}
+	if field.Signature != nil {
+		f.Signature = field.Signature.String()
+	}
+	if field.ConstantValue != nil {
+		f.ConstantValue = e.constantValue(field.ConstantValue)
+	}
+	return f
+}
+
+func (e *encoder) method(method parse.MethodInfo) (m Method, err error) {
+	m = Method{
+		AccessFlags:                   int(method.AccessFlags),
+		Flags:                         method.AccessFlags.Names(),
+		Name:                          e.utf8(method.NameIndex),
+		Descriptor:                    e.utf8(method.DescriptorIndex),
+		VisibleParameterAnnotations:   e.parameterAnnotations(method.VisibleParameterAnnotations),
+		InvisibleParameterAnnotations: e.parameterAnnotations(method.InvisibleParameterAnnotations),
+		Annotations:                   e.annotations(method.Annotations),
+		Attributes:                    e.attributes(method.Attributes),
+	}
+	if method.Signature != nil {
+		m.Signature = method.Signature.String()
+	}
+	if method.AnnotationDefault != nil {
+		value := e.elementValue(*method.AnnotationDefault)
+		m.AnnotationDefault = &value
+	}
+
+	for _, attribute := range method.Attributes {
+		r := reader(attribute)
+		switch e.utf8(attribute.AttributeNameIndex) {
+		case "Exceptions":
+			var indexes []int
+			indexes, err = r.ReadClassList()
+			for _, index := range indexes {
+				m.Exceptions = append(m.Exceptions, e.className(index))
+			}
+		case "MethodParameters":
+			m.Parameters, err = e.parameters(r)
+		}
+		if err != nil {
+			return
+		}
+	}
+
+	if method.Code != nil {
+		m.Code, err = e.code(method.Code)
+	}
+	return
+}
+
+func (e *encoder) parameters(r *parse.ClassFileReader) (parameters []Parameter, err error) {
+	count, err := r.ReadByte()
+	if err != nil {
+		return
+	}
+
+	for i := 0; i < int(count); i++ {
+		var nameIndex int
+		nameIndex, err = r.ReadU2()
+		if err != nil {
+			return
+		}
+		parameter := Parameter{Name: e.utf8(nameIndex)}
+		parameter.AccessFlags, err = r.ReadU2()
+		if err != nil {
+			return
+		}
+		parameters = append(parameters, parameter)
+	}
+	return
+}
+
+func (e *encoder) bootstrapMethods(r *parse.ClassFileReader) (methods []BootstrapMethod, err error) {
+	count, err := r.ReadU2()
+	if err != nil {
+		return
+	}
+
+	for i := 0; i < count; i++ {
+		var ref int
+		ref, err = r.ReadU2()
+		if err != nil {
+			return
+		}
+		var arguments int
+		arguments, err = r.ReadU2()
+		if err != nil {
+			return
+		}
+
+		method := BootstrapMethod{Method: e.constant(ref), Arguments: make([]Constant, 0)}
+		for k := 0; k < arguments; k++ {
+			var argument int
+			argument, err = r.ReadU2()
+			if err != nil {
+				return
+			}
+			method.Arguments = append(method.Arguments, e.constant(argument))
+		}
+		methods = append(methods, method)
+	}
+	return
+}
+
+// ------------------- Code ---------------------
+
+func (e *encoder) code(code *parse.CodeAttribute) (*Code, error) {
+	instructions, err := disasm.Decode(code.Code, e.cf)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &Code{
+		MaxStack:       code.MaxStack,
+		MaxLocals:      code.MaxLocals,
+		Length:         len(code.Code),
+		Instructions:   make([]Instruction, 0, len(instructions)),
+		ExceptionTable: make([]ExceptionHandler, 0, len(code.ExceptionTable)),
+		Attributes:     e.attributes(code.Attributes),
+	}
+	for _, instruction := range instructions {
+		c.Instructions = append(c.Instructions, e.instruction(instruction))
+	}
+	for _, entry := range code.ExceptionTable {
+		c.ExceptionTable = append(c.ExceptionTable, ExceptionHandler{
+			StartPc:   entry.StartPc,
+			EndPc:     entry.EndPc,
+			HandlerPc: entry.HandlerPc,
+			CatchType: e.className(entry.CatchType),
+		})
+	}
+	for _, entry := range code.LineNumberTable {
+		c.LineNumbers = append(c.LineNumbers, LineNumber{StartPc: entry.StartPc, Line: entry.LineNumber})
+	}
+	for _, entry := range code.LocalVariableTable {
+		variable := LocalVariable{
+			StartPc:    entry.StartPc,
+			Length:     entry.Length,
+			Slot:       entry.Index,
+			Name:       entry.Name,
+			Descriptor: entry.Descriptor,
+		}
+		if typed, ok := code.LocalVariableType(entry.Index, entry.StartPc); ok {
+			variable.Signature = typed.Descriptor
+		}
+		c.LocalVariables = append(c.LocalVariables, variable)
+	}
+	return c, nil
+}
+
+func (e *encoder) instruction(instruction disasm.Instruction) Instruction {
+	res := Instruction{
+		Pc:     instruction.Pc,
+		Opcode: instruction.Mnemonic(),
+		Wide:   instruction.Wide,
+	}
+
+	op := instruction.Opcode
+	switch {
+	case op >= disasm.Iload && op <= disasm.Aload, op >= disasm.Istore && op <= disasm.Astore, op == disasm.Ret:
+		res.Local = intPtr(instruction.Local)
+	case op == disasm.Iinc:
+		res.Local = intPtr(instruction.Local)
+		res.Value = intPtr(instruction.Immediate)
+	case op == disasm.Bipush, op == disasm.Sipush, op == disasm.Newarray, op == disasm.Invokeinterface,
+		op == disasm.Multianewarray:
+		res.Value = intPtr(instruction.Immediate)
+	case op >= disasm.Ifeq && op <= disasm.Jsr, op >= disasm.Ifnull && op <= disasm.JsrW:
+		res.Target = intPtr(instruction.Target)
+	}
+
+	if instruction.Index != 0 {
+		constant := e.constant(instruction.Index)
+		res.Constant = &constant
+	}
+	if s := instruction.Switch; s != nil {
+		res.Switch = &Switch{Default: s.Default, Keys: s.Keys, Targets: s.Targets}
+	}
+	return res
+}
+
+func intPtr(i int) *int {
+	return &i
+}
+
+// ------------------- Constant pool ---------------------
+
+// constant converts the constant pool entry at index.
+func (e *encoder) constant(index int) Constant {
+	c := Constant{Index: index}
+	switch t := e.entry(index).(type) {
+	case parse.ConstantUtf8Info:
+		c.Kind, c.Value = "Utf8", t.Text
+	case parse.ConstantIntegerInfo, parse.ConstantFloatInfo, parse.ConstantLongInfo, parse.ConstantDoubleInfo:
+		c.Kind, c.Value = kindOf(t), e.constantValue(t)
+	case parse.ConstantStringInfo:
+		c.Kind, c.Value = "String", e.utf8(t.StringIndex)
+	case parse.ConstantClassInfo:
+		c.Kind, c.Name = "Class", e.utf8(t.NameIndex)
+	case parse.ConstantNameAndTypeInfo:
+		c.Kind = "NameAndType"
+		c.Name, c.Descriptor = e.nameAndType(index)
+	case parse.ConstantFieldrefInfo:
+		c.Kind, c.Class = "Fieldref", e.className(t.ClassIndex)
+		c.Name, c.Descriptor = e.nameAndType(t.NameAndTypeIndex)
+	case parse.ConstantMethodrefInfo:
+		c.Kind, c.Class = "Methodref", e.className(t.ClassIndex)
+		c.Name, c.Descriptor = e.nameAndType(t.NameAndTypeIndex)
+	case parse.ConstantInterfaceMethodrefInfo:
+		c.Kind, c.Class = "InterfaceMethodref", e.className(t.ClassIndex)
+		c.Name, c.Descriptor = e.nameAndType(t.NameAndTypeIndex)
+	case parse.ConstantMethodHandleInfo:
+		reference := e.constant(t.ReferenceIndex)
+		c.Kind, c.ReferenceKind = "MethodHandle", disasm.ReferenceKind(t.ReferenceKind)
+		c.Class, c.Name, c.Descriptor = reference.Class, reference.Name, reference.Descriptor
+	case parse.ConstantMethodTypeInfo:
+		c.Kind, c.Descriptor = "MethodType", e.utf8(t.DescriptorIndex)
+	case parse.ConstantDynamicInfo:
+		c.Kind, c.BootstrapMethod = "Dynamic", intPtr(t.BootstrapMethodAttrIndex)
+		c.Name, c.Descriptor = e.nameAndType(t.NameAndTypeIndex)
+	case parse.ConstantInvokeDynamicInfo:
+		c.Kind, c.BootstrapMethod = "InvokeDynamic", intPtr(t.BootstrapMethodAttrIndex)
+		c.Name, c.Descriptor = e.nameAndType(t.NameAndTypeIndex)
+	case parse.ConstantModuleInfo:
+		c.Kind, c.Name = "Module", e.utf8(t.NameIndex)
+	case parse.ConstantPackageInfo:
+		c.Kind, c.Name = "Package", e.utf8(t.NameIndex)
+	}
+	return c
+}
+
+func kindOf(info parse.CpInfo) string {
+	switch info.(type) {
+	case parse.ConstantIntegerInfo:
+		return "Integer"
+	case parse.ConstantFloatInfo:
+		return "Float"
+	case parse.ConstantLongInfo:
+		return "Long"
+	case parse.ConstantDoubleInfo:
+		return "Double"
+	}
+	return ""
+}
+
+// constantValue returns the value of a numeric or string constant in the encoding of Constant.Value.
+func (e *encoder) constantValue(info parse.CpInfo) any {
+	switch t := info.(type) {
+	case parse.ConstantIntegerInfo:
+		return t.Integer
+	case parse.ConstantFloatInfo:
+		return floatValue(float64(t.Float), 32)
+	case parse.ConstantLongInfo:
+		return strconv.FormatInt(t.Long, 10)
+	case parse.ConstantDoubleInfo:
+		return floatValue(t.Double, 64)
+	case parse.ConstantUtf8Info:
+		return t.Text
+	case parse.ConstantStringInfo:
+		return e.utf8(t.StringIndex)
+	}
+	return nil
+}
+
+// floatValue encodes f with the shortest representation that reads back as the same float of bitSize bits.
+func floatValue(f float64, bitSize int) any {
+	switch {
+	case math.IsNaN(f):
+		return "NaN"
+	case math.IsInf(f, 1):
+		return "Infinity"
+	case math.IsInf(f, -1):
+		return "-Infinity"
+	}
+	return json.Number(strconv.FormatFloat(f, 'g', -1, bitSize))
+}
+
+// entry returns the constant pool entry at index, or nil if there is none.
+func (e *encoder) entry(index int) parse.CpInfo {
+	if index < 1 || index > len(e.cf.ConstantPool) {
+		return nil
+	}
+	return e.cf.ConstantPool[index-1]
+}
+
+// utf8 returns the text of the ConstantUtf8Info at index, or "" if there is none.
+func (e *encoder) utf8(index int) string {
+	utf8, _ := e.entry(index).(parse.ConstantUtf8Info)
+	return utf8.Text
+}
+
+// className returns the name of the ConstantClassInfo at index, or "" if there is none.
+func (e *encoder) className(index int) string {
+	class, _ := e.entry(index).(parse.ConstantClassInfo)
+	return e.utf8(class.NameIndex)
+}
+
+// nameAndType returns the name and descriptor of the ConstantNameAndTypeInfo at index.
+func (e *encoder) nameAndType(index int) (name string, descriptor string) {
+	nat, _ := e.entry(index).(parse.ConstantNameAndTypeInfo)
+	return e.utf8(nat.NameIndex), e.utf8(nat.DescriptorIndex)
+}
+
+// ------------------- Class attributes ---------------------
+
+func (e *encoder) enclosingMethod(method *parse.EnclosingMethod) *EnclosingMethod {
+	if method == nil {
+		return nil
+	}
+	return &EnclosingMethod{Class: method.Class, Name: method.MethodName, Descriptor: method.MethodDescriptor}
+}
+
+func (e *encoder) module(m *parse.ModuleDescriptor) *Module {
+	if m == nil {
+		return nil
+	}
+
+	res := &Module{
+		Name:      m.Name,
+		Flags:     m.ModuleFlags,
+		Version:   m.Version,
+		Requires:  make([]ModuleRequires, 0, len(m.Requires)),
+		Exports:   exports(m.Exports),
+		Opens:     exports(m.Opens),
+		Uses:      append(make([]string, 0, len(m.Uses)), m.Uses...),
+		Provides:  make([]ModuleProvides, 0, len(m.Provides)),
+		Packages:  m.Packages,
+		MainClass: m.MainClass,
+	}
+	for _, requires := range m.Requires {
+		res.Requires = append(res.Requires, ModuleRequires{
+			Module:  requires.Module,
+			Flags:   requires.RequiresFlags,
+			Version: requires.Version,
+		})
+	}
+	for _, provides := range m.Provides {
+		res.Provides = append(res.Provides, ModuleProvides{Service: provides.Service, With: provides.With})
+	}
+	return res
+}
+
+func exports(entries []parse.ModuleExports) []ModuleExports {
+	res := make([]ModuleExports, 0, len(entries))
+	for _, entry := range entries {
+		res = append(res, ModuleExports{Package: entry.Package, Flags: entry.Flags, To: entry.To})
+	}
+	return res
+}
+
+// ------------------- Annotations ---------------------
+
+func (e *encoder) annotations(a parse.Annotations) Annotations {
+	return Annotations{
+		VisibleAnnotations:       e.annotationList(a.VisibleAnnotations),
+		InvisibleAnnotations:     e.annotationList(a.InvisibleAnnotations),
+		VisibleTypeAnnotations:   e.typeAnnotations(a.VisibleTypeAnnotations),
+		InvisibleTypeAnnotations: e.typeAnnotations(a.InvisibleTypeAnnotations),
+	}
+}
+
+func (e *encoder) annotationList(annotations []parse.Annotation) []Annotation {
+	var res []Annotation
+	for _, annotation := range annotations {
+		res = append(res, e.annotation(annotation))
+	}
+	return res
+}
+
+func (e *encoder) parameterAnnotations(parameters [][]parse.Annotation) [][]Annotation {
+	var res [][]Annotation
+	for _, annotations := range parameters {
+		list := e.annotationList(annotations)
+		if list == nil {
+			list = []Annotation{} // parameters without annotations are encoded as []
+		}
+		res = append(res, list)
+	}
+	return res
+}
+
+func (e *encoder) annotation(annotation parse.Annotation) Annotation {
+	res := Annotation{Type: annotation.Type, Elements: make([]Element, 0, len(annotation.ElementValuePairs))}
+	for _, pair := range annotation.ElementValuePairs {
+		res.Elements = append(res.Elements, Element{Name: pair.ElementName, Value: e.elementValue(pair.Value)})
+	}
+	return res
+}
+
+func (e *encoder) elementValue(value parse.ElementValue) ElementValue {
+	res := ElementValue{Tag: string(value.Tag)}
+	switch value.Tag {
+	case 'Z':
+		integer, _ := value.Const.(parse.ConstantIntegerInfo)
+		res.Value = integer.Integer != 0
+	case 'C':
+		integer, _ := value.Const.(parse.ConstantIntegerInfo)
+		res.Value = string(rune(integer.Integer))
+	case 'B', 'D', 'F', 'I', 'J', 'S', 's':
+		res.Value = e.constantValue(value.Const)
+	case 'e':
+		res.EnumType, res.EnumConstant = value.TypeName, value.ConstName
+	case 'c':
+		res.Class = value.ClassInfo
+	case '@':
+		if value.AnnotationValue != nil {
+			annotation := e.annotation(*value.AnnotationValue)
+			res.Annotation = &annotation
+		}
+	case '[':
+		res.Values = make([]ElementValue, 0, len(value.Values))
+		for _, v := range value.Values {
+			res.Values = append(res.Values, e.elementValue(v))
+		}
+	}
+	return res
+}
+
+func (e *encoder) typeAnnotations(annotations []parse.TypeAnnotation) []TypeAnnotation {
+	var res []TypeAnnotation
+	for _, annotation := range annotations {
+		a := TypeAnnotation{TargetType: annotation.TargetType, Annotation: e.annotation(annotation.Annotation)}
+		switch t := annotation.TargetType; {
+		case t == 0x00, t == 0x01:
+			a.TypeParameter = intPtr(annotation.TypeParameterIndex)
+		case t == 0x10:
+			a.Supertype = intPtr(annotation.SupertypeIndex)
+		case t == 0x11, t == 0x12:
+			a.TypeParameter = intPtr(annotation.TypeParameterIndex)
+			a.Bound = intPtr(annotation.BoundIndex)
+		case t == 0x16:
+			a.FormalParameter = intPtr(annotation.FormalParameterIndex)
+		case t == 0x17:
+			a.ThrowsType = intPtr(annotation.ThrowsTypeIndex)
+		case t == 0x40, t == 0x41:
+			for _, target := range annotation.LocalVariableTargets {
+				a.LocalVariables = append(a.LocalVariables, LocalVariableTarget{
+					StartPc: target.StartPc,
+					Length:  target.Length,
+					Slot:    target.Index,
+				})
+			}
+		case t == 0x42:
+			a.ExceptionTableIndex = intPtr(annotation.ExceptionTableIndex)
+		case t >= 0x43 && t <= 0x46:
+			a.Offset = intPtr(annotation.Offset)
+		case t >= 0x47 && t <= 0x4B:
+			a.Offset = intPtr(annotation.Offset)
+			a.TypeArgument = intPtr(annotation.TypeArgumentIndex)
+		}
+		for _, entry := range annotation.TypePath {
+			a.TypePath = append(a.TypePath, TypePathEntry{Kind: entry.TypePathKind, TypeArgument: entry.TypeArgumentIndex})
+		}
+		res = append(res, a)
+	}
+	return res
+}
+
+// ------------------- Flags ---------------------
+
+type flag struct {
+	mask int
+	name string
+}
+
+// innerClassFlags are the flags of InnerClasses entries, the flags of classes, fields and methods have their own
+// types in parse.
+var innerClassFlags = []flag{{0x0001, "ACC_PUBLIC"}, {0x0002, "ACC_PRIVATE"}, {0x0004, "ACC_PROTECTED"},
+	{0x0008, "ACC_STATIC"}, {0x0010, "ACC_FINAL"}, {0x0200, "ACC_INTERFACE"}, {0x0400, "ACC_ABSTRACT"},
+	{0x1000, "ACC_SYNTHETIC"}, {0x2000, "ACC_ANNOTATION"}, {0x4000, "ACC_ENUM"}}
+
+// flagNames returns the names of the flags set in flags, never nil so it's encoded as [].
+func flagNames(flags int, names []flag) []string {
+	res := make([]string, 0)
+	for _, f := range names {
+		if flags&f.mask != 0 {
+			res = append(res, f.name)
+		}
+	}
+	return res
+}
@@ -0,0 +1,244 @@
+package classjson
+
+import (
+	"bytes"
+	"encoding/json"
+	goflag "flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/PPTide/gojdk/parse"
+	"github.com/PPTide/gojdk/parse/jasmin"
+)
+
+var update = goflag.Bool("update", false, "rewrite the golden files in testdata")
+
+// constantsSource is a class with long and double constants, which take two constant pool indexes, and NaN and
+// infinite float and double constants, which aren't JSON numbers.
+const constantsSource = `
+.class public final Constants
+.field public static final BIG J = 9007199254740993
+.field public static final MIN J = -9223372036854775808
+.field public static final PI D = 3.141592653589793
+.field public static final NAN F = NaN
+.field public static final INF F = Infinity
+.field public static final NEG_INF D = -Infinity
+.field public static final TEXT Ljava/lang/String; = "line\n"
+
+.method public static nan()D
+    ldc2_w NaN
+    dreturn
+.end method
+`
+
+// attributesSource is a sealed class with a nest, a switch, an exception handler and debug information. The class
+// attributes the assembler can't write are added by withClassAttributes.
+const attributesSource = `
+.bytecode 61.0
+.source Shape.java
+.class public abstract Shape
+.implements java/lang/Comparable
+.nestmembers Shape$Circle
+.permittedsubclasses Shape$Circle
+.field protected final name Ljava/lang/String;
+
+.method public static parse(Ljava/lang/String;)I
+    .throws java/io/IOException
+    .catch java/lang/NumberFormatException from Start to End using Handler
+    .var 0 is s Ljava/lang/String; from Start to Handler
+    .line 10
+Start:
+    aload_0
+    invokestatic java/lang/Integer/parseInt(Ljava/lang/String;)I
+    tableswitch 0
+        Zero
+        One
+        default : Other
+Zero:
+    .line 11
+    iconst_1
+    ireturn
+One:
+    iconst_2
+    ireturn
+Other:
+    iconst_0
+End:
+    ireturn
+Handler:
+    .line 13
+    pop
+    iconst_m1
+    ireturn
+.end method
+`
+
+// u2 returns v as the two bytes of a u2.
+func u2(v int) []byte {
+	return []byte{byte(v >> 8), byte(v)}
+}
+
+// withClassAttributes adds a Signature, an InnerClasses, a RuntimeVisibleAnnotations and an unknown attribute to the
+// class file content.
+func withClassAttributes(content []byte) (parse.ClassFile, error) {
+	reader, err := parse.NewClassReader(content)
+	if err != nil {
+		return parse.ClassFile{}, err
+	}
+	writer := parse.NewClassWriter(reader)
+	if err := reader.Accept(writer); err != nil {
+		return parse.ClassFile{}, err
+	}
+
+	p := writer.Pool()
+	writer.VisitAttribute("Signature", u2(p.Utf8("Ljava/lang/Object;Ljava/lang/Comparable<LShape;>;")))
+	var innerClasses []byte
+	innerClasses = append(innerClasses, u2(1)...)
+	innerClasses = append(innerClasses, u2(p.Class("Shape$Circle"))...)
+	innerClasses = append(innerClasses, u2(p.Class("Shape"))...)
+	innerClasses = append(innerClasses, u2(p.Utf8("Circle"))...)
+	innerClasses = append(innerClasses, u2(0x0018)...) // static final
+	writer.VisitAttribute("InnerClasses", innerClasses)
+	var annotations []byte
+	annotations = append(annotations, u2(1)...)
+	annotations = append(annotations, u2(p.Utf8("Ljava/lang/Deprecated;"))...)
+	annotations = append(annotations, u2(1)...)
+	annotations = append(annotations, u2(p.Utf8("since"))...)
+	annotations = append(annotations, 's')
+	annotations = append(annotations, u2(p.Utf8("17"))...)
+	writer.VisitAttribute("RuntimeVisibleAnnotations", annotations)
+	writer.VisitAttribute("Custom", []byte{1, 2, 3})
+	return writer.Build()
+}
+
+// goldenClass returns the class the golden file testdata/<name>.json describes.
+func goldenClass(t *testing.T, name string) parse.ClassFile {
+	var cf parse.ClassFile
+	var err error
+	switch name {
+	case "Hello", "module-info":
+		cf, err = parse.Parse(filepath.Join("..", "testdata", name+".class"))
+	case "Constants", "Shape":
+		src := constantsSource
+		if name == "Shape" {
+			src = attributesSource
+		}
+		var content []byte
+		content, err = jasmin.Assemble([]byte(src))
+		if err != nil {
+			t.Fatalf("assembling %s failed: %v", name, err)
+		}
+		if name == "Shape" {
+			cf, err = withClassAttributes(content)
+		} else {
+			cf, err = parse.ParseBytes(content)
+		}
+	}
+	if err != nil {
+		t.Fatalf("building %s failed: %v", name, err)
+	}
+	return cf
+}
+
+// firstDifference describes the first line got and want differ in.
+func firstDifference(got, want string) string {
+	gotLines, wantLines := strings.Split(got, "\n"), strings.Split(want, "\n")
+	for i := 0; i < len(gotLines) && i < len(wantLines); i++ {
+		if gotLines[i] != wantLines[i] {
+			return fmt.Sprintf("line %d is\n\t%s\nwant\n\t%s", i+1, gotLines[i], wantLines[i])
+		}
+	}
+	return fmt.Sprintf("got %d lines, want %d", len(gotLines), len(wantLines))
+}
+
+func TestMarshal(t *testing.T) {
+	for _, name := range []string{"Hello", "module-info", "Constants", "Shape"} {
+		t.Run(name, func(t *testing.T) {
+			cf := goldenClass(t, name)
+			var encoded bytes.Buffer
+			if err := Encode(&encoded, &cf); err != nil {
+				t.Fatalf("Encode failed: %v", err)
+			}
+
+			golden := filepath.Join("testdata", name+".json")
+			if *update {
+				if err := os.WriteFile(golden, encoded.Bytes(), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if encoded.String() != string(want) {
+				t.Errorf("Encode output differs from %s, %s", golden, firstDifference(encoded.String(), string(want)))
+			}
+
+			// Marshal is the same JSON without indentation
+			compact, err := Marshal(&cf)
+			if err != nil {
+				t.Fatalf("Marshal failed: %v", err)
+			}
+			var indented bytes.Buffer
+			if err := json.Indent(&indented, compact, "", "  "); err != nil {
+				t.Fatalf("Marshal returned invalid JSON: %v", err)
+			}
+			indented.WriteByte('\n')
+			if indented.String() != string(want) {
+				t.Errorf("Marshal output differs from %s, %s", golden, firstDifference(indented.String(), string(want)))
+			}
+		})
+	}
+}
+
+func TestMarshalConstants(t *testing.T) {
+	cf := goldenClass(t, "Constants")
+	content, err := Marshal(&cf)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	var class struct {
+		ConstantPool []struct {
+			Index int    `json:"index"`
+			Kind  string `json:"kind"`
+			Value any    `json:"value"`
+		} `json:"constant_pool"`
+		Fields []struct {
+			Name          string `json:"name"`
+			ConstantValue any    `json:"constant_value"`
+		} `json:"fields"`
+	}
+	if err := json.Unmarshal(content, &class); err != nil {
+		t.Fatalf("Marshal returned invalid JSON: %v", err)
+	}
+
+	want := map[string]any{
+		"BIG":     "9007199254740993",
+		"MIN":     "-9223372036854775808",
+		"PI":      3.141592653589793,
+		"NAN":     "NaN",
+		"INF":     "Infinity",
+		"NEG_INF": "-Infinity",
+		"TEXT":    "line\n",
+	}
+	for _, field := range class.Fields {
+		if field.ConstantValue != want[field.Name] {
+			t.Errorf("field %s has the constant value %#v, want %#v", field.Name, field.ConstantValue, want[field.Name])
+		}
+	}
+
+	// the second index of long and double constants isn't listed
+	for i, constant := range class.ConstantPool {
+		if i+1 == len(class.ConstantPool) {
+			break
+		}
+		step := class.ConstantPool[i+1].Index - constant.Index
+		wide := constant.Kind == "Long" || constant.Kind == "Double"
+		if wide && step != 2 || !wide && step != 1 {
+			t.Errorf("%s constant #%d is followed by #%d", constant.Kind, constant.Index, class.ConstantPool[i+1].Index)
+		}
+	}
+}
@@ -0,0 +1,300 @@
+// Package classjson encodes parsed class files as JSON for tools that want to read class structure without linking
+// Go code.
+//
+// The schema is described by the types of this package, their json tags are the keys of the output. All names are
+// resolved, so no consumer has to follow constant pool indexes, but the indexes are kept where they are part of the
+// class file format, e.g. in the operands of instructions. Keys of optional parts are omitted if the class file
+// doesn't have them.
+//
+// Numbers are encoded as JSON numbers except for values that don't survive a float64 round trip: long constants are
+// decimal strings, and NaN and infinite float and double constants are the strings "NaN", "Infinity" and
+// "-Infinity".
+//
+// The schema only changes in backwards compatible ways, by adding keys, as long as SchemaVersion stays the same.
+package classjson
+
+// SchemaVersion is the version of the schema, it's the "schema" key of every encoded class.
+const SchemaVersion = 1
+
+// Class is the top level object of the schema.
+type Class struct {
+	Schema       int      `json:"schema"` // always SchemaVersion
+	MinorVersion int      `json:"minor_version"`
+	MajorVersion int      `json:"major_version"`
+	AccessFlags  int      `json:"access_flags"`
+	Flags        []string `json:"flags"` // names of the set access flags, e.g. "ACC_PUBLIC"
+	Name         string   `json:"name"`  // binary name, e.g. "java/lang/String"
+	SuperClass   string   `json:"super_class,omitempty"`
+	Interfaces   []string `json:"interfaces"`
+	Signature    string   `json:"signature,omitempty"`
+	SourceFile   string   `json:"source_file,omitempty"`
+
+	ConstantPool []Constant `json:"constant_pool"`
+	Fields       []Field    `json:"fields"`
+	Methods      []Method   `json:"methods"`
+
+	InnerClasses        []InnerClass      `json:"inner_classes,omitempty"`
+	EnclosingMethod     *EnclosingMethod  `json:"enclosing_method,omitempty"`
+	NestHost            string            `json:"nest_host,omitempty"`
+	NestMembers         []string          `json:"nest_members,omitempty"`
+	PermittedSubclasses []string          `json:"permitted_subclasses,omitempty"`
+	Record              []RecordComponent `json:"record,omitempty"`
+	BootstrapMethods    []BootstrapMethod `json:"bootstrap_methods,omitempty"`
+	Module              *Module           `json:"module,omitempty"`
+	Annotations
+	Attributes []Attribute `json:"attributes"`
+}
+
+// Constant is one constant pool entry. Long and double constants take two indexes, the second one isn't listed.
+//
+// Kind is the name of the constant type without "CONSTANT_" and decides which keys are used:
+//   - Utf8, String: value
+//   - Integer, Float, Long, Double: value
+//   - Class: name
+//   - NameAndType: name, descriptor
+//   - Fieldref, Methodref, InterfaceMethodref: class, name, descriptor
+//   - MethodHandle: reference_kind, class, name, descriptor
+//   - MethodType: descriptor
+//   - Dynamic, InvokeDynamic: bootstrap_method, name, descriptor
+//   - Module, Package: name
+type Constant struct {
+	Index           int    `json:"index"`
+	Kind            string `json:"kind"`
+	Value           any    `json:"value,omitempty"`
+	Class           string `json:"class,omitempty"`
+	Name            string `json:"name,omitempty"`
+	Descriptor      string `json:"descriptor,omitempty"`
+	ReferenceKind   string `json:"reference_kind,omitempty"`   // e.g. "REF_invokeStatic"
+	BootstrapMethod *int   `json:"bootstrap_method,omitempty"` // index into bootstrap_methods
+}
+
+// Field is one field of the class.
+type Field struct {
+	AccessFlags   int      `json:"access_flags"`
+	Flags         []string `json:"flags"`
+	Name          string   `json:"name"`
+	Descriptor    string   `json:"descriptor"`
+	Signature     string   `json:"signature,omitempty"`
+	ConstantValue any      `json:"constant_value,omitempty"` // encoded like Constant.Value
+	Annotations
+	Attributes []Attribute `json:"attributes"`
+}
+
+// Method is one method of the class.
+type Method struct {
+	AccessFlags                   int            `json:"access_flags"`
+	Flags                         []string       `json:"flags"`
+	Name                          string         `json:"name"`
+	Descriptor                    string         `json:"descriptor"`
+	Signature                     string         `json:"signature,omitempty"`
+	Exceptions                    []string       `json:"exceptions,omitempty"`
+	Parameters                    []Parameter    `json:"parameters,omitempty"`
+	Code                          *Code          `json:"code,omitempty"`
+	VisibleParameterAnnotations   [][]Annotation `json:"visible_parameter_annotations,omitempty"`
+	InvisibleParameterAnnotations [][]Annotation `json:"invisible_parameter_annotations,omitempty"`
+	AnnotationDefault             *ElementValue  `json:"annotation_default,omitempty"`
+	Annotations
+	Attributes []Attribute `json:"attributes"`
+}
+
+// Parameter is one entry of a "MethodParameters" attribute.
+type Parameter struct {
+	Name        string `json:"name,omitempty"`
+	AccessFlags int    `json:"access_flags"`
+}
+
+// Code is the code of a method.
+type Code struct {
+	MaxStack       int                `json:"max_stack"`
+	MaxLocals      int                `json:"max_locals"`
+	Length         int                `json:"length"` // of the code array in bytes
+	Instructions   []Instruction      `json:"instructions"`
+	ExceptionTable []ExceptionHandler `json:"exception_table"`
+	LineNumbers    []LineNumber       `json:"line_numbers,omitempty"`
+	LocalVariables []LocalVariable    `json:"local_variables,omitempty"`
+	Attributes     []Attribute        `json:"attributes"`
+}
+
+// Instruction is one disassembled instruction. Only the operands the opcode has are set.
+type Instruction struct {
+	Pc       int       `json:"pc"`
+	Opcode   string    `json:"opcode"` // mnemonic, e.g. "invokevirtual"
+	Wide     bool      `json:"wide,omitempty"`
+	Local    *int      `json:"local,omitempty"`    // local variable index of loads, stores, iinc and ret
+	Value    *int      `json:"value,omitempty"`    // bipush, sipush, iinc, newarray type, invokeinterface count, multianewarray dimensions
+	Target   *int      `json:"target,omitempty"`   // absolute pc of branches
+	Constant *Constant `json:"constant,omitempty"` // the referenced constant pool entry
+	Switch   *Switch   `json:"switch,omitempty"`
+}
+
+// Switch is the jump table of a tableswitch or lookupswitch, targets are absolute pcs.
+type Switch struct {
+	Default int   `json:"default"`
+	Keys    []int `json:"keys"`
+	Targets []int `json:"targets"`
+}
+
+// ExceptionHandler is one entry of the exception table.
+type ExceptionHandler struct {
+	StartPc   int    `json:"start_pc"`
+	EndPc     int    `json:"end_pc"`
+	HandlerPc int    `json:"handler_pc"`
+	CatchType string `json:"catch_type,omitempty"` // omitted for handlers that catch everything
+}
+
+// LineNumber maps the code starting at StartPc to a source line.
+type LineNumber struct {
+	StartPc int `json:"start_pc"`
+	Line    int `json:"line"`
+}
+
+// LocalVariable is a local variable from the "LocalVariableTable" with the signature from the
+// "LocalVariableTypeTable" if it has a generic type.
+type LocalVariable struct {
+	StartPc    int    `json:"start_pc"`
+	Length     int    `json:"length"`
+	Slot       int    `json:"slot"`
+	Name       string `json:"name"`
+	Descriptor string `json:"descriptor"`
+	Signature  string `json:"signature,omitempty"`
+}
+
+// Attribute is one attribute as stored in the class file. Data holds the content of attributes that aren't decoded
+// elsewhere in the schema, encoded as base64 like encoding/json does for byte slices.
+type Attribute struct {
+	Name   string `json:"name"`
+	Length int    `json:"length"`
+	Data   []byte `json:"data,omitempty"`
+}
+
+// InnerClass is one entry of the "InnerClasses" attribute.
+type InnerClass struct {
+	InnerClass  string   `json:"inner_class"`
+	OuterClass  string   `json:"outer_class,omitempty"`
+	InnerName   string   `json:"inner_name,omitempty"` // omitted for anonymous classes
+	AccessFlags int      `json:"access_flags"`
+	Flags       []string `json:"flags"`
+}
+
+// EnclosingMethod is the "EnclosingMethod" attribute of local and anonymous classes.
+type EnclosingMethod struct {
+	Class      string `json:"class"`
+	Name       string `json:"name,omitempty"`
+	Descriptor string `json:"descriptor,omitempty"`
+}
+
+// RecordComponent is one component of a record class.
+type RecordComponent struct {
+	Name       string `json:"name"`
+	Descriptor string `json:"descriptor"`
+	Signature  string `json:"signature,omitempty"`
+	Annotations
+	Attributes []Attribute `json:"attributes"`
+}
+
+// BootstrapMethod is one entry of the "BootstrapMethods" attribute.
+type BootstrapMethod struct {
+	Method    Constant   `json:"method"` // a MethodHandle
+	Arguments []Constant `json:"arguments"`
+}
+
+// Module is the content of the module attributes of a module-info class.
+type Module struct {
+	Name      string           `json:"name"`
+	Flags     int              `json:"flags"`
+	Version   string           `json:"version,omitempty"`
+	Requires  []ModuleRequires `json:"requires"`
+	Exports   []ModuleExports  `json:"exports"`
+	Opens     []ModuleExports  `json:"opens"`
+	Uses      []string         `json:"uses"`
+	Provides  []ModuleProvides `json:"provides"`
+	Packages  []string         `json:"packages,omitempty"`
+	MainClass string           `json:"main_class,omitempty"`
+}
+
+// ModuleRequires is a dependence on another module.
+type ModuleRequires struct {
+	Module  string `json:"module"`
+	Flags   int    `json:"flags"`
+	Version string `json:"version,omitempty"`
+}
+
+// ModuleExports is an exported or opened package, To is omitted if every module has access.
+type ModuleExports struct {
+	Package string   `json:"package"`
+	Flags   int      `json:"flags"`
+	To      []string `json:"to,omitempty"`
+}
+
+// ModuleProvides is a service implemented by the module.
+type ModuleProvides struct {
+	Service string   `json:"service"`
+	With    []string `json:"with"`
+}
+
+// Annotations are the annotations of a class, field, method or record component.
+type Annotations struct {
+	VisibleAnnotations       []Annotation     `json:"visible_annotations,omitempty"`
+	InvisibleAnnotations     []Annotation     `json:"invisible_annotations,omitempty"`
+	VisibleTypeAnnotations   []TypeAnnotation `json:"visible_type_annotations,omitempty"`
+	InvisibleTypeAnnotations []TypeAnnotation `json:"invisible_type_annotations,omitempty"`
+}
+
+// Annotation is one annotation with the elements that don't have their default value.
+type Annotation struct {
+	Type     string    `json:"type"` // field descriptor of the annotation interface
+	Elements []Element `json:"elements"`
+}
+
+// Element is one element of an annotation.
+type Element struct {
+	Name  string       `json:"name"`
+	Value ElementValue `json:"value"`
+}
+
+// ElementValue is the value of an annotation element. Tag is the tag of the class file format and decides which
+// keys are used:
+//   - B C D F I J S Z s: value, encoded like Constant.Value, with C as a one character string and Z as a boolean
+//   - e: enum_type and enum_constant
+//   - c: class, a return descriptor like "Ljava/lang/String;" or "V"
+//   - @: annotation
+//   - [: values
+type ElementValue struct {
+	Tag          string         `json:"tag"`
+	Value        any            `json:"value,omitempty"`
+	EnumType     string         `json:"enum_type,omitempty"`
+	EnumConstant string         `json:"enum_constant,omitempty"`
+	Class        string         `json:"class,omitempty"`
+	Annotation   *Annotation    `json:"annotation,omitempty"`
+	Values       []ElementValue `json:"values,omitempty"`
+}
+
+// TypeAnnotation is an annotation on a use of a type. TargetType decides which target keys are used, as listed in
+// JVMS §4.7.20.1.
+type TypeAnnotation struct {
+	TargetType          int                   `json:"target_type"`
+	TypeParameter       *int                  `json:"type_parameter,omitempty"`
+	Supertype           *int                  `json:"supertype,omitempty"` // 65535 for the superclass
+	Bound               *int                  `json:"bound,omitempty"`
+	FormalParameter     *int                  `json:"formal_parameter,omitempty"`
+	ThrowsType          *int                  `json:"throws_type,omitempty"`
+	LocalVariables      []LocalVariableTarget `json:"local_variables,omitempty"`
+	ExceptionTableIndex *int                  `json:"exception_table_index,omitempty"`
+	Offset              *int                  `json:"offset,omitempty"`
+	TypeArgument        *int                  `json:"type_argument,omitempty"`
+	TypePath            []TypePathEntry       `json:"type_path,omitempty"`
+	Annotation
+}
+
+// LocalVariableTarget is the range of code in which an annotated local variable has a value.
+type LocalVariableTarget struct {
+	StartPc int `json:"start_pc"`
+	Length  int `json:"length"`
+	Slot    int `json:"slot"`
+}
+
+// TypePathEntry is one step to the annotated part of a type.
+type TypePathEntry struct {
+	Kind         int `json:"kind"`
+	TypeArgument int `json:"type_argument"`
+}
@@ -0,0 +1,866 @@
+package parse
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// CodeBuilder adds the instructions of a method, see MethodBuilder.Code.
+//
+// max_locals and max_stack are computed while instructions are added: every instruction changes the tracked stack
+// height, branches pass it on to their target labels and code following a goto, return, throw or switch continues
+// with the height of its label. The first error, like a stack underflow, is returned by ClassBuilder.Build.
+//
+// Branches are added with 16 bit offsets. When the code is finished, branches to targets that are further away are
+// widened to goto_w and jsr_w, conditional branches by jumping over a goto_w with the opposite condition.
+type CodeBuilder struct {
+	pool                *ConstantPoolBuilder
+	code                []byte
+	stack               int
+	maxStack, maxLocals int
+	reachable           bool
+	fallsThrough        bool // if the last instruction continues with the following code
+	branches            []branch
+	handlers            []tryCatch
+	lines               []LineNumberTableEntry
+	variables           []localVariable
+	variableTypes       []localVariable // descriptor is the signature
+	attributes          []AttributeInfo
+	err                 error
+}
+
+// Label marks a position in the code, create them with NewLabel and place them with Mark.
+type Label struct {
+	pc    int // -1 until the label is marked
+	stack int // stack height at the label, -1 while unknown
+}
+
+// branch is an offset to a label that is filled in once all labels are marked.
+type branch struct {
+	pc     int // of the instruction
+	offset int // position of the offset in the code
+	wide   bool
+	target *Label
+}
+
+type tryCatch struct {
+	start, end, handler *Label
+	catchType           int
+}
+
+type localVariable struct {
+	name, descriptor string
+	start, end       *Label
+	index            int
+}
+
+func newCodeBuilder(pool *ConstantPoolBuilder, accessFlags MethodAccessFlags, descriptor string) *CodeBuilder {
+	c := &CodeBuilder{pool: pool, reachable: true}
+	if !accessFlags.IsStatic() {
+		c.maxLocals++
+	}
+	arguments, _ := c.methodSlots(descriptor)
+	c.maxLocals += arguments
+	return c
+}
+
+// methodSlots returns the number of stack slots the arguments and the result of a method descriptor take up.
+func (c *CodeBuilder) methodSlots(descriptor string) (arguments int, result int) {
+	d, err := ParseMethodDescriptor(descriptor)
+	if err != nil {
+		c.fail("%v", err)
+		return 0, 0
+	}
+	return d.ParameterSlots(), d.Return.Slots()
+}
+
+func (c *CodeBuilder) fail(format string, a ...any) {
+	if c.err == nil {
+		c.err = fmt.Errorf("pc %d: %s", len(c.code), fmt.Sprintf(format, a...))
+	}
+}
+
+// adjust changes the stack height by pushing and popping values of the instruction that is added.
+func (c *CodeBuilder) adjust(pop, push int) {
+	if !c.reachable {
+		c.fail("unreachable code, mark a label first")
+	}
+	if c.stack < pop {
+		c.fail("stack underflow")
+	}
+	c.stack += push - pop
+	if c.stack > c.maxStack {
+		c.maxStack = c.stack
+	}
+	c.fallsThrough = true
+}
+
+// local records that the instruction that is added uses the local variables from index to index+size.
+func (c *CodeBuilder) local(index, size int) {
+	if index < 0 || index > 65535 {
+		c.fail("invalid local variable index %d", index)
+	}
+	if index+size > c.maxLocals {
+		c.maxLocals = index + size
+	}
+}
+
+func (c *CodeBuilder) emit(bytes ...byte) {
+	c.code = append(c.code, bytes...)
+}
+
+func (c *CodeBuilder) emitU2(v int) {
+	c.emit(byte(v>>8), byte(v))
+}
+
+func (c *CodeBuilder) emitU4(v int) {
+	c.emit(byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+}
+
+// end marks the code after the instruction that was added as unreachable until a label is marked.
+func (c *CodeBuilder) end() {
+	c.reachable = false
+	c.fallsThrough = false
+}
+
+// ------------------- Labels ---------------------
+
+// NewLabel returns a label that isn't placed yet.
+func NewLabel() *Label {
+	return &Label{pc: -1, stack: -1}
+}
+
+// NewLabel returns a label that isn't placed yet, like the function NewLabel.
+func (c *CodeBuilder) NewLabel() *Label {
+	return NewLabel()
+}
+
+// Mark places l before the next instruction.
+func (c *CodeBuilder) Mark(l *Label) {
+	if l.pc != -1 {
+		c.fail("label is already marked")
+		return
+	}
+	l.pc = len(c.code)
+	switch {
+	case !c.reachable && l.stack >= 0:
+		c.stack = l.stack
+	case !c.reachable:
+		c.stack = 0
+	case l.stack >= 0 && l.stack != c.stack:
+		c.fail("stack height %d differs from %d at a branch to the label", c.stack, l.stack)
+	}
+	l.stack = c.stack
+	c.reachable = true
+}
+
+// jumpTo passes the stack height on to target.
+func (c *CodeBuilder) jumpTo(target *Label) {
+	switch {
+	case target.stack < 0:
+		target.stack = c.stack
+	case target.stack != c.stack:
+		c.fail("stack height %d differs from %d at the branch target", c.stack, target.stack)
+	}
+}
+
+// TryCatch adds an exception handler for the code from start to end that catches class and its subclasses, or
+// everything if class is "". Add it before marking handler, as the stack height there is 1.
+func (c *CodeBuilder) TryCatch(start, end, handler *Label, class string) {
+	catchType := 0
+	if class != "" {
+		catchType = c.pool.Class(class)
+	}
+	if handler.stack < 0 {
+		handler.stack = 1
+	}
+	c.handlers = append(c.handlers, tryCatch{start, end, handler, catchType})
+}
+
+// Line sets the source line of the following instructions.
+func (c *CodeBuilder) Line(line int) {
+	c.lines = append(c.lines, LineNumberTableEntry{StartPc: len(c.code), LineNumber: line})
+}
+
+// LocalVariable adds a LocalVariableTable entry for the variable at index from start to end.
+func (c *CodeBuilder) LocalVariable(name, descriptor string, start, end *Label, index int) {
+	c.variables = append(c.variables, localVariable{name, descriptor, start, end, index})
+}
+
+// LocalVariableType adds a LocalVariableTypeTable entry with the generic signature of the variable at index from
+// start to end.
+func (c *CodeBuilder) LocalVariableType(name, signature string, start, end *Label, index int) {
+	c.variableTypes = append(c.variableTypes, localVariable{name, signature, start, end, index})
+}
+
+// Attribute adds an attribute of the code with the raw content info.
+func (c *CodeBuilder) Attribute(name string, info []byte) {
+	c.attributes = append(c.attributes, AttributeInfo{AttributeNameIndex: c.pool.Utf8(name), Info: info})
+}
+
+// ------------------- Instructions without operands ---------------------
+
+// stackEffects are the number of values that instructions without operands pop and push, longs and doubles count
+// twice.
+var stackEffects = map[byte][2]int{
+	// nop, aconst_null
+	0x00: {0, 0}, 0x01: {0, 1},
+	// iconst_<i>
+	0x02: {0, 1}, 0x03: {0, 1}, 0x04: {0, 1}, 0x05: {0, 1}, 0x06: {0, 1}, 0x07: {0, 1}, 0x08: {0, 1},
+	// lconst_<l>
+	0x09: {0, 2}, 0x0a: {0, 2},
+	// fconst_<f>
+	0x0b: {0, 1}, 0x0c: {0, 1}, 0x0d: {0, 1},
+	// dconst_<d>
+	0x0e: {0, 2}, 0x0f: {0, 2},
+	// <t>aload
+	0x2e: {2, 1}, 0x2f: {2, 2}, 0x30: {2, 1}, 0x31: {2, 2}, 0x32: {2, 1}, 0x33: {2, 1}, 0x34: {2, 1}, 0x35: {2, 1},
+	// <t>astore
+	0x4f: {3, 0}, 0x50: {4, 0}, 0x51: {3, 0}, 0x52: {4, 0}, 0x53: {3, 0}, 0x54: {3, 0}, 0x55: {3, 0}, 0x56: {3, 0},
+	// pop, pop2, dup, dup_x1, dup_x2, dup2, dup2_x1, dup2_x2, swap
+	0x57: {1, 0}, 0x58: {2, 0}, 0x59: {1, 2}, 0x5a: {2, 3}, 0x5b: {3, 4}, 0x5c: {2, 4}, 0x5d: {3, 5}, 0x5e: {4, 6}, 0x5f: {2, 2},
+	// <t>add
+	0x60: {2, 1}, 0x61: {4, 2}, 0x62: {2, 1}, 0x63: {4, 2},
+	// <t>sub
+	0x64: {2, 1}, 0x65: {4, 2}, 0x66: {2, 1}, 0x67: {4, 2},
+	// <t>mul
+	0x68: {2, 1}, 0x69: {4, 2}, 0x6a: {2, 1}, 0x6b: {4, 2},
+	// <t>div
+	0x6c: {2, 1}, 0x6d: {4, 2}, 0x6e: {2, 1}, 0x6f: {4, 2},
+	// <t>rem
+	0x70: {2, 1}, 0x71: {4, 2}, 0x72: {2, 1}, 0x73: {4, 2},
+	// <t>neg
+	0x74: {1, 1}, 0x75: {2, 2}, 0x76: {1, 1}, 0x77: {2, 2},
+	// shifts
+	0x78: {2, 1}, 0x79: {3, 2}, 0x7a: {2, 1}, 0x7b: {3, 2}, 0x7c: {2, 1}, 0x7d: {3, 2},
+	// and, or, xor
+	0x7e: {2, 1}, 0x7f: {4, 2}, 0x80: {2, 1}, 0x81: {4, 2}, 0x82: {2, 1}, 0x83: {4, 2},
+	// i2l, i2f, i2d
+	0x85: {1, 2}, 0x86: {1, 1}, 0x87: {1, 2},
+	// l2i, l2f, l2d
+	0x88: {2, 1}, 0x89: {2, 1}, 0x8a: {2, 2},
+	// f2i, f2l, f2d
+	0x8b: {1, 1}, 0x8c: {1, 2}, 0x8d: {1, 2},
+	// d2i, d2l, d2f
+	0x8e: {2, 1}, 0x8f: {2, 2}, 0x90: {2, 1},
+	// i2b, i2c, i2s
+	0x91: {1, 1}, 0x92: {1, 1}, 0x93: {1, 1},
+	// lcmp, fcmpl, fcmpg, dcmpl, dcmpg
+	0x94: {4, 1}, 0x95: {2, 1}, 0x96: {2, 1}, 0x97: {4, 1}, 0x98: {4, 1},
+	// <t>return
+	0xac: {1, 0}, 0xad: {2, 0}, 0xae: {1, 0}, 0xaf: {2, 0}, 0xb0: {1, 0}, 0xb1: {0, 0},
+	// arraylength, athrow, monitorenter, monitorexit
+	0xbe: {1, 1}, 0xbf: {1, 0}, 0xc2: {1, 0}, 0xc3: {1, 0},
+}
+
+// Insn adds an instruction without operands, e.g. 0x60 for iadd. The loads and stores of the first four local
+// variables are added with the methods taking the index, like ILoad.
+func (c *CodeBuilder) Insn(opcode byte) {
+	effect, ok := stackEffects[opcode]
+	if !ok {
+		c.fail("opcode %d isn't an instruction without operands", opcode)
+		return
+	}
+	c.adjust(effect[0], effect[1])
+	c.emit(opcode)
+	if opcode >= 0xac && opcode <= 0xb1 || opcode == 0xbf {
+		c.end()
+	}
+}
+
+// AConstNull and the methods below add the instruction of their name.
+func (c *CodeBuilder) AConstNull()  { c.Insn(0x01) }
+func (c *CodeBuilder) IAdd()        { c.Insn(0x60) }
+func (c *CodeBuilder) ISub()        { c.Insn(0x64) }
+func (c *CodeBuilder) IMul()        { c.Insn(0x68) }
+func (c *CodeBuilder) IDiv()        { c.Insn(0x6c) }
+func (c *CodeBuilder) IRem()        { c.Insn(0x70) }
+func (c *CodeBuilder) INeg()        { c.Insn(0x74) }
+func (c *CodeBuilder) Pop()         { c.Insn(0x57) }
+func (c *CodeBuilder) Dup()         { c.Insn(0x59) }
+func (c *CodeBuilder) Swap()        { c.Insn(0x5f) }
+func (c *CodeBuilder) IReturn()     { c.Insn(0xac) }
+func (c *CodeBuilder) LReturn()     { c.Insn(0xad) }
+func (c *CodeBuilder) FReturn()     { c.Insn(0xae) }
+func (c *CodeBuilder) DReturn()     { c.Insn(0xaf) }
+func (c *CodeBuilder) AReturn()     { c.Insn(0xb0) }
+func (c *CodeBuilder) Return()      { c.Insn(0xb1) }
+func (c *CodeBuilder) ArrayLength() { c.Insn(0xbe) }
+func (c *CodeBuilder) AThrow()      { c.Insn(0xbf) }
+
+// ------------------- Constants ---------------------
+
+// IConst pushes v with the shortest instruction.
+func (c *CodeBuilder) IConst(v int32) {
+	switch {
+	case v >= -1 && v <= 5:
+		c.Insn(byte(0x03 + v)) // iconst_<i>
+	case v >= math.MinInt8 && v <= math.MaxInt8:
+		c.adjust(0, 1)
+		c.emit(0x10, byte(v)) // bipush
+	case v >= math.MinInt16 && v <= math.MaxInt16:
+		c.adjust(0, 1)
+		c.emit(0x11) // sipush
+		c.emitU2(int(v))
+	default:
+		c.ldc(c.pool.Integer(v), 1)
+	}
+}
+
+// LConst pushes v.
+func (c *CodeBuilder) LConst(v int64) {
+	if v == 0 || v == 1 {
+		c.Insn(byte(0x09 + v)) // lconst_<l>
+		return
+	}
+	c.ldc(c.pool.Long(v), 2)
+}
+
+// FConst pushes v.
+func (c *CodeBuilder) FConst(v float32) {
+	if (v == 0 && !math.Signbit(float64(v))) || v == 1 || v == 2 {
+		c.Insn(byte(0x0b + int(v))) // fconst_<f>
+		return
+	}
+	c.ldc(c.pool.Float(v), 1)
+}
+
+// DConst pushes v.
+func (c *CodeBuilder) DConst(v float64) {
+	if (v == 0 && !math.Signbit(v)) || v == 1 {
+		c.Insn(byte(0x0e + int(v))) // dconst_<d>
+		return
+	}
+	c.ldc(c.pool.Double(v), 2)
+}
+
+// LdcString pushes the string s.
+func (c *CodeBuilder) LdcString(s string) {
+	c.ldc(c.pool.String(s), 1)
+}
+
+// LdcClass pushes the java.lang.Class of the class called name.
+func (c *CodeBuilder) LdcClass(name string) {
+	c.ldc(c.pool.Class(name), 1)
+}
+
+// Ldc pushes a loadable constant of the constant pool, e.g. one a ClassReader visited when the ClassWriter shares
+// its pool. The indexes in constant have to refer to the pool of the builder.
+func (c *CodeBuilder) Ldc(constant CpInfo) {
+	switch constant.(type) {
+	case ConstantLongInfo, ConstantDoubleInfo:
+		c.ldc(c.pool.add(constant), 2)
+	case ConstantIntegerInfo, ConstantFloatInfo, ConstantStringInfo, ConstantClassInfo, ConstantMethodHandleInfo,
+		ConstantMethodTypeInfo, ConstantDynamicInfo:
+		c.ldc(c.pool.add(constant), 1)
+	default:
+		c.fail("%T can't be loaded by ldc", constant)
+	}
+}
+
+// ldc adds ldc, ldc_w or, for size 2, ldc2_w.
+func (c *CodeBuilder) ldc(index int, size int) {
+	c.adjust(0, size)
+	switch {
+	case size == 2:
+		c.emit(0x14) // ldc2_w
+		c.emitU2(index)
+	case index <= 255:
+		c.emit(0x12, byte(index)) // ldc
+	default:
+		c.emit(0x13) // ldc_w
+		c.emitU2(index)
+	}
+}
+
+// ------------------- Local variables ---------------------
+
+// loadStore adds the load or store opcode, using the short forms for the first four local variables and wide for
+// indexes above 255.
+func (c *CodeBuilder) loadStore(opcode byte, index int, size int) {
+	c.local(index, size)
+	if opcode < 0x36 { // loads
+		c.adjust(0, size)
+	} else {
+		c.adjust(size, 0)
+	}
+
+	switch {
+	case index <= 3 && opcode < 0x36:
+		c.emit(0x1a + 4*(opcode-0x15) + byte(index)) // <t>load_<n>
+	case index <= 3:
+		c.emit(0x3b + 4*(opcode-0x36) + byte(index)) // <t>store_<n>
+	case index <= 255:
+		c.emit(opcode, byte(index))
+	default:
+		c.emit(0xc4, opcode) // wide
+		c.emitU2(index)
+	}
+}
+
+// ILoad and the methods below add the load or store of their name for the local variable at index.
+func (c *CodeBuilder) ILoad(index int)  { c.loadStore(0x15, index, 1) }
+func (c *CodeBuilder) LLoad(index int)  { c.loadStore(0x16, index, 2) }
+func (c *CodeBuilder) FLoad(index int)  { c.loadStore(0x17, index, 1) }
+func (c *CodeBuilder) DLoad(index int)  { c.loadStore(0x18, index, 2) }
+func (c *CodeBuilder) ALoad(index int)  { c.loadStore(0x19, index, 1) }
+func (c *CodeBuilder) IStore(index int) { c.loadStore(0x36, index, 1) }
+func (c *CodeBuilder) LStore(index int) { c.loadStore(0x37, index, 2) }
+func (c *CodeBuilder) FStore(index int) { c.loadStore(0x38, index, 1) }
+func (c *CodeBuilder) DStore(index int) { c.loadStore(0x39, index, 2) }
+func (c *CodeBuilder) AStore(index int) { c.loadStore(0x3a, index, 1) }
+
+// IInc adds increment to the int local variable at index.
+func (c *CodeBuilder) IInc(index int, increment int) {
+	c.local(index, 1)
+	c.adjust(0, 0)
+	if index <= 255 && increment >= math.MinInt8 && increment <= math.MaxInt8 {
+		c.emit(0x84, byte(index), byte(increment))
+		return
+	}
+	if increment < math.MinInt16 || increment > math.MaxInt16 {
+		c.fail("iinc increment %d doesn't fit 16 bits", increment)
+	}
+	c.emit(0xc4, 0x84) // wide iinc
+	c.emitU2(index)
+	c.emitU2(increment)
+}
+
+// ret returns from the subroutine whose return address is in the local variable at index.
+func (c *CodeBuilder) ret(index int) {
+	c.local(index, 1)
+	c.adjust(0, 0)
+	if index <= 255 {
+		c.emit(0xa9, byte(index))
+	} else {
+		c.emit(0xc4, 0xa9) // wide
+		c.emitU2(index)
+	}
+	c.end()
+}
+
+// ------------------- Fields and methods ---------------------
+
+func (c *CodeBuilder) field(opcode byte, class, name, descriptor string) {
+	t, err := ParseFieldType(descriptor)
+	if err != nil {
+		c.fail("%v", err)
+	}
+	size := t.Slots()
+	switch opcode {
+	case 0xb2: // getstatic
+		c.adjust(0, size)
+	case 0xb3: // putstatic
+		c.adjust(size, 0)
+	case 0xb4: // getfield
+		c.adjust(1, size)
+	case 0xb5: // putfield
+		c.adjust(1+size, 0)
+	}
+	c.emit(opcode)
+	c.emitU2(c.pool.Fieldref(class, name, descriptor))
+}
+
+// GetStatic and the methods below access the field name of class with the type descriptor.
+func (c *CodeBuilder) GetStatic(class, name, descriptor string) {
+	c.field(0xb2, class, name, descriptor)
+}
+func (c *CodeBuilder) PutStatic(class, name, descriptor string) {
+	c.field(0xb3, class, name, descriptor)
+}
+func (c *CodeBuilder) GetField(class, name, descriptor string) {
+	c.field(0xb4, class, name, descriptor)
+}
+func (c *CodeBuilder) PutField(class, name, descriptor string) {
+	c.field(0xb5, class, name, descriptor)
+}
+
+func (c *CodeBuilder) invoke(opcode byte, class, name, descriptor string) {
+	arguments, result := c.methodSlots(descriptor)
+	receiver := 1
+	if opcode == 0xb8 { // invokestatic
+		receiver = 0
+	}
+	c.adjust(receiver+arguments, result)
+
+	c.emit(opcode)
+	if opcode == 0xb9 {
+		c.emitU2(c.pool.InterfaceMethodref(class, name, descriptor))
+		c.emit(byte(1+arguments), 0) // count
+		return
+	}
+	c.emitU2(c.pool.Methodref(class, name, descriptor))
+}
+
+// InvokeVirtual and the methods below call the method name of class with descriptor.
+func (c *CodeBuilder) InvokeVirtual(class, name, descriptor string) {
+	c.invoke(0xb6, class, name, descriptor)
+}
+
+func (c *CodeBuilder) InvokeSpecial(class, name, descriptor string) {
+	c.invoke(0xb7, class, name, descriptor)
+}
+
+func (c *CodeBuilder) InvokeStatic(class, name, descriptor string) {
+	c.invoke(0xb8, class, name, descriptor)
+}
+
+func (c *CodeBuilder) InvokeInterface(class, name, descriptor string) {
+	c.invoke(0xb9, class, name, descriptor)
+}
+
+// InvokeDynamic calls the call site with name and descriptor created by the bootstrap method at index
+// bootstrapMethod of the "BootstrapMethods" attribute, which has to be added to the class.
+func (c *CodeBuilder) InvokeDynamic(bootstrapMethod int, name, descriptor string) {
+	arguments, result := c.methodSlots(descriptor)
+	c.adjust(arguments, result)
+	c.emit(0xba)
+	c.emitU2(c.pool.InvokeDynamic(bootstrapMethod, name, descriptor))
+	c.emit(0, 0)
+}
+
+// ------------------- Objects and arrays ---------------------
+
+func (c *CodeBuilder) classInsn(opcode byte, class string, pop, push int) {
+	c.adjust(pop, push)
+	c.emit(opcode)
+	c.emitU2(c.pool.Class(class))
+}
+
+// New and the methods below add the instruction of their name with the class or array type called class.
+func (c *CodeBuilder) New(class string)        { c.classInsn(0xbb, class, 0, 1) }
+func (c *CodeBuilder) ANewArray(class string)  { c.classInsn(0xbd, class, 1, 1) }
+func (c *CodeBuilder) CheckCast(class string)  { c.classInsn(0xc0, class, 1, 1) }
+func (c *CodeBuilder) InstanceOf(class string) { c.classInsn(0xc1, class, 1, 1) }
+
+// arrayTypes are the atype operands of newarray.
+var arrayTypes = map[string]byte{"Z": 4, "C": 5, "F": 6, "D": 7, "B": 8, "S": 9, "I": 10, "J": 11}
+
+// NewArray creates an array of the primitive type with the descriptor elementType, e.g. "I".
+func (c *CodeBuilder) NewArray(elementType string) {
+	atype, ok := arrayTypes[elementType]
+	if !ok {
+		c.fail("invalid primitive array type %q", elementType)
+	}
+	c.newArray(atype)
+}
+
+func (c *CodeBuilder) newArray(atype byte) {
+	c.adjust(1, 1)
+	c.emit(0xbc, atype)
+}
+
+// MultiANewArray creates the array with the descriptor arrayType from the sizes of its first dimensions.
+func (c *CodeBuilder) MultiANewArray(arrayType string, dimensions int) {
+	if dimensions < 1 || dimensions > 255 {
+		c.fail("invalid number of dimensions %d", dimensions)
+	}
+	c.adjust(dimensions, 1)
+	c.emit(0xc5)
+	c.emitU2(c.pool.Class(arrayType))
+	c.emit(byte(dimensions))
+}
+
+// ------------------- Branches ---------------------
+
+// jump adds a branch instruction that pops pop values.
+func (c *CodeBuilder) jump(opcode byte, pop int, target *Label) {
+	c.adjust(pop, 0)
+	c.jumpTo(target)
+	c.branches = append(c.branches, branch{pc: len(c.code), offset: len(c.code) + 1, target: target})
+	c.emit(opcode, 0, 0)
+	if opcode == 0xa7 { // goto
+		c.end()
+	}
+}
+
+// IfEq and the methods below add the branch of their name to target.
+func (c *CodeBuilder) IfEq(target *Label)      { c.jump(0x99, 1, target) }
+func (c *CodeBuilder) IfNe(target *Label)      { c.jump(0x9a, 1, target) }
+func (c *CodeBuilder) IfLt(target *Label)      { c.jump(0x9b, 1, target) }
+func (c *CodeBuilder) IfGe(target *Label)      { c.jump(0x9c, 1, target) }
+func (c *CodeBuilder) IfGt(target *Label)      { c.jump(0x9d, 1, target) }
+func (c *CodeBuilder) IfLe(target *Label)      { c.jump(0x9e, 1, target) }
+func (c *CodeBuilder) IfICmpEq(target *Label)  { c.jump(0x9f, 2, target) }
+func (c *CodeBuilder) IfICmpNe(target *Label)  { c.jump(0xa0, 2, target) }
+func (c *CodeBuilder) IfICmpLt(target *Label)  { c.jump(0xa1, 2, target) }
+func (c *CodeBuilder) IfICmpGe(target *Label)  { c.jump(0xa2, 2, target) }
+func (c *CodeBuilder) IfICmpGt(target *Label)  { c.jump(0xa3, 2, target) }
+func (c *CodeBuilder) IfICmpLe(target *Label)  { c.jump(0xa4, 2, target) }
+func (c *CodeBuilder) IfACmpEq(target *Label)  { c.jump(0xa5, 2, target) }
+func (c *CodeBuilder) IfACmpNe(target *Label)  { c.jump(0xa6, 2, target) }
+func (c *CodeBuilder) Goto(target *Label)      { c.jump(0xa7, 0, target) }
+func (c *CodeBuilder) IfNull(target *Label)    { c.jump(0xc6, 1, target) }
+func (c *CodeBuilder) IfNonNull(target *Label) { c.jump(0xc7, 1, target) }
+
+// jsr jumps to the subroutine at target, which starts with the return address on the stack.
+func (c *CodeBuilder) jsr(target *Label) {
+	c.adjust(0, 1)
+	c.jumpTo(target)
+	c.stack--
+	c.branches = append(c.branches, branch{pc: len(c.code), offset: len(c.code) + 1, target: target})
+	c.emit(0xa8, 0, 0)
+}
+
+// switchHeader adds the opcode, the padding and the default offset of a switch.
+func (c *CodeBuilder) switchHeader(opcode byte, defaultTarget *Label) int {
+	c.adjust(1, 0)
+	pc := len(c.code)
+	c.emit(opcode)
+	for len(c.code)%4 != 0 {
+		c.emit(0)
+	}
+	c.switchTarget(pc, defaultTarget)
+	return pc
+}
+
+func (c *CodeBuilder) switchTarget(pc int, target *Label) {
+	c.jumpTo(target)
+	c.branches = append(c.branches, branch{pc: pc, offset: len(c.code), wide: true, target: target})
+	c.emitU4(0)
+}
+
+// TableSwitch jumps to targets[key-low] or defaultTarget if the key isn't between low and low+len(targets)-1.
+func (c *CodeBuilder) TableSwitch(low int32, defaultTarget *Label, targets ...*Label) {
+	if len(targets) == 0 || int64(low)+int64(len(targets))-1 > math.MaxInt32 {
+		c.fail("invalid tableswitch range")
+		return
+	}
+	pc := c.switchHeader(0xaa, defaultTarget)
+	c.emitU4(int(low))
+	c.emitU4(int(low) + len(targets) - 1)
+	for _, target := range targets {
+		c.switchTarget(pc, target)
+	}
+	c.end()
+}
+
+// LookupSwitch jumps to the target of the key, or defaultTarget if it isn't one of keys, which have to be sorted.
+func (c *CodeBuilder) LookupSwitch(defaultTarget *Label, keys []int32, targets []*Label) {
+	if len(keys) != len(targets) {
+		c.fail("lookupswitch has %d keys and %d targets", len(keys), len(targets))
+		return
+	}
+	for i := 1; i < len(keys); i++ {
+		if keys[i-1] >= keys[i] {
+			c.fail("lookupswitch keys aren't sorted")
+			return
+		}
+	}
+	pc := c.switchHeader(0xab, defaultTarget)
+	c.emitU4(len(keys))
+	for i, key := range keys {
+		c.emitU4(int(key))
+		c.switchTarget(pc, targets[i])
+	}
+	c.end()
+}
+
+// ------------------- Layout ---------------------
+
+// widen replaces the branches whose target is too far for a 16 bit offset: goto and jsr become goto_w and jsr_w and
+// conditional branches jump over a goto_w with the opposite condition. As that moves the following code, the
+// padding of switches changes and more branches may become too far, so it repeats until all offsets fit.
+func (c *CodeBuilder) widen() {
+	for {
+		var widenings []int // indexes of the branches
+		for i, b := range c.branches {
+			offset := b.target.pc - b.pc
+			if !b.wide && (offset < math.MinInt16 || offset > math.MaxInt16) {
+				widenings = append(widenings, i)
+			}
+		}
+		if len(widenings) == 0 {
+			return
+		}
+		c.relocate(widenings)
+	}
+}
+
+// relocate rewrites the code with the widened branches and moves all positions after them.
+func (c *CodeBuilder) relocate(widenings []int) {
+	// growth is the number of bytes the instruction at a pc grows by, including switches whose padding changes.
+	growth := map[int]int{}
+	for _, i := range widenings {
+		pc := c.branches[i].pc
+		if c.code[pc] == 0xa7 || c.code[pc] == 0xa8 { // goto, jsr
+			growth[pc] = 2
+		} else {
+			growth[pc] = 5
+		}
+	}
+	for _, b := range c.branches {
+		if op := c.code[b.pc]; op == 0xaa || op == 0xab { // tableswitch, lookupswitch
+			growth[b.pc] = 0
+		}
+	}
+	pcs := make([]int, 0, len(growth))
+	for pc := range growth {
+		pcs = append(pcs, pc)
+	}
+	sort.Ints(pcs)
+
+	// shifts[i] is the number of bytes the code after pcs[i] moves by.
+	shifts := make([]int, len(pcs))
+	shift := 0
+	for i, pc := range pcs {
+		if op := c.code[pc]; op == 0xaa || op == 0xab {
+			growth[pc] = pc%4 - (pc+shift)%4 // the padding after the opcode is 3 - pc%4
+		}
+		shift += growth[pc]
+		shifts[i] = shift
+	}
+	// move returns the new position of an instruction or operand at pc.
+	move := func(pc int) int {
+		i := sort.SearchInts(pcs, pc)
+		if i == 0 {
+			return pc
+		}
+		return pc + shifts[i-1]
+	}
+
+	code := make([]byte, 0, len(c.code)+shift)
+	last := 0
+	for _, pc := range pcs {
+		code = append(code, c.code[last:pc]...)
+		switch op := c.code[pc]; {
+		case op == 0xaa || op == 0xab:
+			code = append(code, op)
+			for len(code)%4 != 0 {
+				code = append(code, 0)
+			}
+			last = pc + 1 + (3 - pc%4)
+		case op == 0xa7 || op == 0xa8:
+			code = append(code, op+0x21, 0, 0, 0, 0) // goto_w, jsr_w
+			last = pc + 3
+		default:
+			code = append(code, oppositeBranch(op), 0, 8, 0xc8, 0, 0, 0, 0) // goto_w
+			last = pc + 3
+		}
+	}
+	c.code = append(code, c.code[last:]...)
+
+	moved := map[*Label]bool{}
+	moveLabel := func(l *Label) {
+		if !moved[l] && l.pc >= 0 {
+			l.pc = move(l.pc)
+			moved[l] = true
+		}
+	}
+	for i := range c.branches {
+		b := &c.branches[i]
+		moveLabel(b.target)
+		b.pc, b.offset = move(b.pc), move(b.offset)
+	}
+	for _, i := range widenings {
+		b := &c.branches[i]
+		if op := c.code[b.pc]; op != 0xc8 && op != 0xc9 {
+			b.pc += 3 // the goto_w after the opposite branch
+		}
+		b.offset = b.pc + 1
+		b.wide = true
+	}
+	for _, h := range c.handlers {
+		moveLabel(h.start)
+		moveLabel(h.end)
+		moveLabel(h.handler)
+	}
+	for _, v := range c.variables {
+		moveLabel(v.start)
+		moveLabel(v.end)
+	}
+	for i := range c.lines {
+		c.lines[i].StartPc = move(c.lines[i].StartPc)
+	}
+}
+
+// oppositeBranch returns the conditional branch that jumps if op doesn't, e.g. ifne for ifeq.
+func oppositeBranch(op byte) byte {
+	if op == 0xc6 || op == 0xc7 { // ifnull, ifnonnull
+		return op ^ 1
+	}
+	return ((op - 0x99) ^ 1) + 0x99
+}
+
+// finish resolves the labels and returns the Code attribute.
+func (c *CodeBuilder) finish() (*CodeAttribute, error) {
+	if len(c.code) == 0 {
+		c.fail("code is empty")
+	}
+	if c.fallsThrough {
+		c.fail("code falls off the end, it has to end with a return, throw or jump")
+	}
+	if c.err != nil {
+		return nil, c.err
+	}
+
+	for _, b := range c.branches {
+		if b.target.pc < 0 {
+			return nil, fmt.Errorf("pc %d: branch to a label that isn't marked", b.pc)
+		}
+	}
+	c.widen()
+	if len(c.code) > 65535 {
+		return nil, fmt.Errorf("code is %d bytes long, at most 65535 are allowed", len(c.code))
+	}
+
+	for _, b := range c.branches {
+		offset := b.target.pc - b.pc
+		if b.wide {
+			c.code[b.offset] = byte(offset >> 24)
+			c.code[b.offset+1] = byte(offset >> 16)
+			c.code[b.offset+2] = byte(offset >> 8)
+			c.code[b.offset+3] = byte(offset)
+			continue
+		}
+		if offset < math.MinInt16 || offset > math.MaxInt16 {
+			return nil, fmt.Errorf("pc %d: branch is too far for a 16 bit offset", b.pc)
+		}
+		c.code[b.offset] = byte(offset >> 8)
+		c.code[b.offset+1] = byte(offset)
+	}
+
+	code := &CodeAttribute{MaxStack: c.maxStack, MaxLocals: c.maxLocals, Code: c.code}
+	for _, h := range c.handlers {
+		if h.start.pc < 0 || h.end.pc < 0 || h.handler.pc < 0 {
+			return nil, fmt.Errorf("exception handler with a label that isn't marked")
+		}
+		code.ExceptionTable = append(code.ExceptionTable, ExceptionTableEntry{
+			StartPc:   h.start.pc,
+			EndPc:     h.end.pc,
+			HandlerPc: h.handler.pc,
+			CatchType: h.catchType,
+		})
+	}
+	if len(c.lines) != 0 {
+		w := new(ClassFileWriter)
+		_ = w.WriteU2(len(c.lines))
+		for _, line := range c.lines {
+			_ = w.WriteU2(line.StartPc)
+			_ = w.WriteU2(line.LineNumber)
+		}
+		code.Attributes = append(code.Attributes, AttributeInfo{AttributeNameIndex: c.pool.Utf8("LineNumberTable"), Info: w.Bytes()})
+	}
+	for _, table := range []struct {
+		name      string
+		variables []localVariable
+	}{{"LocalVariableTable", c.variables}, {"LocalVariableTypeTable", c.variableTypes}} {
+		if len(table.variables) == 0 {
+			continue
+		}
+		w := new(ClassFileWriter)
+		_ = w.WriteU2(len(table.variables))
+		for _, v := range table.variables {
+			if v.start.pc < 0 || v.end.pc < v.start.pc {
+				return nil, fmt.Errorf("local variable %s has labels that aren't marked in order", v.name)
+			}
+			for _, value := range []int{v.start.pc, v.end.pc - v.start.pc, c.pool.Utf8(v.name), c.pool.Utf8(v.descriptor), v.index} {
+				_ = w.WriteU2(value)
+			}
+		}
+		code.Attributes = append(code.Attributes, AttributeInfo{AttributeNameIndex: c.pool.Utf8(table.name), Info: w.Bytes()})
+	}
+	code.Attributes = append(code.Attributes, c.attributes...)
+	return code, nil
+}
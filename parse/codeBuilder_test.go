@@ -0,0 +1,219 @@
+package parse
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// buildCode returns the Code attribute of the method m with flags and descriptor whose code generate adds.
+func buildCode(flags MethodAccessFlags, descriptor string, generate func(c *CodeBuilder)) (*CodeAttribute, error) {
+	cf, err := NewClassBuilder("T").Method(flags, "m", descriptor).Code(generate).ClassFile()
+	if err != nil {
+		return nil, err
+	}
+	return cf.Methods[0].Code, nil
+}
+
+// filler adds n iinc instructions of 3 bytes each to move the following code further away.
+func filler(c *CodeBuilder, n int) {
+	for i := 0; i < n; i++ {
+		c.IInc(0, 1)
+	}
+}
+
+func TestCodeBuilderLimits(t *testing.T) {
+	tests := []struct {
+		name       string
+		flags      MethodAccessFlags
+		descriptor string
+		generate   func(c *CodeBuilder)
+		maxStack   int
+		maxLocals  int
+	}{
+		{"empty static method", MethodAccStatic, "()V", func(c *CodeBuilder) {
+			c.Return()
+		}, 0, 0},
+		{"arguments of an instance method", 0, "(IJLjava/lang/String;)V", func(c *CodeBuilder) {
+			c.Return()
+		}, 0, 5},
+		{"long arithmetic", MethodAccStatic, "()J", func(c *CodeBuilder) {
+			c.LConst(2)
+			c.LConst(3)
+			c.Insn(0x61) // ladd
+			c.LReturn()
+		}, 4, 0},
+		{"dup2 and pop2", MethodAccStatic, "()J", func(c *CodeBuilder) {
+			c.LConst(1)
+			c.Insn(0x5c) // dup2
+			c.Insn(0x58) // pop2
+			c.LReturn()
+		}, 4, 0},
+		{"long local", MethodAccStatic, "()V", func(c *CodeBuilder) {
+			c.LConst(0)
+			c.LStore(5)
+			c.Return()
+		}, 2, 7},
+		{"wide local", MethodAccStatic, "()V", func(c *CodeBuilder) {
+			c.IConst(1)
+			c.IStore(300)
+			c.IInc(400, 1)
+			c.Return()
+		}, 1, 401},
+		{"invoke", MethodAccStatic, "()I", func(c *CodeBuilder) {
+			c.IConst(1)
+			c.LConst(2)
+			c.InvokeStatic("T", "f", "(IJ)I")
+			c.IReturn()
+		}, 3, 0},
+		{"branches merge", MethodAccStatic, "(I)I", func(c *CodeBuilder) {
+			other, end := c.NewLabel(), c.NewLabel()
+			c.ILoad(0)
+			c.IfEq(other)
+			c.IConst(1)
+			c.Goto(end)
+			c.Mark(other)
+			c.IConst(2)
+			c.Mark(end)
+			c.IReturn()
+		}, 1, 1},
+		{"exception handler", MethodAccStatic, "()V", func(c *CodeBuilder) {
+			start, handler := c.NewLabel(), c.NewLabel()
+			c.TryCatch(start, handler, handler, "")
+			c.Mark(start)
+			c.Return()
+			c.Mark(handler)
+			c.AStore(0)
+			c.ALoad(0)
+			c.AThrow()
+		}, 1, 1},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			code, err := buildCode(test.flags, test.descriptor, test.generate)
+			if err != nil {
+				t.Fatalf("building the code failed: %v", err)
+			}
+			if code.MaxStack != test.maxStack || code.MaxLocals != test.maxLocals {
+				t.Errorf("max_stack %d and max_locals %d, want %d and %d", code.MaxStack, code.MaxLocals,
+					test.maxStack, test.maxLocals)
+			}
+		})
+	}
+}
+
+func TestCodeBuilderErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		generate func(c *CodeBuilder)
+		reason   string
+	}{
+		{"stack underflow", func(c *CodeBuilder) {
+			c.Pop()
+			c.Return()
+		}, "pc 0: stack underflow"},
+		{"unreachable code", func(c *CodeBuilder) {
+			c.Return()
+			c.Return()
+		}, "pc 1: unreachable code"},
+		{"stack heights differ", func(c *CodeBuilder) {
+			l := c.NewLabel()
+			c.IConst(1)
+			c.Goto(l)
+			c.Mark(l)
+			c.Pop()
+			c.Goto(l)
+		}, "stack height 0 differs from 1"},
+		{"falls off the end", func(c *CodeBuilder) {
+			c.IConst(1)
+		}, "falls off the end"},
+		{"label isn't marked", func(c *CodeBuilder) {
+			c.Goto(c.NewLabel())
+		}, "branch to a label that isn't marked"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := buildCode(MethodAccStatic, "()V", test.generate)
+			if err == nil || !strings.Contains(err.Error(), test.reason) {
+				t.Errorf("got error %v, want one containing %q", err, test.reason)
+			}
+		})
+	}
+}
+
+func TestCodeBuilderWidensBranches(t *testing.T) {
+	const n = 11000 // filler instructions, 33000 bytes don't fit a 16 bit offset
+
+	tests := []struct {
+		name     string
+		generate func(c *CodeBuilder)
+		pc       int  // of the widened goto_w or jsr_w
+		opcode   byte // at pc
+		target   int
+		prefix   []byte // code before pc
+	}{
+		{"goto", func(c *CodeBuilder) {
+			end := c.NewLabel()
+			c.Goto(end)
+			c.Mark(c.NewLabel())
+			filler(c, n)
+			c.Mark(end)
+			c.Return()
+		}, 0, 0xc8, 5 + 3*n, nil},
+		{"goto backwards", func(c *CodeBuilder) {
+			start := c.NewLabel()
+			c.Mark(start)
+			filler(c, n)
+			c.Goto(start)
+		}, 3 * n, 0xc8, 0, nil},
+		{"jsr", func(c *CodeBuilder) {
+			subroutine := c.NewLabel()
+			c.jsr(subroutine)
+			c.Return()
+			c.Mark(c.NewLabel())
+			filler(c, n)
+			c.Return()
+			c.Mark(subroutine)
+			c.AStore(1)
+			c.ret(1)
+		}, 0, 0xc9, 7 + 3*n, nil},
+		{"ifeq jumps over goto_w with ifne", func(c *CodeBuilder) {
+			end := c.NewLabel()
+			c.ILoad(0)
+			c.IfEq(end)
+			filler(c, n)
+			c.Mark(end)
+			c.Return()
+		}, 4, 0xc8, 9 + 3*n, []byte{0x1a, 0x9a, 0, 8}}, // iload_0, ifne +8
+		{"ifnull jumps over goto_w with ifnonnull", func(c *CodeBuilder) {
+			end := c.NewLabel()
+			c.AConstNull()
+			c.IfNull(end)
+			filler(c, n)
+			c.Mark(end)
+			c.Return()
+		}, 4, 0xc8, 9 + 3*n, []byte{0x01, 0xc7, 0, 8}}, // aconst_null, ifnonnull +8
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			code, err := buildCode(MethodAccStatic, "(I)V", test.generate)
+			if err != nil {
+				t.Fatalf("building the code failed: %v", err)
+			}
+			c := code.Code
+			if !bytes.Equal(c[:len(test.prefix)], test.prefix) {
+				t.Errorf("code starts with % x, want % x", c[:len(test.prefix)], test.prefix)
+			}
+			if c[test.pc] != test.opcode {
+				t.Fatalf("opcode %#x at pc %d, want %#x", c[test.pc], test.pc, test.opcode)
+			}
+			offset := int(int32(uint32(c[test.pc+1])<<24 | uint32(c[test.pc+2])<<16 | uint32(c[test.pc+3])<<8 | uint32(c[test.pc+4])))
+			if test.pc+offset != test.target {
+				t.Errorf("branch to pc %d, want %d", test.pc+offset, test.target)
+			}
+		})
+	}
+}
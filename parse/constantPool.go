@@ -1,6 +1,9 @@
 package parse
 
-import "fmt"
+import (
+	"fmt"
+	"math"
+)
 
 type CpInfo interface {
 	getTag() byte
@@ -84,8 +87,8 @@ func (c ConstantIntegerInfo) getTag() byte {
 }
 
 type ConstantFloatInfo struct {
-	tag      byte   // u1 tag;
-	FloatVal []byte // u4
+	tag   byte    // u1 tag;
+	Float float32 // u4 bytes;
 }
 
 func (c ConstantFloatInfo) getTag() byte {
@@ -93,9 +96,8 @@ func (c ConstantFloatInfo) getTag() byte {
 }
 
 type ConstantLongInfo struct {
-	tag       byte   // u1 tag;
-	highBytes []byte // u4
-	lowBytes  []byte // u4
+	tag  byte  // u1 tag;
+	Long int64 // u4 high_bytes; u4 low_bytes;
 }
 
 func (c ConstantLongInfo) getTag() byte {
@@ -103,9 +105,8 @@ func (c ConstantLongInfo) getTag() byte {
 }
 
 type ConstantDoubleInfo struct {
-	tag       byte   // u1 tag;
-	highBytes []byte // u4
-	lowBytes  []byte // u4
+	tag    byte    // u1 tag;
+	Double float64 // u4 high_bytes; u4 low_bytes;
 }
 
 func (c ConstantDoubleInfo) getTag() byte {
@@ -146,6 +147,47 @@ func (c ConstantMethodHandleInfo) getTag() byte {
 	return c.tag
 }
 
+type ConstantMethodTypeInfo struct {
+	tag             byte    // u1 tag; (always 16)
+	Descriptor      *CpInfo // ConstantUtf8Info
+	DescriptorIndex int     // u2 descriptor_index;
+}
+
+func (c ConstantMethodTypeInfo) getTag() byte {
+	return c.tag
+}
+
+type ConstantDynamicInfo struct {
+	tag                      byte    // u1 tag; (always 17)
+	BootstrapMethodAttrIndex int     // u2 bootstrap_method_attr_index;
+	NameAndType              *CpInfo // ConstantNameAndTypeInfo
+	NameAndTypeIndex         int     // u2 name_and_type_index;
+}
+
+func (c ConstantDynamicInfo) getTag() byte {
+	return c.tag
+}
+
+type ConstantModuleInfo struct {
+	tag       byte    // u1 tag; (always 19)
+	Name      *CpInfo // ConstantUtf8Info
+	NameIndex int     // u2 name_index;
+}
+
+func (c ConstantModuleInfo) getTag() byte {
+	return c.tag
+}
+
+type ConstantPackageInfo struct {
+	tag       byte    // u1 tag; (always 20)
+	Name      *CpInfo // ConstantUtf8Info
+	NameIndex int     // u2 name_index;
+}
+
+func (c ConstantPackageInfo) getTag() byte {
+	return c.tag
+}
+
 // ReadCPInfo reads one constant pool entry.
 func (r *ClassFileReader) ReadCPInfo() (info CpInfo, err error) {
 	tag, err := r.ReadByte()
@@ -153,7 +195,6 @@ func (r *ClassFileReader) ReadCPInfo() (info CpInfo, err error) {
 		return
 	}
 
-	// TODO: implement fully: https://docs.oracle.com/javase/specs/jvms/se8/html/jvms-4.html#jvms-4.4-140
 	switch tag {
 	case 3:
 		IInfo := ConstantIntegerInfo{tag: 3}
@@ -162,50 +203,33 @@ func (r *ClassFileReader) ReadCPInfo() (info CpInfo, err error) {
 			return
 		}
 		info = IInfo
-	case 4:
-		IInfo := ConstantFloatInfo{tag: 4}
-		for i := 0; i < 4; i++ {
-			b, err := r.ReadByte()
-			if err != nil {
-				return info, err
-			}
-			IInfo.FloatVal = append(IInfo.FloatVal, b)
+	case 4: // CONSTANT_Float
+		FInfo := ConstantFloatInfo{tag: 4}
+		var bits int
+		bits, err = r.ReadU4()
+		if err != nil {
+			return
 		}
-		info = IInfo
+		FInfo.Float = math.Float32frombits(uint32(bits))
+		info = FInfo
 	case 5: // CONSTANT_Long_info
-		IInfo := ConstantLongInfo{tag: 5}
-		for i := 0; i < 4; i++ {
-			b, err := r.ReadByte()
-			if err != nil {
-				return info, err
-			}
-			IInfo.highBytes = append(IInfo.highBytes, b)
-		}
-		for i := 0; i < 4; i++ {
-			b, err := r.ReadByte()
-			if err != nil {
-				return info, err
-			}
-			IInfo.lowBytes = append(IInfo.lowBytes, b)
+		LInfo := ConstantLongInfo{tag: 5}
+		var bits uint64
+		bits, err = r.readU8()
+		if err != nil {
+			return
 		}
-		info = IInfo
+		LInfo.Long = int64(bits)
+		info = LInfo
 	case 6: // CONSTANT_Double_info
-		IInfo := ConstantDoubleInfo{tag: 6}
-		for i := 0; i < 4; i++ {
-			b, err := r.ReadByte()
-			if err != nil {
-				return info, err
-			}
-			IInfo.highBytes = append(IInfo.highBytes, b)
-		}
-		for i := 0; i < 4; i++ {
-			b, err := r.ReadByte()
-			if err != nil {
-				return info, err
-			}
-			IInfo.lowBytes = append(IInfo.lowBytes, b)
+		DInfo := ConstantDoubleInfo{tag: 6}
+		var bits uint64
+		bits, err = r.readU8()
+		if err != nil {
+			return
 		}
-		info = IInfo
+		DInfo.Double = math.Float64frombits(bits)
+		info = DInfo
 	case 7: // CONSTANT_Class
 		CInfo := ConstantClassInfo{tag: 7}
 		CInfo.NameIndex, err = r.ReadU2()
@@ -276,8 +300,7 @@ func (r *ClassFileReader) ReadCPInfo() (info CpInfo, err error) {
 		if err != nil {
 			return
 		}
-		Utf8Info.Content = make([]byte, Utf8Info.length)
-		_, err = r.Read(Utf8Info.Content)
+		Utf8Info.Content, err = r.readBytes(Utf8Info.length, "string")
 		if err != nil {
 			return
 		}
@@ -289,6 +312,9 @@ func (r *ClassFileReader) ReadCPInfo() (info CpInfo, err error) {
 			return
 		}
 		InvokeDynamixInfo.NameAndTypeIndex, err = r.ReadU2()
+		if err != nil {
+			return
+		}
 		info = InvokeDynamixInfo
 	case 15: // CONSTANT_MethodHandle
 		MethodHandleInfo := ConstantMethodHandleInfo{tag: 15}
@@ -301,6 +327,38 @@ func (r *ClassFileReader) ReadCPInfo() (info CpInfo, err error) {
 			return
 		}
 		info = MethodHandleInfo
+	case 16: // CONSTANT_MethodType
+		MethodTypeInfo := ConstantMethodTypeInfo{tag: 16}
+		MethodTypeInfo.DescriptorIndex, err = r.ReadU2()
+		if err != nil {
+			return
+		}
+		info = MethodTypeInfo
+	case 17: // CONSTANT_Dynamic
+		DynamicInfo := ConstantDynamicInfo{tag: 17}
+		DynamicInfo.BootstrapMethodAttrIndex, err = r.ReadU2()
+		if err != nil {
+			return
+		}
+		DynamicInfo.NameAndTypeIndex, err = r.ReadU2()
+		if err != nil {
+			return
+		}
+		info = DynamicInfo
+	case 19: // CONSTANT_Module
+		ModuleInfo := ConstantModuleInfo{tag: 19}
+		ModuleInfo.NameIndex, err = r.ReadU2()
+		if err != nil {
+			return
+		}
+		info = ModuleInfo
+	case 20: // CONSTANT_Package
+		PackageInfo := ConstantPackageInfo{tag: 20}
+		PackageInfo.NameIndex, err = r.ReadU2()
+		if err != nil {
+			return
+		}
+		info = PackageInfo
 	default:
 		err = fmt.Errorf(`constant pool tag "%d" not implemented`, tag)
 		return
@@ -321,6 +379,7 @@ func (r *ClassFileReader) ReadConstantPool() (size int, entries []CpInfo, err er
 		var entry CpInfo
 		entry, err = r.ReadCPInfo()
 		if err != nil {
+			err = atPath(fmt.Sprintf("constant_pool[%d]", len(entries)+1), err)
 			return
 		}
 		entries = append(entries, entry)
@@ -0,0 +1,202 @@
+package parse
+
+import (
+	"fmt"
+	"math"
+)
+
+// ConstantPoolBuilder adds entries to a constant pool for code that generates class files. Adding an entry that is
+// already in the pool returns the index of the existing entry instead.
+//
+// The entries it creates only have their indexes set, the resolved pointers like ConstantClassInfo.Name are nil
+// until the written class file is parsed again.
+type ConstantPoolBuilder struct {
+	entries []CpInfo
+	indexes map[poolKey]int
+}
+
+// poolKey identifies a constant pool entry by its content.
+type poolKey struct {
+	tag  byte
+	text string
+	a, b int64
+}
+
+// NewConstantPoolBuilder returns a builder that appends to the constant pool entries, which may be nil to start
+// with an empty pool.
+func NewConstantPoolBuilder(entries []CpInfo) *ConstantPoolBuilder {
+	b := &ConstantPoolBuilder{
+		entries: append([]CpInfo{}, entries...),
+		indexes: map[poolKey]int{},
+	}
+	for i := 0; i < len(b.entries); i++ {
+		key := keyOf(b.entries[i])
+		if _, ok := b.indexes[key]; !ok {
+			b.indexes[key] = i + 1
+		}
+		switch b.entries[i].(type) {
+		case ConstantLongInfo, ConstantDoubleInfo:
+			i++
+		}
+	}
+	return b
+}
+
+// keyOf returns the key of an entry of the constant pool.
+func keyOf(info CpInfo) poolKey {
+	switch t := info.(type) {
+	case ConstantUtf8Info:
+		return poolKey{tag: 1, text: t.Text}
+	case ConstantIntegerInfo:
+		return poolKey{tag: 3, a: int64(int32(t.Integer))}
+	case ConstantFloatInfo:
+		return poolKey{tag: 4, a: int64(math.Float32bits(t.Float))}
+	case ConstantLongInfo:
+		return poolKey{tag: 5, a: t.Long}
+	case ConstantDoubleInfo:
+		return poolKey{tag: 6, a: int64(math.Float64bits(t.Double))}
+	case ConstantClassInfo:
+		return poolKey{tag: 7, a: int64(t.NameIndex)}
+	case ConstantStringInfo:
+		return poolKey{tag: 8, a: int64(t.StringIndex)}
+	case ConstantFieldrefInfo:
+		return poolKey{tag: 9, a: int64(t.ClassIndex), b: int64(t.NameAndTypeIndex)}
+	case ConstantMethodrefInfo:
+		return poolKey{tag: 10, a: int64(t.ClassIndex), b: int64(t.NameAndTypeIndex)}
+	case ConstantInterfaceMethodrefInfo:
+		return poolKey{tag: 11, a: int64(t.ClassIndex), b: int64(t.NameAndTypeIndex)}
+	case ConstantNameAndTypeInfo:
+		return poolKey{tag: 12, a: int64(t.NameIndex), b: int64(t.DescriptorIndex)}
+	case ConstantMethodHandleInfo:
+		return poolKey{tag: 15, a: int64(t.ReferenceKind), b: int64(t.ReferenceIndex)}
+	case ConstantMethodTypeInfo:
+		return poolKey{tag: 16, a: int64(t.DescriptorIndex)}
+	case ConstantDynamicInfo:
+		return poolKey{tag: 17, a: int64(t.BootstrapMethodAttrIndex), b: int64(t.NameAndTypeIndex)}
+	case ConstantInvokeDynamicInfo:
+		return poolKey{tag: 18, a: int64(t.BootstrapMethodAttrIndex), b: int64(t.NameAndTypeIndex)}
+	case ConstantModuleInfo:
+		return poolKey{tag: 19, a: int64(t.NameIndex)}
+	case ConstantPackageInfo:
+		return poolKey{tag: 20, a: int64(t.NameIndex)}
+	}
+	return poolKey{}
+}
+
+// add returns the index of info, appending it if the pool doesn't contain it yet.
+func (b *ConstantPoolBuilder) add(info CpInfo) int {
+	key := keyOf(info)
+	if index, ok := b.indexes[key]; ok {
+		return index
+	}
+	b.entries = append(b.entries, info)
+	index := len(b.entries)
+	if key.tag == 5 || key.tag == 6 {
+		b.entries = append(b.entries, info) // CONSTANT_Long_info and CONSTANT_Double_info take 2 constant pool entries
+	}
+	b.indexes[key] = index
+	return index
+}
+
+// Entries returns the constant pool in the form of ClassFile.ConstantPool.
+func (b *ConstantPoolBuilder) Entries() ([]CpInfo, error) {
+	if len(b.entries) > 65534 {
+		return nil, fmt.Errorf("constant pool has %d entries, at most 65534 are allowed", len(b.entries))
+	}
+	return append([]CpInfo{}, b.entries...), nil
+}
+
+// Lookup returns the entry at index, or nil if there is none.
+func (b *ConstantPoolBuilder) Lookup(index int) CpInfo {
+	if index < 1 || index > len(b.entries) {
+		return nil
+	}
+	return b.entries[index-1]
+}
+
+// Utf8 returns the index of the ConstantUtf8Info of text.
+func (b *ConstantPoolBuilder) Utf8(text string) int {
+	return b.add(ConstantUtf8Info{tag: 1, Text: text})
+}
+
+// Integer returns the index of the ConstantIntegerInfo of v.
+func (b *ConstantPoolBuilder) Integer(v int32) int {
+	return b.add(ConstantIntegerInfo{tag: 3, Integer: int(v)})
+}
+
+// Float returns the index of the ConstantFloatInfo of v.
+func (b *ConstantPoolBuilder) Float(v float32) int {
+	return b.add(ConstantFloatInfo{tag: 4, Float: v})
+}
+
+// Long returns the index of the ConstantLongInfo of v.
+func (b *ConstantPoolBuilder) Long(v int64) int {
+	return b.add(ConstantLongInfo{tag: 5, Long: v})
+}
+
+// Double returns the index of the ConstantDoubleInfo of v.
+func (b *ConstantPoolBuilder) Double(v float64) int {
+	return b.add(ConstantDoubleInfo{tag: 6, Double: v})
+}
+
+// Class returns the index of the ConstantClassInfo of the class or array called name, e.g. "java/lang/String".
+func (b *ConstantPoolBuilder) Class(name string) int {
+	return b.add(ConstantClassInfo{tag: 7, NameIndex: b.Utf8(name)})
+}
+
+// String returns the index of the ConstantStringInfo of text.
+func (b *ConstantPoolBuilder) String(text string) int {
+	return b.add(ConstantStringInfo{tag: 8, StringIndex: b.Utf8(text)})
+}
+
+// Fieldref returns the index of the ConstantFieldrefInfo of the field name with the type descriptor in class.
+func (b *ConstantPoolBuilder) Fieldref(class, name, descriptor string) int {
+	return b.add(ConstantFieldrefInfo{tag: 9, ClassIndex: b.Class(class), NameAndTypeIndex: b.NameAndType(name, descriptor)})
+}
+
+// Methodref returns the index of the ConstantMethodrefInfo of the method name with descriptor in class.
+func (b *ConstantPoolBuilder) Methodref(class, name, descriptor string) int {
+	return b.add(ConstantMethodrefInfo{tag: 10, ClassIndex: b.Class(class), NameAndTypeIndex: b.NameAndType(name, descriptor)})
+}
+
+// InterfaceMethodref returns the index of the ConstantInterfaceMethodrefInfo of the method name with descriptor in
+// the interface class.
+func (b *ConstantPoolBuilder) InterfaceMethodref(class, name, descriptor string) int {
+	return b.add(ConstantInterfaceMethodrefInfo{tag: 11, ClassIndex: b.Class(class), NameAndTypeIndex: b.NameAndType(name, descriptor)})
+}
+
+// NameAndType returns the index of the ConstantNameAndTypeInfo of name and descriptor.
+func (b *ConstantPoolBuilder) NameAndType(name, descriptor string) int {
+	return b.add(ConstantNameAndTypeInfo{tag: 12, NameIndex: b.Utf8(name), DescriptorIndex: b.Utf8(descriptor)})
+}
+
+// MethodHandle returns the index of the ConstantMethodHandleInfo with referenceKind (1 to 9) for the field or method
+// reference at referenceIndex.
+func (b *ConstantPoolBuilder) MethodHandle(referenceKind byte, referenceIndex int) int {
+	return b.add(ConstantMethodHandleInfo{tag: 15, ReferenceKind: referenceKind, ReferenceIndex: referenceIndex})
+}
+
+// MethodType returns the index of the ConstantMethodTypeInfo of the method descriptor.
+func (b *ConstantPoolBuilder) MethodType(descriptor string) int {
+	return b.add(ConstantMethodTypeInfo{tag: 16, DescriptorIndex: b.Utf8(descriptor)})
+}
+
+// Dynamic returns the index of the ConstantDynamicInfo computed by the bootstrap method at bootstrapMethod.
+func (b *ConstantPoolBuilder) Dynamic(bootstrapMethod int, name, descriptor string) int {
+	return b.add(ConstantDynamicInfo{tag: 17, BootstrapMethodAttrIndex: bootstrapMethod, NameAndTypeIndex: b.NameAndType(name, descriptor)})
+}
+
+// InvokeDynamic returns the index of the ConstantInvokeDynamicInfo linked by the bootstrap method at bootstrapMethod.
+func (b *ConstantPoolBuilder) InvokeDynamic(bootstrapMethod int, name, descriptor string) int {
+	return b.add(ConstantInvokeDynamicInfo{tag: 18, BootstrapMethodAttrIndex: bootstrapMethod, NameAndTypeIndex: b.NameAndType(name, descriptor)})
+}
+
+// Module returns the index of the ConstantModuleInfo of the module called name.
+func (b *ConstantPoolBuilder) Module(name string) int {
+	return b.add(ConstantModuleInfo{tag: 19, NameIndex: b.Utf8(name)})
+}
+
+// Package returns the index of the ConstantPackageInfo of the package called name in internal form.
+func (b *ConstantPoolBuilder) Package(name string) int {
+	return b.add(ConstantPackageInfo{tag: 20, NameIndex: b.Utf8(name)})
+}
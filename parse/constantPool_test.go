@@ -0,0 +1,152 @@
+package parse
+
+import (
+	"bytes"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// readPool reads a constant pool of count entries from the bytes of its entries.
+func readPool(count int, entries ...byte) (int, []CpInfo, error) {
+	content := append([]byte{byte(count >> 8), byte(count)}, entries...)
+	return (*ClassFileReader)(bytes.NewReader(content)).ReadConstantPool()
+}
+
+func TestReadCPInfo(t *testing.T) {
+	tests := []struct {
+		name  string
+		entry []byte
+		want  CpInfo
+	}{
+		{"MethodType", []byte{16, 0, 7}, ConstantMethodTypeInfo{tag: 16, DescriptorIndex: 7}},
+		{"Dynamic", []byte{17, 0, 2, 1, 4}, ConstantDynamicInfo{tag: 17, BootstrapMethodAttrIndex: 2, NameAndTypeIndex: 260}},
+		{"Module", []byte{19, 0, 3}, ConstantModuleInfo{tag: 19, NameIndex: 3}},
+		{"Package", []byte{20, 0xFF, 0xFE}, ConstantPackageInfo{tag: 20, NameIndex: 65534}},
+		{"MethodHandle", []byte{15, 6, 0, 9}, ConstantMethodHandleInfo{tag: 15, ReferenceKind: 6, ReferenceIndex: 9}},
+		{"InvokeDynamic", []byte{18, 0, 0, 0, 5}, ConstantInvokeDynamicInfo{tag: 18, NameAndTypeIndex: 5}},
+		{"Float", []byte{4, 0x3F, 0xC0, 0, 0}, ConstantFloatInfo{tag: 4, Float: 1.5}},
+		{"negative Float", []byte{4, 0xC0, 0x20, 0, 0}, ConstantFloatInfo{tag: 4, Float: -2.5}},
+		{"Float infinity", []byte{4, 0xFF, 0x80, 0, 0}, ConstantFloatInfo{tag: 4, Float: float32(math.Inf(-1))}},
+		{"Long", []byte{5, 0, 0, 0, 1, 0, 0, 0, 2}, ConstantLongInfo{tag: 5, Long: 1<<32 | 2}},
+		{"negative Long", []byte{5, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFE}, ConstantLongInfo{tag: 5, Long: -2}},
+		{"min Long", []byte{5, 0x80, 0, 0, 0, 0, 0, 0, 0}, ConstantLongInfo{tag: 5, Long: math.MinInt64}},
+		{"Double", []byte{6, 0x40, 0x09, 0x21, 0xFB, 0x54, 0x44, 0x2D, 0x18}, ConstantDoubleInfo{tag: 6, Double: math.Pi}},
+		{"negative Double", []byte{6, 0xBF, 0xF0, 0, 0, 0, 0, 0, 0}, ConstantDoubleInfo{tag: 6, Double: -1}},
+		{"negative zero Double", []byte{6, 0x80, 0, 0, 0, 0, 0, 0, 0}, ConstantDoubleInfo{tag: 6, Double: math.Copysign(0, -1)}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := (*ClassFileReader)(bytes.NewReader(test.entry))
+			got, err := r.ReadCPInfo()
+			if err != nil {
+				t.Fatalf("ReadCPInfo failed: %v", err)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("got %+v, want %+v", got, test.want)
+			}
+			if d, ok := got.(ConstantDoubleInfo); ok && math.Signbit(d.Double) != math.Signbit(test.want.(ConstantDoubleInfo).Double) {
+				t.Errorf("sign of %v is lost", d.Double)
+			}
+			if r.Len() != 0 {
+				t.Errorf("%d bytes left", r.Len())
+			}
+		})
+	}
+}
+
+func TestReadCPInfoNaN(t *testing.T) {
+	tests := []struct {
+		name  string
+		entry []byte
+		bits  uint64 // the bits of the NaN, which must be kept
+	}{
+		{"Float NaN", []byte{4, 0x7F, 0xC0, 0, 0}, 0x7FC00000},
+		{"Float NaN with payload", []byte{4, 0xFF, 0x80, 0, 1}, 0xFF800001},
+		{"Double NaN", []byte{6, 0x7F, 0xF8, 0, 0, 0, 0, 0, 0}, 0x7FF8000000000000},
+		{"Double NaN with payload", []byte{6, 0xFF, 0xF0, 0, 0, 0, 0, 0, 1}, 0xFFF0000000000001},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := (*ClassFileReader)(bytes.NewReader(test.entry)).ReadCPInfo()
+			if err != nil {
+				t.Fatalf("ReadCPInfo failed: %v", err)
+			}
+			var bits uint64
+			switch c := got.(type) {
+			case ConstantFloatInfo:
+				if !math.IsNaN(float64(c.Float)) {
+					t.Fatalf("got %v, want NaN", c.Float)
+				}
+				bits = uint64(math.Float32bits(c.Float))
+			case ConstantDoubleInfo:
+				if !math.IsNaN(c.Double) {
+					t.Fatalf("got %v, want NaN", c.Double)
+				}
+				bits = math.Float64bits(c.Double)
+			}
+			if bits != test.bits {
+				t.Errorf("bits %#x, want %#x", bits, test.bits)
+			}
+		})
+	}
+}
+
+func TestReadConstantPool(t *testing.T) {
+	// a long at index 1 and a double at index 4 take up two entries each, so the Utf8 is at 3 and the Module at 6
+	count, entries, err := readPool(7,
+		5, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF,
+		1, 0, 1, 'M',
+		6, 0x3F, 0xF0, 0, 0, 0, 0, 0, 0,
+		19, 0, 3,
+	)
+	if err != nil {
+		t.Fatalf("ReadConstantPool failed: %v", err)
+	}
+	if count != 7 || len(entries) != 6 {
+		t.Fatalf("constant_pool_count %d with %d entries, want 7 with 6", count, len(entries))
+	}
+
+	long := ConstantLongInfo{tag: 5, Long: -1}
+	double := ConstantDoubleInfo{tag: 6, Double: 1}
+	want := []CpInfo{long, long, nil, double, double, ConstantModuleInfo{tag: 19, NameIndex: 3}}
+	for i, w := range want {
+		if w == nil {
+			if text, ok := entries[i].(ConstantUtf8Info); !ok || string(text.Content) != "M" {
+				t.Errorf("entry %d is %+v, want the Utf8 M", i+1, entries[i])
+			}
+		} else if !reflect.DeepEqual(entries[i], w) {
+			t.Errorf("entry %d is %+v, want %+v", i+1, entries[i], w)
+		}
+	}
+}
+
+func TestReadConstantPoolErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		count   int
+		entries []byte
+		reason  string
+	}{
+		{"unknown tag", 2, []byte{2, 0, 0}, `constant pool tag "2" not implemented`},
+		{"truncated Dynamic", 2, []byte{17, 0, 1, 0}, "couldn't read 2 bytes"},
+		{"truncated Long", 3, []byte{5, 0, 0, 0, 0, 0, 0, 0}, "couldn't read 4 bytes"},
+		{"second entry truncated", 3, []byte{16, 0, 1, 20, 0}, "constant_pool[2]"},
+		{"entry after a double", 4, []byte{6, 0, 0, 0, 0, 0, 0, 0, 0, 19}, "constant_pool[3]"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, entries, err := readPool(test.count, test.entries...)
+			if err == nil {
+				t.Fatalf("ReadConstantPool returned %v, want an error", entries)
+			}
+			if !strings.Contains(err.Error(), test.reason) {
+				t.Errorf("got error %q, want one containing %q", err, test.reason)
+			}
+		})
+	}
+}
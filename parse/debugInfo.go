@@ -0,0 +1,178 @@
+package parse
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// LineNumberTableEntry maps the code starting at StartPc to a line of the source file.
+type LineNumberTableEntry struct {
+	StartPc    int // u2
+	LineNumber int // u2
+}
+
+// LocalVariableTableEntry describes a local variable in the code from StartPc to StartPc+Length.
+//
+// In a "LocalVariableTypeTable" Descriptor holds the generic signature of the variable instead of its descriptor.
+type LocalVariableTableEntry struct {
+	StartPc         int // u2
+	Length          int // u2
+	NameIndex       int // u2
+	Name            string
+	DescriptorIndex int // u2 descriptor_index or signature_index
+	Descriptor      string
+	Index           int // u2 local variable slot
+}
+
+// covers reports if the variable has a value at pc.
+func (e LocalVariableTableEntry) covers(pc int) bool {
+	return pc >= e.StartPc && pc < e.StartPc+e.Length
+}
+
+// LineNumber returns the source line of the instruction at pc.
+func (c *CodeAttribute) LineNumber(pc int) (line int, ok bool) {
+	start := -1
+	for _, entry := range c.LineNumberTable {
+		if entry.StartPc <= pc && entry.StartPc > start {
+			start = entry.StartPc
+			line = entry.LineNumber
+			ok = true
+		}
+	}
+	return
+}
+
+// LocalVariable returns the local variable stored in slot at pc.
+func (c *CodeAttribute) LocalVariable(slot int, pc int) (LocalVariableTableEntry, bool) {
+	return findLocalVariable(c.LocalVariableTable, slot, pc)
+}
+
+// LocalVariableType returns the generic signature of the local variable stored in slot at pc. Only variables with a
+// generic type have one.
+func (c *CodeAttribute) LocalVariableType(slot int, pc int) (LocalVariableTableEntry, bool) {
+	return findLocalVariable(c.LocalVariableTypeTable, slot, pc)
+}
+
+func findLocalVariable(table []LocalVariableTableEntry, slot int, pc int) (LocalVariableTableEntry, bool) {
+	for _, entry := range table {
+		if entry.Index == slot && entry.covers(pc) {
+			return entry, true
+		}
+	}
+	return LocalVariableTableEntry{}, false
+}
+
+// ReadLineNumberTable reads the content of a "LineNumberTable" attribute.
+func (r *ClassFileReader) ReadLineNumberTable() (entries []LineNumberTableEntry, err error) {
+	size, err := r.ReadU2()
+	if err != nil {
+		return
+	}
+
+	for i := 0; i < size; i++ {
+		var entry LineNumberTableEntry
+		entry.StartPc, err = r.ReadU2()
+		if err != nil {
+			return
+		}
+		entry.LineNumber, err = r.ReadU2()
+		if err != nil {
+			return
+		}
+		entries = append(entries, entry)
+	}
+
+	return
+}
+
+// ReadLocalVariableTable reads the content of a "LocalVariableTable" or "LocalVariableTypeTable" attribute.
+func (r *ClassFileReader) ReadLocalVariableTable() (entries []LocalVariableTableEntry, err error) {
+	size, err := r.ReadU2()
+	if err != nil {
+		return
+	}
+
+	for i := 0; i < size; i++ {
+		var entry LocalVariableTableEntry
+		entry, err = r.ReadLocalVariableTableEntry()
+		if err != nil {
+			return
+		}
+		entries = append(entries, entry)
+	}
+
+	return
+}
+
+// ReadLocalVariableTableEntry reads one entry of a "LocalVariableTable" or "LocalVariableTypeTable" attribute.
+func (r *ClassFileReader) ReadLocalVariableTableEntry() (entry LocalVariableTableEntry, err error) {
+	entry.StartPc, err = r.ReadU2()
+	if err != nil {
+		return
+	}
+
+	entry.Length, err = r.ReadU2()
+	if err != nil {
+		return
+	}
+
+	entry.NameIndex, err = r.ReadU2()
+	if err != nil {
+		return
+	}
+
+	entry.DescriptorIndex, err = r.ReadU2()
+	if err != nil {
+		return
+	}
+
+	entry.Index, err = r.ReadU2()
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+// decodeCodeAttributes decodes the attributes of a Code attribute the parser knows about.
+//
+// A method may have several tables of each kind, their entries are concatenated.
+func (f *ClassFile) decodeCodeAttributes(code *CodeAttribute) error {
+	for i, attribute := range code.Attributes {
+		name, err := f.attributeName(attribute)
+		if err != nil {
+			return atPath(fmt.Sprintf("attributes[%d].attribute_name_index", i), err)
+		}
+
+		reader := (*ClassFileReader)(bytes.NewReader(attribute.Info))
+		switch name {
+		case "LineNumberTable":
+			var entries []LineNumberTableEntry
+			entries, err = reader.ReadLineNumberTable()
+			code.LineNumberTable = append(code.LineNumberTable, entries...)
+		case "LocalVariableTable", "LocalVariableTypeTable":
+			var entries []LocalVariableTableEntry
+			entries, err = reader.ReadLocalVariableTable()
+			for i := 0; i < len(entries) && err == nil; i++ {
+				entries[i].Name, err = f.utf8Text(entries[i].NameIndex)
+				if err == nil {
+					entries[i].Descriptor, err = f.utf8Text(entries[i].DescriptorIndex)
+				}
+			}
+			if name == "LocalVariableTable" {
+				code.LocalVariableTable = append(code.LocalVariableTable, entries...)
+			} else {
+				code.LocalVariableTypeTable = append(code.LocalVariableTypeTable, entries...)
+			}
+		default:
+			continue
+		}
+		if err != nil {
+			return atPath("attributes["+name+"]", fmt.Errorf("%s attribute: %w", name, err))
+		}
+		if reader.Len() != 0 {
+			return atPath("attributes["+name+"]", fmt.Errorf("couldn't fully read the %s attribute", name))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,148 @@
+package parse
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// localVariableAdder adds the LocalVariableTable javac -g emits for the methods of HelloWorld: this in the
+// constructor and args in main, both for the whole code.
+type localVariableAdder struct {
+	ClassAdapter
+}
+
+func (a localVariableAdder) VisitMethod(accessFlags MethodAccessFlags, name, descriptor string, exceptions []string) MethodVisitor {
+	next := a.ClassAdapter.VisitMethod(accessFlags, name, descriptor, exceptions)
+	if name == "<init>" {
+		return &localVariableMethodAdder{MethodAdapter: MethodAdapter{next}, name: "this", descriptor: "LHelloWorld;"}
+	}
+	return &localVariableMethodAdder{MethodAdapter: MethodAdapter{next}, name: "args", descriptor: descriptor[1 : len(descriptor)-2]}
+}
+
+type localVariableMethodAdder struct {
+	MethodAdapter
+	name, descriptor string
+	start            *Label
+}
+
+func (a *localVariableMethodAdder) VisitCode() {
+	a.MethodAdapter.VisitCode()
+	a.start = NewLabel()
+	a.MethodAdapter.VisitLabel(a.start)
+}
+
+func (a *localVariableMethodAdder) VisitMaxs(maxStack, maxLocals int) {
+	end := NewLabel()
+	a.MethodAdapter.VisitLabel(end)
+	a.MethodAdapter.VisitLocalVariable(a.name, a.descriptor, a.start, end, 0)
+	a.MethodAdapter.VisitMaxs(maxStack, maxLocals)
+}
+
+// helloWorldDebug returns testdata/HelloWorld.class with the debug information javac -g adds.
+func helloWorldDebug(t *testing.T) ClassFile {
+	content, err := os.ReadFile(filepath.Join("testdata", "HelloWorld.class"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cf, err := rewrite(content, func(next ClassVisitor) ClassVisitor { return localVariableAdder{ClassAdapter{next}} })
+	if err != nil {
+		t.Fatalf("adding the LocalVariableTable failed: %v", err)
+	}
+	return cf
+}
+
+func TestLineNumber(t *testing.T) {
+	cf := helloWorldDebug(t)
+	constructor, main := cf.Methods[0].Code, cf.Methods[1].Code
+
+	tests := []struct {
+		name string
+		code *CodeAttribute
+		pc   int
+		line int
+		ok   bool
+	}{
+		{"constructor start", constructor, 0, 15, true},
+		{"constructor return", constructor, 4, 15, true},
+		{"first entry", main, 0, 17, true},
+		{"between entries", main, 10, 17, true},
+		{"before the second entry", main, 27, 17, true},
+		{"second entry", main, 28, 18, true},
+		{"after the code", main, 29, 18, true},
+		{"no LineNumberTable", &CodeAttribute{}, 0, 0, false},
+		{"before the first entry", &CodeAttribute{LineNumberTable: []LineNumberTableEntry{{StartPc: 3, LineNumber: 7}}}, 2, 0, false},
+		// javac emits entries out of pc order for loops, the closest entry at or before pc wins
+		{"entries out of order", &CodeAttribute{LineNumberTable: []LineNumberTableEntry{
+			{StartPc: 10, LineNumber: 5}, {StartPc: 0, LineNumber: 3}, {StartPc: 4, LineNumber: 9},
+		}}, 7, 9, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			line, ok := test.code.LineNumber(test.pc)
+			if line != test.line || ok != test.ok {
+				t.Errorf("LineNumber(%d) = %d, %v, want %d, %v", test.pc, line, ok, test.line, test.ok)
+			}
+		})
+	}
+}
+
+func TestLocalVariable(t *testing.T) {
+	cf := helloWorldDebug(t)
+	constructor, main := cf.Methods[0].Code, cf.Methods[1].Code
+	// slot 1 holds i from pc 2 to 8 and s from pc 10 to 20, slot 2 holds nothing
+	scoped := &CodeAttribute{
+		LocalVariableTable: []LocalVariableTableEntry{
+			{StartPc: 0, Length: 20, Name: "list", Descriptor: "Ljava/util/List;", Index: 0},
+			{StartPc: 2, Length: 6, Name: "i", Descriptor: "I", Index: 1},
+			{StartPc: 10, Length: 10, Name: "s", Descriptor: "Ljava/lang/String;", Index: 1},
+		},
+		LocalVariableTypeTable: []LocalVariableTableEntry{
+			{StartPc: 0, Length: 20, Name: "list", Descriptor: "Ljava/util/List<Ljava/lang/String;>;", Index: 0},
+		},
+	}
+
+	tests := []struct {
+		name string
+		code *CodeAttribute
+		slot int
+		pc   int
+		want string // name of the variable, "" if there is none
+	}{
+		{"this at the start", constructor, 0, 0, "this"},
+		{"this at the last instruction", constructor, 0, 4, "this"},
+		{"this after the code", constructor, 0, 5, ""},
+		{"args between line entries", main, 0, 10, "args"},
+		{"args at the return", main, 0, 28, "args"},
+		{"slot without variable", main, 1, 10, ""},
+		{"before the range", scoped, 1, 1, ""},
+		{"start of the range", scoped, 1, 2, "i"},
+		{"end of the range is exclusive", scoped, 1, 8, ""},
+		{"between two variables of a slot", scoped, 1, 9, ""},
+		{"second variable of a slot", scoped, 1, 10, "s"},
+		{"after the last variable", scoped, 1, 20, ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			entry, ok := test.code.LocalVariable(test.slot, test.pc)
+			if ok != (test.want != "") || entry.Name != test.want {
+				t.Errorf("LocalVariable(%d, %d) = %q, %v, want %q", test.slot, test.pc, entry.Name, ok, test.want)
+			}
+		})
+	}
+
+	if entry, _ := main.LocalVariable(0, 0); entry.Descriptor != "[Ljava/lang/String;" {
+		t.Errorf("args has the descriptor %s, want [Ljava/lang/String;", entry.Descriptor)
+	}
+	if _, ok := main.LocalVariableType(0, 0); ok {
+		t.Error("args has a generic signature")
+	}
+	if entry, ok := scoped.LocalVariableType(0, 5); !ok || entry.Descriptor != "Ljava/util/List<Ljava/lang/String;>;" {
+		t.Errorf("LocalVariableType(0, 5) = %+v, %v, want the signature of list", entry, ok)
+	}
+	if _, ok := scoped.LocalVariableType(1, 5); ok {
+		t.Error("i has a generic signature")
+	}
+}
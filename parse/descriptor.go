@@ -0,0 +1,169 @@
+package parse
+
+import (
+	"fmt"
+	"strings"
+)
+
+// FieldType is the type of a field descriptor (JVMS §4.3.2), or the result of a method descriptor, which may also
+// be void.
+type FieldType struct {
+	// Base is the descriptor character of the type or, for arrays, of the element type: B, C, D, F, I, J, S or Z for
+	// the primitive types, L for class types and V for void.
+	Base byte
+	// ClassName is the name of class types in internal form, e.g. java/lang/String.
+	ClassName string
+	// Dimensions is the number of array dimensions, 0 if the type isn't an array.
+	Dimensions int
+}
+
+// MethodDescriptor is a parsed method descriptor (JVMS §4.3.3).
+type MethodDescriptor struct {
+	Parameters []FieldType
+	Return     FieldType
+}
+
+// ClassType returns the type of the class called name, e.g. java/lang/String.
+func ClassType(name string) FieldType {
+	return FieldType{Base: 'L', ClassName: name}
+}
+
+// ParseFieldType parses and validates a field descriptor like "I" or "[Ljava/lang/String;".
+func ParseFieldType(descriptor string) (FieldType, error) {
+	t, n, ok := parseFieldType(descriptor)
+	if !ok || n != len(descriptor) {
+		return FieldType{}, fmt.Errorf("invalid field descriptor %q", descriptor)
+	}
+	return t, nil
+}
+
+// ParseMethodDescriptor parses and validates a method descriptor like "(I[Ljava/lang/String;)V".
+func ParseMethodDescriptor(descriptor string) (MethodDescriptor, error) {
+	invalid := fmt.Errorf("invalid method descriptor %q", descriptor)
+	if !strings.HasPrefix(descriptor, "(") {
+		return MethodDescriptor{}, invalid
+	}
+	var d MethodDescriptor
+	s := descriptor[1:]
+	for {
+		if s == "" {
+			return MethodDescriptor{}, invalid
+		}
+		if s[0] == ')' {
+			break
+		}
+		t, n, ok := parseFieldType(s)
+		if !ok {
+			return MethodDescriptor{}, invalid
+		}
+		d.Parameters = append(d.Parameters, t)
+		s = s[n:]
+	}
+
+	s = s[1:]
+	if s == "V" {
+		d.Return = FieldType{Base: 'V'}
+		return d, nil
+	}
+	t, n, ok := parseFieldType(s)
+	if !ok || n != len(s) {
+		return MethodDescriptor{}, invalid
+	}
+	d.Return = t
+	return d, nil
+}
+
+// parseFieldType parses the field descriptor at the start of s and returns its length.
+func parseFieldType(s string) (t FieldType, n int, ok bool) {
+	for n < len(s) && s[n] == '[' {
+		n++
+	}
+	if n > 255 || n == len(s) {
+		return FieldType{}, 0, false
+	}
+	t.Dimensions = n
+
+	t.Base = s[n]
+	switch t.Base {
+	case 'B', 'C', 'D', 'F', 'I', 'J', 'S', 'Z':
+		return t, n + 1, true
+	case 'L':
+		end := strings.IndexByte(s[n:], ';')
+		if end == -1 {
+			return FieldType{}, 0, false
+		}
+		t.ClassName = s[n+1 : n+end]
+		if strings.HasPrefix(t.ClassName, "[") || !validClassName(t.ClassName) {
+			return FieldType{}, 0, false
+		}
+		return t, n + end + 1, true
+	}
+	return FieldType{}, 0, false
+}
+
+// String returns the descriptor of the type.
+func (t FieldType) String() string {
+	element := string(t.Base)
+	if t.Base == 'L' {
+		element = "L" + t.ClassName + ";"
+	}
+	return strings.Repeat("[", t.Dimensions) + element
+}
+
+// IsVoid reports if the type is the void result of a method.
+func (t FieldType) IsVoid() bool {
+	return t.Base == 'V' && t.Dimensions == 0
+}
+
+// IsArray reports if the type is an array type.
+func (t FieldType) IsArray() bool {
+	return t.Dimensions > 0
+}
+
+// IsReference reports if the type is a class or array type.
+func (t FieldType) IsReference() bool {
+	return t.Base == 'L' || t.Dimensions > 0
+}
+
+// ElementType returns the type of the elements of an array type, e.g. [I for [[I.
+func (t FieldType) ElementType() FieldType {
+	if t.Dimensions > 0 {
+		t.Dimensions--
+	}
+	return t
+}
+
+// Slots returns the number of local variable or operand stack slots a value of the type takes up: 2 for long and
+// double, 0 for void and 1 for all other types.
+func (t FieldType) Slots() int {
+	switch {
+	case t.Dimensions > 0:
+		return 1
+	case t.Base == 'J' || t.Base == 'D':
+		return 2
+	case t.Base == 'V':
+		return 0
+	}
+	return 1
+}
+
+// ParameterSlots returns the number of local variable slots the parameters take up, without this.
+func (d MethodDescriptor) ParameterSlots() int {
+	slots := 0
+	for _, p := range d.Parameters {
+		slots += p.Slots()
+	}
+	return slots
+}
+
+// String returns the descriptor.
+func (d MethodDescriptor) String() string {
+	var b strings.Builder
+	b.WriteByte('(')
+	for _, p := range d.Parameters {
+		b.WriteString(p.String())
+	}
+	b.WriteByte(')')
+	b.WriteString(d.Return.String())
+	return b.String()
+}
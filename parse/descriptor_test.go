@@ -0,0 +1,169 @@
+package parse
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseFieldType(t *testing.T) {
+	tests := []struct {
+		descriptor string
+		want       FieldType
+		slots      int
+	}{
+		{"I", FieldType{Base: 'I'}, 1},
+		{"Z", FieldType{Base: 'Z'}, 1},
+		{"J", FieldType{Base: 'J'}, 2},
+		{"D", FieldType{Base: 'D'}, 2},
+		{"Ljava/lang/String;", ClassType("java/lang/String"), 1},
+		{"LT;", ClassType("T"), 1},
+		{"[J", FieldType{Base: 'J', Dimensions: 1}, 1},
+		{"[[Ljava/lang/Object;", FieldType{Base: 'L', ClassName: "java/lang/Object", Dimensions: 2}, 1},
+		{strings.Repeat("[", 255) + "B", FieldType{Base: 'B', Dimensions: 255}, 1},
+	}
+
+	for _, test := range tests {
+		t.Run(test.descriptor, func(t *testing.T) {
+			got, err := ParseFieldType(test.descriptor)
+			if err != nil {
+				t.Fatalf("ParseFieldType failed: %v", err)
+			}
+			if got != test.want {
+				t.Errorf("got %+v, want %+v", got, test.want)
+			}
+			if got.String() != test.descriptor {
+				t.Errorf("String returned %q, want %q", got.String(), test.descriptor)
+			}
+			if got.Slots() != test.slots {
+				t.Errorf("%d slots, want %d", got.Slots(), test.slots)
+			}
+		})
+	}
+}
+
+func TestParseFieldTypeErrors(t *testing.T) {
+	tests := []struct {
+		name       string
+		descriptor string
+	}{
+		{"empty", ""},
+		{"void", "V"},
+		{"unknown base type", "X"},
+		{"lower case", "i"},
+		{"trailing characters", "II"},
+		{"array without element type", "[["},
+		{"void array", "[V"},
+		{"256 dimensions", strings.Repeat("[", 256) + "I"},
+		{"class without semicolon", "Ljava/lang/String"},
+		{"empty class name", "L;"},
+		{"empty package", "Ljava//String;"},
+		{"trailing slash", "Ljava/lang/;"},
+		{"dot in class name", "Ljava.lang.String;"},
+		{"array as class name", "L[I;"},
+		{"trailing characters after class", "LT;I"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := ParseFieldType(test.descriptor)
+			if err == nil {
+				t.Fatalf("ParseFieldType returned %+v, want an error", got)
+			}
+			if !strings.Contains(err.Error(), "invalid field descriptor") {
+				t.Errorf("ParseFieldType returned %q, want an invalid field descriptor error", err)
+			}
+		})
+	}
+}
+
+func TestParseMethodDescriptor(t *testing.T) {
+	tests := []struct {
+		descriptor string
+		parameters []FieldType
+		result     FieldType
+		slots      int
+	}{
+		{"()V", nil, FieldType{Base: 'V'}, 0},
+		{"(I)I", []FieldType{{Base: 'I'}}, FieldType{Base: 'I'}, 1},
+		{"(JD)J", []FieldType{{Base: 'J'}, {Base: 'D'}}, FieldType{Base: 'J'}, 4},
+		{"([Ljava/lang/String;)V", []FieldType{{Base: 'L', ClassName: "java/lang/String", Dimensions: 1}},
+			FieldType{Base: 'V'}, 1},
+		{"(IJLT;[D)[[Z", []FieldType{{Base: 'I'}, {Base: 'J'}, ClassType("T"), {Base: 'D', Dimensions: 1}},
+			FieldType{Base: 'Z', Dimensions: 2}, 5},
+	}
+
+	for _, test := range tests {
+		t.Run(test.descriptor, func(t *testing.T) {
+			got, err := ParseMethodDescriptor(test.descriptor)
+			if err != nil {
+				t.Fatalf("ParseMethodDescriptor failed: %v", err)
+			}
+			if !reflect.DeepEqual(got.Parameters, test.parameters) || got.Return != test.result {
+				t.Errorf("got %+v, want parameters %+v and result %+v", got, test.parameters, test.result)
+			}
+			if got.String() != test.descriptor {
+				t.Errorf("String returned %q, want %q", got.String(), test.descriptor)
+			}
+			if got.ParameterSlots() != test.slots {
+				t.Errorf("%d parameter slots, want %d", got.ParameterSlots(), test.slots)
+			}
+			if got.Return.IsVoid() != (test.result.Base == 'V') {
+				t.Errorf("IsVoid returned %v for result %s", got.Return.IsVoid(), got.Return)
+			}
+		})
+	}
+}
+
+func TestParseMethodDescriptorErrors(t *testing.T) {
+	tests := []struct {
+		name       string
+		descriptor string
+	}{
+		{"empty", ""},
+		{"field descriptor", "I"},
+		{"missing (", "I)V"},
+		{"missing )", "(I"},
+		{"missing result", "(I)"},
+		{"void parameter", "(V)V"},
+		{"void array result", "()[V"},
+		{"two results", "()II"},
+		{"trailing characters after void", "()VV"},
+		{"unknown parameter type", "(X)V"},
+		{"class parameter without semicolon", "(Ljava/lang/String)V"},
+		{"empty class name", "(L;)V"},
+		{"dot in class name", "(Ljava.lang.String;)V"},
+		{"array without element type", "([)V"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := ParseMethodDescriptor(test.descriptor)
+			if err == nil {
+				t.Fatalf("ParseMethodDescriptor returned %+v, want an error", got)
+			}
+			if !strings.Contains(err.Error(), "invalid method descriptor") {
+				t.Errorf("ParseMethodDescriptor returned %q, want an invalid method descriptor error", err)
+			}
+		})
+	}
+}
+
+func TestFieldTypeArrays(t *testing.T) {
+	array, err := ParseFieldType("[[Ljava/lang/String;")
+	if err != nil {
+		t.Fatalf("ParseFieldType failed: %v", err)
+	}
+	if !array.IsArray() || !array.IsReference() {
+		t.Errorf("%s isn't an array reference", array)
+	}
+	if element := array.ElementType(); element.String() != "[Ljava/lang/String;" {
+		t.Errorf("element type %s, want [Ljava/lang/String;", element)
+	}
+	if element := array.ElementType().ElementType(); element.IsArray() || element != ClassType("java/lang/String") {
+		t.Errorf("element type of the element type %s, want Ljava/lang/String;", element)
+	}
+	if i := (FieldType{Base: 'I'}); i.IsArray() || i.IsReference() || i.ElementType() != i {
+		t.Errorf("int is an array or reference")
+	}
+}
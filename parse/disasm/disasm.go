@@ -0,0 +1,530 @@
+// Package disasm decodes the code array of a method into typed instructions.
+//
+// Constant pool references of the instructions are resolved against the class file the code belongs to, so debug
+// tooling, the verifier and the interpreter don't each need their own knowledge of the instruction encoding. The
+// opcode table is part of the API for the same reason: Lookup finds an opcode by its mnemonic and Opcode.Format tells
+// how its operands are encoded, which is all code that writes instructions needs to know about them.
+package disasm
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/PPTide/gojdk/parse"
+)
+
+// Instruction is one decoded instruction of a code array.
+type Instruction struct {
+	Pc     int
+	Opcode Opcode // for instructions modified by wide the opcode of the modified instruction
+	Length int    // in bytes, including the operands, switch padding and the wide prefix
+	Wide   bool   // the instruction is modified by wide
+
+	Local     int     // local variable index of loads, stores, iinc and ret
+	Immediate int     // value of bipush, sipush and iinc, array type of newarray, count of invokeinterface, dimensions of multianewarray
+	Target    int     // absolute target pc of branches
+	Switch    *Switch // jump table of tableswitch and lookupswitch
+
+	Index      int          // constant pool index, 0 if the instruction doesn't reference the constant pool
+	Constant   parse.CpInfo // entry at Index, nil if it wasn't resolved
+	Class      string       // resolved class of class references and of field, method and method handle references
+	Name       string       // resolved member name of references, invokedynamic and dynamic constants, or the text of a string
+	Descriptor string       // resolved descriptor of references, invokedynamic, dynamic constants and method types
+}
+
+// Switch is the jump table of a tableswitch or lookupswitch.
+type Switch struct {
+	Default int   // absolute target pc if no key matches
+	Low     int   // first key of a tableswitch
+	High    int   // last key of a tableswitch
+	Keys    []int // keys of a lookupswitch, Low to High for a tableswitch
+	Targets []int // absolute target pc of each key
+}
+
+// Array types of the newarray instruction (JVMS §6.5.newarray).
+var arrayTypes = map[int]string{
+	4:  "boolean",
+	5:  "char",
+	6:  "float",
+	7:  "double",
+	8:  "byte",
+	9:  "short",
+	10: "int",
+	11: "long",
+}
+
+// ArrayType returns the newarray operand of arrays with elements of the primitive type name, e.g. 10 for "int".
+func ArrayType(name string) (int, bool) {
+	for code, n := range arrayTypes {
+		if n == name {
+			return code, true
+		}
+	}
+	return 0, false
+}
+
+// Mnemonic returns the mnemonic of the instruction, e.g. "invokevirtual".
+func (i Instruction) Mnemonic() string {
+	return i.Opcode.String()
+}
+
+// Next returns the pc of the instruction following i.
+func (i Instruction) Next() int {
+	return i.Pc + i.Length
+}
+
+// String returns the instruction with its operands, e.g. "getstatic #7", "iinc 1, -1" or "ifeq 12".
+func (i Instruction) String() string {
+	s := i.Mnemonic()
+	if i.Wide {
+		s = "wide " + s
+	}
+	if operands := i.Operands(); operands != "" {
+		s += " " + operands
+	}
+	return s
+}
+
+// Operands returns the operands of the instruction as String shows them, "" for instructions without operands.
+func (i Instruction) Operands() string {
+	switch opcodes[i.Opcode].format {
+	case LocalOperand:
+		return strconv.Itoa(i.Local)
+	case ByteOperand, ShortOperand:
+		return strconv.Itoa(i.Immediate)
+	case ConstantByte, ConstantShort, InvokeDynamicOperands:
+		return fmt.Sprintf("#%d", i.Index)
+	case IincOperands:
+		return fmt.Sprintf("%d, %d", i.Local, i.Immediate)
+	case BranchShort, BranchInt:
+		return strconv.Itoa(i.Target)
+	case InvokeInterfaceOperands, MultiANewArrayOperands:
+		return fmt.Sprintf("#%d, %d", i.Index, i.Immediate)
+	case NewArrayOperand:
+		if name, ok := arrayTypes[i.Immediate]; ok {
+			return name
+		}
+		return strconv.Itoa(i.Immediate)
+	case TableSwitchOperands, LookupSwitchOperands:
+		var b strings.Builder
+		b.WriteString("{")
+		for k, key := range i.Switch.Keys {
+			fmt.Fprintf(&b, " %d: %d,", key, i.Switch.Targets[k])
+		}
+		fmt.Fprintf(&b, " default: %d }", i.Switch.Default)
+		return b.String()
+	}
+	return ""
+}
+
+// Comment describes the resolved constant pool reference of the instruction the way javap does, e.g.
+// "Method java/io/PrintStream.println:(Ljava/lang/String;)V". It's "" if there is none.
+func (i Instruction) Comment() string {
+	switch t := i.Constant.(type) {
+	case parse.ConstantFieldrefInfo:
+		return "Field " + memberName(i.Class, i.Name, i.Descriptor)
+	case parse.ConstantMethodrefInfo:
+		return "Method " + memberName(i.Class, i.Name, i.Descriptor)
+	case parse.ConstantInterfaceMethodrefInfo:
+		return "InterfaceMethod " + memberName(i.Class, i.Name, i.Descriptor)
+	case parse.ConstantClassInfo:
+		return "class " + quoteName(i.Class)
+	case parse.ConstantStringInfo:
+		return "String " + i.Name
+	case parse.ConstantIntegerInfo:
+		return "int " + Literal(t)
+	case parse.ConstantFloatInfo:
+		return "float " + Literal(t)
+	case parse.ConstantLongInfo:
+		return "long " + Literal(t)
+	case parse.ConstantDoubleInfo:
+		return "double " + Literal(t)
+	case parse.ConstantMethodTypeInfo:
+		return "MethodType " + i.Descriptor
+	case parse.ConstantMethodHandleInfo:
+		return "MethodHandle " + ReferenceKind(t.ReferenceKind) + " " + memberName(i.Class, i.Name, i.Descriptor)
+	case parse.ConstantInvokeDynamicInfo:
+		return fmt.Sprintf("InvokeDynamic #%d:%s:%s", t.BootstrapMethodAttrIndex, quoteName(i.Name), i.Descriptor)
+	case parse.ConstantDynamicInfo:
+		return fmt.Sprintf("Dynamic #%d:%s:%s", t.BootstrapMethodAttrIndex, quoteName(i.Name), i.Descriptor)
+	}
+	return ""
+}
+
+// ReferenceKind returns the name of a method handle reference kind, e.g. "REF_invokeStatic".
+func ReferenceKind(kind byte) string {
+	kinds := [...]string{"", "REF_getField", "REF_getStatic", "REF_putField", "REF_putStatic", "REF_invokeVirtual",
+		"REF_invokeStatic", "REF_invokeSpecial", "REF_newInvokeSpecial", "REF_invokeInterface"}
+	if int(kind) >= len(kinds) || kind == 0 {
+		return fmt.Sprintf("REF_%d", kind)
+	}
+	return kinds[kind]
+}
+
+// memberName formats a member reference like javap, e.g. java/lang/Object."<init>":()V.
+func memberName(class string, name string, descriptor string) string {
+	return quoteName(class) + "." + quoteName(name) + ":" + descriptor
+}
+
+// quoteName quotes names javap would quote, like "<init>" and array classes.
+func quoteName(name string) string {
+	if strings.HasPrefix(name, "<") || strings.HasPrefix(name, "[") {
+		return strconv.Quote(name)
+	}
+	return name
+}
+
+// Literal formats a numeric constant the way javap does, e.g. "5", "1.5f", "5l" or "1.5d". It's "" for other
+// constants.
+func Literal(info parse.CpInfo) string {
+	switch t := info.(type) {
+	case parse.ConstantIntegerInfo:
+		return strconv.Itoa(int(int32(t.Integer)))
+	case parse.ConstantFloatInfo:
+		return formatFloat(float64(t.Float), 32) + "f"
+	case parse.ConstantLongInfo:
+		return strconv.FormatInt(t.Long, 10) + "l"
+	case parse.ConstantDoubleInfo:
+		return formatFloat(t.Double, 64) + "d"
+	}
+	return ""
+}
+
+// formatFloat formats floating point constants like Java's Float.toString and Double.toString.
+func formatFloat(f float64, bitSize int) string {
+	switch {
+	case math.IsNaN(f):
+		return "NaN"
+	case math.IsInf(f, 1):
+		return "Infinity"
+	case math.IsInf(f, -1):
+		return "-Infinity"
+	}
+	abs := math.Abs(f)
+	if abs != 0 && (abs < 1e-3 || abs >= 1e7) {
+		s := strconv.FormatFloat(f, 'E', -1, bitSize)
+		mantissa, exponent, _ := strings.Cut(s, "E")
+		if !strings.Contains(mantissa, ".") {
+			mantissa += ".0"
+		}
+		e, _ := strconv.Atoi(exponent)
+		return mantissa + "E" + strconv.Itoa(e)
+	}
+	s := strconv.FormatFloat(f, 'f', -1, bitSize)
+	if !strings.Contains(s, ".") {
+		s += ".0"
+	}
+	return s
+}
+
+// DecodeMethod decodes the code of method, which has to be a method of cf. Methods without code have no instructions.
+func DecodeMethod(cf *parse.ClassFile, method parse.MethodInfo) ([]Instruction, error) {
+	if method.Code == nil {
+		return nil, nil
+	}
+	return Decode(method.Code.Code, cf)
+}
+
+// Decode decodes all instructions of code. If cf isn't nil the constant pool references are resolved against it.
+func Decode(code []byte, cf *parse.ClassFile) ([]Instruction, error) {
+	var instructions []Instruction
+	for pc := 0; pc < len(code); {
+		instruction, err := DecodeInstruction(code, pc, cf)
+		if err != nil {
+			return instructions, fmt.Errorf("pc %d: %w", pc, err)
+		}
+		instructions = append(instructions, instruction)
+		pc = instruction.Next()
+	}
+	return instructions, nil
+}
+
+// DecodeInstruction decodes the instruction at pc. If cf isn't nil its constant pool reference is resolved against it.
+func DecodeInstruction(code []byte, pc int, cf *parse.ClassFile) (instruction Instruction, err error) {
+	if pc < 0 || pc >= len(code) {
+		return instruction, fmt.Errorf("pc %d outside of the code", pc)
+	}
+	instruction.Pc = pc
+	instruction.Opcode = Opcode(code[pc])
+	if !instruction.Opcode.Valid() {
+		return instruction, fmt.Errorf("illegal opcode %d", code[pc])
+	}
+
+	operands := pc + 1
+	format := opcodes[instruction.Opcode].format
+	if format == WideOperand {
+		if operands >= len(code) {
+			return instruction, errors.New("truncated wide instruction")
+		}
+		instruction.Opcode = Opcode(code[operands])
+		instruction.Wide = true
+		operands++
+		format = opcodes[instruction.Opcode].format
+		if format != LocalOperand && format != IincOperands {
+			return instruction, fmt.Errorf("opcode %d can't be modified by wide", byte(instruction.Opcode))
+		}
+	}
+
+	// has sets the length of the instruction to n bytes of operands and checks that they are part of the code
+	has := func(n int) bool {
+		instruction.Length = operands + n - pc
+		if operands+n > len(code) {
+			err = fmt.Errorf("%s runs over the end of the code", instruction.Opcode)
+			return false
+		}
+		return true
+	}
+
+	switch format {
+	case NoOperands:
+		has(0)
+	case LocalOperand:
+		if instruction.Wide && has(2) {
+			instruction.Local = u2(code, operands)
+		} else if !instruction.Wide && has(1) {
+			instruction.Local = u1(code, operands)
+		}
+	case ByteOperand:
+		if has(1) {
+			instruction.Immediate = s1(code, operands)
+		}
+	case ShortOperand:
+		if has(2) {
+			instruction.Immediate = s2(code, operands)
+		}
+	case ConstantByte:
+		if has(1) {
+			instruction.Index = u1(code, operands)
+		}
+	case ConstantShort:
+		if has(2) {
+			instruction.Index = u2(code, operands)
+		}
+	case InvokeDynamicOperands:
+		if has(4) {
+			instruction.Index = u2(code, operands)
+		}
+	case IincOperands:
+		if instruction.Wide && has(4) {
+			instruction.Local, instruction.Immediate = u2(code, operands), s2(code, operands+2)
+		} else if !instruction.Wide && has(2) {
+			instruction.Local, instruction.Immediate = u1(code, operands), s1(code, operands+1)
+		}
+	case BranchShort:
+		if has(2) {
+			instruction.Target = pc + s2(code, operands)
+		}
+	case BranchInt:
+		if has(4) {
+			instruction.Target = pc + s4(code, operands)
+		}
+	case InvokeInterfaceOperands:
+		if has(4) {
+			instruction.Index, instruction.Immediate = u2(code, operands), u1(code, operands+2)
+		}
+	case NewArrayOperand:
+		if has(1) {
+			instruction.Immediate = u1(code, operands)
+		}
+	case MultiANewArrayOperands:
+		if has(3) {
+			instruction.Index, instruction.Immediate = u2(code, operands), u1(code, operands+2)
+		}
+	case TableSwitchOperands, LookupSwitchOperands:
+		instruction.Switch, instruction.Length, err = decodeSwitch(code, pc, format)
+	}
+	if err != nil {
+		return
+	}
+
+	if cf != nil && instruction.ReferencesConstant() {
+		err = instruction.resolve(cf)
+	}
+	return
+}
+
+// ReferencesConstant reports if the instruction has a constant pool index operand.
+func (i Instruction) ReferencesConstant() bool {
+	switch opcodes[i.Opcode].format {
+	case ConstantByte, ConstantShort, InvokeInterfaceOperands, InvokeDynamicOperands, MultiANewArrayOperands:
+		return true
+	}
+	return false
+}
+
+// decodeSwitch decodes the jump table of the tableswitch or lookupswitch at pc and returns the length of the
+// instruction.
+func decodeSwitch(code []byte, pc int, format Format) (s *Switch, length int, err error) {
+	start := pc + 1 + (3-pc%4)%4 // the operands are 4 byte aligned to the start of the code
+	fixed := 8                   // default and npairs
+	if format == TableSwitchOperands {
+		fixed = 12 // default, low and high
+	}
+	if start+fixed > len(code) {
+		return nil, 0, errors.New("truncated switch instruction")
+	}
+
+	s = &Switch{Default: pc + s4(code, start)}
+	var count, entries, entrySize int
+	if format == TableSwitchOperands {
+		s.Low, s.High = s4(code, start+4), s4(code, start+8)
+		if s.Low > s.High {
+			return nil, 0, fmt.Errorf("tableswitch low %d bigger than high %d", s.Low, s.High)
+		}
+		count, entrySize = s.High-s.Low+1, 4
+	} else {
+		count, entrySize = s4(code, start+4), 8
+		if count < 0 {
+			return nil, 0, fmt.Errorf("negative lookupswitch npairs %d", count)
+		}
+	}
+	entries = start + fixed
+	// checked before allocating, so a crafted count can't allocate more than the code size
+	if count > (len(code)-entries)/entrySize {
+		return nil, 0, errors.New("switch instruction runs over the end of the code")
+	}
+
+	s.Keys = make([]int, count)
+	s.Targets = make([]int, count)
+	for k := 0; k < count; k++ {
+		if format == TableSwitchOperands {
+			s.Keys[k] = s.Low + k
+			s.Targets[k] = pc + s4(code, entries+4*k)
+		} else {
+			s.Keys[k] = s4(code, entries+8*k)
+			s.Targets[k] = pc + s4(code, entries+8*k+4)
+		}
+	}
+	return s, entries + count*entrySize - pc, nil
+}
+
+// resolve looks up the constant pool entry of the instruction in cf.
+func (i *Instruction) resolve(cf *parse.ClassFile) (err error) {
+	i.Constant, err = constant(cf, i.Index)
+	if err != nil {
+		return
+	}
+
+	switch t := i.Constant.(type) {
+	case parse.ConstantClassInfo:
+		i.Class, err = utf8(cf, t.NameIndex)
+	case parse.ConstantStringInfo:
+		i.Name, err = utf8(cf, t.StringIndex)
+	case parse.ConstantFieldrefInfo, parse.ConstantMethodrefInfo, parse.ConstantInterfaceMethodrefInfo:
+		i.Class, i.Name, i.Descriptor, err = memberRef(cf, i.Index)
+	case parse.ConstantMethodHandleInfo:
+		i.Class, i.Name, i.Descriptor, err = memberRef(cf, t.ReferenceIndex)
+	case parse.ConstantMethodTypeInfo:
+		i.Descriptor, err = utf8(cf, t.DescriptorIndex)
+	case parse.ConstantInvokeDynamicInfo:
+		i.Name, i.Descriptor, err = nameAndType(cf, t.NameAndTypeIndex)
+	case parse.ConstantDynamicInfo:
+		i.Name, i.Descriptor, err = nameAndType(cf, t.NameAndTypeIndex)
+	}
+	return
+}
+
+// ------------------- Constant Pool ---------------------
+
+func constant(cf *parse.ClassFile, index int) (parse.CpInfo, error) {
+	if index < 1 || index > len(cf.ConstantPool) {
+		return nil, fmt.Errorf("constant pool index %d out of range", index)
+	}
+	return cf.ConstantPool[index-1], nil
+}
+
+func utf8(cf *parse.ClassFile, index int) (string, error) {
+	info, err := constant(cf, index)
+	if err != nil {
+		return "", err
+	}
+	utf8, ok := info.(parse.ConstantUtf8Info)
+	if !ok {
+		return "", fmt.Errorf("constant pool entry %d is %s instead of ConstantUtf8Info", index, reflect.TypeOf(info).Name())
+	}
+	return utf8.Text, nil
+}
+
+func className(cf *parse.ClassFile, index int) (string, error) {
+	info, err := constant(cf, index)
+	if err != nil {
+		return "", err
+	}
+	class, ok := info.(parse.ConstantClassInfo)
+	if !ok {
+		return "", fmt.Errorf("constant pool entry %d is %s instead of ConstantClassInfo", index, reflect.TypeOf(info).Name())
+	}
+	return utf8(cf, class.NameIndex)
+}
+
+func nameAndType(cf *parse.ClassFile, index int) (name string, descriptor string, err error) {
+	info, err := constant(cf, index)
+	if err != nil {
+		return
+	}
+	nat, ok := info.(parse.ConstantNameAndTypeInfo)
+	if !ok {
+		err = fmt.Errorf("constant pool entry %d is %s instead of ConstantNameAndTypeInfo", index, reflect.TypeOf(info).Name())
+		return
+	}
+	name, err = utf8(cf, nat.NameIndex)
+	if err != nil {
+		return
+	}
+	descriptor, err = utf8(cf, nat.DescriptorIndex)
+	return
+}
+
+// memberRef returns the class, name and descriptor of the field or method reference at index.
+func memberRef(cf *parse.ClassFile, index int) (class string, name string, descriptor string, err error) {
+	info, err := constant(cf, index)
+	if err != nil {
+		return
+	}
+
+	var classIndex, natIndex int
+	switch t := info.(type) {
+	case parse.ConstantFieldrefInfo:
+		classIndex, natIndex = t.ClassIndex, t.NameAndTypeIndex
+	case parse.ConstantMethodrefInfo:
+		classIndex, natIndex = t.ClassIndex, t.NameAndTypeIndex
+	case parse.ConstantInterfaceMethodrefInfo:
+		classIndex, natIndex = t.ClassIndex, t.NameAndTypeIndex
+	default:
+		err = fmt.Errorf("constant pool entry %d is %s instead of a member reference", index, reflect.TypeOf(info).Name())
+		return
+	}
+
+	class, err = className(cf, classIndex)
+	if err != nil {
+		return
+	}
+	name, descriptor, err = nameAndType(cf, natIndex)
+	return
+}
+
+// ------------------- Operands ---------------------
+
+func u1(code []byte, pc int) int {
+	return int(code[pc])
+}
+
+func s1(code []byte, pc int) int {
+	return int(int8(code[pc]))
+}
+
+func u2(code []byte, pc int) int {
+	return int(code[pc])<<8 | int(code[pc+1])
+}
+
+func s2(code []byte, pc int) int {
+	return int(int16(u2(code, pc)))
+}
+
+func s4(code []byte, pc int) int {
+	return int(int32(uint32(code[pc])<<24 | uint32(code[pc+1])<<16 | uint32(code[pc+2])<<8 | uint32(code[pc+3])))
+}
@@ -0,0 +1,147 @@
+package disasm
+
+import (
+	"strings"
+	"testing"
+)
+
+// code concatenates the parts of a code array, ints are written as s4.
+func code(parts ...interface{}) []byte {
+	var b []byte
+	for _, part := range parts {
+		switch p := part.(type) {
+		case int:
+			b = append(b, byte(p>>24), byte(p>>16), byte(p>>8), byte(p))
+		case []byte:
+			b = append(b, p...)
+		case Opcode:
+			b = append(b, byte(p))
+		}
+	}
+	return b
+}
+
+// nops returns n nop instructions to move the following instruction to pc n.
+func nops(n int) []byte {
+	return make([]byte, n)
+}
+
+func TestDecodeInstruction(t *testing.T) {
+	tests := []struct {
+		name   string
+		code   []byte
+		pc     int
+		want   string
+		length int
+	}{
+		// switch operands are 4 byte aligned to the start of the code
+		{"tableswitch at pc 0", code(Tableswitch, nops(3), 20, 1, 2, 10, 12),
+			0, "tableswitch { 1: 10, 2: 12, default: 20 }", 24},
+		{"tableswitch at pc 1", code(nops(1), Tableswitch, nops(2), 20, 1, 2, 10, 12),
+			1, "tableswitch { 1: 11, 2: 13, default: 21 }", 23},
+		{"tableswitch at pc 2", code(nops(2), Tableswitch, nops(1), 20, -1, -1, 10),
+			2, "tableswitch { -1: 12, default: 22 }", 18},
+		{"tableswitch at pc 3", code(nops(3), Tableswitch, 20, 5, 5, 10),
+			3, "tableswitch { 5: 13, default: 23 }", 17},
+		{"lookupswitch at pc 0", code(Lookupswitch, nops(3), 20, 2, -7, 10, 100, 12),
+			0, "lookupswitch { -7: 10, 100: 12, default: 20 }", 28},
+		{"lookupswitch at pc 2", code(nops(2), Lookupswitch, nops(1), 20, 1, 3, -2),
+			2, "lookupswitch { 3: 0, default: 22 }", 18},
+		{"empty lookupswitch", code(nops(3), Lookupswitch, 8, 0),
+			3, "lookupswitch { default: 11 }", 9},
+
+		// wide
+		{"iload", code(Iload, []byte{255}), 0, "iload 255", 2},
+		{"wide iload", code(Wide, Iload, []byte{1, 44}), 0, "wide iload 300", 4},
+		{"wide astore", code(Wide, Astore, []byte{255, 255}), 0, "wide astore 65535", 4},
+		{"iinc", code(Iinc, []byte{1, 0xFF}), 0, "iinc 1, -1", 3},
+		{"wide iinc", code(Wide, Iinc, []byte{1, 44, 0xFC, 0x18}), 0, "wide iinc 300, -1000", 6},
+		{"wide ret", code(nops(1), Wide, Ret, []byte{1, 0}), 1, "wide ret 256", 4},
+
+		// branches are absolute
+		{"goto backwards", code(nops(4), Goto, []byte{0xFF, 0xFC}), 4, "goto 0", 3},
+		{"goto_w", code(nops(1), GotoW, 70000), 1, "goto_w 70001", 5},
+		{"bipush", code(Bipush, []byte{0x80}), 0, "bipush -128", 2},
+		{"newarray", code(Newarray, []byte{10}), 0, "newarray int", 2},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			instruction, err := DecodeInstruction(test.code, test.pc, nil)
+			if err != nil {
+				t.Fatalf("DecodeInstruction failed: %v", err)
+			}
+			if got := instruction.String(); got != test.want {
+				t.Errorf("got %q, want %q", got, test.want)
+			}
+			if instruction.Length != test.length {
+				t.Errorf("length %d, want %d", instruction.Length, test.length)
+			}
+			if instruction.Next() != len(test.code) {
+				t.Errorf("next instruction at %d, want the end of the code at %d", instruction.Next(), len(test.code))
+			}
+		})
+	}
+}
+
+func TestDecodeInstructionErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		code   []byte
+		pc     int
+		reason string
+	}{
+		{"reserved opcode", []byte{0xFF}, 0, "illegal opcode 255"},
+		{"pc outside of the code", code(Nop), 1, "outside of the code"},
+		{"truncated operand", code(Sipush, []byte{1}), 0, "runs over the end of the code"},
+		{"truncated wide", code(Wide), 0, "truncated wide instruction"},
+		{"wide nop", code(Wide, Nop), 0, "can't be modified by wide"},
+		{"wide goto", code(Wide, Goto, []byte{0, 0}), 0, "can't be modified by wide"},
+		{"truncated wide iinc", code(Wide, Iinc, []byte{0, 1, 0}), 0, "runs over the end of the code"},
+		{"truncated switch", code(Tableswitch, nops(3), 20, 1), 0, "truncated switch instruction"},
+		{"tableswitch padding missing", code(nops(1), Tableswitch, 20, 1, 1, 10), 1, "runs over the end of the code"},
+		{"tableswitch low above high", code(Tableswitch, nops(3), 20, 2, 1), 0, "low 2 bigger than high 1"},
+		{"tableswitch runs over the end", code(Tableswitch, nops(3), 20, 1, 2, 10), 0, "runs over the end of the code"},
+		{"negative npairs", code(Lookupswitch, nops(3), 20, -1), 0, "negative lookupswitch npairs"},
+		{"huge npairs", code(Lookupswitch, nops(3), 20, 1<<30), 0, "runs over the end of the code"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			instruction, err := DecodeInstruction(test.code, test.pc, nil)
+			if err == nil {
+				t.Fatalf("DecodeInstruction returned %s, want an error", instruction)
+			}
+			if !strings.Contains(err.Error(), test.reason) {
+				t.Errorf("DecodeInstruction returned %q, want an error containing %q", err, test.reason)
+			}
+		})
+	}
+}
+
+func TestDecode(t *testing.T) {
+	// iload_0, tableswitch at pc 1 with 2 bytes of padding, two returns as targets, then a wide iinc
+	c := code(Iload0, Tableswitch, nops(2), 24, 0, 1, 23, 24, Return, Return, Wide, Iinc, []byte{1, 0, 0, 1})
+	instructions, err := Decode(c, nil)
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+
+	want := []struct {
+		pc       int
+		mnemonic string
+	}{{0, "iload_0"}, {1, "tableswitch"}, {24, "return"}, {25, "return"}, {26, "iinc"}}
+	if len(instructions) != len(want) {
+		t.Fatalf("decoded %d instructions, want %d: %v", len(instructions), len(want), instructions)
+	}
+	for i, instruction := range instructions {
+		if instruction.Pc != want[i].pc || instruction.Mnemonic() != want[i].mnemonic {
+			t.Errorf("instruction %d is %s at pc %d, want %s at pc %d", i, instruction.Mnemonic(), instruction.Pc,
+				want[i].mnemonic, want[i].pc)
+		}
+	}
+
+	if _, err := Decode(c[:len(c)-1], nil); err == nil || !strings.HasPrefix(err.Error(), "pc 26:") {
+		t.Errorf("Decode of truncated code returned %v, want an error at pc 26", err)
+	}
+}
@@ -0,0 +1,481 @@
+package disasm
+
+import "fmt"
+
+// Opcode is the first byte of a JVM instruction (JVMS §6.5).
+type Opcode byte
+
+// The opcodes of the Java Virtual Machine instruction set, named after their mnemonics.
+const (
+	Nop             Opcode = iota // 0x00
+	AconstNull                    // 0x01
+	IconstM1                      // 0x02
+	Iconst0                       // 0x03
+	Iconst1                       // 0x04
+	Iconst2                       // 0x05
+	Iconst3                       // 0x06
+	Iconst4                       // 0x07
+	Iconst5                       // 0x08
+	Lconst0                       // 0x09
+	Lconst1                       // 0x0a
+	Fconst0                       // 0x0b
+	Fconst1                       // 0x0c
+	Fconst2                       // 0x0d
+	Dconst0                       // 0x0e
+	Dconst1                       // 0x0f
+	Bipush                        // 0x10
+	Sipush                        // 0x11
+	Ldc                           // 0x12
+	LdcW                          // 0x13
+	Ldc2W                         // 0x14
+	Iload                         // 0x15
+	Lload                         // 0x16
+	Fload                         // 0x17
+	Dload                         // 0x18
+	Aload                         // 0x19
+	Iload0                        // 0x1a
+	Iload1                        // 0x1b
+	Iload2                        // 0x1c
+	Iload3                        // 0x1d
+	Lload0                        // 0x1e
+	Lload1                        // 0x1f
+	Lload2                        // 0x20
+	Lload3                        // 0x21
+	Fload0                        // 0x22
+	Fload1                        // 0x23
+	Fload2                        // 0x24
+	Fload3                        // 0x25
+	Dload0                        // 0x26
+	Dload1                        // 0x27
+	Dload2                        // 0x28
+	Dload3                        // 0x29
+	Aload0                        // 0x2a
+	Aload1                        // 0x2b
+	Aload2                        // 0x2c
+	Aload3                        // 0x2d
+	Iaload                        // 0x2e
+	Laload                        // 0x2f
+	Faload                        // 0x30
+	Daload                        // 0x31
+	Aaload                        // 0x32
+	Baload                        // 0x33
+	Caload                        // 0x34
+	Saload                        // 0x35
+	Istore                        // 0x36
+	Lstore                        // 0x37
+	Fstore                        // 0x38
+	Dstore                        // 0x39
+	Astore                        // 0x3a
+	Istore0                       // 0x3b
+	Istore1                       // 0x3c
+	Istore2                       // 0x3d
+	Istore3                       // 0x3e
+	Lstore0                       // 0x3f
+	Lstore1                       // 0x40
+	Lstore2                       // 0x41
+	Lstore3                       // 0x42
+	Fstore0                       // 0x43
+	Fstore1                       // 0x44
+	Fstore2                       // 0x45
+	Fstore3                       // 0x46
+	Dstore0                       // 0x47
+	Dstore1                       // 0x48
+	Dstore2                       // 0x49
+	Dstore3                       // 0x4a
+	Astore0                       // 0x4b
+	Astore1                       // 0x4c
+	Astore2                       // 0x4d
+	Astore3                       // 0x4e
+	Iastore                       // 0x4f
+	Lastore                       // 0x50
+	Fastore                       // 0x51
+	Dastore                       // 0x52
+	Aastore                       // 0x53
+	Bastore                       // 0x54
+	Castore                       // 0x55
+	Sastore                       // 0x56
+	Pop                           // 0x57
+	Pop2                          // 0x58
+	Dup                           // 0x59
+	DupX1                         // 0x5a
+	DupX2                         // 0x5b
+	Dup2                          // 0x5c
+	Dup2X1                        // 0x5d
+	Dup2X2                        // 0x5e
+	Swap                          // 0x5f
+	Iadd                          // 0x60
+	Ladd                          // 0x61
+	Fadd                          // 0x62
+	Dadd                          // 0x63
+	Isub                          // 0x64
+	Lsub                          // 0x65
+	Fsub                          // 0x66
+	Dsub                          // 0x67
+	Imul                          // 0x68
+	Lmul                          // 0x69
+	Fmul                          // 0x6a
+	Dmul                          // 0x6b
+	Idiv                          // 0x6c
+	Ldiv                          // 0x6d
+	Fdiv                          // 0x6e
+	Ddiv                          // 0x6f
+	Irem                          // 0x70
+	Lrem                          // 0x71
+	Frem                          // 0x72
+	Drem                          // 0x73
+	Ineg                          // 0x74
+	Lneg                          // 0x75
+	Fneg                          // 0x76
+	Dneg                          // 0x77
+	Ishl                          // 0x78
+	Lshl                          // 0x79
+	Ishr                          // 0x7a
+	Lshr                          // 0x7b
+	Iushr                         // 0x7c
+	Lushr                         // 0x7d
+	Iand                          // 0x7e
+	Land                          // 0x7f
+	Ior                           // 0x80
+	Lor                           // 0x81
+	Ixor                          // 0x82
+	Lxor                          // 0x83
+	Iinc                          // 0x84
+	I2l                           // 0x85
+	I2f                           // 0x86
+	I2d                           // 0x87
+	L2i                           // 0x88
+	L2f                           // 0x89
+	L2d                           // 0x8a
+	F2i                           // 0x8b
+	F2l                           // 0x8c
+	F2d                           // 0x8d
+	D2i                           // 0x8e
+	D2l                           // 0x8f
+	D2f                           // 0x90
+	I2b                           // 0x91
+	I2c                           // 0x92
+	I2s                           // 0x93
+	Lcmp                          // 0x94
+	Fcmpl                         // 0x95
+	Fcmpg                         // 0x96
+	Dcmpl                         // 0x97
+	Dcmpg                         // 0x98
+	Ifeq                          // 0x99
+	Ifne                          // 0x9a
+	Iflt                          // 0x9b
+	Ifge                          // 0x9c
+	Ifgt                          // 0x9d
+	Ifle                          // 0x9e
+	IfIcmpeq                      // 0x9f
+	IfIcmpne                      // 0xa0
+	IfIcmplt                      // 0xa1
+	IfIcmpge                      // 0xa2
+	IfIcmpgt                      // 0xa3
+	IfIcmple                      // 0xa4
+	IfAcmpeq                      // 0xa5
+	IfAcmpne                      // 0xa6
+	Goto                          // 0xa7
+	Jsr                           // 0xa8
+	Ret                           // 0xa9
+	Tableswitch                   // 0xaa
+	Lookupswitch                  // 0xab
+	Ireturn                       // 0xac
+	Lreturn                       // 0xad
+	Freturn                       // 0xae
+	Dreturn                       // 0xaf
+	Areturn                       // 0xb0
+	Return                        // 0xb1
+	Getstatic                     // 0xb2
+	Putstatic                     // 0xb3
+	Getfield                      // 0xb4
+	Putfield                      // 0xb5
+	Invokevirtual                 // 0xb6
+	Invokespecial                 // 0xb7
+	Invokestatic                  // 0xb8
+	Invokeinterface               // 0xb9
+	Invokedynamic                 // 0xba
+	New                           // 0xbb
+	Newarray                      // 0xbc
+	Anewarray                     // 0xbd
+	Arraylength                   // 0xbe
+	Athrow                        // 0xbf
+	Checkcast                     // 0xc0
+	Instanceof                    // 0xc1
+	Monitorenter                  // 0xc2
+	Monitorexit                   // 0xc3
+	Wide                          // 0xc4
+	Multianewarray                // 0xc5
+	Ifnull                        // 0xc6
+	Ifnonnull                     // 0xc7
+	GotoW                         // 0xc8
+	JsrW                          // 0xc9
+)
+
+// Format describes the operands following an opcode. Decode reads the operands of every format, and an assembler
+// that looks up a mnemonic with Lookup knows from the Format which operands to expect.
+type Format byte
+
+const (
+	NoOperands              Format = iota
+	LocalOperand                   // u1 local variable index, u2 if modified by wide
+	ByteOperand                    // s1 immediate
+	ShortOperand                   // s2 immediate
+	ConstantByte                   // u1 constant pool index
+	ConstantShort                  // u2 constant pool index
+	IincOperands                   // u1 local variable index and s1 increment, u2 and s2 if modified by wide
+	BranchShort                    // s2 branch offset
+	BranchInt                      // s4 branch offset
+	TableSwitchOperands            // padding, s4 default, low and high and high-low+1 s4 offsets
+	LookupSwitchOperands           // padding, s4 default and npairs and npairs s4 key and offset pairs
+	InvokeInterfaceOperands        // u2 constant pool index, u1 count and a zero byte
+	InvokeDynamicOperands          // u2 constant pool index and two zero bytes
+	NewArrayOperand                // u1 array type
+	MultiANewArrayOperands         // u2 constant pool index and u1 dimensions
+	WideOperand                    // opcode of the modified instruction followed by its widened operands
+)
+
+type opcodeInfo struct {
+	mnemonic string
+	format   Format
+}
+
+// opcodes holds the mnemonic and operand format of every opcode, the mnemonic of undefined opcodes is "".
+var opcodes = [256]opcodeInfo{
+	Nop:             {"nop", NoOperands},
+	AconstNull:      {"aconst_null", NoOperands},
+	IconstM1:        {"iconst_m1", NoOperands},
+	Iconst0:         {"iconst_0", NoOperands},
+	Iconst1:         {"iconst_1", NoOperands},
+	Iconst2:         {"iconst_2", NoOperands},
+	Iconst3:         {"iconst_3", NoOperands},
+	Iconst4:         {"iconst_4", NoOperands},
+	Iconst5:         {"iconst_5", NoOperands},
+	Lconst0:         {"lconst_0", NoOperands},
+	Lconst1:         {"lconst_1", NoOperands},
+	Fconst0:         {"fconst_0", NoOperands},
+	Fconst1:         {"fconst_1", NoOperands},
+	Fconst2:         {"fconst_2", NoOperands},
+	Dconst0:         {"dconst_0", NoOperands},
+	Dconst1:         {"dconst_1", NoOperands},
+	Bipush:          {"bipush", ByteOperand},
+	Sipush:          {"sipush", ShortOperand},
+	Ldc:             {"ldc", ConstantByte},
+	LdcW:            {"ldc_w", ConstantShort},
+	Ldc2W:           {"ldc2_w", ConstantShort},
+	Iload:           {"iload", LocalOperand},
+	Lload:           {"lload", LocalOperand},
+	Fload:           {"fload", LocalOperand},
+	Dload:           {"dload", LocalOperand},
+	Aload:           {"aload", LocalOperand},
+	Iload0:          {"iload_0", NoOperands},
+	Iload1:          {"iload_1", NoOperands},
+	Iload2:          {"iload_2", NoOperands},
+	Iload3:          {"iload_3", NoOperands},
+	Lload0:          {"lload_0", NoOperands},
+	Lload1:          {"lload_1", NoOperands},
+	Lload2:          {"lload_2", NoOperands},
+	Lload3:          {"lload_3", NoOperands},
+	Fload0:          {"fload_0", NoOperands},
+	Fload1:          {"fload_1", NoOperands},
+	Fload2:          {"fload_2", NoOperands},
+	Fload3:          {"fload_3", NoOperands},
+	Dload0:          {"dload_0", NoOperands},
+	Dload1:          {"dload_1", NoOperands},
+	Dload2:          {"dload_2", NoOperands},
+	Dload3:          {"dload_3", NoOperands},
+	Aload0:          {"aload_0", NoOperands},
+	Aload1:          {"aload_1", NoOperands},
+	Aload2:          {"aload_2", NoOperands},
+	Aload3:          {"aload_3", NoOperands},
+	Iaload:          {"iaload", NoOperands},
+	Laload:          {"laload", NoOperands},
+	Faload:          {"faload", NoOperands},
+	Daload:          {"daload", NoOperands},
+	Aaload:          {"aaload", NoOperands},
+	Baload:          {"baload", NoOperands},
+	Caload:          {"caload", NoOperands},
+	Saload:          {"saload", NoOperands},
+	Istore:          {"istore", LocalOperand},
+	Lstore:          {"lstore", LocalOperand},
+	Fstore:          {"fstore", LocalOperand},
+	Dstore:          {"dstore", LocalOperand},
+	Astore:          {"astore", LocalOperand},
+	Istore0:         {"istore_0", NoOperands},
+	Istore1:         {"istore_1", NoOperands},
+	Istore2:         {"istore_2", NoOperands},
+	Istore3:         {"istore_3", NoOperands},
+	Lstore0:         {"lstore_0", NoOperands},
+	Lstore1:         {"lstore_1", NoOperands},
+	Lstore2:         {"lstore_2", NoOperands},
+	Lstore3:         {"lstore_3", NoOperands},
+	Fstore0:         {"fstore_0", NoOperands},
+	Fstore1:         {"fstore_1", NoOperands},
+	Fstore2:         {"fstore_2", NoOperands},
+	Fstore3:         {"fstore_3", NoOperands},
+	Dstore0:         {"dstore_0", NoOperands},
+	Dstore1:         {"dstore_1", NoOperands},
+	Dstore2:         {"dstore_2", NoOperands},
+	Dstore3:         {"dstore_3", NoOperands},
+	Astore0:         {"astore_0", NoOperands},
+	Astore1:         {"astore_1", NoOperands},
+	Astore2:         {"astore_2", NoOperands},
+	Astore3:         {"astore_3", NoOperands},
+	Iastore:         {"iastore", NoOperands},
+	Lastore:         {"lastore", NoOperands},
+	Fastore:         {"fastore", NoOperands},
+	Dastore:         {"dastore", NoOperands},
+	Aastore:         {"aastore", NoOperands},
+	Bastore:         {"bastore", NoOperands},
+	Castore:         {"castore", NoOperands},
+	Sastore:         {"sastore", NoOperands},
+	Pop:             {"pop", NoOperands},
+	Pop2:            {"pop2", NoOperands},
+	Dup:             {"dup", NoOperands},
+	DupX1:           {"dup_x1", NoOperands},
+	DupX2:           {"dup_x2", NoOperands},
+	Dup2:            {"dup2", NoOperands},
+	Dup2X1:          {"dup2_x1", NoOperands},
+	Dup2X2:          {"dup2_x2", NoOperands},
+	Swap:            {"swap", NoOperands},
+	Iadd:            {"iadd", NoOperands},
+	Ladd:            {"ladd", NoOperands},
+	Fadd:            {"fadd", NoOperands},
+	Dadd:            {"dadd", NoOperands},
+	Isub:            {"isub", NoOperands},
+	Lsub:            {"lsub", NoOperands},
+	Fsub:            {"fsub", NoOperands},
+	Dsub:            {"dsub", NoOperands},
+	Imul:            {"imul", NoOperands},
+	Lmul:            {"lmul", NoOperands},
+	Fmul:            {"fmul", NoOperands},
+	Dmul:            {"dmul", NoOperands},
+	Idiv:            {"idiv", NoOperands},
+	Ldiv:            {"ldiv", NoOperands},
+	Fdiv:            {"fdiv", NoOperands},
+	Ddiv:            {"ddiv", NoOperands},
+	Irem:            {"irem", NoOperands},
+	Lrem:            {"lrem", NoOperands},
+	Frem:            {"frem", NoOperands},
+	Drem:            {"drem", NoOperands},
+	Ineg:            {"ineg", NoOperands},
+	Lneg:            {"lneg", NoOperands},
+	Fneg:            {"fneg", NoOperands},
+	Dneg:            {"dneg", NoOperands},
+	Ishl:            {"ishl", NoOperands},
+	Lshl:            {"lshl", NoOperands},
+	Ishr:            {"ishr", NoOperands},
+	Lshr:            {"lshr", NoOperands},
+	Iushr:           {"iushr", NoOperands},
+	Lushr:           {"lushr", NoOperands},
+	Iand:            {"iand", NoOperands},
+	Land:            {"land", NoOperands},
+	Ior:             {"ior", NoOperands},
+	Lor:             {"lor", NoOperands},
+	Ixor:            {"ixor", NoOperands},
+	Lxor:            {"lxor", NoOperands},
+	Iinc:            {"iinc", IincOperands},
+	I2l:             {"i2l", NoOperands},
+	I2f:             {"i2f", NoOperands},
+	I2d:             {"i2d", NoOperands},
+	L2i:             {"l2i", NoOperands},
+	L2f:             {"l2f", NoOperands},
+	L2d:             {"l2d", NoOperands},
+	F2i:             {"f2i", NoOperands},
+	F2l:             {"f2l", NoOperands},
+	F2d:             {"f2d", NoOperands},
+	D2i:             {"d2i", NoOperands},
+	D2l:             {"d2l", NoOperands},
+	D2f:             {"d2f", NoOperands},
+	I2b:             {"i2b", NoOperands},
+	I2c:             {"i2c", NoOperands},
+	I2s:             {"i2s", NoOperands},
+	Lcmp:            {"lcmp", NoOperands},
+	Fcmpl:           {"fcmpl", NoOperands},
+	Fcmpg:           {"fcmpg", NoOperands},
+	Dcmpl:           {"dcmpl", NoOperands},
+	Dcmpg:           {"dcmpg", NoOperands},
+	Ifeq:            {"ifeq", BranchShort},
+	Ifne:            {"ifne", BranchShort},
+	Iflt:            {"iflt", BranchShort},
+	Ifge:            {"ifge", BranchShort},
+	Ifgt:            {"ifgt", BranchShort},
+	Ifle:            {"ifle", BranchShort},
+	IfIcmpeq:        {"if_icmpeq", BranchShort},
+	IfIcmpne:        {"if_icmpne", BranchShort},
+	IfIcmplt:        {"if_icmplt", BranchShort},
+	IfIcmpge:        {"if_icmpge", BranchShort},
+	IfIcmpgt:        {"if_icmpgt", BranchShort},
+	IfIcmple:        {"if_icmple", BranchShort},
+	IfAcmpeq:        {"if_acmpeq", BranchShort},
+	IfAcmpne:        {"if_acmpne", BranchShort},
+	Goto:            {"goto", BranchShort},
+	Jsr:             {"jsr", BranchShort},
+	Ret:             {"ret", LocalOperand},
+	Tableswitch:     {"tableswitch", TableSwitchOperands},
+	Lookupswitch:    {"lookupswitch", LookupSwitchOperands},
+	Ireturn:         {"ireturn", NoOperands},
+	Lreturn:         {"lreturn", NoOperands},
+	Freturn:         {"freturn", NoOperands},
+	Dreturn:         {"dreturn", NoOperands},
+	Areturn:         {"areturn", NoOperands},
+	Return:          {"return", NoOperands},
+	Getstatic:       {"getstatic", ConstantShort},
+	Putstatic:       {"putstatic", ConstantShort},
+	Getfield:        {"getfield", ConstantShort},
+	Putfield:        {"putfield", ConstantShort},
+	Invokevirtual:   {"invokevirtual", ConstantShort},
+	Invokespecial:   {"invokespecial", ConstantShort},
+	Invokestatic:    {"invokestatic", ConstantShort},
+	Invokeinterface: {"invokeinterface", InvokeInterfaceOperands},
+	Invokedynamic:   {"invokedynamic", InvokeDynamicOperands},
+	New:             {"new", ConstantShort},
+	Newarray:        {"newarray", NewArrayOperand},
+	Anewarray:       {"anewarray", ConstantShort},
+	Arraylength:     {"arraylength", NoOperands},
+	Athrow:          {"athrow", NoOperands},
+	Checkcast:       {"checkcast", ConstantShort},
+	Instanceof:      {"instanceof", ConstantShort},
+	Monitorenter:    {"monitorenter", NoOperands},
+	Monitorexit:     {"monitorexit", NoOperands},
+	Wide:            {"wide", WideOperand},
+	Multianewarray:  {"multianewarray", MultiANewArrayOperands},
+	Ifnull:          {"ifnull", BranchShort},
+	Ifnonnull:       {"ifnonnull", BranchShort},
+	GotoW:           {"goto_w", BranchInt},
+	JsrW:            {"jsr_w", BranchInt},
+}
+
+// mnemonics maps mnemonics back to their opcodes.
+var mnemonics = func() map[string]Opcode {
+	m := make(map[string]Opcode, len(opcodes))
+	for op, info := range opcodes {
+		if info.mnemonic != "" {
+			m[info.mnemonic] = Opcode(op)
+		}
+	}
+	return m
+}()
+
+// String returns the mnemonic of op, e.g. "invokevirtual".
+func (op Opcode) String() string {
+	if !op.Valid() {
+		return fmt.Sprintf("opcode(%d)", byte(op))
+	}
+	return opcodes[op].mnemonic
+}
+
+// Valid reports if op is an instruction of the JVM. The reserved opcodes breakpoint, impdep1 and impdep2 aren't.
+func (op Opcode) Valid() bool {
+	return opcodes[op].mnemonic != ""
+}
+
+// Format returns the format of the operands following op.
+func (op Opcode) Format() Format {
+	return opcodes[op].format
+}
+
+// Lookup returns the opcode with the mnemonic name.
+func Lookup(name string) (Opcode, bool) {
+	op, ok := mnemonics[name]
+	return op, ok
+}
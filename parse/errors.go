@@ -0,0 +1,232 @@
+package parse
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// FormatError is returned by Parse, ParseReader and ParseBytes if a class file is malformed.
+//
+// Several format errors found while checking a class file are returned together with errors.Join, errors.As finds the
+// first one.
+type FormatError struct {
+	File   string // name of the class file, "" if it wasn't read by Parse
+	Offset int    // byte offset of the problem or of the innermost structure containing it, -1 if unknown
+	Path   string // structural path like "methods[3].attributes[Code].exception_table[1]", "" for the whole file
+	Err    error
+}
+
+func (e *FormatError) Error() string {
+	var b strings.Builder
+	if e.File != "" {
+		b.WriteString(e.File)
+		b.WriteString(": ")
+	}
+	if e.Path != "" {
+		b.WriteString(e.Path)
+		b.WriteString(": ")
+	}
+	if e.Offset >= 0 {
+		fmt.Fprintf(&b, "offset %d: ", e.Offset)
+	}
+	b.WriteString(e.Err.Error())
+	return b.String()
+}
+
+func (e *FormatError) Unwrap() error {
+	return e.Err
+}
+
+// atPath puts segment in front of the structural path of err.
+func atPath(segment string, err error) error {
+	if err == nil {
+		return nil
+	}
+	formatError, ok := err.(*FormatError)
+	if !ok {
+		return &FormatError{Offset: -1, Path: segment, Err: err}
+	}
+	if formatError.Path == "" {
+		formatError.Path = segment
+	} else {
+		formatError.Path = segment + "." + formatError.Path
+	}
+	return formatError
+}
+
+// position returns the number of bytes already read from r.
+func (r *ClassFileReader) position() int {
+	return int((*bytes.Reader)(r).Size()) - r.Len()
+}
+
+// offsets maps the structural paths of a parsed class file to their byte offsets in the file.
+type offsets map[string]int
+
+// add records offset for path, the first structure with a path wins.
+func (o offsets) add(path string, offset int) {
+	if _, ok := o[path]; !ok {
+		o[path] = offset
+	}
+}
+
+// lookup returns the offset of the innermost structure path is part of, or -1.
+func (o offsets) lookup(path string) int {
+	for path != "" {
+		if offset, ok := o[path]; ok {
+			return offset
+		}
+		cut := strings.LastIndexAny(path, ".[")
+		if cut == -1 {
+			break
+		}
+		path = path[:cut]
+	}
+	return -1
+}
+
+// locate fills in the file name and, if it's still unknown, the offset of the FormatError err.
+func locate(file string, err error, offset func(path string) int) error {
+	formatError, ok := err.(*FormatError)
+	if !ok {
+		formatError = &FormatError{Offset: -1, Err: err}
+	}
+	formatError.File = file
+	if formatError.Offset < 0 {
+		formatError.Offset = offset(formatError.Path)
+	}
+	return formatError
+}
+
+// scanOffsets reads the structure of the class file content again to find the offsets of the structures in it.
+// content has to be readable by ClassFile.read.
+func scanOffsets(content []byte) offsets {
+	o := offsets{}
+	r := (*ClassFileReader)(bytes.NewReader(content))
+	f := &ClassFile{}
+
+	skip := func(path string, n int) {
+		o.add(path, r.position())
+		_, _ = r.Seek(int64(n), io.SeekCurrent)
+	}
+
+	skip("magic", 4)
+	skip("minor_version", 2)
+	skip("major_version", 2)
+
+	o.add("constant_pool_count", r.position())
+	size, _ := r.ReadU2()
+	for i := 0; i < size-1; i++ {
+		o.add(fmt.Sprintf("constant_pool[%d]", i+1), r.position())
+		entry, err := r.ReadCPInfo()
+		if err != nil {
+			return o
+		}
+		f.ConstantPool = append(f.ConstantPool, entry)
+		if entry.getTag() == 5 || entry.getTag() == 6 {
+			i++
+			f.ConstantPool = append(f.ConstantPool, entry)
+		}
+	}
+
+	skip("access_flags", 2)
+	skip("this_class", 2)
+	skip("super_class", 2)
+	o.add("interfaces_count", r.position())
+	count, _ := r.ReadU2()
+	for i := 0; i < count; i++ {
+		skip(fmt.Sprintf("interfaces[%d]", i), 2)
+	}
+
+	for _, kind := range []string{"fields", "methods"} {
+		o.add(kind+"_count", r.position())
+		count, _ = r.ReadU2()
+		for i := 0; i < count; i++ {
+			path := fmt.Sprintf("%s[%d]", kind, i)
+			o.add(path, r.position())
+			skip(path+".access_flags", 2)
+			skip(path+".name_index", 2)
+			skip(path+".descriptor_index", 2)
+			o.scanAttributes(f, r, path+".attributes", 0)
+		}
+	}
+
+	o.scanAttributes(f, r, "attributes", 0)
+	return o
+}
+
+// scanAttributes records the offsets of the attributes read from r, whose content starts at base in the class file.
+func (o offsets) scanAttributes(f *ClassFile, r *ClassFileReader, path string, base int) {
+	o.add(path+"_count", base+r.position())
+	count, _ := r.ReadU2()
+	for i := 0; i < count; i++ {
+		offset := base + r.position()
+		attribute, err := r.ReadAttributeInfo()
+		if err != nil {
+			return
+		}
+		o.add(fmt.Sprintf("%s[%d]", path, i), offset)
+		o.add(fmt.Sprintf("%s[%d].attribute_name_index", path, i), offset)
+
+		name := rawUtf8Text(f, attribute.AttributeNameIndex)
+		if name == "" {
+			continue
+		}
+		attributePath := path + "[" + name + "]"
+		o.add(attributePath, offset)
+		if name != "Code" {
+			continue
+		}
+
+		// the content of an attribute starts after its u2 attribute_name_index and u4 attribute_length
+		infoOffset := offset + 6
+		code := (*ClassFileReader)(bytes.NewReader(attribute.Info))
+		_, _ = code.Seek(4, io.SeekStart)
+		o.add(attributePath+".code_length", infoOffset+4)
+		codeLength, _ := code.ReadU4()
+		o.add(attributePath+".code", infoOffset+8)
+		_, _ = code.Seek(int64(codeLength), io.SeekCurrent)
+		o.add(attributePath+".exception_table_length", infoOffset+code.position())
+		count, _ := code.ReadU2()
+		for k := 0; k < count; k++ {
+			o.add(fmt.Sprintf("%s.exception_table[%d]", attributePath, k), infoOffset+code.position())
+			_, _ = code.Seek(8, io.SeekCurrent)
+		}
+		o.scanAttributes(f, code, attributePath+".attributes", infoOffset)
+	}
+}
+
+// rawUtf8Text returns the text of the ConstantUtf8Info at index before resolveIndexes, or "".
+func rawUtf8Text(f *ClassFile, index int) string {
+	if index < 1 || index > len(f.ConstantPool) {
+		return ""
+	}
+	utf8, ok := f.ConstantPool[index-1].(ConstantUtf8Info)
+	if !ok {
+		return ""
+	}
+	if utf8.Content == nil {
+		return utf8.Text
+	}
+	text, _ := DecodeModifiedUTF8(utf8.Content)
+	return text
+}
+
+// formatErrors converts the errors of Validate into FormatErrors located with o.
+func (o offsets) formatErrors(file string, errs ValidationErrors) error {
+	converted := make([]error, 0, len(errs))
+	for _, err := range errs {
+		converted = append(converted, &FormatError{
+			File:   file,
+			Offset: o.lookup(err.Path),
+			Path:   err.Path,
+			Err:    errors.New(err.Reason),
+		})
+	}
+	if len(converted) == 1 {
+		return converted[0]
+	}
+	return errors.Join(converted...)
+}
@@ -0,0 +1,184 @@
+package parse
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Offsets of structures in testdata/Square.class, as shown by xxd.
+const (
+	squareClassEntry   = 0x23  // constant_pool[6], the Class Square
+	squareThisClass    = 0x138 // this_class
+	squareMethod1      = 0x16d // methods[1], static int square(int)
+	squareMethod1Table = 0x18b // methods[1].attributes[Code].attributes[0], the LineNumberTable
+)
+
+// patch returns a copy of content with the bytes at offset replaced by b.
+func patch(content []byte, offset int, b ...byte) []byte {
+	res := append([]byte{}, content...)
+	copy(res[offset:], b)
+	return res
+}
+
+// tryCatchClass returns a class file with a method a and a method m, whose code is covered by an exception handler,
+// and the offset of the exception table entry of m.
+func tryCatchClass(t *testing.T) ([]byte, int) {
+	content, err := NewClassBuilder("T").
+		Method(MethodAccStatic, "a", "()V").Code(func(c *CodeBuilder) { c.Return() }).
+		Method(MethodAccStatic, "m", "()V").Code(func(c *CodeBuilder) {
+		start, handler := c.NewLabel(), c.NewLabel()
+		c.TryCatch(start, handler, handler, "java/lang/Exception")
+		c.Mark(start)
+		c.Return()
+		c.Mark(handler)
+		c.AStore(0)
+		c.ALoad(0)
+		c.AThrow()
+	}).Bytes()
+	if err != nil {
+		t.Fatalf("building the class failed: %v", err)
+	}
+	// return, astore_0, aload_0, athrow followed by the exception_table_length 1
+	code := []byte{0xb1, 0x4b, 0x2a, 0xbf, 0, 1}
+	i := bytes.Index(content, code)
+	if i < 0 || bytes.Index(content[i+1:], code) >= 0 {
+		t.Fatal("the code of m isn't in the class file exactly once")
+	}
+	return content, i + len(code)
+}
+
+func TestFormatErrorLocation(t *testing.T) {
+	square, err := os.ReadFile(filepath.Join("testdata", "Square.class"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tryCatch, entry := tryCatchClass(t)
+
+	tests := []struct {
+		name    string
+		content []byte
+		offset  int
+		path    string
+		reason  string
+	}{
+		{"magic", patch(square, 0, 0xCA, 0xFE, 0xBA, 0xBF), 0, "magic", "incorrect magic"},
+		{"major_version", patch(square, 6, 0, 99), 6, "major_version", "unsupported class file version 99.0"},
+		{"this_class", patch(square, squareThisClass, 0, 8), squareThisClass, "this_class",
+			"constant pool entry 8 is ConstantUtf8Info instead of ConstantClassInfo"},
+		{"constant pool entry", patch(square, squareClassEntry+1, 0, 30), squareClassEntry,
+			"constant_pool[6]", `invalid class name "Ljava/io/PrintStream;"`},
+		{"method name", patch(square, squareMethod1+2, 0, 30), squareMethod1 + 2, "methods[1].name_index",
+			`invalid method name "Ljava/io/PrintStream;"`},
+		{"attribute of the code", patch(square, squareMethod1Table, 0, 1), squareMethod1Table,
+			"methods[1].attributes[Code].attributes[0].attribute_name_index",
+			"is ConstantMethodrefInfo instead of ConstantUtf8Info"},
+		{"exception range", patch(tryCatch, entry+2, 0, 0), entry, "methods[1].attributes[Code].exception_table[0]",
+			"invalid range 0..0"},
+		{"exception handler", patch(tryCatch, entry+4, 0, 0xFF), entry, "methods[1].attributes[Code].exception_table[0]",
+			"handler pc 255 out of range"},
+		{"exception catch_type", patch(tryCatch, entry+6, 0, 1), entry,
+			"methods[1].attributes[Code].exception_table[0].catch_type", "instead of ConstantClassInfo"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := ParseBytes(test.content)
+			var formatError *FormatError
+			if !errors.As(err, &formatError) {
+				t.Fatalf("ParseBytes returned %v, want a *FormatError", err)
+			}
+			if formatError.Offset != test.offset || formatError.Path != test.path ||
+				!strings.Contains(formatError.Err.Error(), test.reason) {
+				t.Errorf("got %q at %s, offset %d, want one containing %q at %s, offset %d", formatError.Err,
+					formatError.Path, formatError.Offset, test.reason, test.path, test.offset)
+			}
+		})
+	}
+}
+
+func TestFormatErrorFile(t *testing.T) {
+	square, err := os.ReadFile(filepath.Join("testdata", "Square.class"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	name := filepath.Join(t.TempDir(), "Square.class")
+	if err := os.WriteFile(name, patch(square, 6, 0, 99), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = Parse(name)
+	var formatError *FormatError
+	if !errors.As(err, &formatError) {
+		t.Fatalf("Parse returned %v, want a *FormatError", err)
+	}
+	if formatError.File != name {
+		t.Errorf("error in file %q, want %q", formatError.File, name)
+	}
+	if want := name + ": major_version: offset 6: "; !strings.HasPrefix(err.Error(), want) {
+		t.Errorf("got %q, want it to start with %q", err, want)
+	}
+}
+
+func TestFormatErrorJoined(t *testing.T) {
+	square, err := os.ReadFile(filepath.Join("testdata", "Square.class"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	corrupt := patch(square, 6, 0, 99)
+	corrupt = patch(corrupt, squareMethod1+2, 0, 30)
+
+	_, err = ParseBytes(corrupt)
+	var formatError *FormatError
+	if !errors.As(err, &formatError) {
+		t.Fatalf("ParseBytes returned %v, want a *FormatError", err)
+	}
+	if formatError.Path != "major_version" {
+		t.Errorf("errors.As found the error at %s, want the first one at major_version", formatError.Path)
+	}
+
+	joined, ok := err.(interface{ Unwrap() []error })
+	if !ok {
+		t.Fatalf("ParseBytes returned %T, want errors joined with errors.Join", err)
+	}
+	type location struct {
+		path   string
+		offset int
+	}
+	want := []location{{"major_version", 6}, {"methods[1].name_index", squareMethod1 + 2}}
+	var got []location
+	for _, e := range joined.Unwrap() {
+		formatError, ok := e.(*FormatError)
+		if !ok {
+			t.Fatalf("joined error %v is a %T, want a *FormatError", e, e)
+		}
+		got = append(got, location{formatError.Path, formatError.Offset})
+	}
+	if len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+		t.Errorf("joined errors at %+v, want %+v", got, want)
+	}
+}
+
+func TestOffsetsLookup(t *testing.T) {
+	o := offsets{"methods[1]": 10, "methods[1].attributes[Code]": 20, "methods[1].attributes[Code].code_length": 24}
+
+	tests := []struct {
+		path   string
+		offset int
+	}{
+		{"methods[1].attributes[Code].code_length", 24},
+		{"methods[1].attributes[Code].exception_table[0].catch_type", 20},
+		{"methods[1].descriptor_index", 10},
+		{"methods[2].name_index", -1},
+		{"", -1},
+	}
+
+	for _, test := range tests {
+		if got := o.lookup(test.path); got != test.offset {
+			t.Errorf("lookup(%q) = %d, want %d", test.path, got, test.offset)
+		}
+	}
+}
@@ -1,11 +1,19 @@
 package parse
 
+import "fmt"
+
 type FieldInfo struct {
-	AccessFlags     int
+	AccessFlags     FieldAccessFlags
 	NameIndex       int
+	Name            string // resolved NameIndex
 	DescriptorIndex int
+	Descriptor      string // resolved DescriptorIndex
 	AttributesCount int
 	Attributes      []AttributeInfo
+	ConstantValue   CpInfo        // decoded "ConstantValue" attribute, a numeric constant or ConstantStringInfo
+	Signature       TypeSignature // decoded "Signature" attribute, nil if the field type isn't generic
+	SignatureError  error         // why the "Signature" attribute couldn't be decoded
+	Annotations
 }
 
 func (r *ClassFileReader) ReadFields() (size int, entries []FieldInfo, err error) {
@@ -19,6 +27,7 @@ func (r *ClassFileReader) ReadFields() (size int, entries []FieldInfo, err error
 		entry, err = r.ReadFieldInfo()
 		entries = append(entries, entry)
 		if err != nil {
+			err = atPath(fmt.Sprintf("fields[%d]", i), err)
 			return
 		}
 	}
@@ -27,10 +36,11 @@ func (r *ClassFileReader) ReadFields() (size int, entries []FieldInfo, err error
 }
 
 func (r *ClassFileReader) ReadFieldInfo() (info FieldInfo, err error) {
-	info.AccessFlags, err = r.ReadU2()
+	flags, err := r.ReadU2()
 	if err != nil {
 		return
 	}
+	info.AccessFlags = FieldAccessFlags(flags)
 
 	info.NameIndex, err = r.ReadU2()
 	if err != nil {
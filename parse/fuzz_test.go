@@ -0,0 +1,42 @@
+package parse
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"testing"
+)
+
+// FuzzParseBytes checks that ParseBytes never panics and only fails with a FormatError, and that every class file
+// it accepts can be written and parsed again.
+//
+// Run it with: go test -fuzz FuzzParseBytes
+func FuzzParseBytes(f *testing.F) {
+	for _, seed := range testClasses(f) {
+		content, err := os.ReadFile(seed)
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(content)
+	}
+	f.Add([]byte{0xCA, 0xFE, 0xBA, 0xBE, 0x00, 0x00, 0x00, 0x34})
+
+	f.Fuzz(func(t *testing.T, content []byte) {
+		cf, err := ParseBytes(content)
+		if err != nil {
+			var formatError *FormatError
+			if !errors.As(err, &formatError) {
+				t.Fatalf("ParseBytes returned %T instead of a FormatError: %v", err, err)
+			}
+			return
+		}
+
+		var out bytes.Buffer
+		if err := Write(&out, cf); err != nil {
+			t.Fatalf("Write failed for a parsed class file: %v", err)
+		}
+		if _, err := ParseBytes(out.Bytes()); err != nil {
+			t.Fatalf("written class file doesn't parse again: %v", err)
+		}
+	})
+}
@@ -0,0 +1,537 @@
+package jasmin
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/PPTide/gojdk/parse"
+	"github.com/PPTide/gojdk/parse/disasm"
+)
+
+// method is a method whose code is assembled once the whole source is read.
+type method struct {
+	line                int // of the .method directive
+	endLine             int // of the .end method directive
+	accessFlags         parse.MethodAccessFlags
+	name                string
+	descriptor          string
+	maxStack, maxLocals int      // -1 if they are computed
+	exceptions          []string // classes of the .throws directives
+
+	instructions []*instruction
+	labels       map[string]int // index of the instruction following each label
+	handlers     []handler
+	lines        []lineNumber
+	variables    []variable
+}
+
+// instruction is one instruction with its operands. Constants of ldc are already added to the pool, labels are
+// resolved when the code is assembled.
+type instruction struct {
+	line     int
+	mnemonic string
+	opcode   disasm.Opcode
+	operands []int // local index, immediate, constant pool index, increment, atype and dimensions
+
+	// class, name and descriptor of field, method and type instructions and the descriptor of multianewarray
+	class, name, descriptor string
+
+	target string // label of branches
+
+	// tableswitch and lookupswitch
+	keys         []int
+	targets      []string
+	defaultLabel string
+}
+
+type handler struct {
+	start, end, handler string
+	class               string // "" catches everything
+	line                int
+}
+
+type lineNumber struct {
+	instruction int // index of the first instruction of the line
+	line        int
+}
+
+type variable struct {
+	slot             int
+	name, descriptor string
+	start, end       string
+	line             int
+}
+
+func (m *method) defineLabel(name string) error {
+	if _, ok := m.labels[name]; ok {
+		return fmt.Errorf("duplicate label %s", name)
+	}
+	m.labels[name] = len(m.instructions)
+	return nil
+}
+
+// parseInstruction adds the instruction described by tokens to the current method.
+func (a *assembler) parseInstruction(tokens []token) error {
+	mnemonic := tokens[0].text
+	op, ok := disasm.Lookup(mnemonic)
+	if !ok || tokens[0].quoted {
+		return fmt.Errorf("unknown instruction %s", mnemonic)
+	}
+	ins := &instruction{line: a.line, mnemonic: mnemonic, opcode: op}
+	operands := tokens[1:]
+
+	if op == disasm.Ldc || op == disasm.LdcW || op == disasm.Ldc2W {
+		if len(operands) != 1 {
+			return fmt.Errorf("usage: %s constant", mnemonic)
+		}
+		index, err := a.loadableConstant(op, operands[0])
+		if err != nil {
+			return err
+		}
+		ins.operands = []int{index}
+		a.method.instructions = append(a.method.instructions, ins)
+		return nil
+	}
+
+	args, err := words(operands)
+	if err != nil {
+		return err
+	}
+	usage := func(operands string) error {
+		if operands == "" {
+			return fmt.Errorf("%s doesn't take operands", mnemonic)
+		}
+		return fmt.Errorf("usage: %s %s", mnemonic, operands)
+	}
+
+	switch op.Format() {
+	case disasm.NoOperands:
+		if len(args) != 0 {
+			return usage("")
+		}
+	case disasm.LocalOperand:
+		if len(args) != 1 {
+			return usage("index")
+		}
+		index, err := parseInt(args[0], 17)
+		if err != nil || index < 0 || index > 65535 {
+			return fmt.Errorf("invalid local variable index %q", args[0])
+		}
+		ins.operands = []int{int(index)}
+	case disasm.ByteOperand, disasm.ShortOperand:
+		if len(args) != 1 {
+			return usage("value")
+		}
+		bits := 8
+		if op.Format() == disasm.ShortOperand {
+			bits = 16
+		}
+		v, err := parseInt(args[0], bits)
+		if err != nil {
+			return err
+		}
+		ins.operands = []int{int(v)}
+	case disasm.ConstantShort:
+		if err := ins.memberOperand(args); err != nil {
+			return err
+		}
+	case disasm.IincOperands:
+		if len(args) != 2 {
+			return usage("index increment")
+		}
+		index, err := parseInt(args[0], 17)
+		if err != nil || index < 0 || index > 65535 {
+			return fmt.Errorf("invalid local variable index %q", args[0])
+		}
+		increment, err := parseInt(args[1], 16)
+		if err != nil {
+			return err
+		}
+		ins.operands = []int{int(index), int(increment)}
+	case disasm.BranchShort, disasm.BranchInt:
+		if len(args) != 1 {
+			return usage("label")
+		}
+		ins.target = args[0]
+	case disasm.TableSwitchOperands:
+		if len(args) < 1 || len(args) > 2 {
+			return usage("low [high]")
+		}
+		low, err := parseInt(args[0], 32)
+		if err != nil {
+			return err
+		}
+		ins.keys = []int{int(low)}
+		if len(args) == 2 {
+			high, err := parseInt(args[1], 32)
+			if err != nil {
+				return err
+			}
+			ins.operands = []int{int(high)} // checked against the number of labels at the end of the block
+		}
+		a.switchBlock = ins
+	case disasm.LookupSwitchOperands:
+		if len(args) != 0 {
+			return usage("")
+		}
+		a.switchBlock = ins
+	case disasm.InvokeInterfaceOperands:
+		if len(args) < 1 || len(args) > 2 {
+			return usage("class/name(descriptor) [count]")
+		}
+		if err := ins.memberOperand(args[:1]); err != nil {
+			return err
+		}
+		descriptor, err := parse.ParseMethodDescriptor(ins.descriptor)
+		if err != nil {
+			return err
+		}
+		if len(args) == 2 {
+			count, err := parseInt(args[1], 9)
+			if err != nil || count < 1 || count > 255 {
+				return fmt.Errorf("invalid count %q", args[1])
+			}
+			if int(count) != descriptor.ParameterSlots()+1 {
+				return fmt.Errorf("count %d doesn't match the descriptor %s, which needs %d", count, ins.descriptor,
+					descriptor.ParameterSlots()+1)
+			}
+		}
+	case disasm.InvokeDynamicOperands:
+		return fmt.Errorf("invokedynamic isn't supported")
+	case disasm.NewArrayOperand:
+		if len(args) != 1 {
+			return usage("type")
+		}
+		arrayType, ok := disasm.ArrayType(args[0])
+		if !ok {
+			return fmt.Errorf("invalid array type %q", args[0])
+		}
+		ins.operands = []int{arrayType}
+	case disasm.MultiANewArrayOperands:
+		if len(args) != 2 {
+			return usage("descriptor dimensions")
+		}
+		dimensions, err := parseInt(args[1], 9)
+		if err != nil || dimensions < 1 || dimensions > 255 {
+			return fmt.Errorf("invalid number of dimensions %q", args[1])
+		}
+		ins.descriptor = args[0]
+		ins.operands = []int{int(dimensions)}
+	case disasm.WideOperand:
+		return fmt.Errorf("wide is added automatically when an index or increment needs it")
+	default:
+		return fmt.Errorf("unsupported instruction %s", mnemonic)
+	}
+
+	a.method.instructions = append(a.method.instructions, ins)
+	return nil
+}
+
+// loadableConstant adds the operand of ldc, ldc_w or ldc2_w to the constant pool.
+func (a *assembler) loadableConstant(op disasm.Opcode, operand token) (int, error) {
+	if operand.quoted {
+		if op == disasm.Ldc2W {
+			return 0, fmt.Errorf("ldc2_w only loads long and double constants")
+		}
+		return a.pool.String(operand.text), nil
+	}
+
+	s := operand.text
+	numeric := isFloat(s) || strings.IndexAny(s[:1], "+-0123456789") == 0
+	switch {
+	case op == disasm.Ldc2W && isFloat(s):
+		v, err := parseFloat(s, 64)
+		return a.pool.Double(v), err
+	case op == disasm.Ldc2W && numeric:
+		v, err := parseInt(s, 64)
+		return a.pool.Long(v), err
+	case op == disasm.Ldc2W:
+		return 0, fmt.Errorf("ldc2_w only loads long and double constants")
+	case isFloat(s):
+		v, err := parseFloat(s, 32)
+		return a.pool.Float(float32(v)), err
+	case numeric:
+		v, err := parseInt(s, 32)
+		return a.pool.Integer(int32(v)), err
+	}
+	return a.pool.Class(s), nil
+}
+
+// memberOperand sets the class, name and descriptor of field, method and type instructions from their operands.
+func (ins *instruction) memberOperand(args []string) error {
+	switch op := ins.opcode; op {
+	case disasm.Getstatic, disasm.Putstatic, disasm.Getfield, disasm.Putfield:
+		if len(args) != 2 {
+			return fmt.Errorf("usage: %s class/name descriptor", op)
+		}
+		class, name, ok := splitMember(args[0])
+		if !ok {
+			return fmt.Errorf("invalid field %q, expected class/name", args[0])
+		}
+		ins.class, ins.name, ins.descriptor = class, name, args[1]
+		return nil
+	case disasm.Invokevirtual, disasm.Invokespecial, disasm.Invokestatic, disasm.Invokeinterface:
+		if len(args) != 1 {
+			return fmt.Errorf("usage: %s class/name(descriptor)", op)
+		}
+		paren := strings.IndexByte(args[0], '(')
+		if paren < 0 {
+			return fmt.Errorf("invalid method %q, expected class/name(descriptor)", args[0])
+		}
+		class, name, ok := splitMember(args[0][:paren])
+		if !ok {
+			return fmt.Errorf("invalid method %q, expected class/name(descriptor)", args[0])
+		}
+		ins.class, ins.name, ins.descriptor = class, name, args[0][paren:]
+		return nil
+	}
+
+	// new, anewarray, checkcast and instanceof
+	if len(args) != 1 {
+		return fmt.Errorf("usage: %s class", ins.opcode)
+	}
+	ins.class = args[0]
+	return nil
+}
+
+// splitMember splits "java/lang/System/out" into the class and the member name.
+func splitMember(s string) (class string, name string, ok bool) {
+	slash := strings.LastIndexByte(s, '/')
+	if slash <= 0 || slash == len(s)-1 {
+		return "", "", false
+	}
+	return s[:slash], s[slash+1:], true
+}
+
+// parseSwitchLine reads one target of the tableswitch or lookupswitch whose block is open.
+func (a *assembler) parseSwitchLine(tokens []token) error {
+	args, err := words(tokens)
+	if err != nil {
+		return err
+	}
+	ins := a.switchBlock
+	key, label, hasKey := strings.Cut(strings.Join(args, " "), ":")
+	key, label = strings.TrimSpace(key), strings.TrimSpace(label)
+	if !hasKey {
+		key, label = "", key
+	}
+	if label == "" || strings.ContainsAny(label, " \t") {
+		return fmt.Errorf("invalid %s target %q", ins.mnemonic, strings.Join(args, " "))
+	}
+
+	switch {
+	case key == "default":
+		ins.defaultLabel = label
+		a.switchBlock = nil
+		if ins.opcode == disasm.Tableswitch {
+			if len(ins.targets) == 0 {
+				return fmt.Errorf("tableswitch needs at least one target")
+			}
+			high := ins.keys[0] + len(ins.targets) - 1
+			if len(ins.operands) == 1 && ins.operands[0] != high {
+				return fmt.Errorf("tableswitch has %d targets, %d are needed for %d to %d", len(ins.targets),
+					ins.operands[0]-ins.keys[0]+1, ins.keys[0], ins.operands[0])
+			}
+			for k := 1; k < len(ins.targets); k++ {
+				ins.keys = append(ins.keys, ins.keys[0]+k)
+			}
+		}
+	case ins.opcode == disasm.Tableswitch:
+		if hasKey {
+			return fmt.Errorf("tableswitch targets are labels, only the default has a key")
+		}
+		ins.targets = append(ins.targets, label)
+	default:
+		v, err := parseInt(key, 32)
+		if err != nil {
+			return err
+		}
+		for _, existing := range ins.keys {
+			if existing == int(v) {
+				return fmt.Errorf("duplicate lookupswitch key %d", v)
+			}
+		}
+		ins.keys = append(ins.keys, int(v))
+		ins.targets = append(ins.targets, label)
+	}
+	return nil
+}
+
+// ------------------- Assembly ---------------------
+
+// visitMethod passes m on to the writer, whose CodeBuilder assembles the code.
+func (a *assembler) visitMethod(m *method) error {
+	a.line = m.line
+	mv := a.writer.VisitMethod(m.accessFlags, m.name, m.descriptor, m.exceptions)
+	if len(m.instructions) == 0 {
+		if !m.accessFlags.IsNative() && !m.accessFlags.IsAbstract() {
+			return a.errorf("method %s%s has no code", m.name, m.descriptor)
+		}
+		mv.VisitEnd()
+		return nil
+	}
+
+	if err := a.visitCode(mv, m); err != nil {
+		return err
+	}
+	mv.VisitEnd()
+	a.line = m.endLine
+	return a.writerError()
+}
+
+// writerError returns the first error of the writer at the current line.
+func (a *assembler) writerError() error {
+	if err := a.writer.Err(); err != nil {
+		return a.errorf("%w", err)
+	}
+	return nil
+}
+
+// visitCode visits the code of m with one label for every position that has a label or a .line.
+func (a *assembler) visitCode(mv parse.MethodVisitor, m *method) error {
+	labels := make([]*parse.Label, len(m.instructions)+1)
+	for _, i := range m.labels {
+		labels[i] = parse.NewLabel()
+	}
+	for _, line := range m.lines {
+		if labels[line.instruction] == nil {
+			labels[line.instruction] = parse.NewLabel()
+		}
+	}
+	label := func(name string, line int) (*parse.Label, error) {
+		i, ok := m.labels[name]
+		if !ok {
+			a.line = line
+			return nil, a.errorf("undefined label %s", name)
+		}
+		return labels[i], nil
+	}
+
+	mv.VisitCode()
+	for _, h := range m.handlers {
+		var start, end, handler *parse.Label
+		var err error
+		for _, l := range []struct {
+			name  string
+			label **parse.Label
+		}{{h.start, &start}, {h.end, &end}, {h.handler, &handler}} {
+			*l.label, err = label(l.name, h.line)
+			if err != nil {
+				return err
+			}
+		}
+		mv.VisitTryCatchBlock(start, end, handler, h.class)
+	}
+
+	lines := m.lines
+	for i, ins := range m.instructions {
+		if labels[i] != nil {
+			mv.VisitLabel(labels[i])
+		}
+		for ; len(lines) != 0 && lines[0].instruction == i; lines = lines[1:] {
+			mv.VisitLineNumber(lines[0].line, labels[i])
+		}
+		a.line = ins.line
+		if err := a.visitInstruction(mv, ins, label); err != nil {
+			return err
+		}
+		if err := a.writerError(); err != nil {
+			return err
+		}
+	}
+	if end := labels[len(m.instructions)]; end != nil {
+		mv.VisitLabel(end)
+	}
+
+	for _, v := range m.variables {
+		start, err := label(v.start, v.line)
+		if err != nil {
+			return err
+		}
+		end, err := label(v.end, v.line)
+		if err != nil {
+			return err
+		}
+		if m.labels[v.end] < m.labels[v.start] {
+			a.line = v.line
+			return a.errorf("local variable ends before it starts")
+		}
+		mv.VisitLocalVariable(v.name, v.descriptor, start, end, v.slot)
+	}
+	// the CodeBuilder computes the limits, .limit overrides them in the written class
+	mv.VisitMaxs(m.maxStack, m.maxLocals)
+	return nil
+}
+
+// visitInstruction visits ins in the general form of MethodVisitor, the CodeBuilder of the writer picks the
+// encoding.
+func (a *assembler) visitInstruction(mv parse.MethodVisitor, ins *instruction, label func(name string, line int) (*parse.Label, error)) error {
+	op := byte(ins.opcode)
+	switch ins.opcode.Format() {
+	case disasm.NoOperands:
+		switch {
+		case op >= byte(disasm.Iload0) && op <= byte(disasm.Aload3):
+			mv.VisitVarInsn(byte(disasm.Iload)+(op-byte(disasm.Iload0))/4, int(op-byte(disasm.Iload0))%4)
+		case op >= byte(disasm.Istore0) && op <= byte(disasm.Astore3):
+			mv.VisitVarInsn(byte(disasm.Istore)+(op-byte(disasm.Istore0))/4, int(op-byte(disasm.Istore0))%4)
+		default:
+			mv.VisitInsn(op)
+		}
+	case disasm.LocalOperand:
+		mv.VisitVarInsn(op, ins.operands[0])
+	case disasm.ByteOperand, disasm.ShortOperand, disasm.NewArrayOperand:
+		mv.VisitIntInsn(op, ins.operands[0])
+	case disasm.ConstantByte, disasm.ConstantShort, disasm.InvokeInterfaceOperands:
+		switch ins.opcode {
+		case disasm.Ldc, disasm.LdcW, disasm.Ldc2W:
+			mv.VisitLdcInsn(a.pool.Lookup(ins.operands[0]))
+		case disasm.Getstatic, disasm.Putstatic, disasm.Getfield, disasm.Putfield:
+			mv.VisitFieldInsn(op, ins.class, ins.name, ins.descriptor)
+		case disasm.Invokevirtual, disasm.Invokespecial, disasm.Invokestatic, disasm.Invokeinterface:
+			mv.VisitMethodInsn(op, ins.class, ins.name, ins.descriptor)
+		default:
+			mv.VisitTypeInsn(op, ins.class)
+		}
+	case disasm.IincOperands:
+		mv.VisitIincInsn(ins.operands[0], ins.operands[1])
+	case disasm.MultiANewArrayOperands:
+		mv.VisitMultiANewArrayInsn(ins.descriptor, ins.operands[0])
+	case disasm.BranchShort, disasm.BranchInt:
+		target, err := label(ins.target, ins.line)
+		if err != nil {
+			return err
+		}
+		switch ins.opcode {
+		case disasm.GotoW:
+			op = byte(disasm.Goto)
+		case disasm.JsrW:
+			op = byte(disasm.Jsr)
+		}
+		mv.VisitJumpInsn(op, target)
+	case disasm.TableSwitchOperands, disasm.LookupSwitchOperands:
+		defaultTarget, err := label(ins.defaultLabel, ins.line)
+		if err != nil {
+			return err
+		}
+		order := make([]int, len(ins.keys))
+		for k := range order {
+			order[k] = k
+		}
+		sort.Slice(order, func(i, j int) bool { return ins.keys[order[i]] < ins.keys[order[j]] })
+		keys := make([]int32, len(order))
+		targets := make([]*parse.Label, len(order))
+		for i, k := range order {
+			keys[i] = int32(ins.keys[k])
+			if targets[i], err = label(ins.targets[k], ins.line); err != nil {
+				return err
+			}
+		}
+		if ins.opcode == disasm.Tableswitch {
+			mv.VisitTableSwitchInsn(keys[0], defaultTarget, targets)
+		} else {
+			mv.VisitLookupSwitchInsn(defaultTarget, keys, targets)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,537 @@
+// Package jasmin assembles class files from a text form of bytecode modeled after the Jasmin assembler, so
+// interpreter test cases can be written without a JDK.
+//
+// A source file describes one class:
+//
+//	.bytecode 52.0                      ; class file version, 52.0 if omitted
+//	.source Square.java
+//	.class public Square
+//	.super java/lang/Object             ; java/lang/Object if omitted
+//	.implements java/lang/Runnable
+//
+//	.field public static final SIZE I = 4
+//
+//	.method public static main([Ljava/lang/String;)V
+//	    .throws java/io/IOException
+//	    getstatic java/lang/System/out Ljava/io/PrintStream;
+//	    getstatic Square/SIZE I
+//	Loop:
+//	    dup
+//	    ifle Done
+//	    iinc 1 -1                       ; locals are counted automatically
+//	    goto Loop
+//	Done:
+//	    invokevirtual java/io/PrintStream/println(I)V
+//	    return
+//	.end method
+//
+// The nest of a class is written with .nesthost class or .nestmembers class..., which need class file version 55.0,
+// and the subclasses a sealed class permits with .permittedsubclasses class..., which needs version 61.0.
+//
+// Comments start with a ';' at the start of a line or after white space. Labels are names followed by a colon at the
+// start of a line. Instructions use the mnemonics of the JVMS with these operands:
+//   - local variable indexes and numbers for loads, stores, iinc, ret, bipush and sipush; the shortest form is
+//     written, e.g. iload_1 for iload 1 and iconst_3 for bipush 3, and wide is added when needed
+//   - ldc and ldc_w take an int, a float (written with a '.' or an exponent, or NaN and Infinity), a "string" or a
+//     class name; either is written as ldc_w if the constant pool index doesn't fit a byte and as ldc otherwise.
+//     ldc2_w takes a long or a double, which is written like a float
+//   - field instructions take class/name followed by the descriptor
+//   - invoke instructions take class/name(descriptor); invokeinterface may be followed by its count
+//   - new, anewarray, checkcast and instanceof take a class name, newarray a primitive type like int and
+//     multianewarray an array descriptor followed by the number of dimensions
+//   - branches take a label; goto_w and jsr_w are the same as goto and jsr, all branches are widened when their
+//     target is too far for a 16 bit offset
+//   - tableswitch takes the lowest key followed by one label per line and lookupswitch takes "key : label" lines,
+//     both end with a "default : label" line
+//
+// Inside a method these directives are allowed:
+//   - .limit stack N and .limit locals N override the computed max_stack and max_locals
+//   - .throws class adds to the Exceptions attribute
+//   - .catch class from L1 to L2 using L3 adds an exception handler, all as class catches everything
+//   - .line N sets the source line of the following instructions
+//   - .var N is name descriptor from L1 to L2 adds a LocalVariableTable entry
+//
+// The code is assembled with a parse.CodeBuilder, which computes max_stack and max_locals from the stack height. That
+// has to be the same on every path to an instruction, code after a goto, return, throw or switch starts with the
+// height of the branches to its label or 0. For class files of version 50.0 and newer the StackMapTable is computed
+// with verify.StackMapTable.
+package jasmin
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/PPTide/gojdk/parse"
+	"github.com/PPTide/gojdk/parse/verify"
+)
+
+// Error is an error in the source of a class, Line is 0 if it isn't caused by a single line.
+type Error struct {
+	File string
+	Line int
+	Err  error
+}
+
+func (e *Error) Error() string {
+	var parts []string
+	if e.File != "" {
+		parts = append(parts, e.File)
+	}
+	if e.Line > 0 {
+		parts = append(parts, strconv.Itoa(e.Line))
+	}
+	if len(parts) == 0 {
+		return e.Err.Error()
+	}
+	return strings.Join(parts, ":") + ": " + e.Err.Error()
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// AssembleFile assembles the source file called name and returns the content of the class file.
+func AssembleFile(name string) ([]byte, error) {
+	src, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	return assemble(name, src)
+}
+
+// Assemble assembles src and returns the content of the class file.
+func Assemble(src []byte) ([]byte, error) {
+	return assemble("", src)
+}
+
+func assemble(file string, src []byte) ([]byte, error) {
+	a := &assembler{
+		file:         file,
+		writer:       parse.NewClassWriter(nil).StackMapTables(verify.StackMapTable),
+		majorVersion: 52,
+		superName:    "java/lang/Object",
+	}
+	a.pool = a.writer.Pool()
+
+	for i, line := range strings.Split(string(src), "\n") {
+		a.line = i + 1
+		tokens, err := tokenize(line)
+		if err == nil {
+			err = a.parseLine(tokens)
+		}
+		if err != nil {
+			return nil, a.errorf("%w", err)
+		}
+	}
+	a.line = 0
+
+	if a.switchBlock != nil {
+		return nil, a.errorf("%s without default", a.switchBlock.mnemonic)
+	}
+	if a.method != nil {
+		return nil, a.errorf(".end method missing for %s%s", a.method.name, a.method.descriptor)
+	}
+	if a.name == "" {
+		return nil, a.errorf(".class missing")
+	}
+	return a.finish()
+}
+
+type assembler struct {
+	file string
+	line int
+
+	writer *parse.ClassWriter
+	pool   *parse.ConstantPoolBuilder // of writer, for the constants of ldc and ConstantValue
+
+	majorVersion, minorVersion int
+	accessFlags                parse.ClassAccessFlags
+	name, superName            string // name is "" until .class
+	interfaces                 []string
+	source                     string
+	nestHost                   string // "" if the class is the host of its own nest
+	nestMembers                []string
+	permittedSubclasses        []string
+	fields                     []field
+	methods                    []*method
+
+	method      *method      // method whose code is being read, nil outside of .method
+	switchBlock *instruction // tableswitch or lookupswitch whose targets are being read
+}
+
+// field is a field with the constant pool index of its ConstantValue, 0 if it has none.
+type field struct {
+	accessFlags      parse.FieldAccessFlags
+	name, descriptor string
+	value            int
+}
+
+func (a *assembler) errorf(format string, args ...interface{}) *Error {
+	return &Error{File: a.file, Line: a.line, Err: fmt.Errorf(format, args...)}
+}
+
+// parseLine handles one line of the source.
+func (a *assembler) parseLine(tokens []token) error {
+	if len(tokens) == 0 {
+		return nil
+	}
+	if a.switchBlock != nil {
+		return a.parseSwitchLine(tokens)
+	}
+
+	first := tokens[0].text
+	if !tokens[0].quoted && strings.HasSuffix(first, ":") && first != ":" {
+		if a.method == nil {
+			return fmt.Errorf("label %s outside of a method", first)
+		}
+		if err := a.method.defineLabel(strings.TrimSuffix(first, ":")); err != nil {
+			return err
+		}
+		tokens = tokens[1:]
+		if len(tokens) == 0 {
+			return nil
+		}
+		first = tokens[0].text
+	}
+
+	if strings.HasPrefix(first, ".") {
+		return a.parseDirective(first, tokens[1:])
+	}
+	if a.method == nil {
+		return fmt.Errorf("instruction %s outside of a method", first)
+	}
+	return a.parseInstruction(tokens)
+}
+
+func (a *assembler) parseDirective(directive string, tokens []token) error {
+	if a.method != nil {
+		return a.parseMethodDirective(directive, tokens)
+	}
+
+	if directive == ".field" {
+		return a.parseField(tokens)
+	}
+
+	args, err := words(tokens)
+	if err != nil {
+		return err
+	}
+	switch directive {
+	case ".bytecode":
+		if len(args) != 1 {
+			return fmt.Errorf("usage: .bytecode major.minor")
+		}
+		major, minor, _ := strings.Cut(args[0], ".")
+		a.majorVersion, err = strconv.Atoi(major)
+		if err == nil && minor != "" {
+			a.minorVersion, err = strconv.Atoi(minor)
+		}
+		if err != nil {
+			return fmt.Errorf("invalid version %q", args[0])
+		}
+	case ".source":
+		if len(args) != 1 {
+			return fmt.Errorf("usage: .source file")
+		}
+		a.source = args[0]
+	case ".class", ".interface":
+		if a.name != "" {
+			return fmt.Errorf("duplicate %s", directive)
+		}
+		if len(args) == 0 {
+			return fmt.Errorf("usage: %s flags name", directive)
+		}
+		flags, err := accessFlags(args[:len(args)-1], classFlags)
+		if err != nil {
+			return err
+		}
+		if directive == ".interface" {
+			flags |= parse.ClassAccInterface | parse.ClassAccAbstract
+		} else {
+			flags |= parse.ClassAccSuper
+		}
+		a.accessFlags = flags
+		a.name = args[len(args)-1]
+	case ".super":
+		if len(args) != 1 {
+			return fmt.Errorf("usage: .super class")
+		}
+		a.superName = args[0]
+	case ".implements":
+		if len(args) != 1 {
+			return fmt.Errorf("usage: .implements interface")
+		}
+		a.interfaces = append(a.interfaces, args[0])
+	case ".nesthost":
+		if len(args) != 1 {
+			return fmt.Errorf("usage: .nesthost class")
+		}
+		a.nestHost = args[0]
+	case ".nestmembers", ".permittedsubclasses":
+		if len(args) == 0 {
+			return fmt.Errorf("usage: %s class...", directive)
+		}
+		if directive == ".nestmembers" {
+			a.nestMembers = append(a.nestMembers, args...)
+		} else {
+			a.permittedSubclasses = append(a.permittedSubclasses, args...)
+		}
+	case ".method":
+		if a.name == "" {
+			return fmt.Errorf(".method before .class")
+		}
+		if len(args) == 0 {
+			return fmt.Errorf("usage: .method flags name(descriptor)")
+		}
+		flags, err := accessFlags(args[:len(args)-1], methodFlags)
+		if err != nil {
+			return err
+		}
+		signature := args[len(args)-1]
+		paren := strings.IndexByte(signature, '(')
+		if paren <= 0 {
+			return fmt.Errorf("invalid method %q, expected name(descriptor)", signature)
+		}
+		a.method = &method{
+			line:        a.line,
+			accessFlags: flags,
+			name:        signature[:paren],
+			descriptor:  signature[paren:],
+			labels:      map[string]int{},
+			maxStack:    -1,
+			maxLocals:   -1,
+		}
+	default:
+		return fmt.Errorf("unknown directive %s", directive)
+	}
+	return nil
+}
+
+// parseField handles ".field flags name descriptor [= value]".
+func (a *assembler) parseField(tokens []token) error {
+	var value *token
+	if n := len(tokens); n >= 2 && tokens[n-2].text == "=" && !tokens[n-2].quoted {
+		value = &tokens[n-1]
+		tokens = tokens[:n-2]
+	}
+	args, err := words(tokens)
+	if err != nil {
+		return err
+	}
+	if len(args) < 2 {
+		return fmt.Errorf("usage: .field flags name descriptor [= value]")
+	}
+	flags, err := accessFlags(args[:len(args)-2], fieldFlags)
+	if err != nil {
+		return err
+	}
+
+	f := field{accessFlags: flags, name: args[len(args)-2], descriptor: args[len(args)-1]}
+	if value != nil {
+		f.value, err = a.constantValue(f.descriptor, *value)
+		if err != nil {
+			return err
+		}
+	}
+	a.fields = append(a.fields, f)
+	return nil
+}
+
+// constantValue adds the initial value of a field of the type descriptor to the constant pool.
+func (a *assembler) constantValue(descriptor string, value token) (int, error) {
+	if descriptor == "Ljava/lang/String;" {
+		if !value.quoted {
+			return 0, fmt.Errorf("expected a string as value of a String field")
+		}
+		return a.pool.String(value.text), nil
+	}
+	if value.quoted {
+		return 0, fmt.Errorf("a string can't be the value of a field of type %s", descriptor)
+	}
+
+	switch descriptor {
+	case "B", "C", "I", "S", "Z":
+		v, err := parseInt(value.text, 32)
+		return a.pool.Integer(int32(v)), err
+	case "J":
+		v, err := parseInt(value.text, 64)
+		return a.pool.Long(v), err
+	case "F":
+		v, err := parseFloat(value.text, 32)
+		return a.pool.Float(float32(v)), err
+	case "D":
+		v, err := parseFloat(value.text, 64)
+		return a.pool.Double(v), err
+	}
+	return 0, fmt.Errorf("fields of type %s can't have a constant value", descriptor)
+}
+
+func (a *assembler) parseMethodDirective(directive string, tokens []token) error {
+	m := a.method
+	args, err := words(tokens)
+	if err != nil {
+		return err
+	}
+
+	switch directive {
+	case ".end":
+		if len(args) != 1 || args[0] != "method" {
+			return fmt.Errorf("usage: .end method")
+		}
+		m.endLine = a.line
+		a.methods = append(a.methods, m)
+		a.method = nil
+	case ".limit":
+		if len(args) != 2 || (args[0] != "stack" && args[0] != "locals") {
+			return fmt.Errorf("usage: .limit stack|locals N")
+		}
+		v, err := parseInt(args[1], 17)
+		if err != nil || v < 0 || v > 65535 {
+			return fmt.Errorf("invalid limit %q", args[1])
+		}
+		if args[0] == "stack" {
+			m.maxStack = int(v)
+		} else {
+			m.maxLocals = int(v)
+		}
+	case ".throws":
+		if len(args) != 1 {
+			return fmt.Errorf("usage: .throws class")
+		}
+		m.exceptions = append(m.exceptions, args[0])
+	case ".catch":
+		if len(args) != 7 || args[1] != "from" || args[3] != "to" || args[5] != "using" {
+			return fmt.Errorf("usage: .catch class|all from label to label using label")
+		}
+		handler := handler{start: args[2], end: args[4], handler: args[6], line: a.line}
+		if args[0] != "all" {
+			handler.class = args[0]
+		}
+		m.handlers = append(m.handlers, handler)
+	case ".line":
+		if len(args) != 1 {
+			return fmt.Errorf("usage: .line N")
+		}
+		line, err := parseInt(args[0], 17)
+		if err != nil || line < 0 || line > 65535 {
+			return fmt.Errorf("invalid line number %q", args[0])
+		}
+		m.lines = append(m.lines, lineNumber{instruction: len(m.instructions), line: int(line)})
+	case ".var":
+		if len(args) != 8 || args[1] != "is" || args[4] != "from" || args[6] != "to" {
+			return fmt.Errorf("usage: .var N is name descriptor from label to label")
+		}
+		slot, err := parseInt(args[0], 17)
+		if err != nil || slot < 0 || slot > 65535 {
+			return fmt.Errorf("invalid local variable index %q", args[0])
+		}
+		m.variables = append(m.variables, variable{
+			slot:       int(slot),
+			name:       args[2],
+			descriptor: args[3],
+			start:      args[5],
+			end:        args[7],
+			line:       a.line,
+		})
+	default:
+		return fmt.Errorf("directive %s isn't allowed inside a method", directive)
+	}
+	return nil
+}
+
+// classList returns the content of a NestMembers or PermittedSubclasses attribute listing the classes called names.
+func (a *assembler) classList(names []string) []byte {
+	info := []byte{byte(len(names) >> 8), byte(len(names))}
+	for _, name := range names {
+		index := a.pool.Class(name)
+		info = append(info, byte(index>>8), byte(index))
+	}
+	return info
+}
+
+// finish visits the class with the writer and returns the content of the class file.
+func (a *assembler) finish() ([]byte, error) {
+	a.writer.VisitClass(a.majorVersion, a.minorVersion, a.accessFlags, a.name, a.superName, a.interfaces)
+	for _, f := range a.fields {
+		fv := a.writer.VisitField(f.accessFlags, f.name, f.descriptor)
+		if f.value != 0 {
+			fv.VisitAttribute("ConstantValue", []byte{byte(f.value >> 8), byte(f.value)})
+		}
+		fv.VisitEnd()
+	}
+	for _, m := range a.methods {
+		if err := a.visitMethod(m); err != nil {
+			return nil, err
+		}
+	}
+	if a.source != "" {
+		a.writer.VisitSource(a.source)
+	}
+	if a.nestHost != "" {
+		index := a.pool.Class(a.nestHost)
+		a.writer.VisitAttribute("NestHost", []byte{byte(index >> 8), byte(index)})
+	}
+	if a.nestMembers != nil {
+		a.writer.VisitAttribute("NestMembers", a.classList(a.nestMembers))
+	}
+	if a.permittedSubclasses != nil {
+		a.writer.VisitAttribute("PermittedSubclasses", a.classList(a.permittedSubclasses))
+	}
+	a.writer.VisitEnd()
+
+	a.line = 0
+	cf, err := a.writer.ClassFile()
+	if err != nil {
+		return nil, a.errorf("%w", err)
+	}
+	for i, m := range a.methods {
+		if cf.Methods[i].Code == nil {
+			continue
+		}
+		code := *cf.Methods[i].Code
+		if m.maxStack >= 0 {
+			code.MaxStack = m.maxStack
+		}
+		if m.maxLocals >= 0 {
+			code.MaxLocals = m.maxLocals
+		}
+		cf.Methods[i].Code = &code
+	}
+	var out bytes.Buffer
+	err = parse.Write(&out, cf)
+	if err != nil {
+		return nil, a.errorf("%w", err)
+	}
+	return out.Bytes(), nil
+}
+
+var classFlags = map[string]parse.ClassAccessFlags{"public": parse.ClassAccPublic, "final": parse.ClassAccFinal,
+	"abstract": parse.ClassAccAbstract, "synthetic": parse.ClassAccSynthetic, "annotation": parse.ClassAccAnnotation,
+	"enum": parse.ClassAccEnum}
+
+var fieldFlags = map[string]parse.FieldAccessFlags{"public": parse.FieldAccPublic, "private": parse.FieldAccPrivate,
+	"protected": parse.FieldAccProtected, "static": parse.FieldAccStatic, "final": parse.FieldAccFinal,
+	"volatile": parse.FieldAccVolatile, "transient": parse.FieldAccTransient, "synthetic": parse.FieldAccSynthetic,
+	"enum": parse.FieldAccEnum}
+
+var methodFlags = map[string]parse.MethodAccessFlags{"public": parse.MethodAccPublic, "private": parse.MethodAccPrivate,
+	"protected": parse.MethodAccProtected, "static": parse.MethodAccStatic, "final": parse.MethodAccFinal,
+	"synchronized": parse.MethodAccSynchronized, "bridge": parse.MethodAccBridge, "varargs": parse.MethodAccVarargs,
+	"native": parse.MethodAccNative, "abstract": parse.MethodAccAbstract, "strictfp": parse.MethodAccStrict,
+	"synthetic": parse.MethodAccSynthetic}
+
+// accessFlags combines the flags called names.
+func accessFlags[F ~uint16](names []string, flags map[string]F) (F, error) {
+	var res F
+	for _, name := range names {
+		flag, ok := flags[name]
+		if !ok {
+			return 0, fmt.Errorf("unknown access flag %q", name)
+		}
+		res |= flag
+	}
+	return res, nil
+}
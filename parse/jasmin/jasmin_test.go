@@ -0,0 +1,191 @@
+package jasmin
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/PPTide/gojdk/parse"
+	"github.com/PPTide/gojdk/parse/disasm"
+)
+
+// methodSource returns the source of a class T with the static method m(I)I whose code is body, so the first line of
+// body is line 3.
+func methodSource(body ...string) string {
+	return ".class public T\n.method public static m(I)I\n" + strings.Join(body, "\n") + "\n.end method\n"
+}
+
+func TestAssemble(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want []string
+	}{
+		{"labels", methodSource(
+			"    iconst_0",
+			"    istore_1",
+			"Loop:",
+			"    iload_0",
+			"    ifle Done",
+			"    iinc 1 2",
+			"    iinc 0 -1",
+			"    goto Loop",
+			"Done: iload_1",
+			"    ireturn",
+		), []string{"iconst_0", "istore_1", "iload_0", "ifle 15", "iinc 1, 2", "iinc 0, -1", "goto 2", "iload_1", "ireturn"}},
+		{"tableswitch", methodSource(
+			"    iload_0",
+			"    tableswitch 1 2",
+			"        One",
+			"        Two",
+			"        default : Other",
+			"One: iconst_1",
+			"    ireturn",
+			"Two: iconst_2",
+			"    ireturn",
+			"Other: iconst_0",
+			"    ireturn",
+		), []string{"iload_0", "tableswitch { 1: 24, 2: 26, default: 28 }", "iconst_1", "ireturn", "iconst_2", "ireturn",
+			"iconst_0", "ireturn"}},
+		{"lookupswitch keys are sorted", methodSource(
+			"    iload_0",
+			"    lookupswitch",
+			"        100 : Big",
+			"        -5 : Small",
+			"        default : Other",
+			"Big: iconst_1",
+			"    ireturn",
+			"Small: iconst_2",
+			"    ireturn",
+			"Other: iconst_0",
+			"    ireturn",
+		), []string{"iload_0", "lookupswitch { -5: 30, 100: 28, default: 32 }", "iconst_1", "ireturn", "iconst_2",
+			"ireturn", "iconst_0", "ireturn"}},
+		{"two labels at one instruction", methodSource(
+			"    iload_0",
+			"    ifeq A",
+			"    goto B",
+			"A:",
+			"B:",
+			"    iload_0",
+			"    ireturn",
+		), []string{"iload_0", "ifeq 7", "goto 7", "iload_0", "ireturn"}},
+		{"shortest forms", methodSource(
+			"    iload 0",
+			"    bipush 3",
+			"    iadd",
+			"    istore 300",
+			"    iinc 300 1",
+			"    iload 300",
+			"    goto_w End",
+			"End:",
+			"    ireturn",
+		), []string{"iload_0", "iconst_3", "iadd", "wide istore 300", "wide iinc 300, 1", "wide iload 300", "goto 20",
+			"ireturn"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			content, err := Assemble([]byte(test.src))
+			if err != nil {
+				t.Fatalf("Assemble failed: %v", err)
+			}
+			cf, err := parse.ParseBytes(content)
+			if err != nil {
+				t.Fatalf("ParseBytes failed: %v", err)
+			}
+			instructions, err := disasm.DecodeMethod(&cf, cf.Methods[0])
+			if err != nil {
+				t.Fatalf("DecodeMethod failed: %v", err)
+			}
+			var got []string
+			for _, instruction := range instructions {
+				got = append(got, instruction.String())
+			}
+			if strings.Join(got, "\n") != strings.Join(test.want, "\n") {
+				t.Errorf("got code\n\t%s\nwant\n\t%s", strings.Join(got, "\n\t"), strings.Join(test.want, "\n\t"))
+			}
+		})
+	}
+}
+
+func TestAssembleErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		src    string
+		line   int
+		reason string
+	}{
+		{"unknown instruction", methodSource("    iload_0", "    frobnicate", "    ireturn"), 4, "unknown instruction frobnicate"},
+		{"undefined label", methodSource("    iload_0", "    ifeq Nowhere", "    iload_0", "    ireturn"), 4,
+			"undefined label Nowhere"},
+		{"undefined handler label", methodSource(".catch all from A to B using C", "A: iload_0", "B: ireturn"), 3,
+			"undefined label C"},
+		{"duplicate label", methodSource("A: iload_0", "A: ireturn"), 4, "duplicate label A"},
+		{"label outside of a method", "Start:\n", 1, "outside of a method"},
+		{"stack underflow", methodSource("    iadd", "    ireturn"), 3, "stack underflow"},
+		{"stack heights differ", methodSource("    iload_0", "L: iload_0", "    goto L"), 5, "differs"},
+		{"falls off the end", methodSource("    iload_0", "    pop"), 5, "falls off the end"},
+		{"tableswitch with too few targets", methodSource("    iload_0", "    tableswitch 0 2", "        A",
+			"        default : A", "A: iload_0", "    ireturn"), 6, "tableswitch has 1 targets, 3 are needed"},
+		{"duplicate lookupswitch key", methodSource("    iload_0", "    lookupswitch", "        1 : A", "        1 : A",
+			"        default : A", "A: iload_0", "    ireturn"), 6, "duplicate lookupswitch key 1"},
+		{"switch block ends at .end method", methodSource("    iload_0", "    lookupswitch", "        1 : A"), 6,
+			"invalid lookupswitch target \".end method\""},
+		{"switch without default", ".class public T\n.method public static m()V\n    iconst_0\n    lookupswitch\n", 0,
+			"lookupswitch without default"},
+		{"wrong invokeinterface count", methodSource("    aconst_null", "    invokeinterface I/f()I 2", "    ireturn"), 4,
+			"count 2 doesn't match"},
+		{"missing .end method", ".class public T\n.method public static m()V\n    return\n", 0, ".end method missing"},
+		{"two nest hosts", ".class public T\n.nesthost A B\n", 2, "usage: .nesthost class"},
+		{"no permitted subclass", ".class public T\n.permittedsubclasses\n", 2, "usage: .permittedsubclasses class..."},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := Assemble([]byte(test.src))
+			var e *Error
+			if !errors.As(err, &e) {
+				t.Fatalf("Assemble returned %v, want an *Error", err)
+			}
+			if e.Line != test.line || !strings.Contains(e.Err.Error(), test.reason) {
+				t.Errorf("got error %q at line %d, want one containing %q at line %d", e.Err, e.Line, test.reason, test.line)
+			}
+		})
+	}
+}
+
+func TestAssembleNestAndSealedClasses(t *testing.T) {
+	content, err := Assemble([]byte(`
+.bytecode 61.0
+.class public sealed/Host
+.nestmembers sealed/Host$A
+.nestmembers sealed/Host$B
+.permittedsubclasses sealed/Host$A sealed/Host$B
+`))
+	if err != nil {
+		t.Fatalf("Assemble failed: %v", err)
+	}
+	host, err := parse.ParseBytes(content)
+	if err != nil {
+		t.Fatalf("ParseBytes failed: %v", err)
+	}
+	members := []string{"sealed/Host$A", "sealed/Host$B"}
+	if strings.Join(host.NestMembers, " ") != strings.Join(members, " ") ||
+		strings.Join(host.PermittedSubclasses, " ") != strings.Join(members, " ") {
+		t.Errorf("NestMembers %v and PermittedSubclasses %v, want %v for both", host.NestMembers,
+			host.PermittedSubclasses, members)
+	}
+
+	content, err = Assemble([]byte(".bytecode 61.0\n.class sealed/Host$A\n.super sealed/Host\n.nesthost sealed/Host\n"))
+	if err != nil {
+		t.Fatalf("Assemble failed: %v", err)
+	}
+	member, err := parse.ParseBytes(content)
+	if err != nil {
+		t.Fatalf("ParseBytes failed: %v", err)
+	}
+	if member.NestHost != "sealed/Host" {
+		t.Errorf("NestHost %q, want sealed/Host", member.NestHost)
+	}
+}
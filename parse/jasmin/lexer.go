@@ -0,0 +1,138 @@
+package jasmin
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// token is one word of a source line.
+type token struct {
+	text   string // unquoted text of strings
+	quoted bool
+}
+
+// tokenize splits a source line into its tokens, dropping the comment at its end.
+//
+// A comment starts with a ';' at the start of the line or after white space, so descriptors like
+// Ljava/lang/String; don't start one.
+func tokenize(line string) ([]token, error) {
+	var tokens []token
+	i := 0
+	for i < len(line) {
+		r := rune(line[i])
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == ';':
+			return tokens, nil
+		case r == '"':
+			text, n, err := unquote(line[i:])
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, token{text: text, quoted: true})
+			i += n
+		default:
+			start := i
+			for i < len(line) && !unicode.IsSpace(rune(line[i])) {
+				i++
+			}
+			tokens = append(tokens, token{text: line[start:i]})
+		}
+	}
+	return tokens, nil
+}
+
+// unquote decodes the string literal at the start of s and returns its length in s. It supports the escapes of
+// Java string literals.
+func unquote(s string) (text string, n int, err error) {
+	var b strings.Builder
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '"':
+			return b.String(), i + 1, nil
+		case '\\':
+			i++
+			if i == len(s) {
+				return "", 0, errors.New("unterminated string")
+			}
+			switch s[i] {
+			case 'b':
+				b.WriteByte('\b')
+			case 't':
+				b.WriteByte('\t')
+			case 'n':
+				b.WriteByte('\n')
+			case 'f':
+				b.WriteByte('\f')
+			case 'r':
+				b.WriteByte('\r')
+			case '"', '\'', '\\':
+				b.WriteByte(s[i])
+			case 'u':
+				if i+5 > len(s) {
+					return "", 0, errors.New("invalid \\u escape")
+				}
+				code, err := strconv.ParseUint(s[i+1:i+5], 16, 16)
+				if err != nil {
+					return "", 0, fmt.Errorf("invalid \\u escape %q", s[i-1:i+5])
+				}
+				b.WriteRune(rune(code))
+				i += 4
+			default:
+				return "", 0, fmt.Errorf("invalid escape \\%c", s[i])
+			}
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+	return "", 0, errors.New("unterminated string")
+}
+
+// words returns the text of tokens, failing for strings.
+func words(tokens []token) ([]string, error) {
+	res := make([]string, len(tokens))
+	for i, t := range tokens {
+		if t.quoted {
+			return nil, fmt.Errorf("unexpected string %q", t.text)
+		}
+		res[i] = t.text
+	}
+	return res, nil
+}
+
+// parseInt parses a decimal, hexadecimal (0x) or octal (leading 0) integer of bitSize bits.
+func parseInt(s string, bitSize int) (int64, error) {
+	v, err := strconv.ParseInt(s, 0, bitSize)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %d bit integer %q", bitSize, s)
+	}
+	return v, nil
+}
+
+// isFloat reports if the number s is written as floating point number.
+func isFloat(s string) bool {
+	switch s {
+	case "NaN", "Infinity", "+Infinity", "-Infinity":
+		return true
+	}
+	return !strings.HasPrefix(s, "0x") && !strings.HasPrefix(s, "-0x") && strings.ContainsAny(s, ".eE")
+}
+
+// parseFloat parses a floating point number of bitSize bits, including NaN and Infinity.
+func parseFloat(s string, bitSize int) (float64, error) {
+	switch s {
+	case "Infinity", "+Infinity":
+		s = "+Inf"
+	case "-Infinity":
+		s = "-Inf"
+	}
+	v, err := strconv.ParseFloat(s, bitSize)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %d bit floating point number %q", bitSize, s)
+	}
+	return v, nil
+}
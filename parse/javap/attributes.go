@@ -0,0 +1,601 @@
+package javap
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/PPTide/gojdk/parse"
+	"github.com/PPTide/gojdk/parse/disasm"
+)
+
+// attributes prints attributes of a class, field, method or Code attribute.
+func (p *printer) attributes(indent int, attributes []parse.AttributeInfo) {
+	for _, attribute := range attributes {
+		p.attribute(indent, attribute)
+	}
+}
+
+func (p *printer) attribute(indent int, attribute parse.AttributeInfo) {
+	name := p.utf8(attribute.AttributeNameIndex)
+	r := (*parse.ClassFileReader)(bytes.NewReader(attribute.Info))
+
+	var err error
+	switch name {
+	case "Code":
+		err = p.printCode(indent)
+	case "SourceFile":
+		p.line(indent, "SourceFile: %q", p.utf8(readU2s(attribute.Info, 1)[0]))
+	case "Signature":
+		index := readU2s(attribute.Info, 1)[0]
+		p.commented(indent, fmt.Sprintf("Signature: #%d", index), p.utf8(index))
+	case "ConstantValue":
+		p.line(indent, "ConstantValue: %s", p.constantText(readU2s(attribute.Info, 1)[0]))
+	case "Exceptions":
+		var names []string
+		for _, index := range readU2List(attribute.Info) {
+			names = append(names, javaName(p.className(index)))
+		}
+		p.line(indent, "Exceptions:")
+		p.line(indent+2, "throws %s", strings.Join(names, ", "))
+	case "Deprecated", "Synthetic":
+		p.line(indent, "%s: true", name)
+	case "SourceDebugExtension":
+		p.line(indent, "SourceDebugExtension:")
+		for _, line := range strings.Split(strings.TrimSuffix(string(attribute.Info), "\n"), "\n") {
+			p.line(indent+2, "%s", line)
+		}
+	case "LineNumberTable":
+		var entries []parse.LineNumberTableEntry
+		entries, err = r.ReadLineNumberTable()
+		p.line(indent, "LineNumberTable:")
+		for _, entry := range entries {
+			p.line(indent+2, "line %d: %d", entry.LineNumber, entry.StartPc)
+		}
+	case "LocalVariableTable", "LocalVariableTypeTable":
+		var entries []parse.LocalVariableTableEntry
+		entries, err = r.ReadLocalVariableTable()
+		p.line(indent, "%s:", name)
+		p.line(indent+2, "Start  Length  Slot  Name   Signature")
+		for _, entry := range entries {
+			p.line(indent+2, "%5d %7d %5d %5s   %s", entry.StartPc, entry.Length, entry.Index,
+				p.utf8(entry.NameIndex), p.utf8(entry.DescriptorIndex))
+		}
+	case "StackMapTable":
+		err = p.stackMapTable(indent, r)
+	case "InnerClasses":
+		var entries []parse.InnerClassEntry
+		entries, err = r.ReadInnerClasses()
+		p.line(indent, "InnerClasses:")
+		for _, entry := range entries {
+			p.innerClass(indent+2, entry)
+		}
+	case "EnclosingMethod":
+		var method parse.EnclosingMethod
+		method, err = r.ReadEnclosingMethod()
+		comment := p.className(method.ClassIndex)
+		if method.MethodIndex != 0 {
+			nat, _ := p.constant(method.MethodIndex).(parse.ConstantNameAndTypeInfo)
+			comment += "." + quoteName(p.utf8(nat.NameIndex))
+		}
+		p.commented(indent, fmt.Sprintf("EnclosingMethod: #%d.#%d", method.ClassIndex, method.MethodIndex), comment)
+	case "NestHost":
+		p.line(indent, "NestHost: class %s", p.className(readU2s(attribute.Info, 1)[0]))
+	case "NestMembers", "PermittedSubclasses":
+		p.line(indent, "%s:", name)
+		for _, index := range readU2List(attribute.Info) {
+			p.line(indent+2, "%s", p.className(index))
+		}
+	case "BootstrapMethods":
+		err = p.bootstrapMethods(indent, r)
+	case "MethodParameters":
+		err = p.methodParameters(indent, r)
+	case "RuntimeVisibleAnnotations", "RuntimeInvisibleAnnotations":
+		var annotations []parse.Annotation
+		annotations, err = r.ReadAnnotations()
+		p.line(indent, "%s:", name)
+		for i, annotation := range annotations {
+			p.annotation(indent+2, fmt.Sprintf("%d: ", i), annotation)
+		}
+	case "RuntimeVisibleParameterAnnotations", "RuntimeInvisibleParameterAnnotations":
+		var parameters [][]parse.Annotation
+		parameters, err = r.ReadParameterAnnotations()
+		p.line(indent, "%s:", name)
+		for i, annotations := range parameters {
+			p.line(indent+2, "parameter %d:", i)
+			for k, annotation := range annotations {
+				p.annotation(indent+4, fmt.Sprintf("%d: ", k), annotation)
+			}
+		}
+	case "RuntimeVisibleTypeAnnotations", "RuntimeInvisibleTypeAnnotations":
+		var annotations []parse.TypeAnnotation
+		annotations, err = r.ReadTypeAnnotations()
+		p.line(indent, "%s:", name)
+		for i, annotation := range annotations {
+			p.annotation(indent+2, fmt.Sprintf("%d: ", i), annotation.Annotation)
+			p.line(indent+4, "target_type=0x%02x", annotation.TargetType)
+		}
+	case "AnnotationDefault":
+		var value parse.ElementValue
+		value, err = r.ReadElementValue()
+		p.line(indent, "AnnotationDefault:")
+		p.line(indent+2, "default_value: %s", p.rawElementValue(value))
+		p.line(indent+4, "%s", p.elementValue(value))
+	case "Record":
+		var components []parse.RecordComponentInfo
+		components, err = r.ReadRecord()
+		p.line(indent, "Record:")
+		for _, component := range components {
+			descriptor := p.utf8(component.DescriptorIndex)
+			p.line(indent+2, "%s %s;", javaType(descriptor), p.utf8(component.NameIndex))
+			p.line(indent+4, "descriptor: %s", descriptor)
+			p.attributes(indent+4, component.Attributes)
+			p.line(0, "")
+		}
+	case "Module":
+		var module parse.ModuleDescriptor
+		err = r.ReadModule(&module)
+		p.module(indent, module)
+	case "ModulePackages":
+		p.line(indent, "ModulePackages:")
+		for _, index := range readU2List(attribute.Info) {
+			pkg, _ := p.constant(index).(parse.ConstantPackageInfo)
+			p.commented(indent+2, fmt.Sprintf("#%d", index), p.utf8(pkg.NameIndex))
+		}
+	case "ModuleMainClass":
+		index := readU2s(attribute.Info, 1)[0]
+		p.commented(indent, fmt.Sprintf("ModuleMainClass: #%d", index), p.className(index))
+	default:
+		p.unknownAttribute(indent, name, attribute.Info)
+	}
+	if err != nil {
+		p.line(indent, "Error: %s: %v", name, err)
+	}
+}
+
+// unknownAttribute prints the content of an attribute as hex dump.
+func (p *printer) unknownAttribute(indent int, name string, info []byte) {
+	p.line(indent, "%s: length = 0x%X (unknown attribute)", name, len(info))
+	for start := 0; start < len(info); start += 16 {
+		end := start + 16
+		if end > len(info) {
+			end = len(info)
+		}
+		var hex []string
+		for _, b := range info[start:end] {
+			hex = append(hex, fmt.Sprintf("%02x", b))
+		}
+		p.line(indent+1, "%s", strings.Join(hex, " "))
+	}
+}
+
+// ------------------- Code ---------------------
+
+// printCode prints the decoded "Code" attribute of the method whose attributes are printed.
+func (p *printer) printCode(indent int) error {
+	code := p.code
+	if code == nil {
+		return errors.New("Code attribute wasn't decoded")
+	}
+
+	p.line(indent, "Code:")
+	p.line(indent+2, "stack=%d, locals=%d, args_size=%d", code.MaxStack, code.MaxLocals, p.argsSize)
+
+	instructions, err := disasm.Decode(code.Code, p.cf)
+	for _, instruction := range instructions {
+		p.instruction(indent+2, instruction)
+	}
+	if err != nil {
+		return err
+	}
+
+	if len(code.ExceptionTable) != 0 {
+		p.line(indent+2, "Exception table:")
+		p.line(indent+4, "   from    to  target type")
+		for _, entry := range code.ExceptionTable {
+			catchType := "any"
+			if entry.CatchType != 0 {
+				catchType = "Class " + p.className(entry.CatchType)
+			}
+			p.line(indent+4, " %5d %5d %5d   %s", entry.StartPc, entry.EndPc, entry.HandlerPc, catchType)
+		}
+	}
+
+	p.attributes(indent+2, code.Attributes)
+	return nil
+}
+
+// instruction prints one instruction like javap, e.g. "   1: invokespecial #1    // Method java/lang/Object."<init>":()V".
+func (p *printer) instruction(indent int, instruction disasm.Instruction) {
+	mnemonic := instruction.Mnemonic()
+	if instruction.Wide {
+		mnemonic += "_w"
+	}
+
+	var operands string
+	switch instruction.Opcode {
+	case disasm.Tableswitch, disasm.Lookupswitch:
+		s := instruction.Switch
+		if instruction.Opcode == disasm.Tableswitch {
+			p.line(indent, "%4d: %-13s { // %d to %d", instruction.Pc, mnemonic, s.Low, s.High)
+		} else {
+			p.line(indent, "%4d: %-13s { // %d", instruction.Pc, mnemonic, len(s.Keys))
+		}
+		for k, key := range s.Keys {
+			p.line(indent, "%18d: %d", key, s.Targets[k])
+		}
+		p.line(indent, "%18s: %d", "default", s.Default)
+		p.line(indent, "%7s", "}")
+		return
+	case disasm.Invokeinterface, disasm.Multianewarray:
+		operands = fmt.Sprintf("#%d,  %d", instruction.Index, instruction.Immediate)
+	case disasm.Invokedynamic:
+		operands = fmt.Sprintf("#%d,  0", instruction.Index)
+	default:
+		operands = instruction.Operands()
+	}
+
+	switch comment := p.comment(instruction); {
+	case comment != "":
+		p.line(indent, "%4d: %-13s %-19s // %s", instruction.Pc, mnemonic, operands, comment)
+	case operands != "":
+		p.line(indent, "%4d: %-13s %s", instruction.Pc, mnemonic, operands)
+	default:
+		p.line(indent, "%4d: %s", instruction.Pc, mnemonic)
+	}
+}
+
+// comment returns the comment javap prints after instruction, which leaves out the class of references to members of
+// the class itself.
+func (p *printer) comment(instruction disasm.Instruction) string {
+	var kind string
+	var classIndex, nameAndTypeIndex int
+	switch t := instruction.Constant.(type) {
+	case parse.ConstantFieldrefInfo:
+		kind, classIndex, nameAndTypeIndex = "Field", t.ClassIndex, t.NameAndTypeIndex
+	case parse.ConstantMethodrefInfo:
+		kind, classIndex, nameAndTypeIndex = "Method", t.ClassIndex, t.NameAndTypeIndex
+	case parse.ConstantInterfaceMethodrefInfo:
+		kind, classIndex, nameAndTypeIndex = "InterfaceMethod", t.ClassIndex, t.NameAndTypeIndex
+	}
+	if kind == "" || classIndex != p.cf.ThisClass {
+		return instruction.Comment()
+	}
+	return kind + " " + p.nameAndTypeAt(nameAndTypeIndex)
+}
+
+// stackMapTable prints the frames of a "StackMapTable" attribute.
+func (p *printer) stackMapTable(indent int, r *parse.ClassFileReader) error {
+	count, err := r.ReadU2()
+	if err != nil {
+		return err
+	}
+	p.line(indent, "StackMapTable: number_of_entries = %d", count)
+
+	for i := 0; i < count; i++ {
+		frameType, err := r.ReadByte()
+		if err != nil {
+			return err
+		}
+
+		var kind string
+		switch {
+		case frameType <= 63:
+			kind = "same"
+		case frameType <= 127:
+			kind = "same_locals_1_stack_item"
+		case frameType < 247:
+			return fmt.Errorf("reserved frame type %d", frameType)
+		case frameType == 247:
+			kind = "same_locals_1_stack_item_frame_extended"
+		case frameType <= 250:
+			kind = "chop"
+		case frameType == 251:
+			kind = "same_frame_extended"
+		case frameType <= 254:
+			kind = "append"
+		default:
+			kind = "full_frame"
+		}
+		p.line(indent+2, "frame_type = %d /* %s */", frameType, kind)
+
+		if frameType >= 247 {
+			delta, err := r.ReadU2()
+			if err != nil {
+				return err
+			}
+			p.line(indent+4, "offset_delta = %d", delta)
+		}
+
+		switch {
+		case frameType >= 64 && frameType <= 127, frameType == 247:
+			err = p.verificationTypes(indent+4, "stack", r, 1)
+		case frameType >= 252 && frameType <= 254:
+			err = p.verificationTypes(indent+4, "locals", r, int(frameType)-251)
+		case frameType == 255:
+			var n int
+			n, err = r.ReadU2()
+			if err == nil {
+				err = p.verificationTypes(indent+4, "locals", r, n)
+			}
+			if err == nil {
+				n, err = r.ReadU2()
+			}
+			if err == nil {
+				err = p.verificationTypes(indent+4, "stack", r, n)
+			}
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// verificationTypes prints count verification types of a stack map frame, e.g. "locals = [ int, class java/lang/String ]".
+func (p *printer) verificationTypes(indent int, label string, r *parse.ClassFileReader, count int) error {
+	types := make([]string, 0, count)
+	for i := 0; i < count; i++ {
+		tag, err := r.ReadByte()
+		if err != nil {
+			return err
+		}
+		switch tag {
+		case 0:
+			types = append(types, "top")
+		case 1:
+			types = append(types, "int")
+		case 2:
+			types = append(types, "float")
+		case 3:
+			types = append(types, "double")
+		case 4:
+			types = append(types, "long")
+		case 5:
+			types = append(types, "null")
+		case 6:
+			types = append(types, "this")
+		case 7, 8:
+			value, err := r.ReadU2()
+			if err != nil {
+				return err
+			}
+			if tag == 7 {
+				types = append(types, "class "+quoteName(p.className(value)))
+			} else {
+				types = append(types, fmt.Sprintf("uninitialized %d", value))
+			}
+		default:
+			return fmt.Errorf("unknown verification type tag %d", tag)
+		}
+	}
+	p.line(indent, "%s = [ %s ]", label, strings.Join(types, ", "))
+	return nil
+}
+
+// ------------------- Class attributes ---------------------
+
+func (p *printer) innerClass(indent int, entry parse.InnerClassEntry) {
+	modifiers := modifierList(entry.InnerClassAccessFlags, innerClassModifiers)
+	if entry.InnerClassAccessFlags&0x0200 != 0 {
+		modifiers = strings.Replace(modifiers, "abstract ", "", 1) + "interface "
+	}
+
+	text := fmt.Sprintf("%s#%d", modifiers, entry.InnerClassInfoIndex)
+	comment := ""
+	if entry.InnerNameIndex != 0 {
+		text += fmt.Sprintf("= #%d", entry.InnerNameIndex)
+		comment = p.utf8(entry.InnerNameIndex) + "="
+	}
+	comment += "class " + p.className(entry.InnerClassInfoIndex)
+	if entry.OuterClassInfoIndex != 0 {
+		text += fmt.Sprintf(" of #%d", entry.OuterClassInfoIndex)
+		comment += " of class " + p.className(entry.OuterClassInfoIndex)
+	}
+	p.commented(indent, text+";", comment)
+}
+
+func (p *printer) bootstrapMethods(indent int, r *parse.ClassFileReader) error {
+	count, err := r.ReadU2()
+	if err != nil {
+		return err
+	}
+	p.line(indent, "BootstrapMethods:")
+	for i := 0; i < count; i++ {
+		ref, err := r.ReadU2()
+		if err != nil {
+			return err
+		}
+		arguments, err := readU2ListFrom(r)
+		if err != nil {
+			return err
+		}
+
+		handle, _ := p.constant(ref).(parse.ConstantMethodHandleInfo)
+		p.line(indent+2, "%d: #%d %s %s", i, ref, disasm.ReferenceKind(handle.ReferenceKind), p.reference(handle.ReferenceIndex))
+		p.line(indent+4, "Method arguments:")
+		for _, argument := range arguments {
+			text := p.constantText(argument)
+			if _, ok := p.constant(argument).(parse.ConstantStringInfo); ok {
+				text = strings.TrimPrefix(text, "String ")
+			}
+			p.line(indent+6, "#%d %s", argument, text)
+		}
+	}
+	return nil
+}
+
+func (p *printer) methodParameters(indent int, r *parse.ClassFileReader) error {
+	count, err := r.ReadByte()
+	if err != nil {
+		return err
+	}
+	p.line(indent, "MethodParameters:")
+	p.line(indent+2, "%-30s %s", "Name", "Flags")
+	for i := 0; i < int(count); i++ {
+		values := make([]int, 2)
+		for k := range values {
+			values[k], err = r.ReadU2()
+			if err != nil {
+				return err
+			}
+		}
+		name := "<no name>"
+		if values[0] != 0 {
+			name = p.utf8(values[0])
+		}
+		var flags []string
+		for _, f := range []flag{{0x0010, "final"}, {0x1000, "synthetic"}, {0x8000, "mandated"}} {
+			if values[1]&f.mask != 0 {
+				flags = append(flags, f.name)
+			}
+		}
+		p.line(indent+2, "%-30s %s", name, strings.Join(flags, " "))
+	}
+	return nil
+}
+
+func (p *printer) module(indent int, m parse.ModuleDescriptor) {
+	p.line(indent, "Module:")
+	p.commented(indent+2, fmt.Sprintf("#%d,%x", m.ModuleNameIndex, m.ModuleFlags), quoteName(p.moduleName(m.ModuleNameIndex)))
+	p.commented(indent+2, fmt.Sprintf("#%d", m.ModuleVersionIndex), p.utf8(m.ModuleVersionIndex))
+
+	p.line(indent+2, "%-40s // requires", fmt.Sprintf("%d", len(m.Requires)))
+	for _, requires := range m.Requires {
+		p.commented(indent+4, fmt.Sprintf("#%d,%x", requires.RequiresIndex, requires.RequiresFlags), quoteName(p.moduleName(requires.RequiresIndex)))
+		p.commented(indent+4, fmt.Sprintf("#%d", requires.RequiresVersionIndex), p.utf8(requires.RequiresVersionIndex))
+	}
+
+	for _, table := range []struct {
+		name    string
+		entries []parse.ModuleExports
+	}{{"exports", m.Exports}, {"opens", m.Opens}} {
+		p.line(indent+2, "%-40s // %s", fmt.Sprintf("%d", len(table.entries)), table.name)
+		for _, entry := range table.entries {
+			pkg, _ := p.constant(entry.PackageIndex).(parse.ConstantPackageInfo)
+			p.commented(indent+4, fmt.Sprintf("#%d,%x", entry.PackageIndex, entry.Flags), p.utf8(pkg.NameIndex))
+			if len(entry.ToIndex) != 0 {
+				p.line(indent+4, "%-40s // to", fmt.Sprintf("%d", len(entry.ToIndex)))
+				for _, index := range entry.ToIndex {
+					p.commented(indent+6, fmt.Sprintf("#%d", index), quoteName(p.moduleName(index)))
+				}
+			}
+		}
+	}
+
+	p.line(indent+2, "%-40s // uses", fmt.Sprintf("%d", len(m.UsesIndex)))
+	for _, index := range m.UsesIndex {
+		p.commented(indent+4, fmt.Sprintf("#%d", index), p.className(index))
+	}
+
+	p.line(indent+2, "%-40s // provides", fmt.Sprintf("%d", len(m.Provides)))
+	for _, provides := range m.Provides {
+		p.commented(indent+4, fmt.Sprintf("#%d", provides.ProvidesIndex), p.className(provides.ProvidesIndex))
+		p.line(indent+4, "%-40s // with", fmt.Sprintf("%d", len(provides.WithIndex)))
+		for _, index := range provides.WithIndex {
+			p.commented(indent+6, fmt.Sprintf("#%d", index), p.className(index))
+		}
+	}
+}
+
+// moduleName returns the name of the ConstantModuleInfo at index, or "" if there is none.
+func (p *printer) moduleName(index int) string {
+	module, _ := p.constant(index).(parse.ConstantModuleInfo)
+	return p.utf8(module.NameIndex)
+}
+
+// readU2ListFrom reads a u2 count followed by that many u2 values from r.
+func readU2ListFrom(r *parse.ClassFileReader) ([]int, error) {
+	count, err := r.ReadU2()
+	if err != nil {
+		return nil, err
+	}
+	values := make([]int, 0, count)
+	for i := 0; i < count; i++ {
+		value, err := r.ReadU2()
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+	}
+	return values, nil
+}
+
+// ------------------- Annotations ---------------------
+
+// annotation prints an annotation in its raw form, e.g. "0: #12(#13=I#14)", followed by its Java form.
+func (p *printer) annotation(indent int, prefix string, annotation parse.Annotation) {
+	p.line(indent, "%s%s", prefix, p.rawAnnotation(annotation))
+	p.line(indent+2, "%s", p.javaAnnotation(annotation))
+}
+
+func (p *printer) rawAnnotation(annotation parse.Annotation) string {
+	pairs := make([]string, len(annotation.ElementValuePairs))
+	for i, pair := range annotation.ElementValuePairs {
+		pairs[i] = fmt.Sprintf("#%d=%s", pair.ElementNameIndex, p.rawElementValue(pair.Value))
+	}
+	return fmt.Sprintf("#%d(%s)", annotation.TypeIndex, strings.Join(pairs, ","))
+}
+
+func (p *printer) rawElementValue(value parse.ElementValue) string {
+	switch value.Tag {
+	case 'e':
+		return fmt.Sprintf("e#%d.#%d", value.TypeNameIndex, value.ConstNameIndex)
+	case 'c':
+		return fmt.Sprintf("c#%d", value.ClassInfoIndex)
+	case '@':
+		return "@" + p.rawAnnotation(*value.AnnotationValue)
+	case '[':
+		values := make([]string, len(value.Values))
+		for i, v := range value.Values {
+			values[i] = p.rawElementValue(v)
+		}
+		return "[" + strings.Join(values, ",") + "]"
+	}
+	return fmt.Sprintf("%c#%d", value.Tag, value.ConstValueIndex)
+}
+
+func (p *printer) javaAnnotation(annotation parse.Annotation) string {
+	name := javaType(p.utf8(annotation.TypeIndex))
+	if len(annotation.ElementValuePairs) == 0 {
+		return name
+	}
+	pairs := make([]string, len(annotation.ElementValuePairs))
+	for i, pair := range annotation.ElementValuePairs {
+		pairs[i] = p.utf8(pair.ElementNameIndex) + "=" + p.elementValue(pair.Value)
+	}
+	return name + "(" + strings.Join(pairs, ", ") + ")"
+}
+
+// elementValue formats an element value like Java source, e.g. 5, "text" or Color.RED.
+func (p *printer) elementValue(value parse.ElementValue) string {
+	switch value.Tag {
+	case 's':
+		return `"` + escape(p.utf8(value.ConstValueIndex)) + `"`
+	case 'Z':
+		if integer, ok := p.constant(value.ConstValueIndex).(parse.ConstantIntegerInfo); ok && integer.Integer != 0 {
+			return "true"
+		}
+		return "false"
+	case 'C':
+		if integer, ok := p.constant(value.ConstValueIndex).(parse.ConstantIntegerInfo); ok {
+			return fmt.Sprintf("'%s'", escape(string(rune(integer.Integer))))
+		}
+	case 'B', 'S', 'I', 'J', 'F', 'D':
+		return disasm.Literal(p.constant(value.ConstValueIndex))
+	case 'e':
+		return javaType(p.utf8(value.TypeNameIndex)) + "." + p.utf8(value.ConstNameIndex)
+	case 'c':
+		return javaType(p.utf8(value.ClassInfoIndex)) + ".class"
+	case '@':
+		return "@" + p.javaAnnotation(*value.AnnotationValue)
+	case '[':
+		values := make([]string, len(value.Values))
+		for i, v := range value.Values {
+			values[i] = p.elementValue(v)
+		}
+		return "[" + strings.Join(values, ",") + "]"
+	}
+	return fmt.Sprintf("#%d", value.ConstValueIndex)
+}
@@ -0,0 +1,219 @@
+package javap
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/PPTide/gojdk/parse"
+	"github.com/PPTide/gojdk/parse/disasm"
+)
+
+func (p *printer) constantPool() {
+	pool := p.cf.ConstantPool
+	width := len(fmt.Sprintf("#%d", len(pool))) + 2
+
+	p.line(0, "Constant pool:")
+	for i := 0; i < len(pool); i++ {
+		kind, operands, comment := p.describeConstant(pool[i])
+		index := fmt.Sprintf("%*s", width, fmt.Sprintf("#%d", i+1))
+		if comment == "" {
+			p.line(0, "%s = %-18s %s", index, kind, operands)
+		} else {
+			p.line(0, "%s = %-18s %-14s // %s", index, kind, operands, comment)
+		}
+
+		switch pool[i].(type) {
+		case parse.ConstantLongInfo, parse.ConstantDoubleInfo:
+			i++ // the second slot isn't shown
+		}
+	}
+}
+
+// describeConstant returns the kind, the operands and the comment javap shows for a constant pool entry.
+func (p *printer) describeConstant(info parse.CpInfo) (kind string, operands string, comment string) {
+	switch t := info.(type) {
+	case parse.ConstantUtf8Info:
+		return "Utf8", escape(t.Text), ""
+	case parse.ConstantIntegerInfo:
+		return "Integer", disasm.Literal(t), ""
+	case parse.ConstantFloatInfo:
+		return "Float", disasm.Literal(t), ""
+	case parse.ConstantLongInfo:
+		return "Long", disasm.Literal(t), ""
+	case parse.ConstantDoubleInfo:
+		return "Double", disasm.Literal(t), ""
+	case parse.ConstantClassInfo:
+		return "Class", fmt.Sprintf("#%d", t.NameIndex), quoteName(p.utf8(t.NameIndex))
+	case parse.ConstantStringInfo:
+		return "String", fmt.Sprintf("#%d", t.StringIndex), escape(p.utf8(t.StringIndex))
+	case parse.ConstantFieldrefInfo:
+		return "Fieldref", fmt.Sprintf("#%d.#%d", t.ClassIndex, t.NameAndTypeIndex), p.member(t.ClassIndex, t.NameAndTypeIndex)
+	case parse.ConstantMethodrefInfo:
+		return "Methodref", fmt.Sprintf("#%d.#%d", t.ClassIndex, t.NameAndTypeIndex), p.member(t.ClassIndex, t.NameAndTypeIndex)
+	case parse.ConstantInterfaceMethodrefInfo:
+		return "InterfaceMethodref", fmt.Sprintf("#%d.#%d", t.ClassIndex, t.NameAndTypeIndex), p.member(t.ClassIndex, t.NameAndTypeIndex)
+	case parse.ConstantNameAndTypeInfo:
+		return "NameAndType", fmt.Sprintf("#%d:#%d", t.NameIndex, t.DescriptorIndex), p.nameAndType(t)
+	case parse.ConstantMethodHandleInfo:
+		return "MethodHandle", fmt.Sprintf("%d:#%d", t.ReferenceKind, t.ReferenceIndex),
+			disasm.ReferenceKind(t.ReferenceKind) + " " + p.reference(t.ReferenceIndex)
+	case parse.ConstantMethodTypeInfo:
+		return "MethodType", fmt.Sprintf("#%d", t.DescriptorIndex), p.utf8(t.DescriptorIndex)
+	case parse.ConstantDynamicInfo:
+		return "Dynamic", fmt.Sprintf("#%d:#%d", t.BootstrapMethodAttrIndex, t.NameAndTypeIndex),
+			fmt.Sprintf("#%d:%s", t.BootstrapMethodAttrIndex, p.nameAndTypeAt(t.NameAndTypeIndex))
+	case parse.ConstantInvokeDynamicInfo:
+		return "InvokeDynamic", fmt.Sprintf("#%d:#%d", t.BootstrapMethodAttrIndex, t.NameAndTypeIndex),
+			fmt.Sprintf("#%d:%s", t.BootstrapMethodAttrIndex, p.nameAndTypeAt(t.NameAndTypeIndex))
+	case parse.ConstantModuleInfo:
+		return "Module", fmt.Sprintf("#%d", t.NameIndex), quoteName(p.utf8(t.NameIndex))
+	case parse.ConstantPackageInfo:
+		return "Package", fmt.Sprintf("#%d", t.NameIndex), p.utf8(t.NameIndex)
+	}
+	return fmt.Sprintf("%T", info), "", ""
+}
+
+// constantText describes the constant at index the way javap does in attributes, e.g. "int 5" or "String hello".
+func (p *printer) constantText(index int) string {
+	switch t := p.constant(index).(type) {
+	case parse.ConstantIntegerInfo:
+		return "int " + disasm.Literal(t)
+	case parse.ConstantFloatInfo:
+		return "float " + disasm.Literal(t)
+	case parse.ConstantLongInfo:
+		return "long " + disasm.Literal(t)
+	case parse.ConstantDoubleInfo:
+		return "double " + disasm.Literal(t)
+	case parse.ConstantStringInfo:
+		return "String " + escape(p.utf8(t.StringIndex))
+	case parse.ConstantClassInfo:
+		return "class " + quoteName(p.utf8(t.NameIndex))
+	case parse.ConstantMethodTypeInfo:
+		return "MethodType " + p.utf8(t.DescriptorIndex)
+	case parse.ConstantMethodHandleInfo:
+		return "MethodHandle " + disasm.ReferenceKind(t.ReferenceKind) + " " + p.reference(t.ReferenceIndex)
+	case parse.ConstantDynamicInfo:
+		return fmt.Sprintf("Dynamic #%d:%s", t.BootstrapMethodAttrIndex, p.nameAndTypeAt(t.NameAndTypeIndex))
+	}
+	return fmt.Sprintf("#%d", index)
+}
+
+// constant returns the constant pool entry at index, or nil if there is none.
+func (p *printer) constant(index int) parse.CpInfo {
+	if index < 1 || index > len(p.cf.ConstantPool) {
+		return nil
+	}
+	return p.cf.ConstantPool[index-1]
+}
+
+// utf8 returns the text of the ConstantUtf8Info at index, or "" if there is none.
+func (p *printer) utf8(index int) string {
+	utf8, _ := p.constant(index).(parse.ConstantUtf8Info)
+	return utf8.Text
+}
+
+// className returns the name of the ConstantClassInfo at index, or "" if there is none.
+func (p *printer) className(index int) string {
+	class, ok := p.constant(index).(parse.ConstantClassInfo)
+	if !ok {
+		return ""
+	}
+	return p.utf8(class.NameIndex)
+}
+
+// nameAndTypeAt formats the ConstantNameAndTypeInfo at index as name:descriptor.
+func (p *printer) nameAndTypeAt(index int) string {
+	nat, _ := p.constant(index).(parse.ConstantNameAndTypeInfo)
+	return p.nameAndType(nat)
+}
+
+// nameAndType formats nat as name:descriptor.
+func (p *printer) nameAndType(nat parse.ConstantNameAndTypeInfo) string {
+	return quoteName(p.utf8(nat.NameIndex)) + ":" + p.utf8(nat.DescriptorIndex)
+}
+
+// member formats a field or method reference as class.name:descriptor.
+func (p *printer) member(classIndex int, nameAndTypeIndex int) string {
+	return quoteName(p.className(classIndex)) + "." + p.nameAndTypeAt(nameAndTypeIndex)
+}
+
+// reference formats the field or method reference at index.
+func (p *printer) reference(index int) string {
+	switch t := p.constant(index).(type) {
+	case parse.ConstantFieldrefInfo:
+		return p.member(t.ClassIndex, t.NameAndTypeIndex)
+	case parse.ConstantMethodrefInfo:
+		return p.member(t.ClassIndex, t.NameAndTypeIndex)
+	case parse.ConstantInterfaceMethodrefInfo:
+		return p.member(t.ClassIndex, t.NameAndTypeIndex)
+	}
+	return fmt.Sprintf("#%d", index)
+}
+
+// quoteName quotes names javap would quote, like "<init>" and array classes.
+func quoteName(name string) string {
+	if strings.HasPrefix(name, "<") || strings.HasPrefix(name, "[") {
+		return strconv.Quote(name)
+	}
+	return name
+}
+
+// escape escapes the characters javap doesn't print literally in strings.
+func escape(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch r {
+		case '\t':
+			b.WriteString(`\t`)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\r':
+			b.WriteString(`\r`)
+		case '\f':
+			b.WriteString(`\f`)
+		case '\b':
+			b.WriteString(`\b`)
+		case '"':
+			b.WriteString(`\"`)
+		case '\\':
+			b.WriteString(`\\`)
+		default:
+			if unicode.IsPrint(r) {
+				b.WriteRune(r)
+			} else {
+				fmt.Fprintf(&b, `\u%04x`, r)
+			}
+		}
+	}
+	return b.String()
+}
+
+// readU2s reads count u2 values from info, nil if info is too short.
+func readU2s(info []byte, count int) []int {
+	r := (*parse.ClassFileReader)(bytes.NewReader(info))
+	values := make([]int, count)
+	for i := range values {
+		var err error
+		values[i], err = r.ReadU2()
+		if err != nil {
+			return nil
+		}
+	}
+	return values
+}
+
+// readU2List reads a u2 count followed by that many u2 values from info.
+func readU2List(info []byte) []int {
+	count := readU2s(info, 1)
+	if count == nil {
+		return nil
+	}
+	values := readU2s(info, count[0]+1)
+	if values == nil {
+		return nil
+	}
+	return values[1:]
+}
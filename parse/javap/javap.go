@@ -0,0 +1,358 @@
+// Package javap prints class files in the format of the JDK's javap -v.
+//
+// The output follows javap closely enough to diff the two, generic signatures are only shown as Signature
+// attributes and not in the declarations.
+package javap
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/PPTide/gojdk/parse"
+)
+
+// PrintFile prints the class file called name like javap -v, including the header with its path, size and checksum.
+func PrintFile(w io.Writer, name string) error {
+	content, err := os.ReadFile(name)
+	if err != nil {
+		return err
+	}
+	stat, err := os.Stat(name)
+	if err != nil {
+		return err
+	}
+	cf, err := parse.ParseBytes(content)
+	if err != nil {
+		return err
+	}
+
+	path, err := filepath.Abs(name)
+	if err != nil {
+		return err
+	}
+
+	p := printer{cf: &cf}
+	p.line(0, "Classfile %s", path)
+	p.line(2, "Last modified %s; size %d bytes", stat.ModTime().Format("Jan 2, 2006"), len(content))
+	p.line(2, "SHA-256 checksum %x", sha256.Sum256(content))
+	p.class()
+	_, err = io.WriteString(w, p.b.String())
+	return err
+}
+
+// Print prints cf like javap -v, without the header that describes the file it was read from.
+func Print(w io.Writer, cf *parse.ClassFile) error {
+	p := printer{cf: cf}
+	p.class()
+	_, err := io.WriteString(w, p.b.String())
+	return err
+}
+
+type printer struct {
+	b  strings.Builder
+	cf *parse.ClassFile
+
+	argsSize int                  // args_size of the method whose attributes are printed
+	code     *parse.CodeAttribute // decoded "Code" attribute of the method whose attributes are printed
+}
+
+// line prints one line indented by indent spaces.
+func (p *printer) line(indent int, format string, args ...interface{}) {
+	p.b.WriteString(strings.Repeat(" ", indent))
+	fmt.Fprintf(&p.b, format, args...)
+	p.b.WriteByte('\n')
+}
+
+// commented prints text followed by a javap comment, which starts at the 43rd column for lines indented by 2.
+func (p *printer) commented(indent int, text string, comment string) {
+	p.line(indent, "%-40s // %s", text, comment)
+}
+
+func (p *printer) class() {
+	cf := p.cf
+	if sourceFile := p.sourceFile(); sourceFile != "" {
+		p.line(2, "Compiled from %q", sourceFile)
+	}
+
+	p.line(0, "%s", p.classDeclaration())
+	p.line(2, "minor version: %d", cf.MinorVersion)
+	p.line(2, "major version: %d", cf.MajorVersion)
+	p.line(2, "flags: %s", cf.AccessFlags)
+	p.commented(2, fmt.Sprintf("this_class: #%d", cf.ThisClass), p.className(cf.ThisClass))
+	if cf.SuperClass == 0 {
+		p.line(2, "super_class: #0")
+	} else {
+		p.commented(2, fmt.Sprintf("super_class: #%d", cf.SuperClass), p.className(cf.SuperClass))
+	}
+	p.line(2, "interfaces: %d, fields: %d, methods: %d, attributes: %d",
+		len(cf.Interfaces), len(cf.Fields), len(cf.Methods), len(cf.Attributes))
+
+	p.constantPool()
+
+	p.line(0, "{")
+	first := true
+	for _, field := range cf.Fields {
+		if !first {
+			p.line(0, "")
+		}
+		first = false
+		p.field(field)
+	}
+	for _, method := range cf.Methods {
+		if !first {
+			p.line(0, "")
+		}
+		first = false
+		p.method(method)
+	}
+	p.line(0, "}")
+
+	p.attributes(0, cf.Attributes)
+}
+
+// classDeclaration returns the Java declaration of the class, e.g. "public class Hello extends Base implements Runnable".
+func (p *printer) classDeclaration() string {
+	cf := p.cf
+	if cf.Module != nil {
+		declaration := "module " + cf.Module.Name
+		if cf.Module.Version != "" {
+			declaration += "@" + cf.Module.Version
+		}
+		return declaration
+	}
+
+	flags := cf.AccessFlags
+	var words []string
+	if flags.IsPublic() {
+		words = append(words, "public")
+	}
+	if flags.IsFinal() {
+		words = append(words, "final")
+	}
+	if flags.IsAbstract() && !flags.IsInterface() {
+		words = append(words, "abstract")
+	}
+	if flags.IsInterface() {
+		words = append(words, "interface")
+	} else {
+		words = append(words, "class")
+	}
+	words = append(words, javaName(p.className(cf.ThisClass)))
+
+	superName := p.className(cf.SuperClass)
+	if cf.SuperClass != 0 && superName != "java/lang/Object" {
+		words = append(words, "extends", javaName(superName))
+	}
+	if len(cf.Interfaces) != 0 {
+		names := make([]string, len(cf.Interfaces))
+		for i, index := range cf.Interfaces {
+			names[i] = javaName(p.className(index))
+		}
+		if flags.IsInterface() {
+			words = append(words, "extends")
+		} else {
+			words = append(words, "implements")
+		}
+		words = append(words, strings.Join(names, ","))
+	}
+	return strings.Join(words, " ")
+}
+
+func (p *printer) field(field parse.FieldInfo) {
+	modifiers := modifierList(int(field.AccessFlags), fieldModifiers)
+	p.line(2, "%s%s %s;", modifiers, javaType(field.Descriptor), field.Name)
+	p.line(4, "descriptor: %s", field.Descriptor)
+	p.line(4, "flags: %s", field.AccessFlags)
+	p.attributes(4, field.Attributes)
+}
+
+func (p *printer) method(method parse.MethodInfo) {
+	name := p.utf8(method.NameIndex)
+	descriptor := p.utf8(method.DescriptorIndex)
+	params, result := javaMethodTypes(descriptor)
+	if method.AccessFlags.IsVarargs() && len(params) != 0 {
+		last := params[len(params)-1]
+		params[len(params)-1] = strings.TrimSuffix(last, "[]") + "..."
+	}
+
+	modifiers := modifierList(int(method.AccessFlags), methodModifiers)
+	var declaration string
+	switch name {
+	case "<clinit>":
+		declaration = "static {}"
+	case "<init>":
+		declaration = fmt.Sprintf("%s%s(%s)", modifiers, javaName(p.className(p.cf.ThisClass)), strings.Join(params, ", "))
+	default:
+		declaration = fmt.Sprintf("%s%s %s(%s)", modifiers, result, name, strings.Join(params, ", "))
+	}
+	if exceptions := p.exceptions(method); len(exceptions) != 0 {
+		declaration += " throws " + strings.Join(exceptions, ", ")
+	}
+	p.line(2, "%s;", declaration)
+	p.line(4, "descriptor: %s", descriptor)
+	p.line(4, "flags: %s", method.AccessFlags)
+	p.argsSize = argsSize(p.utf8(method.DescriptorIndex), method.AccessFlags.IsStatic())
+	p.code = method.Code
+	p.attributes(4, method.Attributes)
+	p.code = nil
+}
+
+// exceptions returns the Java names of the checked exceptions of the "Exceptions" attribute of method.
+func (p *printer) exceptions(method parse.MethodInfo) []string {
+	for _, attribute := range method.Attributes {
+		if p.utf8(attribute.AttributeNameIndex) != "Exceptions" {
+			continue
+		}
+		var names []string
+		for _, index := range readU2List(attribute.Info) {
+			names = append(names, javaName(p.className(index)))
+		}
+		return names
+	}
+	return nil
+}
+
+// sourceFile returns the name from the "SourceFile" attribute of the class, or "".
+func (p *printer) sourceFile() string {
+	for _, attribute := range p.cf.Attributes {
+		if p.utf8(attribute.AttributeNameIndex) == "SourceFile" {
+			if indexes := readU2s(attribute.Info, 1); indexes != nil {
+				return p.utf8(indexes[0])
+			}
+		}
+	}
+	return ""
+}
+
+// ------------------- Modifiers ---------------------
+
+type flag struct {
+	mask int
+	name string
+}
+
+var fieldModifiers = []flag{{0x0001, "public"}, {0x0002, "private"}, {0x0004, "protected"}, {0x0008, "static"},
+	{0x0010, "final"}, {0x0040, "volatile"}, {0x0080, "transient"}}
+
+var methodModifiers = []flag{{0x0001, "public"}, {0x0002, "private"}, {0x0004, "protected"}, {0x0008, "static"},
+	{0x0010, "final"}, {0x0020, "synchronized"}, {0x0100, "native"}, {0x0400, "abstract"}, {0x0800, "strictfp"}}
+
+var innerClassModifiers = []flag{{0x0001, "public"}, {0x0002, "private"}, {0x0004, "protected"},
+	{0x0008, "static"}, {0x0010, "final"}, {0x0400, "abstract"}}
+
+// modifierList returns the Java modifiers of flags, each followed by a space.
+func modifierList(flags int, names []flag) string {
+	var b strings.Builder
+	for _, f := range names {
+		if flags&f.mask != 0 {
+			b.WriteString(f.name)
+			b.WriteByte(' ')
+		}
+	}
+	return b.String()
+}
+
+// ------------------- Types ---------------------
+
+// javaName turns an internal class name like java/lang/String into java.lang.String.
+func javaName(name string) string {
+	if strings.HasPrefix(name, "[") {
+		return javaType(name)
+	}
+	return strings.ReplaceAll(name, "/", ".")
+}
+
+// javaType turns a field descriptor like [Ljava/lang/String; into java.lang.String[].
+func javaType(descriptor string) string {
+	name, rest := splitFieldType(descriptor)
+	if rest != "" {
+		return descriptor
+	}
+	return name
+}
+
+// javaMethodTypes returns the Java parameter and result types of a method descriptor.
+func javaMethodTypes(descriptor string) (params []string, result string) {
+	if !strings.HasPrefix(descriptor, "(") {
+		return nil, descriptor
+	}
+	rest := descriptor[1:]
+	for rest != "" && rest[0] != ')' {
+		var param string
+		param, rest = splitFieldType(rest)
+		if param == "" {
+			return nil, descriptor
+		}
+		params = append(params, param)
+	}
+	if rest == "" {
+		return nil, descriptor
+	}
+	result, rest = splitFieldType(rest[1:])
+	if rest != "" {
+		return nil, descriptor
+	}
+	return params, result
+}
+
+// argsSize returns the number of local variable slots the arguments of a method take.
+func argsSize(descriptor string, static bool) int {
+	size := 1
+	if static {
+		size = 0
+	}
+	params, _ := javaMethodTypes(descriptor)
+	for _, param := range params {
+		if param == "long" || param == "double" {
+			size += 2
+		} else {
+			size++
+		}
+	}
+	return size
+}
+
+// splitFieldType returns the Java name of the type descriptor at the start of s and the rest of s. The name is ""
+// if s doesn't start with a valid descriptor.
+func splitFieldType(s string) (name string, rest string) {
+	if s == "" {
+		return "", s
+	}
+	switch s[0] {
+	case 'B':
+		return "byte", s[1:]
+	case 'C':
+		return "char", s[1:]
+	case 'D':
+		return "double", s[1:]
+	case 'F':
+		return "float", s[1:]
+	case 'I':
+		return "int", s[1:]
+	case 'J':
+		return "long", s[1:]
+	case 'S':
+		return "short", s[1:]
+	case 'Z':
+		return "boolean", s[1:]
+	case 'V':
+		return "void", s[1:]
+	case 'L':
+		end := strings.IndexByte(s, ';')
+		if end == -1 {
+			return "", s
+		}
+		return javaName(s[1:end]), s[end+1:]
+	case '[':
+		component, rest := splitFieldType(s[1:])
+		if component == "" {
+			return "", s
+		}
+		return component + "[]", rest
+	}
+	return "", s
+}
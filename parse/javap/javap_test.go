@@ -0,0 +1,89 @@
+package javap
+
+import (
+	"bytes"
+	"crypto/sha256"
+	goflag "flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/PPTide/gojdk/parse"
+)
+
+var update = goflag.Bool("update", false, "rewrite the golden files in testdata")
+
+// goldenClasses are the classes of parse/testdata whose javap -v output is kept in testdata/<name>.javap.
+var goldenClasses = []string{"Hello", "HelloWorld", "Square"}
+
+// firstDifference describes the first line got and want differ in.
+func firstDifference(got, want string) string {
+	gotLines, wantLines := strings.Split(got, "\n"), strings.Split(want, "\n")
+	for i := 0; i < len(gotLines) && i < len(wantLines); i++ {
+		if gotLines[i] != wantLines[i] {
+			return fmt.Sprintf("line %d is\n\t%q\nwant\n\t%q", i+1, gotLines[i], wantLines[i])
+		}
+	}
+	return fmt.Sprintf("got %d lines, want %d", len(gotLines), len(wantLines))
+}
+
+func TestPrint(t *testing.T) {
+	for _, name := range goldenClasses {
+		t.Run(name, func(t *testing.T) {
+			cf, err := parse.Parse(filepath.Join("..", "testdata", name+".class"))
+			if err != nil {
+				t.Fatalf("Parse failed: %v", err)
+			}
+			var out bytes.Buffer
+			if err := Print(&out, &cf); err != nil {
+				t.Fatalf("Print failed: %v", err)
+			}
+
+			golden := filepath.Join("testdata", name+".javap")
+			if *update {
+				if err := os.WriteFile(golden, out.Bytes(), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if out.String() != string(want) {
+				t.Errorf("output differs from %s, %s", golden, firstDifference(out.String(), string(want)))
+			}
+		})
+	}
+}
+
+func TestPrintFile(t *testing.T) {
+	name := filepath.Join("..", "testdata", "Square.class")
+	content, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stat, err := os.Stat(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path, err := filepath.Abs(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := os.ReadFile(filepath.Join("testdata", "Square.javap"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	if err := PrintFile(&out, name); err != nil {
+		t.Fatalf("PrintFile failed: %v", err)
+	}
+	want := fmt.Sprintf("Classfile %s\n  Last modified %s; size 521 bytes\n  SHA-256 checksum %x\n%s", path,
+		stat.ModTime().Format("Jan 2, 2006"), sha256.Sum256(content), body)
+	if out.String() != want {
+		t.Errorf("output differs, %s", firstDifference(out.String(), want))
+	}
+}
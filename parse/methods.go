@@ -1,11 +1,20 @@
 package parse
 
+import "fmt"
+
 type MethodInfo struct {
-	AccessFlags     int
+	AccessFlags     MethodAccessFlags
 	NameIndex       int
 	DescriptorIndex int
 	AttributesCount int
 	Attributes      []AttributeInfo
+	Code            *CodeAttribute   // decoded "Code" attribute, nil for abstract and native methods
+	Signature       *MethodSignature // decoded "Signature" attribute, nil if the method isn't generic
+	SignatureError  error            // why the "Signature" attribute couldn't be decoded
+	Annotations
+	VisibleParameterAnnotations   [][]Annotation // decoded "RuntimeVisibleParameterAnnotations" attribute
+	InvisibleParameterAnnotations [][]Annotation // decoded "RuntimeInvisibleParameterAnnotations" attribute
+	AnnotationDefault             *ElementValue  // decoded "AnnotationDefault" attribute of annotation interface elements
 }
 
 func (r *ClassFileReader) ReadMethods() (size int, entries []MethodInfo, err error) {
@@ -19,6 +28,7 @@ func (r *ClassFileReader) ReadMethods() (size int, entries []MethodInfo, err err
 		entry, err = r.ReadMethodInfo()
 		entries = append(entries, entry)
 		if err != nil {
+			err = atPath(fmt.Sprintf("methods[%d]", i), err)
 			return
 		}
 	}
@@ -27,10 +37,11 @@ func (r *ClassFileReader) ReadMethods() (size int, entries []MethodInfo, err err
 }
 
 func (r *ClassFileReader) ReadMethodInfo() (info MethodInfo, err error) {
-	info.AccessFlags, err = r.ReadU2()
+	flags, err := r.ReadU2()
 	if err != nil {
 		return
 	}
+	info.AccessFlags = MethodAccessFlags(flags)
 
 	info.NameIndex, err = r.ReadU2()
 	if err != nil {
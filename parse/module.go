@@ -0,0 +1,307 @@
+package parse
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// ModuleDescriptor is the decoded content of the "Module", "ModulePackages" and "ModuleMainClass" attributes of a
+// module-info class (JVMS §4.7.25 to §4.7.27).
+type ModuleDescriptor struct {
+	ModuleNameIndex    int // u2 index of a ConstantModuleInfo
+	Name               string
+	ModuleFlags        int // u2 0x0020: ACC_OPEN, 0x1000: ACC_SYNTHETIC, 0x8000: ACC_MANDATED
+	ModuleVersionIndex int // u2 index of a ConstantUtf8Info or 0
+	Version            string
+	Requires           []ModuleRequires
+	Exports            []ModuleExports
+	Opens              []ModuleExports
+	UsesIndex          []int // u2 indexes of ConstantClassInfo
+	Uses               []string
+	Provides           []ModuleProvides
+
+	PackageIndex   []int // u2 indexes of ConstantPackageInfo from "ModulePackages"
+	Packages       []string
+	MainClassIndex int // u2 index of a ConstantClassInfo from "ModuleMainClass" or 0
+	MainClass      string
+}
+
+// ModuleRequires is a dependence on another module.
+type ModuleRequires struct {
+	RequiresIndex        int // u2 index of a ConstantModuleInfo
+	Module               string
+	RequiresFlags        int // u2 0x0020: ACC_TRANSITIVE, 0x0040: ACC_STATIC_PHASE, 0x1000: ACC_SYNTHETIC, 0x8000: ACC_MANDATED
+	RequiresVersionIndex int // u2 index of a ConstantUtf8Info or 0
+	Version              string
+}
+
+// ModuleExports is an exported or opened package. To is empty if every module has access.
+type ModuleExports struct {
+	PackageIndex int // u2 index of a ConstantPackageInfo
+	Package      string
+	Flags        int   // u2 0x1000: ACC_SYNTHETIC, 0x8000: ACC_MANDATED
+	ToIndex      []int // u2 indexes of ConstantModuleInfo
+	To           []string
+}
+
+// ModuleProvides is a service implemented by the module.
+type ModuleProvides struct {
+	ProvidesIndex int // u2 index of a ConstantClassInfo
+	Service       string
+	WithIndex     []int // u2 indexes of ConstantClassInfo
+	With          []string
+}
+
+// ReadModule reads the content of a "Module" attribute into m, leaving the fields of the other module attributes
+// untouched.
+func (r *ClassFileReader) ReadModule(m *ModuleDescriptor) (err error) {
+	m.ModuleNameIndex, err = r.ReadU2()
+	if err != nil {
+		return
+	}
+
+	m.ModuleFlags, err = r.ReadU2()
+	if err != nil {
+		return
+	}
+
+	m.ModuleVersionIndex, err = r.ReadU2()
+	if err != nil {
+		return
+	}
+
+	size, err := r.ReadU2()
+	if err != nil {
+		return
+	}
+	for i := 0; i < size; i++ {
+		var requires ModuleRequires
+		requires.RequiresIndex, err = r.ReadU2()
+		if err != nil {
+			return
+		}
+		requires.RequiresFlags, err = r.ReadU2()
+		if err != nil {
+			return
+		}
+		requires.RequiresVersionIndex, err = r.ReadU2()
+		if err != nil {
+			return
+		}
+		m.Requires = append(m.Requires, requires)
+	}
+
+	m.Exports, err = r.readModuleExports()
+	if err != nil {
+		return
+	}
+
+	m.Opens, err = r.readModuleExports()
+	if err != nil {
+		return
+	}
+
+	m.UsesIndex, err = r.readU2List()
+	if err != nil {
+		return
+	}
+
+	size, err = r.ReadU2()
+	if err != nil {
+		return
+	}
+	for i := 0; i < size; i++ {
+		var provides ModuleProvides
+		provides.ProvidesIndex, err = r.ReadU2()
+		if err != nil {
+			return
+		}
+		provides.WithIndex, err = r.readU2List()
+		if err != nil {
+			return
+		}
+		m.Provides = append(m.Provides, provides)
+	}
+
+	return
+}
+
+// readModuleExports reads the exports or opens table of a "Module" attribute.
+func (r *ClassFileReader) readModuleExports() (entries []ModuleExports, err error) {
+	size, err := r.ReadU2()
+	if err != nil {
+		return
+	}
+
+	for i := 0; i < size; i++ {
+		var entry ModuleExports
+		entry.PackageIndex, err = r.ReadU2()
+		if err != nil {
+			return
+		}
+		entry.Flags, err = r.ReadU2()
+		if err != nil {
+			return
+		}
+		entry.ToIndex, err = r.readU2List()
+		if err != nil {
+			return
+		}
+		entries = append(entries, entry)
+	}
+
+	return
+}
+
+// readU2List reads a u2 count followed by that many u2 values.
+func (r *ClassFileReader) readU2List() (values []int, err error) {
+	size, err := r.ReadU2()
+	if err != nil {
+		return
+	}
+
+	for i := 0; i < size; i++ {
+		var value int
+		value, err = r.ReadU2()
+		if err != nil {
+			return
+		}
+		values = append(values, value)
+	}
+
+	return
+}
+
+// decodeModuleAttribute decodes the "Module", "ModulePackages" or "ModuleMainClass" attribute into f.Module.
+func (f *ClassFile) decodeModuleAttribute(name string, attribute AttributeInfo) error {
+	reader := (*ClassFileReader)(bytes.NewReader(attribute.Info))
+	if f.Module == nil {
+		f.Module = new(ModuleDescriptor)
+	}
+	m := f.Module
+
+	var err error
+	switch name {
+	case "Module":
+		err = reader.ReadModule(m)
+		if err == nil {
+			err = f.resolveModule(m)
+		}
+	case "ModulePackages":
+		m.PackageIndex, err = reader.readU2List()
+		m.Packages = make([]string, len(m.PackageIndex))
+		for i := 0; i < len(m.PackageIndex) && err == nil; i++ {
+			m.Packages[i], err = f.packageName(m.PackageIndex[i])
+		}
+	case "ModuleMainClass":
+		m.MainClassIndex, err = reader.ReadU2()
+		if err == nil {
+			m.MainClass, err = f.className(m.MainClassIndex)
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("%s attribute: %w", name, err)
+	}
+	if reader.Len() != 0 {
+		return fmt.Errorf("couldn't fully read the %s attribute", name)
+	}
+	return nil
+}
+
+// resolveModule fills in the names the indexes of a decoded "Module" attribute point to.
+func (f *ClassFile) resolveModule(m *ModuleDescriptor) (err error) {
+	m.Name, err = f.moduleName(m.ModuleNameIndex)
+	if err != nil {
+		return
+	}
+	m.Version, err = f.optionalUtf8Text(m.ModuleVersionIndex)
+	if err != nil {
+		return
+	}
+
+	for i := range m.Requires {
+		requires := &m.Requires[i]
+		requires.Module, err = f.moduleName(requires.RequiresIndex)
+		if err != nil {
+			return
+		}
+		requires.Version, err = f.optionalUtf8Text(requires.RequiresVersionIndex)
+		if err != nil {
+			return
+		}
+	}
+
+	for _, table := range [][]ModuleExports{m.Exports, m.Opens} {
+		for i := range table {
+			entry := &table[i]
+			entry.Package, err = f.packageName(entry.PackageIndex)
+			if err != nil {
+				return
+			}
+			entry.To = make([]string, len(entry.ToIndex))
+			for k, index := range entry.ToIndex {
+				entry.To[k], err = f.moduleName(index)
+				if err != nil {
+					return
+				}
+			}
+		}
+	}
+
+	m.Uses = make([]string, len(m.UsesIndex))
+	for i, index := range m.UsesIndex {
+		m.Uses[i], err = f.className(index)
+		if err != nil {
+			return
+		}
+	}
+
+	for i := range m.Provides {
+		provides := &m.Provides[i]
+		provides.Service, err = f.className(provides.ProvidesIndex)
+		if err != nil {
+			return
+		}
+		provides.With = make([]string, len(provides.WithIndex))
+		for k, index := range provides.WithIndex {
+			provides.With[k], err = f.className(index)
+			if err != nil {
+				return
+			}
+		}
+	}
+
+	return
+}
+
+// moduleName returns the name of the ConstantModuleInfo at index.
+func (f *ClassFile) moduleName(index int) (string, error) {
+	if index < 1 || index > len(f.ConstantPool) {
+		return "", fmt.Errorf("constant pool index %d out of range", index)
+	}
+	module, ok := f.ConstantPool[index-1].(ConstantModuleInfo)
+	if !ok {
+		return "", fmt.Errorf("constant pool entry %d not of type ConstantModuleInfo", index)
+	}
+	return f.utf8Text(module.NameIndex)
+}
+
+// packageName returns the name of the ConstantPackageInfo at index.
+func (f *ClassFile) packageName(index int) (string, error) {
+	if index < 1 || index > len(f.ConstantPool) {
+		return "", fmt.Errorf("constant pool index %d out of range", index)
+	}
+	pkg, ok := f.ConstantPool[index-1].(ConstantPackageInfo)
+	if !ok {
+		return "", fmt.Errorf("constant pool entry %d not of type ConstantPackageInfo", index)
+	}
+	return f.utf8Text(pkg.NameIndex)
+}
+
+// optionalUtf8Text returns the text of the ConstantUtf8Info at index or "" if index is 0.
+func (f *ClassFile) optionalUtf8Text(index int) (string, error) {
+	if index == 0 {
+		return "", nil
+	}
+	return f.utf8Text(index)
+}
@@ -0,0 +1,146 @@
+package parse
+
+import (
+	"bytes"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDecodeModule(t *testing.T) {
+	cf, err := Parse(filepath.Join("testdata", "module-info.class"))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	m := cf.Module
+	if m == nil {
+		t.Fatal("module-info has no ModuleDescriptor")
+	}
+
+	if m.Name != "com.example.app" || m.ModuleFlags != 0 || m.Version != "1.0" {
+		t.Errorf("module %s@%s with flags %#x, want com.example.app@1.0 without flags", m.Name, m.Version, m.ModuleFlags)
+	}
+
+	type requires struct {
+		module  string
+		flags   int
+		version string
+	}
+	var gotRequires []requires
+	for _, r := range m.Requires {
+		gotRequires = append(gotRequires, requires{r.Module, r.RequiresFlags, r.Version})
+	}
+	wantRequires := []requires{
+		{"java.base", 0x8000, "17"},     // ACC_MANDATED
+		{"java.logging", 0x0020, "17"},  // ACC_TRANSITIVE
+		{"java.compiler", 0x0040, "17"}, // ACC_STATIC_PHASE
+	}
+	if !reflect.DeepEqual(gotRequires, wantRequires) {
+		t.Errorf("requires %+v, want %+v", gotRequires, wantRequires)
+	}
+
+	type exports struct {
+		pkg   string
+		flags int
+		to    []string
+	}
+	convert := func(entries []ModuleExports) []exports {
+		var res []exports
+		for _, e := range entries {
+			res = append(res, exports{e.Package, e.Flags, e.To})
+		}
+		return res
+	}
+	wantExports := []exports{
+		{"com/example/api", 0, []string{}},
+		{"com/example/spi", 0, []string{"com.example.plugins", "com.example.tests"}},
+	}
+	if got := convert(m.Exports); !reflect.DeepEqual(got, wantExports) {
+		t.Errorf("exports %+v, want %+v", got, wantExports)
+	}
+	wantOpens := []exports{
+		{"com/example/model", 0, []string{}},
+		{"com/example/internal", 0, []string{"com.example.tests"}},
+	}
+	if got := convert(m.Opens); !reflect.DeepEqual(got, wantOpens) {
+		t.Errorf("opens %+v, want %+v", got, wantOpens)
+	}
+
+	if !reflect.DeepEqual(m.Uses, []string{"com/example/spi/Plugin"}) {
+		t.Errorf("uses %v, want com/example/spi/Plugin", m.Uses)
+	}
+	wantProvides := []ModuleProvides{{
+		Service: "com/example/spi/Plugin",
+		With:    []string{"com/example/impl/DefaultPlugin", "com/example/impl/FastPlugin"},
+	}}
+	for i := range m.Provides {
+		m.Provides[i].ProvidesIndex, m.Provides[i].WithIndex = 0, nil
+	}
+	if !reflect.DeepEqual(m.Provides, wantProvides) {
+		t.Errorf("provides %+v, want %+v", m.Provides, wantProvides)
+	}
+
+	wantPackages := []string{"com/example/api", "com/example/spi", "com/example/model", "com/example/internal",
+		"com/example/impl", "com/example/app"}
+	if !reflect.DeepEqual(m.Packages, wantPackages) {
+		t.Errorf("packages %v, want %v", m.Packages, wantPackages)
+	}
+	if m.MainClass != "com/example/app/Main" {
+		t.Errorf("main class %s, want com/example/app/Main", m.MainClass)
+	}
+}
+
+func TestDecodeModuleErrors(t *testing.T) {
+	cf, err := Parse(filepath.Join("testdata", "module-info.class"))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	// indexes into the constant pool of module-info.class
+	const (
+		moduleName  = 5  // Module com.example.app
+		utf8        = 6  // Utf8 1.0
+		pluginClass = 27 // Class com/example/spi/Plugin
+		apiPackage  = 15 // Package com/example/api
+	)
+	module := cf.Attributes[1].Info
+
+	tests := []struct {
+		name      string
+		attribute int // index of the attribute to replace
+		info      []byte
+		reason    string
+	}{
+		{"module name isn't a Module", 1, concat(u2(utf8), module[2:]), "constant pool entry 6 not of type ConstantModuleInfo"},
+		{"version isn't a Utf8", 1, concat(module[:4], u2(moduleName), module[6:]), "constant pool entry 5 not of type ConstantUtf8Info"},
+		{"truncated", 1, module[:len(module)-1], "couldn't read 2 bytes"},
+		{"trailing bytes", 1, concat(module, []byte{0}), "couldn't fully read the Module attribute"},
+		{"package isn't a Package", 2, concat(u2(1), u2(pluginClass)), "constant pool entry 27 not of type ConstantPackageInfo"},
+		{"package index out of range", 2, concat(u2(1), u2(1000)), "constant pool index 1000 out of range"},
+		{"main class isn't a Class", 3, u2(apiPackage), "constant pool entry 15 is ConstantPackageInfo instead of ConstantClassInfo"},
+		{"main class with trailing bytes", 3, concat(u2(pluginClass), u2(pluginClass)),
+			"attribute length 4 doesn't match expected length 2"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			corrupt := cf
+			corrupt.Attributes = append([]AttributeInfo{}, cf.Attributes...)
+			corrupt.Attributes[test.attribute].Info = test.info
+			var out bytes.Buffer
+			if err := Write(&out, corrupt); err != nil {
+				t.Fatalf("Write failed: %v", err)
+			}
+
+			_, err := ParseBytes(out.Bytes())
+			var formatError *FormatError
+			if !errors.As(err, &formatError) {
+				t.Fatalf("ParseBytes returned %v, want a *FormatError", err)
+			}
+			if !strings.HasPrefix(formatError.Path, "attributes[Module") || !strings.Contains(err.Error(), test.reason) {
+				t.Errorf("got %q at %s, want one containing %q at a module attribute", err, formatError.Path, test.reason)
+			}
+		})
+	}
+}
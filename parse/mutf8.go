@@ -0,0 +1,117 @@
+package parse
+
+import (
+	"fmt"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// DecodeModifiedUTF8 decodes the modified UTF-8 used by ConstantUtf8Info into a go string.
+//
+// Modified UTF-8 differs from standard UTF-8 in two ways: the null character is encoded as the two bytes 0xC0 0x80
+// and supplementary characters are encoded as a surrogate pair of two three byte sequences.
+// Unpaired surrogates are legal in java strings but not in UTF-8, they are kept as their three byte sequence so they
+// survive EncodeModifiedUTF8.
+func DecodeModifiedUTF8(b []byte) (string, error) {
+	out := make([]byte, 0, len(b))
+
+	for i := 0; i < len(b); {
+		c := b[i]
+		switch {
+		case c == 0 || c >= 0xF0:
+			return "", fmt.Errorf("illegal byte 0x%02X at offset %d in modified UTF-8", c, i)
+		case c < 0x80:
+			out = append(out, c)
+			i++
+		case c&0xE0 == 0xC0:
+			if i+1 >= len(b) || b[i+1]&0xC0 != 0x80 {
+				return "", fmt.Errorf("truncated two byte sequence at offset %d in modified UTF-8", i)
+			}
+			r := rune(c&0x1F)<<6 | rune(b[i+1]&0x3F)
+			if r != 0 && r < 0x80 {
+				return "", fmt.Errorf("overlong two byte sequence at offset %d in modified UTF-8", i)
+			}
+			out = utf8.AppendRune(out, r)
+			i += 2
+		case c&0xF0 == 0xE0:
+			r, err := decodeThreeBytes(b, i)
+			if err != nil {
+				return "", err
+			}
+			if utf16.IsSurrogate(r) && r < 0xDC00 && i+5 < len(b) && b[i+3]&0xF0 == 0xE0 {
+				low, err := decodeThreeBytes(b, i+3)
+				if err != nil {
+					return "", err
+				}
+				if pair := utf16.DecodeRune(r, low); pair != utf8.RuneError {
+					out = utf8.AppendRune(out, pair)
+					i += 6
+					continue
+				}
+			}
+			if utf16.IsSurrogate(r) {
+				out = append(out, b[i:i+3]...)
+			} else {
+				out = utf8.AppendRune(out, r)
+			}
+			i += 3
+		default:
+			return "", fmt.Errorf("unexpected continuation byte 0x%02X at offset %d in modified UTF-8", c, i)
+		}
+	}
+
+	return string(out), nil
+}
+
+// decodeThreeBytes decodes the three byte sequence starting at b[i].
+func decodeThreeBytes(b []byte, i int) (rune, error) {
+	if i+2 >= len(b) || b[i+1]&0xC0 != 0x80 || b[i+2]&0xC0 != 0x80 {
+		return 0, fmt.Errorf("truncated three byte sequence at offset %d in modified UTF-8", i)
+	}
+	r := rune(b[i]&0x0F)<<12 | rune(b[i+1]&0x3F)<<6 | rune(b[i+2]&0x3F)
+	if r < 0x800 {
+		return 0, fmt.Errorf("overlong three byte sequence at offset %d in modified UTF-8", i)
+	}
+	return r, nil
+}
+
+// EncodeModifiedUTF8 encodes s as modified UTF-8 like it is stored in ConstantUtf8Info.Content.
+//
+// Surrogates kept by DecodeModifiedUTF8 are written back unchanged, any other invalid UTF-8 in s is replaced by
+// U+FFFD.
+func EncodeModifiedUTF8(s string) []byte {
+	out := make([]byte, 0, len(s))
+
+	for i := 0; i < len(s); {
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if r == utf8.RuneError && size == 1 {
+			if i+2 < len(s) && s[i] == 0xED && s[i+1]&0xE0 == 0xA0 && s[i+2]&0xC0 == 0x80 {
+				out = append(out, s[i:i+3]...)
+				i += 3
+				continue
+			}
+		}
+		i += size
+
+		switch {
+		case r == 0:
+			out = append(out, 0xC0, 0x80)
+		case r < 0x80:
+			out = append(out, byte(r))
+		case r > 0xFFFF:
+			high, low := utf16.EncodeRune(r)
+			out = appendThreeBytes(appendThreeBytes(out, high), low)
+		case r < 0x800:
+			out = append(out, 0xC0|byte(r>>6), 0x80|byte(r&0x3F))
+		default:
+			out = appendThreeBytes(out, r)
+		}
+	}
+
+	return out
+}
+
+// appendThreeBytes appends the three byte encoding of a rune in the basic multilingual plane.
+func appendThreeBytes(b []byte, r rune) []byte {
+	return append(b, 0xE0|byte(r>>12), 0x80|byte((r>>6)&0x3F), 0x80|byte(r&0x3F))
+}
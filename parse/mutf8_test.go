@@ -0,0 +1,61 @@
+package parse
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestModifiedUTF8(t *testing.T) {
+	tests := []struct {
+		name    string
+		text    string
+		encoded []byte
+	}{
+		{"empty", "", []byte{}},
+		{"ascii", "main", []byte("main")},
+		{"nul", "a\x00b", []byte{'a', 0xC0, 0x80, 'b'}},
+		{"two bytes", "é", []byte{0xC3, 0xA9}},
+		{"three bytes", "€", []byte{0xE2, 0x82, 0xAC}},
+		{"supplementary", "😀", []byte{0xED, 0xA0, 0xBD, 0xED, 0xB8, 0x80}},
+		{"supplementary between ascii", "a😀b", []byte{'a', 0xED, 0xA0, 0xBD, 0xED, 0xB8, 0x80, 'b'}},
+		{"unpaired high surrogate", "\xED\xA0\xBD", []byte{0xED, 0xA0, 0xBD}},
+		{"unpaired low surrogate", "x\xED\xB8\x80", []byte{'x', 0xED, 0xB8, 0x80}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			encoded := EncodeModifiedUTF8(test.text)
+			if !bytes.Equal(encoded, test.encoded) {
+				t.Errorf("EncodeModifiedUTF8(%q) = % X, want % X", test.text, encoded, test.encoded)
+			}
+			text, err := DecodeModifiedUTF8(test.encoded)
+			if err != nil {
+				t.Fatalf("DecodeModifiedUTF8(% X) failed: %v", test.encoded, err)
+			}
+			if text != test.text {
+				t.Errorf("DecodeModifiedUTF8(% X) = %q, want %q", test.encoded, text, test.text)
+			}
+		})
+	}
+}
+
+func TestDecodeModifiedUTF8Errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		encoded []byte
+	}{
+		{"raw nul", []byte{'a', 0x00}},
+		{"four byte sequence", []byte{0xF0, 0x9F, 0x98, 0x80}},
+		{"truncated two bytes", []byte{0xC3}},
+		{"truncated three bytes", []byte{0xE2, 0x82}},
+		{"overlong two bytes", []byte{0xC1, 0x81}},
+		{"overlong three bytes", []byte{0xE0, 0x81, 0x81}},
+		{"continuation byte", []byte{0x80}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if text, err := DecodeModifiedUTF8(test.encoded); err == nil {
+				t.Errorf("DecodeModifiedUTF8(% X) = %q, want an error", test.encoded, text)
+			}
+		})
+	}
+}
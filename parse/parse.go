@@ -57,6 +57,36 @@ func (r *ClassFileReader) ReadU2() (res int, err error) {
 	return
 }
 
+// readBytes reads n bytes. Lengths are checked against the rest of the input before allocating anything, so a
+// crafted length can't make the parser allocate more than the size of the class file.
+func (r *ClassFileReader) readBytes(n int, what string) ([]byte, error) {
+	if n > r.Len() {
+		return nil, fmt.Errorf("%s length %d exceeds the %d bytes left", what, n, r.Len())
+	}
+	b := make([]byte, n)
+	if n == 0 {
+		return b, nil // Read reports io.EOF for empty slices at the end of the input
+	}
+	_, err := r.Read(b)
+	return b, err
+}
+
+// readU8 reads 8 bytes and interprets them as a big endian unsigned int.
+func (r *ClassFileReader) readU8() (res uint64, err error) {
+	high, err := r.ReadU4()
+	if err != nil {
+		return
+	}
+	low, err := r.ReadU4()
+	if err != nil {
+		return
+	}
+
+	res = uint64(high)<<32 | uint64(low)
+
+	return
+}
+
 // Seek calls bytes.Reader.Seek() (implement io.Seeker)
 func (r *ClassFileReader) Seek(offset int64, whence int) (int64, error) {
 	return ((*bytes.Reader)(r)).Seek(offset, whence)
@@ -80,99 +110,379 @@ type ClassFile struct {
 	MajorVersion      int
 	ConstantPoolCount int
 	ConstantPool      []CpInfo
-	AccessFlags       int
+	AccessFlags       ClassAccessFlags
 	ThisClass         int
 	SuperClass        int
 	InterfacesCount   int
 	Interfaces        []int
 	FieldsCount       int
-	Fields            interface{}
+	Fields            []FieldInfo
 	MethodsCount      int
 	Methods           []MethodInfo
 	AttributesCount   int
 	Attributes        []AttributeInfo
+	Signature         *ClassSignature   // decoded "Signature" attribute, nil for classes that aren't generic
+	SignatureError    error             // why the "Signature" attribute couldn't be decoded, it doesn't fail Parse
+	Module            *ModuleDescriptor // decoded module attributes, nil unless this is a module-info class
+
+	InnerClasses        []InnerClassEntry     // decoded "InnerClasses" attribute
+	EnclosingMethod     *EnclosingMethod      // decoded "EnclosingMethod" attribute, nil unless this is a local or anonymous class
+	NestHost            string                // decoded "NestHost" attribute, "" if the class is the host of its own nest
+	NestMembers         []string              // decoded "NestMembers" attribute
+	PermittedSubclasses []string              // decoded "PermittedSubclasses" attribute, nil unless the class is sealed
+	Record              []RecordComponentInfo // decoded "Record" attribute, nil unless the class is a record
+	Annotations
 }
 
 func (f *ClassFile) resolveIndexes() error {
-	for i, info := range f.ConstantPool {
-		switch t := info.(type) {
-		case ConstantUtf8Info:
-			t.Text = string(t.Content)
-			f.ConstantPool[i] = t
-		case ConstantNameAndTypeInfo:
-			if _, ok := f.ConstantPool[t.NameIndex-1].(ConstantUtf8Info); !ok {
-				return fmt.Errorf("name not of type ConstantUtf8Info")
-			}
-			t.Name = &f.ConstantPool[t.NameIndex-1]
-			if _, ok := f.ConstantPool[t.DescriptorIndex-1].(ConstantUtf8Info); !ok {
-				return fmt.Errorf("name not of type ConstantUtf8Info")
-			}
-			t.Descriptor = &f.ConstantPool[t.DescriptorIndex-1]
-			f.ConstantPool[i] = t
-		case ConstantClassInfo:
-			if _, ok := f.ConstantPool[t.NameIndex-1].(ConstantUtf8Info); !ok {
-				return fmt.Errorf("name not of type ConstantUtf8Info")
-			}
-			t.Name = &f.ConstantPool[t.NameIndex-1]
-			f.ConstantPool[i] = t
-		case ConstantMethodrefInfo:
-			if _, ok := f.ConstantPool[t.ClassIndex-1].(ConstantClassInfo); !ok {
-				return fmt.Errorf("name not of type ConstantClassInfo")
-			}
-			t.Class = &f.ConstantPool[t.ClassIndex-1]
-			if _, ok := f.ConstantPool[t.NameAndTypeIndex-1].(ConstantNameAndTypeInfo); !ok {
-				return fmt.Errorf("name not of type ConstantNameAndTypeInfo")
-			}
-			t.NameAndType = &f.ConstantPool[t.NameAndTypeIndex-1]
-			f.ConstantPool[i] = t
-		case ConstantFieldrefInfo:
-			if _, ok := f.ConstantPool[t.ClassIndex-1].(ConstantClassInfo); !ok {
-				return fmt.Errorf("name not of type ConstantClassInfo")
-			}
-			t.Class = &f.ConstantPool[t.ClassIndex-1]
-			if _, ok := f.ConstantPool[t.NameAndTypeIndex-1].(ConstantNameAndTypeInfo); !ok {
-				return fmt.Errorf("name not of type ConstantNameAndTypeInfo")
-			}
-			t.NameAndType = &f.ConstantPool[t.NameAndTypeIndex-1]
-			f.ConstantPool[i] = t
-		case ConstantStringInfo:
-			if _, ok := f.ConstantPool[t.StringIndex-1].(ConstantUtf8Info); !ok {
-				return fmt.Errorf("name not of type ConstantUft8Info")
-			}
-			t.String = &f.ConstantPool[t.StringIndex-1]
-			f.ConstantPool[i] = t
-		case ConstantIntegerInfo:
-			continue
-		case ConstantLongInfo:
-			continue //TODO: I might be able to do stuff here :)
-		case ConstantInvokeDynamicInfo:
-			if _, ok := f.ConstantPool[t.NameAndTypeIndex-1].(ConstantNameAndTypeInfo); !ok {
-				return fmt.Errorf("name not of type ConstantNameAndTypeInfo")
-			}
-			t.NameAndType = &f.ConstantPool[t.NameAndTypeIndex-1]
-			f.ConstantPool[i] = t
-		case ConstantMethodHandleInfo:
-			//TODO: Check reference using reference kind
-			t.Reference = &f.ConstantPool[t.ReferenceIndex]
-			f.ConstantPool[i] = t
-		case ConstantInterfaceMethodrefInfo:
-			if _, ok := f.ConstantPool[t.ClassIndex-1].(ConstantClassInfo); !ok {
-				return fmt.Errorf("name not of type ConstantClassInfo")
-			}
-			t.Class = &f.ConstantPool[t.ClassIndex-1]
-			if _, ok := f.ConstantPool[t.NameAndTypeIndex-1].(ConstantNameAndTypeInfo); !ok {
-				return fmt.Errorf("name not of type ConstantNameAndTypeInfo")
-			}
-			t.NameAndType = &f.ConstantPool[t.NameAndTypeIndex-1]
-			f.ConstantPool[i] = t
-		default:
-			return fmt.Errorf("unkown type %s trying to resolve indexes", reflect.TypeOf(t))
+	for i := range f.ConstantPool {
+		if err := f.resolveConstant(i); err != nil {
+			return atPath(fmt.Sprintf("constant_pool[%d]", i+1), err)
 		}
 	}
 	//TODO: add the same for interfaces, fields, methods and attributes
 	return nil
 }
 
+// resolveConstant resolves the indexes of the constant pool entry in slot i.
+func (f *ClassFile) resolveConstant(i int) (err error) {
+	switch t := f.ConstantPool[i].(type) {
+	case ConstantUtf8Info:
+		t.Text, err = DecodeModifiedUTF8(t.Content)
+		if err != nil {
+			return
+		}
+		f.ConstantPool[i] = t
+	case ConstantNameAndTypeInfo:
+		t.Name, err = f.entryOf(t.NameIndex, ConstantUtf8Info{})
+		if err != nil {
+			return
+		}
+		t.Descriptor, err = f.entryOf(t.DescriptorIndex, ConstantUtf8Info{})
+		if err != nil {
+			return
+		}
+		f.ConstantPool[i] = t
+	case ConstantClassInfo:
+		t.Name, err = f.entryOf(t.NameIndex, ConstantUtf8Info{})
+		if err != nil {
+			return
+		}
+		f.ConstantPool[i] = t
+	case ConstantMethodrefInfo:
+		t.Class, t.NameAndType, err = f.memberRef(t.ClassIndex, t.NameAndTypeIndex)
+		if err != nil {
+			return
+		}
+		f.ConstantPool[i] = t
+	case ConstantFieldrefInfo:
+		t.Class, t.NameAndType, err = f.memberRef(t.ClassIndex, t.NameAndTypeIndex)
+		if err != nil {
+			return
+		}
+		f.ConstantPool[i] = t
+	case ConstantStringInfo:
+		t.String, err = f.entryOf(t.StringIndex, ConstantUtf8Info{})
+		if err != nil {
+			return
+		}
+		f.ConstantPool[i] = t
+	case ConstantIntegerInfo, ConstantFloatInfo, ConstantLongInfo, ConstantDoubleInfo:
+		return
+	case ConstantInvokeDynamicInfo:
+		t.NameAndType, err = f.entryOf(t.NameAndTypeIndex, ConstantNameAndTypeInfo{})
+		if err != nil {
+			return
+		}
+		f.ConstantPool[i] = t
+	case ConstantMethodHandleInfo:
+		// which kind of reference is allowed for the reference kind is checked by Validate
+		t.Reference, err = f.entry(t.ReferenceIndex)
+		if err != nil {
+			return
+		}
+		switch (*t.Reference).(type) {
+		case ConstantFieldrefInfo, ConstantMethodrefInfo, ConstantInterfaceMethodrefInfo:
+		default:
+			return fmt.Errorf("reference not of type ConstantFieldrefInfo, ConstantMethodrefInfo or ConstantInterfaceMethodrefInfo")
+		}
+		f.ConstantPool[i] = t
+	case ConstantInterfaceMethodrefInfo:
+		t.Class, t.NameAndType, err = f.memberRef(t.ClassIndex, t.NameAndTypeIndex)
+		if err != nil {
+			return
+		}
+		f.ConstantPool[i] = t
+	case ConstantMethodTypeInfo:
+		t.Descriptor, err = f.entryOf(t.DescriptorIndex, ConstantUtf8Info{})
+		if err != nil {
+			return
+		}
+		f.ConstantPool[i] = t
+	case ConstantDynamicInfo:
+		t.NameAndType, err = f.entryOf(t.NameAndTypeIndex, ConstantNameAndTypeInfo{})
+		if err != nil {
+			return
+		}
+		f.ConstantPool[i] = t
+	case ConstantModuleInfo:
+		t.Name, err = f.entryOf(t.NameIndex, ConstantUtf8Info{})
+		if err != nil {
+			return
+		}
+		f.ConstantPool[i] = t
+	case ConstantPackageInfo:
+		t.Name, err = f.entryOf(t.NameIndex, ConstantUtf8Info{})
+		if err != nil {
+			return
+		}
+		f.ConstantPool[i] = t
+	default:
+		return fmt.Errorf("unkown type %s trying to resolve indexes", reflect.TypeOf(t))
+	}
+	return
+}
+
+// entry returns the constant pool entry at index.
+func (f *ClassFile) entry(index int) (*CpInfo, error) {
+	if index < 1 || index > len(f.ConstantPool) {
+		return nil, fmt.Errorf("constant pool index %d out of range", index)
+	}
+	return &f.ConstantPool[index-1], nil
+}
+
+// entryOf returns the constant pool entry at index, which has to be of the same type as want.
+func (f *ClassFile) entryOf(index int, want CpInfo) (*CpInfo, error) {
+	info, err := f.entry(index)
+	if err != nil {
+		return nil, err
+	}
+	if reflect.TypeOf(*info) != reflect.TypeOf(want) {
+		return nil, fmt.Errorf("constant pool entry %d not of type %s", index, reflect.TypeOf(want).Name())
+	}
+	return info, nil
+}
+
+// memberRef returns the class and name and type entries of a field, method or interface method reference.
+func (f *ClassFile) memberRef(classIndex int, nameAndTypeIndex int) (class *CpInfo, nameAndType *CpInfo, err error) {
+	class, err = f.entryOf(classIndex, ConstantClassInfo{})
+	if err != nil {
+		return
+	}
+	nameAndType, err = f.entryOf(nameAndTypeIndex, ConstantNameAndTypeInfo{})
+	return
+}
+
+// utf8Text returns the text of the ConstantUtf8Info at index.
+func (f *ClassFile) utf8Text(index int) (string, error) {
+	if index < 1 || index > len(f.ConstantPool) {
+		return "", fmt.Errorf("constant pool index %d out of range", index)
+	}
+	utf8, ok := f.ConstantPool[index-1].(ConstantUtf8Info)
+	if !ok {
+		return "", fmt.Errorf("constant pool entry %d not of type ConstantUtf8Info", index)
+	}
+	return utf8.Text, nil
+}
+
+// className returns the name of the ConstantClassInfo at index.
+func (f *ClassFile) className(index int) (string, error) {
+	if index < 1 || index > len(f.ConstantPool) {
+		return "", fmt.Errorf("constant pool index %d out of range", index)
+	}
+	class, ok := f.ConstantPool[index-1].(ConstantClassInfo)
+	if !ok {
+		return "", fmt.Errorf("constant pool entry %d not of type ConstantClassInfo", index)
+	}
+	return f.utf8Text(class.NameIndex)
+}
+
+// attributeName returns the name of attribute, or "" if it's a predefined attribute that isn't recognized in the
+// version of f and has to be ignored.
+func (f *ClassFile) attributeName(attribute AttributeInfo) (string, error) {
+	name, err := f.utf8Text(attribute.AttributeNameIndex)
+	if err != nil {
+		return "", err
+	}
+	if _, predefined := attributeSince[name]; predefined && !isAttributeRecognized(name, f.MajorVersion) {
+		return "", nil
+	}
+	return name, nil
+}
+
+// decodeAttributes decodes the attributes the parser knows about into their typed representation.
+func (f *ClassFile) decodeAttributes() error {
+	for i, attribute := range f.Attributes {
+		name, err := f.attributeName(attribute)
+		if err != nil {
+			return atPath(fmt.Sprintf("attributes[%d].attribute_name_index", i), err)
+		}
+		if err := f.decodeClassFileAttribute(name, attribute); err != nil {
+			return atPath("attributes["+name+"]", err)
+		}
+	}
+
+	for i := range f.Fields {
+		if err := f.decodeField(&f.Fields[i]); err != nil {
+			return atPath(fmt.Sprintf("fields[%d]", i), err)
+		}
+	}
+
+	for i := range f.Methods {
+		if err := f.decodeMethod(&f.Methods[i]); err != nil {
+			return atPath(fmt.Sprintf("methods[%d]", i), err)
+		}
+	}
+	return nil
+}
+
+// decodeClassFileAttribute decodes the class attribute called name.
+func (f *ClassFile) decodeClassFileAttribute(name string, attribute AttributeInfo) error {
+	switch name {
+	case "Signature":
+		f.Signature, f.SignatureError = decodeSignature(f, attribute, ParseClassSignature)
+		return nil
+	case "Module", "ModulePackages", "ModuleMainClass":
+		return f.decodeModuleAttribute(name, attribute)
+	case "InnerClasses", "EnclosingMethod", "NestHost", "NestMembers", "PermittedSubclasses", "Record":
+		return f.decodeClassAttribute(name, attribute)
+	}
+	_, err := f.decodeAnnotations(name, attribute, &f.Annotations)
+	return err
+}
+
+// decodeField resolves the name and descriptor of field and decodes its attributes.
+func (f *ClassFile) decodeField(field *FieldInfo) (err error) {
+	field.Name, err = f.utf8Text(field.NameIndex)
+	if err != nil {
+		return atPath("name_index", err)
+	}
+	field.Descriptor, err = f.utf8Text(field.DescriptorIndex)
+	if err != nil {
+		return atPath("descriptor_index", err)
+	}
+
+	for i, attribute := range field.Attributes {
+		var name string
+		name, err = f.attributeName(attribute)
+		if err != nil {
+			return atPath(fmt.Sprintf("attributes[%d].attribute_name_index", i), err)
+		}
+
+		switch name {
+		case "Signature":
+			field.Signature, field.SignatureError = decodeSignature(f, attribute, ParseFieldSignature)
+		case "ConstantValue":
+			field.ConstantValue, err = f.constantValue(attribute)
+		default:
+			_, err = f.decodeAnnotations(name, attribute, &field.Annotations)
+		}
+		if err != nil {
+			return atPath("attributes["+name+"]", err)
+		}
+	}
+
+	return
+}
+
+// decodeMethod decodes the attributes of method.
+func (f *ClassFile) decodeMethod(method *MethodInfo) (err error) {
+	for i, attribute := range method.Attributes {
+		var name string
+		name, err = f.attributeName(attribute)
+		if err != nil {
+			return atPath(fmt.Sprintf("attributes[%d].attribute_name_index", i), err)
+		}
+
+		switch name {
+		case "Code":
+			method.Code, err = f.decodeCode(attribute)
+		case "Signature":
+			method.Signature, method.SignatureError = decodeSignature(f, attribute, ParseMethodSignature)
+		case "RuntimeVisibleParameterAnnotations":
+			method.VisibleParameterAnnotations, err = f.decodeParameterAnnotations(name, attribute)
+		case "RuntimeInvisibleParameterAnnotations":
+			method.InvisibleParameterAnnotations, err = f.decodeParameterAnnotations(name, attribute)
+		case "AnnotationDefault":
+			method.AnnotationDefault, err = f.decodeAnnotationDefault(attribute)
+		default:
+			_, err = f.decodeAnnotations(name, attribute, &method.Annotations)
+		}
+		if err != nil {
+			return atPath("attributes["+name+"]", err)
+		}
+	}
+
+	return
+}
+
+// decodeCode reads a Code attribute and decodes its attributes.
+func (f *ClassFile) decodeCode(attribute AttributeInfo) (*CodeAttribute, error) {
+	reader := (*ClassFileReader)(bytes.NewReader(attribute.Info))
+	code, err := reader.ReadCodeAttribute()
+	if err != nil {
+		return nil, err
+	}
+	if reader.Len() != 0 {
+		return nil, errors.New("couldn't fully read the Code attribute")
+	}
+	err = f.decodeCodeAttributes(&code)
+	if err != nil {
+		return nil, err
+	}
+	return &code, nil
+}
+
+// constantValue returns the constant pool entry a ConstantValue attribute points to.
+func (f *ClassFile) constantValue(attribute AttributeInfo) (CpInfo, error) {
+	reader := (*ClassFileReader)(bytes.NewReader(attribute.Info))
+	index, err := reader.ReadU2()
+	if err != nil {
+		return nil, err
+	}
+	if reader.Len() != 0 {
+		return nil, errors.New("couldn't fully read the ConstantValue attribute")
+	}
+	if index < 1 || index > len(f.ConstantPool) {
+		return nil, fmt.Errorf("constant pool index %d out of range", index)
+	}
+
+	switch value := f.ConstantPool[index-1].(type) {
+	case ConstantIntegerInfo, ConstantLongInfo, ConstantFloatInfo, ConstantDoubleInfo, ConstantStringInfo:
+		return value, nil
+	}
+	return nil, fmt.Errorf("constant pool entry %d can't be a ConstantValue", index)
+}
+
+// decodeSignature parses the signature a Signature attribute points to with parseSignature. The JVM doesn't check
+// signatures when it loads a class (JVMS §4.7.9.1), so callers keep the error next to the raw attribute instead of
+// rejecting the class.
+func decodeSignature[S any](f *ClassFile, attribute AttributeInfo, parseSignature func(string) (S, error)) (S, error) {
+	var zero S
+	signature, err := f.signatureText(attribute)
+	if err != nil {
+		return zero, err
+	}
+	decoded, err := parseSignature(signature)
+	if err != nil {
+		return zero, err
+	}
+	return decoded, nil
+}
+
+// signatureText returns the signature string a Signature attribute points to.
+func (f *ClassFile) signatureText(attribute AttributeInfo) (string, error) {
+	reader := (*ClassFileReader)(bytes.NewReader(attribute.Info))
+	index, err := reader.ReadU2()
+	if err != nil {
+		return "", err
+	}
+	if reader.Len() != 0 {
+		return "", errors.New("couldn't fully read the Signature attribute")
+	}
+	return f.utf8Text(index)
+}
+
 // Parse a file and return a ClassFile.
 func Parse(filename string) (cF ClassFile, err error) {
 	file, err := os.Open(filename)
@@ -184,90 +494,135 @@ func Parse(filename string) (cF ClassFile, err error) {
 			panic(err)
 		}
 	}()
+
 	content, err := io.ReadAll(file)
 	if err != nil {
 		return
 	}
-	reader := (*ClassFileReader)(bytes.NewReader(content))
 
-	cF.Magic, err = reader.ReadU4()
+	return parseBytes(filename, content)
+}
+
+// ParseReader reads a class file from r until EOF and returns a ClassFile.
+func ParseReader(r io.Reader) (cF ClassFile, err error) {
+	content, err := io.ReadAll(r)
 	if err != nil {
 		return
 	}
-	if cF.Magic != 0xCAFEBABE {
-		err = errors.New("incorrect magic")
+
+	return ParseBytes(content)
+}
+
+// ParseBytes parses the content of a class file and returns a ClassFile.
+//
+// Malformed class files are reported as *FormatError.
+func ParseBytes(content []byte) (cF ClassFile, err error) {
+	return parseBytes("", content)
+}
+
+// parseBytes parses the content of the class file called file.
+func parseBytes(file string, content []byte) (cF ClassFile, err error) {
+	reader := (*ClassFileReader)(bytes.NewReader(content))
+
+	err = cF.read(reader)
+	if err != nil {
+		// everything up to the failing read was fine, so the problem is where the reader stopped
+		err = locate(file, err, func(string) int { return reader.position() })
 		return
 	}
 
-	cF.MinorVersion, err = reader.ReadU2()
+	// resolveIndexes trusts the indexes in the constant pool, so the format has to be checked first
+	o := scanOffsets(content)
+	_, err = Validate(cF)
 	if err != nil {
+		err = o.formatErrors(file, err.(ValidationErrors))
 		return
 	}
 
-	cF.MajorVersion, err = reader.ReadU2()
+	err = cF.resolveIndexes()
+	if err == nil {
+		err = cF.decodeAttributes()
+	}
 	if err != nil {
+		err = locate(file, err, o.lookup)
 		return
 	}
 
-	cF.ConstantPoolCount, cF.ConstantPool, err = reader.ReadConstantPool()
+	return
+}
+
+// read reads the structure of a class file from reader without checking its content.
+func (f *ClassFile) read(reader *ClassFileReader) (err error) {
+	f.Magic, err = reader.ReadU4()
 	if err != nil {
-		return
+		return atPath("magic", err)
+	}
+	if f.Magic != 0xCAFEBABE {
+		return &FormatError{Offset: 0, Path: "magic", Err: errors.New("incorrect magic")}
 	}
 
-	cF.AccessFlags, err = reader.ReadU2()
+	f.MinorVersion, err = reader.ReadU2()
 	if err != nil {
-		return
+		return atPath("minor_version", err)
 	}
 
-	cF.ThisClass, err = reader.ReadU2()
+	f.MajorVersion, err = reader.ReadU2()
 	if err != nil {
-		return
+		return atPath("major_version", err)
 	}
 
-	cF.SuperClass, err = reader.ReadU2()
+	f.ConstantPoolCount, f.ConstantPool, err = reader.ReadConstantPool()
 	if err != nil {
 		return
 	}
 
-	cF.InterfacesCount, err = reader.ReadU2()
+	flags, err := reader.ReadU2()
 	if err != nil {
-		return
+		return atPath("access_flags", err)
+	}
+	f.AccessFlags = ClassAccessFlags(flags)
+
+	f.ThisClass, err = reader.ReadU2()
+	if err != nil {
+		return atPath("this_class", err)
 	}
 
-	/*if cF.InterfacesCount > 0 {
-		return cF, errors.New("interfaces not implemented")
-	}*/
-	for i := 0; i < cF.InterfacesCount; i++ {
+	f.SuperClass, err = reader.ReadU2()
+	if err != nil {
+		return atPath("super_class", err)
+	}
+
+	f.InterfacesCount, err = reader.ReadU2()
+	if err != nil {
+		return atPath("interfaces_count", err)
+	}
+
+	for i := 0; i < f.InterfacesCount; i++ {
 		faceOfTheInterKind, err := reader.ReadU2()
 		if err != nil {
-			return cF, err
+			return atPath(fmt.Sprintf("interfaces[%d]", i), err)
 		}
-		cF.Interfaces = append(cF.Interfaces, faceOfTheInterKind)
+		f.Interfaces = append(f.Interfaces, faceOfTheInterKind)
 	}
 
-	cF.FieldsCount, cF.Fields, err = reader.ReadFields()
+	f.FieldsCount, f.Fields, err = reader.ReadFields()
 	if err != nil {
 		return
 	}
 
-	cF.MethodsCount, cF.Methods, err = reader.ReadMethods()
+	f.MethodsCount, f.Methods, err = reader.ReadMethods()
 	if err != nil {
 		return
 	}
 
-	cF.AttributesCount, cF.Attributes, err = reader.ReadAttributes()
+	f.AttributesCount, f.Attributes, err = reader.ReadAttributes()
 	if err != nil {
 		return
 	}
 
 	// There shouldn't be any data left in the file at this point
 	if reader.Len() != 0 {
-		return cF, errors.New("couldn't fully read the .class file")
-	}
-
-	err = cF.resolveIndexes()
-	if err != nil {
-		return
+		return &FormatError{Offset: reader.position(), Err: errors.New("couldn't fully read the .class file")}
 	}
 
 	return
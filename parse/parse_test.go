@@ -0,0 +1,96 @@
+package parse
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func TestParseReader(t *testing.T) {
+	for _, name := range testClasses(t) {
+		t.Run(filepath.Base(name), func(t *testing.T) {
+			content, err := os.ReadFile(name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want, err := Parse(name)
+			if err != nil {
+				t.Fatalf("Parse failed: %v", err)
+			}
+			fromBytes, err := ParseBytes(content)
+			if err != nil {
+				t.Fatalf("ParseBytes failed: %v", err)
+			}
+			// OneByteReader makes ParseReader read the class file in many small reads
+			fromReader, err := ParseReader(iotest.OneByteReader(bytes.NewReader(content)))
+			if err != nil {
+				t.Fatalf("ParseReader failed: %v", err)
+			}
+			if !reflect.DeepEqual(fromBytes, want) || !reflect.DeepEqual(fromReader, want) {
+				t.Error("ParseBytes or ParseReader returned a different class file than Parse")
+			}
+		})
+	}
+}
+
+func TestParseReaderError(t *testing.T) {
+	readError := errors.New("read failed")
+	_, err := ParseReader(iotest.ErrReader(readError))
+	if !errors.Is(err, readError) {
+		t.Errorf("ParseReader returned %v, want the error of the reader", err)
+	}
+	var formatError *FormatError
+	if errors.As(err, &formatError) {
+		t.Errorf("ParseReader returned the read error as a FormatError: %v", err)
+	}
+}
+
+func TestParseBytesMalformed(t *testing.T) {
+	content, err := os.ReadFile(filepath.Join("testdata", "Hello.class"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		content []byte
+		offset  int
+		reason  string
+	}{
+		{"empty", nil, 0, "couldn't read 4 bytes"},
+		{"magic only", content[:4], 4, "couldn't read 2 bytes"},
+		{"truncated constant pool", content[:20], 20, "constant_pool["},
+		{"last byte missing", content[:len(content)-1], len(content) - 2,
+			"attribute length 2 exceeds the 1 bytes left"},
+		{"trailing byte", append(append([]byte{}, content...), 0), len(content), "couldn't fully read the .class file"},
+		{"trailing class file", append(append([]byte{}, content...), content...), len(content),
+			"couldn't fully read the .class file"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			for _, parse := range []struct {
+				name string
+				f    func([]byte) (ClassFile, error)
+			}{
+				{"ParseBytes", ParseBytes},
+				{"ParseReader", func(b []byte) (ClassFile, error) { return ParseReader(bytes.NewReader(b)) }},
+			} {
+				_, err := parse.f(test.content)
+				var formatError *FormatError
+				if !errors.As(err, &formatError) {
+					t.Fatalf("%s returned %v, want a *FormatError", parse.name, err)
+				}
+				if formatError.Offset != test.offset || !strings.Contains(err.Error(), test.reason) {
+					t.Errorf("%s returned %q at offset %d, want one containing %q at offset %d", parse.name, err,
+						formatError.Offset, test.reason, test.offset)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,414 @@
+package parse
+
+import (
+	"fmt"
+	"strings"
+)
+
+// TypeSignature is one java type in a generic signature (JVMS §4.7.9.1).
+//
+// It is one of *BaseTypeSignature, *ClassTypeSignature, *TypeVariableSignature or *ArrayTypeSignature.
+type TypeSignature interface {
+	// String returns the type in signature form, so parsing it again gives the same type.
+	String() string
+	isTypeSignature()
+}
+
+// BaseTypeSignature is a primitive type or void in the result of a MethodSignature.
+type BaseTypeSignature struct {
+	Descriptor byte // one of B C D F I J S Z or V
+}
+
+func (t *BaseTypeSignature) String() string {
+	return string(t.Descriptor)
+}
+
+func (t *BaseTypeSignature) isTypeSignature() {}
+
+// ClassTypeSignature is a possibly parameterized class type like Ljava/util/Map<TK;TV;>.Entry<TK;TV;>;
+type ClassTypeSignature struct {
+	Package string                     // package in internal form without the trailing slash, e.g. "java/util"
+	Classes []SimpleClassTypeSignature // the outermost class first, followed by its inner classes
+}
+
+func (t *ClassTypeSignature) String() string {
+	var b strings.Builder
+	b.WriteByte('L')
+	if t.Package != "" {
+		b.WriteString(t.Package)
+		b.WriteByte('/')
+	}
+	for i, class := range t.Classes {
+		if i > 0 {
+			b.WriteByte('.')
+		}
+		b.WriteString(class.String())
+	}
+	b.WriteByte(';')
+	return b.String()
+}
+
+func (t *ClassTypeSignature) isTypeSignature() {}
+
+// ClassName returns the binary name in internal form of the class, e.g. "java/util/Map$Entry".
+func (t *ClassTypeSignature) ClassName() string {
+	names := make([]string, 0, len(t.Classes))
+	for _, class := range t.Classes {
+		names = append(names, class.Name)
+	}
+	if t.Package == "" {
+		return strings.Join(names, "$")
+	}
+	return t.Package + "/" + strings.Join(names, "$")
+}
+
+// SimpleClassTypeSignature is one class of a ClassTypeSignature with its type arguments.
+type SimpleClassTypeSignature struct {
+	Name          string
+	TypeArguments []TypeArgument
+}
+
+func (t SimpleClassTypeSignature) String() string {
+	if len(t.TypeArguments) == 0 {
+		return t.Name
+	}
+	args := make([]string, 0, len(t.TypeArguments))
+	for _, arg := range t.TypeArguments {
+		args = append(args, arg.String())
+	}
+	return t.Name + "<" + strings.Join(args, "") + ">"
+}
+
+// TypeArgument is one type argument of a parameterized class type.
+type TypeArgument struct {
+	Wildcard byte          // 0 for an exact type, '+' for "? extends", '-' for "? super" or '*' for "?"
+	Type     TypeSignature // nil for '*'
+}
+
+func (a TypeArgument) String() string {
+	switch a.Wildcard {
+	case '*':
+		return "*"
+	case 0:
+		return a.Type.String()
+	}
+	return string(a.Wildcard) + a.Type.String()
+}
+
+// TypeVariableSignature is the use of a type variable like TT;
+type TypeVariableSignature struct {
+	Name string
+}
+
+func (t *TypeVariableSignature) String() string {
+	return "T" + t.Name + ";"
+}
+
+func (t *TypeVariableSignature) isTypeSignature() {}
+
+// ArrayTypeSignature is an array of Component.
+type ArrayTypeSignature struct {
+	Component TypeSignature
+}
+
+func (t *ArrayTypeSignature) String() string {
+	return "[" + t.Component.String()
+}
+
+func (t *ArrayTypeSignature) isTypeSignature() {}
+
+// TypeParameter is the declaration of a type variable of a generic class or method.
+type TypeParameter struct {
+	Name            string
+	ClassBound      TypeSignature // nil if the class bound is omitted like in <T::Ljava/lang/Comparable<TT;>;>
+	InterfaceBounds []TypeSignature
+}
+
+func (p TypeParameter) String() string {
+	var b strings.Builder
+	b.WriteString(p.Name)
+	b.WriteByte(':')
+	if p.ClassBound != nil {
+		b.WriteString(p.ClassBound.String())
+	}
+	for _, bound := range p.InterfaceBounds {
+		b.WriteByte(':')
+		b.WriteString(bound.String())
+	}
+	return b.String()
+}
+
+func typeParametersString(params []TypeParameter) string {
+	if len(params) == 0 {
+		return ""
+	}
+	s := make([]string, 0, len(params))
+	for _, param := range params {
+		s = append(s, param.String())
+	}
+	return "<" + strings.Join(s, "") + ">"
+}
+
+// ClassSignature is the generic signature of a class.
+type ClassSignature struct {
+	TypeParameters []TypeParameter
+	SuperClass     *ClassTypeSignature
+	Interfaces     []*ClassTypeSignature
+}
+
+func (s *ClassSignature) String() string {
+	var b strings.Builder
+	b.WriteString(typeParametersString(s.TypeParameters))
+	b.WriteString(s.SuperClass.String())
+	for _, face := range s.Interfaces {
+		b.WriteString(face.String())
+	}
+	return b.String()
+}
+
+// MethodSignature is the generic signature of a method.
+type MethodSignature struct {
+	TypeParameters []TypeParameter
+	Parameters     []TypeSignature
+	Result         TypeSignature   // a *BaseTypeSignature with Descriptor 'V' for void
+	Throws         []TypeSignature // *ClassTypeSignature or *TypeVariableSignature
+}
+
+func (s *MethodSignature) String() string {
+	var b strings.Builder
+	b.WriteString(typeParametersString(s.TypeParameters))
+	b.WriteByte('(')
+	for _, param := range s.Parameters {
+		b.WriteString(param.String())
+	}
+	b.WriteByte(')')
+	b.WriteString(s.Result.String())
+	for _, throws := range s.Throws {
+		b.WriteByte('^')
+		b.WriteString(throws.String())
+	}
+	return b.String()
+}
+
+// ParseClassSignature parses the content of a Signature attribute of a class.
+func ParseClassSignature(signature string) (*ClassSignature, error) {
+	p := signatureParser{s: signature}
+	res := new(ClassSignature)
+
+	res.TypeParameters = p.typeParameters()
+	res.SuperClass = p.classType()
+	for p.err == nil && p.i < len(p.s) {
+		res.Interfaces = append(res.Interfaces, p.classType())
+	}
+
+	return res, p.finish()
+}
+
+// ParseMethodSignature parses the content of a Signature attribute of a method.
+func ParseMethodSignature(signature string) (*MethodSignature, error) {
+	p := signatureParser{s: signature}
+	res := new(MethodSignature)
+
+	res.TypeParameters = p.typeParameters()
+	p.expect('(')
+	for p.err == nil && p.peek() != ')' {
+		res.Parameters = append(res.Parameters, p.javaType())
+	}
+	p.expect(')')
+	if p.peek() == 'V' {
+		p.i++
+		res.Result = &BaseTypeSignature{Descriptor: 'V'}
+	} else {
+		res.Result = p.javaType()
+	}
+	for p.err == nil && p.peek() == '^' {
+		p.i++
+		if p.peek() == 'T' {
+			res.Throws = append(res.Throws, p.typeVariable())
+		} else {
+			res.Throws = append(res.Throws, p.classType())
+		}
+	}
+
+	return res, p.finish()
+}
+
+// ParseFieldSignature parses the content of a Signature attribute of a field or record component.
+func ParseFieldSignature(signature string) (TypeSignature, error) {
+	p := signatureParser{s: signature}
+	res := p.referenceType()
+	return res, p.finish()
+}
+
+// signatureParser is a recursive descent parser for the grammar of JVMS §4.7.9.1.
+//
+// After the first error all methods return zero values and err keeps the first error.
+type signatureParser struct {
+	s   string
+	i   int
+	err error
+}
+
+func (p *signatureParser) fail(format string, args ...interface{}) {
+	if p.err == nil {
+		p.err = fmt.Errorf("invalid signature %q at offset %d: %s", p.s, p.i, fmt.Sprintf(format, args...))
+	}
+}
+
+// peek returns the next character or 0 at the end or after an error.
+func (p *signatureParser) peek() byte {
+	if p.err != nil || p.i >= len(p.s) {
+		return 0
+	}
+	return p.s[p.i]
+}
+
+func (p *signatureParser) expect(c byte) {
+	if p.peek() != c {
+		p.fail("expected %q", c)
+		return
+	}
+	p.i++
+}
+
+func (p *signatureParser) finish() error {
+	if p.err == nil && p.i != len(p.s) {
+		p.fail("unexpected trailing characters")
+	}
+	return p.err
+}
+
+// identifier reads an identifier that ends at one of the characters in end.
+func (p *signatureParser) identifier(end string) string {
+	start := p.i
+	for p.err == nil && p.i < len(p.s) && !strings.ContainsRune(end, rune(p.s[p.i])) {
+		p.i++
+	}
+	if p.i == start {
+		p.fail("expected an identifier")
+		return ""
+	}
+	return p.s[start:p.i]
+}
+
+func (p *signatureParser) typeParameters() []TypeParameter {
+	if p.peek() != '<' {
+		return nil
+	}
+	p.i++
+
+	var params []TypeParameter
+	for p.err == nil && p.peek() != '>' {
+		var param TypeParameter
+		param.Name = p.identifier(":;/.<>[")
+		p.expect(':')
+		if c := p.peek(); c == 'L' || c == 'T' || c == '[' {
+			param.ClassBound = p.referenceType()
+		}
+		for p.err == nil && p.peek() == ':' {
+			p.i++
+			param.InterfaceBounds = append(param.InterfaceBounds, p.referenceType())
+		}
+		params = append(params, param)
+	}
+	p.expect('>')
+	if p.err == nil && len(params) == 0 {
+		p.fail("empty type parameters")
+	}
+
+	return params
+}
+
+// javaType reads a reference type or a primitive type.
+func (p *signatureParser) javaType() TypeSignature {
+	switch c := p.peek(); c {
+	case 'B', 'C', 'D', 'F', 'I', 'J', 'S', 'Z':
+		p.i++
+		return &BaseTypeSignature{Descriptor: c}
+	}
+	return p.referenceType()
+}
+
+func (p *signatureParser) referenceType() TypeSignature {
+	switch p.peek() {
+	case 'L':
+		return p.classType()
+	case 'T':
+		return p.typeVariable()
+	case '[':
+		p.i++
+		return &ArrayTypeSignature{Component: p.javaType()}
+	}
+	p.fail("expected a reference type")
+	return nil
+}
+
+func (p *signatureParser) typeVariable() TypeSignature {
+	p.expect('T')
+	name := p.identifier(";/.<>[:")
+	p.expect(';')
+	return &TypeVariableSignature{Name: name}
+}
+
+func (p *signatureParser) classType() *ClassTypeSignature {
+	p.expect('L')
+	res := new(ClassTypeSignature)
+
+	// the package is everything up to the last '/' in front of the first simple class name
+	start := p.i
+	end := strings.IndexAny(p.s[min(start, len(p.s)):], "<.;")
+	if end == -1 {
+		p.fail("unterminated class type")
+		return res
+	}
+	if slash := strings.LastIndexByte(p.s[start:start+end], '/'); slash != -1 {
+		res.Package = p.s[start : start+slash]
+		p.i = start + slash + 1
+		for _, part := range strings.Split(res.Package, "/") {
+			if part == "" {
+				p.fail("empty package name segment")
+			}
+		}
+	}
+
+	for p.err == nil {
+		class := SimpleClassTypeSignature{Name: p.identifier(";/.<>[:")}
+		if p.peek() == '<' {
+			p.i++
+			for p.err == nil && p.peek() != '>' {
+				class.TypeArguments = append(class.TypeArguments, p.typeArgument())
+			}
+			p.expect('>')
+			if p.err == nil && len(class.TypeArguments) == 0 {
+				p.fail("empty type arguments")
+			}
+		}
+		res.Classes = append(res.Classes, class)
+		if p.peek() != '.' {
+			break
+		}
+		p.i++
+	}
+	p.expect(';')
+
+	return res
+}
+
+func (p *signatureParser) typeArgument() TypeArgument {
+	switch c := p.peek(); c {
+	case '*':
+		p.i++
+		return TypeArgument{Wildcard: '*'}
+	case '+', '-':
+		p.i++
+		return TypeArgument{Wildcard: c, Type: p.referenceType()}
+	}
+	return TypeArgument{Type: p.referenceType()}
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,263 @@
+package parse
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// withSignature returns a class with a Signature attribute on the class and on its method m that point to signature.
+func withSignature(signature string) *ClassBuilder {
+	b := NewClassBuilder("T")
+	index := b.Pool().Utf8(signature)
+	info := []byte{byte(index >> 8), byte(index)}
+	b.Attribute("Signature", info).
+		Method(MethodAccPublic|MethodAccAbstract, "m", "()V").Attribute("Signature", info)
+	return b.Access(ClassAccPublic | ClassAccAbstract)
+}
+
+func TestParseSignatureAttribute(t *testing.T) {
+	tests := []struct {
+		name      string
+		signature string
+		classOK   bool // the class signature is expected to decode
+		methodOK  bool // the method signature is expected to decode
+	}{
+		{"class and method signature", "<T:Ljava/lang/Object;>Ljava/lang/Object;", true, false},
+		{"method signature", "<T:Ljava/lang/Object;>()TT;", false, true},
+		{"malformed", "<T>Ljava/lang/Object;", false, false},
+		{"malformed method signature", "<T:Ljava/lang/Object;>(TT)V", false, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cf, err := withSignature(test.signature).Build()
+			if err != nil {
+				t.Fatalf("Build failed, a Signature attribute must not fail Parse: %v", err)
+			}
+			if (cf.Signature != nil) != test.classOK || (cf.SignatureError == nil) != test.classOK {
+				t.Errorf("class signature %v with error %v, want decoded %v", cf.Signature, cf.SignatureError, test.classOK)
+			} else if test.classOK && cf.Signature.String() != test.signature {
+				t.Errorf("class signature %s, want %s", cf.Signature, test.signature)
+			}
+			for _, method := range cf.Methods {
+				if (method.Signature != nil) != test.methodOK || (method.SignatureError == nil) != test.methodOK {
+					t.Errorf("method signature %v with error %v, want decoded %v", method.Signature, method.SignatureError, test.methodOK)
+				} else if test.methodOK && method.Signature.String() != test.signature {
+					t.Errorf("method signature %s, want %s", method.Signature, test.signature)
+				}
+			}
+			if len(cf.Attributes) == 0 {
+				t.Error("the raw Signature attribute was dropped")
+			}
+		})
+	}
+}
+
+// classType returns the class type with the classes in pkg, the outermost class first.
+func classType(pkg string, classes ...SimpleClassTypeSignature) *ClassTypeSignature {
+	return &ClassTypeSignature{Package: pkg, Classes: classes}
+}
+
+// simpleClass returns the class name with the type arguments args.
+func simpleClass(name string, args ...TypeArgument) SimpleClassTypeSignature {
+	return SimpleClassTypeSignature{Name: name, TypeArguments: args}
+}
+
+// exact returns the type argument t without a wildcard.
+func exact(t TypeSignature) TypeArgument {
+	return TypeArgument{Type: t}
+}
+
+// typeVariable returns the use of the type variable name.
+func typeVariable(name string) *TypeVariableSignature {
+	return &TypeVariableSignature{Name: name}
+}
+
+func TestParseClassSignature(t *testing.T) {
+	object := classType("java/lang", simpleClass("Object"))
+	tests := []struct {
+		name      string
+		signature string
+		want      *ClassSignature
+	}{
+		{"type parameter", "<T:Ljava/lang/Object;>Ljava/lang/Object;", &ClassSignature{
+			TypeParameters: []TypeParameter{{Name: "T", ClassBound: object}},
+			SuperClass:     object,
+		}},
+		{"interface bounds", "<K::Ljava/lang/Comparable<TK;>;V:Ljava/lang/Number;:Ljava/io/Serializable;:Ljava/lang/Cloneable;>" +
+			"Ljava/util/AbstractMap<TK;TV;>;Ljava/util/Map<TK;TV;>;", &ClassSignature{
+			TypeParameters: []TypeParameter{
+				{Name: "K", InterfaceBounds: []TypeSignature{
+					classType("java/lang", simpleClass("Comparable", exact(typeVariable("K")))),
+				}},
+				{Name: "V", ClassBound: classType("java/lang", simpleClass("Number")), InterfaceBounds: []TypeSignature{
+					classType("java/io", simpleClass("Serializable")),
+					classType("java/lang", simpleClass("Cloneable")),
+				}},
+			},
+			SuperClass: classType("java/util", simpleClass("AbstractMap", exact(typeVariable("K")), exact(typeVariable("V")))),
+			Interfaces: []*ClassTypeSignature{
+				classType("java/util", simpleClass("Map", exact(typeVariable("K")), exact(typeVariable("V")))),
+			},
+		}},
+		{"inner class of a parameterized class", "Ljava/lang/Object;Ljava/lang/Iterable<LOuter<TT;>.Inner<TU;>;>;",
+			&ClassSignature{
+				SuperClass: object,
+				Interfaces: []*ClassTypeSignature{classType("java/lang", simpleClass("Iterable",
+					exact(classType("", simpleClass("Outer", exact(typeVariable("T"))),
+						simpleClass("Inner", exact(typeVariable("U")))))))},
+			}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := ParseClassSignature(test.signature)
+			if err != nil {
+				t.Fatalf("ParseClassSignature failed: %v", err)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("got %#v, want %#v", got, test.want)
+			}
+			if got.String() != test.signature {
+				t.Errorf("String returned %s, want %s", got, test.signature)
+			}
+		})
+	}
+}
+
+func TestParseMethodSignature(t *testing.T) {
+	list := func(arg TypeArgument) *ClassTypeSignature {
+		return classType("java/util", simpleClass("List", arg))
+	}
+	tests := []struct {
+		name      string
+		signature string
+		want      *MethodSignature
+	}{
+		{"base types and arrays", "([I[[Ljava/lang/String;J)V", &MethodSignature{
+			Parameters: []TypeSignature{
+				&ArrayTypeSignature{Component: &BaseTypeSignature{Descriptor: 'I'}},
+				&ArrayTypeSignature{Component: &ArrayTypeSignature{Component: classType("java/lang", simpleClass("String"))}},
+				&BaseTypeSignature{Descriptor: 'J'},
+			},
+			Result: &BaseTypeSignature{Descriptor: 'V'},
+		}},
+		{"wildcards", "<T:Ljava/lang/Object;>(Ljava/util/List<+TT;>;Ljava/util/List<-Ljava/lang/Integer;>;Ljava/util/List<*>;)[[TT;",
+			&MethodSignature{
+				TypeParameters: []TypeParameter{{Name: "T", ClassBound: classType("java/lang", simpleClass("Object"))}},
+				Parameters: []TypeSignature{
+					list(TypeArgument{Wildcard: '+', Type: typeVariable("T")}),
+					list(TypeArgument{Wildcard: '-', Type: classType("java/lang", simpleClass("Integer"))}),
+					list(TypeArgument{Wildcard: '*'}),
+				},
+				Result: &ArrayTypeSignature{Component: &ArrayTypeSignature{Component: typeVariable("T")}},
+			}},
+		{"throws", "<X:Ljava/lang/Throwable;>()I^Ljava/io/IOException;^TX;", &MethodSignature{
+			TypeParameters: []TypeParameter{{Name: "X", ClassBound: classType("java/lang", simpleClass("Throwable"))}},
+			Result:         &BaseTypeSignature{Descriptor: 'I'},
+			Throws:         []TypeSignature{classType("java/io", simpleClass("IOException")), typeVariable("X")},
+		}},
+		{"nested inner classes", "(Lp/Outer<TT;>.Inner<TU;>.Deep;)TU;", &MethodSignature{
+			Parameters: []TypeSignature{classType("p", simpleClass("Outer", exact(typeVariable("T"))),
+				simpleClass("Inner", exact(typeVariable("U"))), simpleClass("Deep"))},
+			Result: typeVariable("U"),
+		}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := ParseMethodSignature(test.signature)
+			if err != nil {
+				t.Fatalf("ParseMethodSignature failed: %v", err)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("got %#v, want %#v", got, test.want)
+			}
+			if got.String() != test.signature {
+				t.Errorf("String returned %s, want %s", got, test.signature)
+			}
+		})
+	}
+}
+
+func TestParseFieldSignature(t *testing.T) {
+	tests := []struct {
+		signature string
+		want      TypeSignature
+		className string // ClassName of class types
+	}{
+		{"TT;", typeVariable("T"), ""},
+		{"[TT;", &ArrayTypeSignature{Component: typeVariable("T")}, ""},
+		{"Ljava/util/Map<Ljava/lang/String;[TT;>.Entry<**>;", classType("java/util",
+			simpleClass("Map", exact(classType("java/lang", simpleClass("String"))),
+				exact(&ArrayTypeSignature{Component: typeVariable("T")})),
+			simpleClass("Entry", TypeArgument{Wildcard: '*'}, TypeArgument{Wildcard: '*'})), "java/util/Map$Entry"},
+		{"LOuter.Inner;", classType("", simpleClass("Outer"), simpleClass("Inner")), "Outer$Inner"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.signature, func(t *testing.T) {
+			got, err := ParseFieldSignature(test.signature)
+			if err != nil {
+				t.Fatalf("ParseFieldSignature failed: %v", err)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("got %#v, want %#v", got, test.want)
+			}
+			if got.String() != test.signature {
+				t.Errorf("String returned %s, want %s", got, test.signature)
+			}
+			if class, ok := got.(*ClassTypeSignature); ok && class.ClassName() != test.className {
+				t.Errorf("ClassName returned %s, want %s", class.ClassName(), test.className)
+			}
+		})
+	}
+}
+
+func TestParseSignatureErrors(t *testing.T) {
+	tests := []struct {
+		name      string
+		parse     func(string) error
+		signature string
+		reason    string
+	}{
+		{"class without super class", classSignature, "<T:Ljava/lang/Object;>", `expected 'L'`},
+		{"empty type parameters", classSignature, "<>Ljava/lang/Object;", "empty type parameters"},
+		{"type parameter without bound", classSignature, "<T>Ljava/lang/Object;", `expected ':'`},
+		{"empty type arguments", fieldSignature, "Ljava/util/List<>;", "empty type arguments"},
+		{"empty package name segment", fieldSignature, "Ljava//List;", "empty package name segment"},
+		{"unterminated class type", fieldSignature, "Ljava/lang/Object", "unterminated class type"},
+		{"base type field", fieldSignature, "I", "expected a reference type"},
+		{"trailing characters", fieldSignature, "TT;TU;", "unexpected trailing characters"},
+		{"type variable without semicolon", methodSignature, "(TT)V", `expected ';'`},
+		{"missing result", methodSignature, "(I)", "expected a reference type"},
+		{"base type thrown", methodSignature, "()V^I", `expected 'L'`},
+		{"void parameter", methodSignature, "(V)V", "expected a reference type"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := test.parse(test.signature)
+			if err == nil || !strings.Contains(err.Error(), test.reason) {
+				t.Errorf("got error %v for %q, want one containing %q", err, test.signature, test.reason)
+			}
+		})
+	}
+}
+
+// classSignature, methodSignature and fieldSignature return the error of parsing s.
+func classSignature(s string) error {
+	_, err := ParseClassSignature(s)
+	return err
+}
+
+func methodSignature(s string) error {
+	_, err := ParseMethodSignature(s)
+	return err
+}
+
+func fieldSignature(s string) error {
+	_, err := ParseFieldSignature(s)
+	return err
+}
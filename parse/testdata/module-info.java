@@ -0,0 +1,15 @@
+// Source of module-info.class, compiled with --module-version 1.0 and packaged with --main-class com.example.app.Main.
+module com.example.app {
+    requires transitive java.logging;
+    requires static java.compiler;
+
+    exports com.example.api;
+    exports com.example.spi to com.example.plugins, com.example.tests;
+
+    opens com.example.model;
+    opens com.example.internal to com.example.tests;
+
+    uses com.example.spi.Plugin;
+
+    provides com.example.spi.Plugin with com.example.impl.DefaultPlugin, com.example.impl.FastPlugin;
+}
@@ -0,0 +1,222 @@
+package tree
+
+import "github.com/PPTide/gojdk/parse"
+
+// MethodNode is a method of a ClassNode, it is a parse.MethodVisitor that collects the visited code.
+type MethodNode struct {
+	AccessFlags parse.MethodAccessFlags
+	Name        string
+	Descriptor  string
+	Exceptions  []string
+	Attributes  []Attribute // other than "Code" and "Exceptions"
+
+	// Instructions is the code of the method, nil for abstract and native methods. Besides instructions it holds
+	// the LabelNodes they refer to and LineNumberNodes.
+	Instructions   []Node
+	TryCatchBlocks []TryCatchBlock
+	LocalVariables []LocalVariable
+	// LocalVariableTypes are the entries of the "LocalVariableTypeTable", their Descriptor is the generic signature.
+	LocalVariableTypes []LocalVariable
+	// CodeAttributes are the other attributes of the code except "StackMapTable", see
+	// parse.MethodVisitor.VisitCodeAttribute.
+	CodeAttributes []Attribute
+	// MaxStack and MaxLocals are the limits the code was read with, they are computed again when it is written.
+	MaxStack, MaxLocals int
+
+	labels map[*parse.Label]*LabelNode // of the visited code
+}
+
+// Index returns the index of node in the instructions, or -1 if it isn't one of them.
+func (m *MethodNode) Index(node Node) int {
+	for i, n := range m.Instructions {
+		if n == node {
+			return i
+		}
+	}
+	return -1
+}
+
+// Insert inserts nodes before the instruction at i, or at the end if i is len(m.Instructions).
+func (m *MethodNode) Insert(i int, nodes ...Node) {
+	instructions := make([]Node, 0, len(m.Instructions)+len(nodes))
+	instructions = append(instructions, m.Instructions[:i]...)
+	instructions = append(instructions, nodes...)
+	m.Instructions = append(instructions, m.Instructions[i:]...)
+}
+
+// Remove removes the instructions from i to j, excluding j. Removed labels must not be referred to anymore.
+func (m *MethodNode) Remove(i, j int) {
+	m.Instructions = append(m.Instructions[:i], m.Instructions[j:]...)
+}
+
+// label returns the node of a visited label.
+func (m *MethodNode) label(l *parse.Label) *LabelNode {
+	if m.labels[l] == nil {
+		m.labels[l] = NewLabel()
+	}
+	return m.labels[l]
+}
+
+func (m *MethodNode) labelList(labels []*parse.Label) []*LabelNode {
+	nodes := make([]*LabelNode, len(labels))
+	for i, l := range labels {
+		nodes[i] = m.label(l)
+	}
+	return nodes
+}
+
+func (m *MethodNode) add(node Node) {
+	m.Instructions = append(m.Instructions, node)
+}
+
+func (m *MethodNode) VisitAttribute(name string, info []byte) {
+	m.Attributes = append(m.Attributes, Attribute{name, info})
+}
+
+func (m *MethodNode) VisitCode() {
+	m.Instructions = []Node{}
+	m.labels = map[*parse.Label]*LabelNode{}
+}
+
+func (m *MethodNode) VisitTryCatchBlock(start, end, handler *parse.Label, class string) {
+	m.TryCatchBlocks = append(m.TryCatchBlocks, TryCatchBlock{m.label(start), m.label(end), m.label(handler), class})
+}
+
+func (m *MethodNode) VisitLabel(label *parse.Label) {
+	m.add(m.label(label))
+}
+
+func (m *MethodNode) VisitLineNumber(line int, start *parse.Label) {
+	m.add(&LineNumberNode{Line: line, Start: m.label(start)})
+}
+
+func (m *MethodNode) VisitInsn(opcode byte) {
+	m.add(&InsnNode{Opcode: opcode})
+}
+
+func (m *MethodNode) VisitIntInsn(opcode byte, operand int) {
+	m.add(&IntInsnNode{Opcode: opcode, Operand: operand})
+}
+
+func (m *MethodNode) VisitVarInsn(opcode byte, index int) {
+	m.add(&VarInsnNode{Opcode: opcode, Index: index})
+}
+
+func (m *MethodNode) VisitTypeInsn(opcode byte, class string) {
+	m.add(&TypeInsnNode{Opcode: opcode, Class: class})
+}
+
+func (m *MethodNode) VisitFieldInsn(opcode byte, class, name, descriptor string) {
+	m.add(&FieldInsnNode{Opcode: opcode, Owner: class, Name: name, Descriptor: descriptor})
+}
+
+func (m *MethodNode) VisitMethodInsn(opcode byte, class, name, descriptor string) {
+	m.add(&MethodInsnNode{Opcode: opcode, Owner: class, Name: name, Descriptor: descriptor})
+}
+
+func (m *MethodNode) VisitInvokeDynamicInsn(bootstrapMethod int, name, descriptor string) {
+	m.add(&InvokeDynamicInsnNode{BootstrapMethod: bootstrapMethod, Name: name, Descriptor: descriptor})
+}
+
+func (m *MethodNode) VisitJumpInsn(opcode byte, target *parse.Label) {
+	m.add(&JumpInsnNode{Opcode: opcode, Target: m.label(target)})
+}
+
+func (m *MethodNode) VisitLdcInsn(constant parse.CpInfo) {
+	m.add(&LdcInsnNode{Constant: constant})
+}
+
+func (m *MethodNode) VisitIincInsn(index int, increment int) {
+	m.add(&IincInsnNode{Index: index, Increment: increment})
+}
+
+func (m *MethodNode) VisitTableSwitchInsn(low int32, defaultTarget *parse.Label, targets []*parse.Label) {
+	m.add(&TableSwitchInsnNode{Low: low, Default: m.label(defaultTarget), Targets: m.labelList(targets)})
+}
+
+func (m *MethodNode) VisitLookupSwitchInsn(defaultTarget *parse.Label, keys []int32, targets []*parse.Label) {
+	m.add(&LookupSwitchInsnNode{Default: m.label(defaultTarget), Keys: keys, Targets: m.labelList(targets)})
+}
+
+func (m *MethodNode) VisitMultiANewArrayInsn(descriptor string, dimensions int) {
+	m.add(&MultiANewArrayInsnNode{Descriptor: descriptor, Dimensions: dimensions})
+}
+
+func (m *MethodNode) VisitLocalVariable(name, descriptor string, start, end *parse.Label, index int) {
+	m.LocalVariables = append(m.LocalVariables, LocalVariable{name, descriptor, m.label(start), m.label(end), index})
+}
+
+func (m *MethodNode) VisitLocalVariableType(name, signature string, start, end *parse.Label, index int) {
+	m.LocalVariableTypes = append(m.LocalVariableTypes, LocalVariable{name, signature, m.label(start), m.label(end), index})
+}
+
+func (m *MethodNode) VisitCodeAttribute(name string, info []byte) {
+	m.CodeAttributes = append(m.CodeAttributes, Attribute{name, info})
+}
+
+func (m *MethodNode) VisitMaxs(maxStack, maxLocals int) {
+	m.MaxStack, m.MaxLocals = maxStack, maxLocals
+}
+
+func (m *MethodNode) VisitEnd() {
+	m.labels = nil
+}
+
+// Accept visits the method's attributes and code with mv. The labels are visited as new parse.Labels, so a method
+// can be visited more than once.
+func (m *MethodNode) Accept(mv parse.MethodVisitor) {
+	for _, a := range m.Attributes {
+		mv.VisitAttribute(a.Name, a.Info)
+	}
+	if m.Instructions != nil {
+		m.resetLabels()
+		mv.VisitCode()
+		for _, t := range m.TryCatchBlocks {
+			mv.VisitTryCatchBlock(t.Start.Label(), t.End.Label(), t.Handler.Label(), t.Type)
+		}
+		for _, node := range m.Instructions {
+			node.Accept(mv)
+		}
+		for _, v := range m.LocalVariables {
+			mv.VisitLocalVariable(v.Name, v.Descriptor, v.Start.Label(), v.End.Label(), v.Index)
+		}
+		for _, v := range m.LocalVariableTypes {
+			mv.VisitLocalVariableType(v.Name, v.Descriptor, v.Start.Label(), v.End.Label(), v.Index)
+		}
+		for _, a := range m.CodeAttributes {
+			mv.VisitCodeAttribute(a.Name, a.Info)
+		}
+		mv.VisitMaxs(m.MaxStack, m.MaxLocals)
+	}
+	mv.VisitEnd()
+}
+
+// resetLabels drops the parse.Labels of the last visit from all labels the code refers to.
+func (m *MethodNode) resetLabels() {
+	var labels []*LabelNode
+	for _, node := range m.Instructions {
+		switch n := node.(type) {
+		case *LabelNode:
+			labels = append(labels, n)
+		case *LineNumberNode:
+			labels = append(labels, n.Start)
+		case *JumpInsnNode:
+			labels = append(labels, n.Target)
+		case *TableSwitchInsnNode:
+			labels = append(append(labels, n.Default), n.Targets...)
+		case *LookupSwitchInsnNode:
+			labels = append(append(labels, n.Default), n.Targets...)
+		}
+	}
+	for _, t := range m.TryCatchBlocks {
+		labels = append(labels, t.Start, t.End, t.Handler)
+	}
+	for _, table := range [][]LocalVariable{m.LocalVariables, m.LocalVariableTypes} {
+		for _, v := range table {
+			labels = append(labels, v.Start, v.End)
+		}
+	}
+	for _, l := range labels {
+		l.label = nil
+	}
+}
@@ -0,0 +1,189 @@
+package tree
+
+import "github.com/PPTide/gojdk/parse"
+
+// Node is an entry of MethodNode.Instructions. Instructions use the opcodes of the general forms the
+// parse.MethodVisitor methods take, e.g. iload with the index 1 instead of iload_1 and goto instead of goto_w.
+type Node interface {
+	// Accept visits the node with mv.
+	Accept(mv parse.MethodVisitor)
+}
+
+// LabelNode marks the position of the following instruction for branches, exception handlers, line numbers and
+// local variables.
+type LabelNode struct {
+	label *parse.Label // while the method is visited
+}
+
+// NewLabel returns a label to insert into the instructions.
+func NewLabel() *LabelNode {
+	return &LabelNode{}
+}
+
+// Label returns the parse.Label the node is visited as.
+func (n *LabelNode) Label() *parse.Label {
+	if n.label == nil {
+		n.label = parse.NewLabel()
+	}
+	return n.label
+}
+
+func (n *LabelNode) Accept(mv parse.MethodVisitor) {
+	mv.VisitLabel(n.Label())
+}
+
+// LineNumberNode sets the source line of the code starting at Start.
+type LineNumberNode struct {
+	Line  int
+	Start *LabelNode
+}
+
+func (n *LineNumberNode) Accept(mv parse.MethodVisitor) {
+	mv.VisitLineNumber(n.Line, n.Start.Label())
+}
+
+// InsnNode is an instruction without operands, like iadd.
+type InsnNode struct {
+	Opcode byte
+}
+
+func (n *InsnNode) Accept(mv parse.MethodVisitor) {
+	mv.VisitInsn(n.Opcode)
+}
+
+// IntInsnNode is bipush, sipush or newarray, whose operand is the atype.
+type IntInsnNode struct {
+	Opcode  byte
+	Operand int
+}
+
+func (n *IntInsnNode) Accept(mv parse.MethodVisitor) {
+	mv.VisitIntInsn(n.Opcode, n.Operand)
+}
+
+// VarInsnNode is a load, store or ret of the local variable at Index.
+type VarInsnNode struct {
+	Opcode byte
+	Index  int
+}
+
+func (n *VarInsnNode) Accept(mv parse.MethodVisitor) {
+	mv.VisitVarInsn(n.Opcode, n.Index)
+}
+
+// TypeInsnNode is new, anewarray, checkcast or instanceof.
+type TypeInsnNode struct {
+	Opcode byte
+	Class  string
+}
+
+func (n *TypeInsnNode) Accept(mv parse.MethodVisitor) {
+	mv.VisitTypeInsn(n.Opcode, n.Class)
+}
+
+// FieldInsnNode is getstatic, putstatic, getfield or putfield.
+type FieldInsnNode struct {
+	Opcode     byte
+	Owner      string
+	Name       string
+	Descriptor string
+}
+
+func (n *FieldInsnNode) Accept(mv parse.MethodVisitor) {
+	mv.VisitFieldInsn(n.Opcode, n.Owner, n.Name, n.Descriptor)
+}
+
+// MethodInsnNode is invokevirtual, invokespecial, invokestatic or invokeinterface.
+type MethodInsnNode struct {
+	Opcode     byte
+	Owner      string
+	Name       string
+	Descriptor string
+}
+
+func (n *MethodInsnNode) Accept(mv parse.MethodVisitor) {
+	mv.VisitMethodInsn(n.Opcode, n.Owner, n.Name, n.Descriptor)
+}
+
+// InvokeDynamicInsnNode is invokedynamic with the index of its bootstrap method in the "BootstrapMethods"
+// attribute.
+type InvokeDynamicInsnNode struct {
+	BootstrapMethod int
+	Name            string
+	Descriptor      string
+}
+
+func (n *InvokeDynamicInsnNode) Accept(mv parse.MethodVisitor) {
+	mv.VisitInvokeDynamicInsn(n.BootstrapMethod, n.Name, n.Descriptor)
+}
+
+// JumpInsnNode is a conditional branch, goto or jsr. Branches that are too far for a 16 bit offset are widened
+// when the class is written.
+type JumpInsnNode struct {
+	Opcode byte
+	Target *LabelNode
+}
+
+func (n *JumpInsnNode) Accept(mv parse.MethodVisitor) {
+	mv.VisitJumpInsn(n.Opcode, n.Target.Label())
+}
+
+// LdcInsnNode is ldc, ldc_w or ldc2_w, the form is chosen when the class is written. Indexes in Constant refer to
+// the constant pool of the class that was read.
+type LdcInsnNode struct {
+	Constant parse.CpInfo
+}
+
+func (n *LdcInsnNode) Accept(mv parse.MethodVisitor) {
+	mv.VisitLdcInsn(n.Constant)
+}
+
+// IincInsnNode is iinc.
+type IincInsnNode struct {
+	Index     int
+	Increment int
+}
+
+func (n *IincInsnNode) Accept(mv parse.MethodVisitor) {
+	mv.VisitIincInsn(n.Index, n.Increment)
+}
+
+// TableSwitchInsnNode is tableswitch, Targets are the labels of the keys from Low on.
+type TableSwitchInsnNode struct {
+	Low     int32
+	Default *LabelNode
+	Targets []*LabelNode
+}
+
+func (n *TableSwitchInsnNode) Accept(mv parse.MethodVisitor) {
+	mv.VisitTableSwitchInsn(n.Low, n.Default.Label(), labels(n.Targets))
+}
+
+// LookupSwitchInsnNode is lookupswitch, Keys have to be sorted.
+type LookupSwitchInsnNode struct {
+	Default *LabelNode
+	Keys    []int32
+	Targets []*LabelNode
+}
+
+func (n *LookupSwitchInsnNode) Accept(mv parse.MethodVisitor) {
+	mv.VisitLookupSwitchInsn(n.Default.Label(), n.Keys, labels(n.Targets))
+}
+
+// MultiANewArrayInsnNode is multianewarray.
+type MultiANewArrayInsnNode struct {
+	Descriptor string
+	Dimensions int
+}
+
+func (n *MultiANewArrayInsnNode) Accept(mv parse.MethodVisitor) {
+	mv.VisitMultiANewArrayInsn(n.Descriptor, n.Dimensions)
+}
+
+func labels(nodes []*LabelNode) []*parse.Label {
+	res := make([]*parse.Label, len(nodes))
+	for i, n := range nodes {
+		res[i] = n.Label()
+	}
+	return res
+}
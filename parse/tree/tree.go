@@ -0,0 +1,212 @@
+// Package tree holds classes as trees that can be edited in place: the code of a method is a list of instruction
+// nodes that refer to LabelNodes instead of offsets.
+//
+//	class, err := tree.Read(content)
+//	...
+//	m := class.Method("main", "([Ljava/lang/String;)V")
+//	m.Insert(0, &tree.VarInsnNode{Opcode: 0x15, Index: 0}, &tree.InsnNode{Opcode: 0x57}) // iload 0, pop
+//	content, err = class.Bytes()
+//
+// When the class is written again the code is assembled by a parse.ClassWriter: the offsets of branches, switches,
+// exception handlers, line numbers and local variables are computed from the labels, branches that became too far
+// are widened to goto_w, max_stack and max_locals are computed and the StackMapTables are regenerated by
+// ClassNode.Verifier. Set it to a verify.Verifier that can load the classes the code uses, otherwise two different
+// class types merge to java/lang/Object:
+//
+//	class.Verifier = &verify.Verifier{Load: load}
+package tree
+
+import (
+	"errors"
+
+	"github.com/PPTide/gojdk/parse"
+	"github.com/PPTide/gojdk/parse/verify"
+)
+
+// ClassNode is a class, it is a parse.ClassVisitor that collects the visited class.
+type ClassNode struct {
+	MajorVersion, MinorVersion int
+	AccessFlags                parse.ClassAccessFlags
+	Name                       string
+	SuperName                  string // "" for java/lang/Object and module-info
+	Interfaces                 []string
+	SourceFile                 string // "" if the class has no SourceFile attribute
+	Fields                     []*FieldNode
+	Methods                    []*MethodNode
+	Attributes                 []Attribute
+
+	// Verifier computes the StackMapTables when the class is written, it merges two class types to their closest
+	// common super class. If it's nil, other classes aren't loaded and the merged type is java/lang/Object, so
+	// instructions that need the super class fail to verify.
+	Verifier *verify.Verifier
+
+	reader *parse.ClassReader // that the class was read with, raw attributes refer to its constant pool
+}
+
+// Attribute is an attribute with its raw content.
+type Attribute struct {
+	Name string
+	Info []byte
+}
+
+// FieldNode is a field of a ClassNode.
+type FieldNode struct {
+	AccessFlags parse.FieldAccessFlags
+	Name        string
+	Descriptor  string
+	Attributes  []Attribute // including "ConstantValue"
+}
+
+// TryCatchBlock is an exception handler for the code from Start to End, Type is "" if it catches everything.
+type TryCatchBlock struct {
+	Start, End, Handler *LabelNode
+	Type                string
+}
+
+// LocalVariable is an entry of the "LocalVariableTable" attribute.
+type LocalVariable struct {
+	Name       string
+	Descriptor string
+	Start, End *LabelNode
+	Index      int
+}
+
+// Read returns the class in content.
+func Read(content []byte) (*ClassNode, error) {
+	reader, err := parse.NewClassReader(content)
+	if err != nil {
+		return nil, err
+	}
+	class := &ClassNode{reader: reader}
+	err = reader.Accept(class)
+	if err != nil {
+		return nil, err
+	}
+	return class, nil
+}
+
+// Method returns the method called name with descriptor, or nil if the class has none.
+func (n *ClassNode) Method(name, descriptor string) *MethodNode {
+	for _, m := range n.Methods {
+		if m.Name == name && m.Descriptor == descriptor {
+			return m
+		}
+	}
+	return nil
+}
+
+// Field returns the field called name, or nil if the class has none.
+func (n *ClassNode) Field(name string) *FieldNode {
+	for _, f := range n.Fields {
+		if f.Name == name {
+			return f
+		}
+	}
+	return nil
+}
+
+func (n *ClassNode) VisitClass(majorVersion, minorVersion int, accessFlags parse.ClassAccessFlags, name, superName string, interfaces []string) {
+	n.MajorVersion, n.MinorVersion = majorVersion, minorVersion
+	n.AccessFlags = accessFlags
+	n.Name, n.SuperName = name, superName
+	n.Interfaces = interfaces
+}
+
+func (n *ClassNode) VisitField(accessFlags parse.FieldAccessFlags, name, descriptor string) parse.FieldVisitor {
+	f := &FieldNode{AccessFlags: accessFlags, Name: name, Descriptor: descriptor}
+	n.Fields = append(n.Fields, f)
+	return f
+}
+
+func (n *ClassNode) VisitMethod(accessFlags parse.MethodAccessFlags, name, descriptor string, exceptions []string) parse.MethodVisitor {
+	m := &MethodNode{AccessFlags: accessFlags, Name: name, Descriptor: descriptor, Exceptions: exceptions}
+	n.Methods = append(n.Methods, m)
+	return m
+}
+
+func (n *ClassNode) VisitSource(file string) {
+	n.SourceFile = file
+}
+
+func (n *ClassNode) VisitAttribute(name string, info []byte) {
+	n.Attributes = append(n.Attributes, Attribute{name, info})
+}
+
+func (n *ClassNode) VisitEnd() {}
+
+// Accept visits the class with v.
+func (n *ClassNode) Accept(v parse.ClassVisitor) {
+	v.VisitClass(n.MajorVersion, n.MinorVersion, n.AccessFlags, n.Name, n.SuperName, n.Interfaces)
+	for _, f := range n.Fields {
+		if fv := v.VisitField(f.AccessFlags, f.Name, f.Descriptor); fv != nil {
+			f.Accept(fv)
+		}
+	}
+	for _, m := range n.Methods {
+		if mv := v.VisitMethod(m.AccessFlags, m.Name, m.Descriptor, m.Exceptions); mv != nil {
+			m.Accept(mv)
+		}
+	}
+	if n.SourceFile != "" {
+		v.VisitSource(n.SourceFile)
+	}
+	for _, a := range n.Attributes {
+		v.VisitAttribute(a.Name, a.Info)
+	}
+	v.VisitEnd()
+}
+
+// writer returns a ClassWriter with the class written to it.
+func (n *ClassNode) writer() (*parse.ClassWriter, error) {
+	if n.Name == "" {
+		return nil, errors.New("class has no name")
+	}
+	v := n.Verifier
+	if v == nil {
+		v = new(verify.Verifier)
+	}
+	w := parse.NewClassWriter(n.reader).StackMapTables(v.StackMapTable)
+	n.Accept(w)
+	return w, nil
+}
+
+// ClassFile returns the class as it will be written, see parse.ClassWriter.ClassFile.
+func (n *ClassNode) ClassFile() (parse.ClassFile, error) {
+	w, err := n.writer()
+	if err != nil {
+		return parse.ClassFile{}, err
+	}
+	return w.ClassFile()
+}
+
+// Bytes returns the class in the .class file format.
+func (n *ClassNode) Bytes() ([]byte, error) {
+	w, err := n.writer()
+	if err != nil {
+		return nil, err
+	}
+	return w.Bytes()
+}
+
+// Build returns the class like parse.Parse returns it.
+func (n *ClassNode) Build() (parse.ClassFile, error) {
+	w, err := n.writer()
+	if err != nil {
+		return parse.ClassFile{}, err
+	}
+	return w.Build()
+}
+
+func (f *FieldNode) VisitAttribute(name string, info []byte) {
+	f.Attributes = append(f.Attributes, Attribute{name, info})
+}
+
+func (f *FieldNode) VisitEnd() {}
+
+// Accept visits the attributes of the field with v.
+func (f *FieldNode) Accept(v parse.FieldVisitor) {
+	for _, a := range f.Attributes {
+		v.VisitAttribute(a.Name, a.Info)
+	}
+	v.VisitEnd()
+}
@@ -0,0 +1,241 @@
+package tree
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/PPTide/gojdk/parse"
+	"github.com/PPTide/gojdk/parse/disasm"
+	"github.com/PPTide/gojdk/parse/javap"
+	"github.com/PPTide/gojdk/parse/verify"
+)
+
+// classWith returns a class T of version major with the static method m(I)V whose code is instructions.
+func classWith(major int, instructions ...Node) *ClassNode {
+	return &ClassNode{
+		MajorVersion: major,
+		AccessFlags:  parse.ClassAccPublic | parse.ClassAccSuper,
+		Name:         "T",
+		SuperName:    "java/lang/Object",
+		Methods: []*MethodNode{{
+			AccessFlags:  parse.MethodAccPublic | parse.MethodAccStatic,
+			Name:         "m",
+			Descriptor:   "(I)V",
+			Instructions: instructions,
+		}},
+	}
+}
+
+// mnemonics builds class, verifies it and returns the mnemonics of the code of m without nop and iinc. It checks
+// that all branches target an instruction.
+func mnemonics(t *testing.T, class *ClassNode) []string {
+	t.Helper()
+	cf, err := class.Build()
+	if err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+	if err := verify.Class(cf); err != nil {
+		t.Fatalf("written class doesn't verify: %v", err)
+	}
+	instructions, err := disasm.DecodeMethod(&cf, cf.Methods[0])
+	if err != nil {
+		t.Fatalf("DecodeMethod failed: %v", err)
+	}
+
+	pcs := map[int]bool{}
+	for _, instruction := range instructions {
+		pcs[instruction.Pc] = true
+	}
+	var res []string
+	for _, instruction := range instructions {
+		targets := []int{}
+		switch {
+		case instruction.Switch != nil:
+			targets = append(append(targets, instruction.Switch.Default), instruction.Switch.Targets...)
+		case instruction.Opcode.Format() == disasm.BranchShort || instruction.Opcode.Format() == disasm.BranchInt:
+			targets = append(targets, instruction.Target)
+		}
+		for _, target := range targets {
+			if !pcs[target] {
+				t.Errorf("%s at pc %d jumps to pc %d, which isn't an instruction", instruction, instruction.Pc, target)
+			}
+		}
+		if instruction.Opcode != disasm.Nop && instruction.Opcode != disasm.Iinc {
+			res = append(res, instruction.Mnemonic())
+		}
+	}
+	return res
+}
+
+func TestInsertWidensBranches(t *testing.T) {
+	const n = 11000 // iinc instructions to insert, 33000 bytes don't fit a 16 bit offset
+
+	tests := []struct {
+		name   string
+		major  int
+		code   func(at Node) []Node // code of m, the instructions are inserted before at
+		before []string
+		after  []string
+	}{
+		{"goto", 52, func(at Node) []Node {
+			body, end := NewLabel(), NewLabel()
+			return []Node{&VarInsnNode{Opcode: 0x15, Index: 0}, &JumpInsnNode{Opcode: 0x99, Target: body},
+				&JumpInsnNode{Opcode: 0xa7, Target: end}, body, at, end, &InsnNode{Opcode: 0xb1}}
+		}, []string{"iload_0", "ifeq", "goto", "return"}, []string{"iload_0", "ifeq", "goto_w", "return"}},
+		{"goto backwards", 52, func(at Node) []Node {
+			start := NewLabel()
+			return []Node{start, at, &JumpInsnNode{Opcode: 0xa7, Target: start}}
+		}, []string{"goto"}, []string{"goto_w"}},
+		{"ifeq", 52, func(at Node) []Node {
+			end := NewLabel()
+			return []Node{&VarInsnNode{Opcode: 0x15, Index: 0}, &JumpInsnNode{Opcode: 0x99, Target: end}, at, end,
+				&InsnNode{Opcode: 0xb1}}
+		}, []string{"iload_0", "ifeq", "return"}, []string{"iload_0", "ifne", "goto_w", "return"}},
+		{"jsr", 49, func(at Node) []Node {
+			body, subroutine := NewLabel(), NewLabel()
+			return []Node{&VarInsnNode{Opcode: 0x15, Index: 0}, &JumpInsnNode{Opcode: 0x99, Target: body},
+				&JumpInsnNode{Opcode: 0xa8, Target: subroutine}, &InsnNode{Opcode: 0xb1},
+				body, at, &InsnNode{Opcode: 0xb1},
+				subroutine, &VarInsnNode{Opcode: 0x3a, Index: 1}, &VarInsnNode{Opcode: 0xa9, Index: 1}}
+		}, []string{"iload_0", "ifeq", "jsr", "return", "return", "astore_1", "ret"},
+			[]string{"iload_0", "ifeq", "jsr_w", "return", "return", "astore_1", "ret"}},
+		{"switch padding changes", 52, func(at Node) []Node {
+			skip, one, other := NewLabel(), NewLabel(), NewLabel()
+			return []Node{
+				&VarInsnNode{Opcode: 0x15, Index: 0}, &JumpInsnNode{Opcode: 0x99, Target: skip}, at, skip,
+				&VarInsnNode{Opcode: 0x15, Index: 0},
+				&TableSwitchInsnNode{Low: 1, Default: other, Targets: []*LabelNode{one}},
+				one, &InsnNode{Opcode: 0xb1},
+				other, &InsnNode{Opcode: 0xb1},
+			}
+		}, []string{"iload_0", "ifeq", "iload_0", "tableswitch", "return", "return"},
+			[]string{"iload_0", "ifne", "goto_w", "iload_0", "tableswitch", "return", "return"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			at := &InsnNode{Opcode: 0x00} // nop
+			class := classWith(test.major, test.code(at)...)
+			if got := mnemonics(t, class); !reflect.DeepEqual(got, test.before) {
+				t.Errorf("before inserting got %v, want %v", got, test.before)
+			}
+
+			m := class.Method("m", "(I)V")
+			filler := make([]Node, n)
+			for i := range filler {
+				filler[i] = &IincInsnNode{Index: 0, Increment: 1}
+			}
+			m.Insert(m.Index(at), filler...)
+			if got := mnemonics(t, class); !reflect.DeepEqual(got, test.after) {
+				t.Errorf("after inserting got %v, want %v", got, test.after)
+			}
+		})
+	}
+}
+
+func TestReadWriteRoundTrip(t *testing.T) {
+	names, err := filepath.Glob(filepath.Join("..", "testdata", "*.class"))
+	if err != nil || len(names) == 0 {
+		t.Fatalf("no class files in testdata: %v", err)
+	}
+	for _, name := range names {
+		t.Run(filepath.Base(name), func(t *testing.T) {
+			content, err := os.ReadFile(name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want, err := parse.ParseBytes(content)
+			if err != nil {
+				t.Fatalf("ParseBytes failed: %v", err)
+			}
+			class, err := Read(content)
+			if err != nil {
+				t.Fatalf("Read failed: %v", err)
+			}
+			got, err := class.Build()
+			if err != nil {
+				t.Fatalf("Build failed: %v", err)
+			}
+			if err := verify.Class(got); err != nil {
+				t.Errorf("written class doesn't verify: %v", err)
+			}
+			if len(got.Methods) != len(want.Methods) {
+				t.Fatalf("%d methods, want %d", len(got.Methods), len(want.Methods))
+			}
+			for i, method := range want.Methods {
+				if method.Code != nil && !bytes.Equal(got.Methods[i].Code.Code, method.Code.Code) {
+					t.Errorf("code of method %d differs", i)
+				}
+			}
+		})
+	}
+}
+
+func TestWriteMergesToCommonSuperclass(t *testing.T) {
+	// A and B extend Base, m reads the field of Base from an A or a B depending on its argument
+	classes := map[string]*parse.ClassBuilder{
+		"Base": parse.NewClassBuilder("Base").Version(52, 0).Field(parse.FieldAccPublic, "x", "I"),
+		"A":    parse.NewClassBuilder("A").Version(52, 0).Super("Base"),
+		"B":    parse.NewClassBuilder("B").Version(52, 0).Super("Base"),
+	}
+	loaded := map[string]parse.ClassFile{}
+	for name, b := range classes {
+		cf, err := b.Build()
+		if err != nil {
+			t.Fatalf("building %s failed: %v", name, err)
+		}
+		loaded[name] = cf
+	}
+	verifier := func() *verify.Verifier {
+		return &verify.Verifier{Load: func(name string) (parse.ClassFile, error) {
+			cf, ok := loaded[name]
+			if !ok {
+				return parse.ClassFile{}, os.ErrNotExist
+			}
+			return cf, nil
+		}}
+	}
+
+	content, err := parse.NewClassBuilder("T").Version(52, 0).StackMapTables(verifier().StackMapTable).
+		Method(parse.MethodAccStatic, "m", "(ZLA;LB;)I").Code(func(c *parse.CodeBuilder) {
+		other, join := c.NewLabel(), c.NewLabel()
+		c.ILoad(0)
+		c.IfEq(other)
+		c.ALoad(1)
+		c.Goto(join)
+		c.Mark(other)
+		c.ALoad(2)
+		c.Mark(join)
+		c.GetField("Base", "x", "I")
+		c.IReturn()
+	}).
+		Bytes()
+	if err != nil {
+		t.Fatalf("building T failed: %v", err)
+	}
+
+	class, err := Read(content)
+	if err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+	class.Verifier = verifier()
+	cf, err := class.Build()
+	if err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+	if err := verifier().Class(cf); err != nil {
+		t.Errorf("written class doesn't verify with the classes loaded: %v", err)
+	}
+
+	var out bytes.Buffer
+	if err := javap.Print(&out, &cf); err != nil {
+		t.Fatalf("Print failed: %v", err)
+	}
+	if !strings.Contains(out.String(), "stack = [ class Base ]") {
+		t.Errorf("StackMapTable doesn't merge A and B to Base:\n%s", out.String())
+	}
+}
@@ -1,7 +1,861 @@
 package parse
 
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// ValidationError describes one violation of the class file format found by Validate.
+type ValidationError struct {
+	Path   string // structural path of the violating item, e.g. "methods[3].attributes[Code]"
+	Reason string
+}
+
+func (e ValidationError) Error() string {
+	return e.Path + ": " + e.Reason
+}
+
+// ValidationErrors is the list of all violations found by Validate.
+type ValidationErrors []ValidationError
+
+func (e ValidationErrors) Error() string {
+	msgs := make([]string, 0, len(e))
+	for _, err := range e {
+		msgs = append(msgs, err.Error())
+	}
+	return fmt.Sprintf("%d format errors: %s", len(e), strings.Join(msgs, "; "))
+}
+
+// Unwrap allows errors.As to find the single ValidationError values.
+func (e ValidationErrors) Unwrap() []error {
+	errs := make([]error, 0, len(e))
+	for _, err := range e {
+		errs = append(errs, err)
+	}
+	return errs
+}
+
+// Validate checks cf against the format checking rules of JVMS §4.8.
+//
+// It only relies on indexes and the raw content of the constant pool, so it can be used before resolveIndexes.
+// All violations are returned as ValidationErrors, res is cf if no violations were found.
 func Validate(cf ClassFile) (res ClassFile, err error) {
-	// FIXME: Everything's probably okay :)
+	v := validator{cf: &cf}
+	v.validate()
+
+	if len(v.errs) != 0 {
+		return res, v.errs
+	}
+	return cf, nil
+}
+
+type validator struct {
+	cf       *ClassFile
+	usable   []bool // false for the second slot of CONSTANT_Long_info and CONSTANT_Double_info
+	isModule bool
+	errs     ValidationErrors
+}
+
+func (v *validator) report(path string, format string, args ...interface{}) {
+	v.errs = append(v.errs, ValidationError{Path: path, Reason: fmt.Sprintf(format, args...)})
+}
+
+func (v *validator) validate() {
+	if v.cf.Magic != 0xCAFEBABE {
+		v.report("magic", "incorrect magic 0x%X", v.cf.Magic)
+	}
+
+	major, minor := v.cf.MajorVersion, v.cf.MinorVersion
+	switch {
+	case major < MinMajorVersion || major > MaxMajorVersion:
+		v.report("major_version", "unsupported class file version %d.%d, supported are %d.0 to %d.0 (Java %s)",
+			major, minor, MinMajorVersion, MaxMajorVersion, JavaRelease(MaxMajorVersion))
+	case major >= 56 && minor == previewMinorVersion:
+		v.report("minor_version", "class file depends on the preview features of Java %s, which aren't supported",
+			JavaRelease(major))
+	case major >= 56 && minor != 0:
+		v.report("minor_version", "minor version %d must be 0 or %d in class file version %d", minor, previewMinorVersion, major)
+	}
+
+	v.isModule = v.cf.AccessFlags.IsModule()
+
+	v.usable = make([]bool, len(v.cf.ConstantPool))
+	for i := 0; i < len(v.cf.ConstantPool); i++ {
+		v.usable[i] = true
+		switch v.cf.ConstantPool[i].(type) {
+		case ConstantLongInfo, ConstantDoubleInfo:
+			i++
+		}
+	}
+
+	for i := range v.cf.ConstantPool {
+		if v.usable[i] {
+			v.validateConstant(i + 1)
+		}
+	}
+
+	v.validateClass()
+
+	seen := map[string]bool{}
+	for i, field := range v.cf.Fields {
+		path := fmt.Sprintf("fields[%d]", i)
+		name, descriptor := v.validateField(path, field)
+		if seen[name+":"+descriptor] {
+			v.report(path, "duplicate field %s:%s", name, descriptor)
+		}
+		seen[name+":"+descriptor] = true
+	}
+
+	seen = map[string]bool{}
+	for i, method := range v.cf.Methods {
+		path := fmt.Sprintf("methods[%d]", i)
+		name, descriptor := v.validateMethod(path, method)
+		if seen[name+descriptor] {
+			v.report(path, "duplicate method %s%s", name, descriptor)
+		}
+		seen[name+descriptor] = true
+	}
+
+	v.validateAttributes("attributes", v.cf.Attributes, "class")
+}
 
+// entry returns the constant pool entry at index or reports why it can't be referenced.
+func (v *validator) entry(path string, index int) (CpInfo, bool) {
+	if index < 1 || index > len(v.cf.ConstantPool) {
+		v.report(path, "constant pool index %d out of range 1..%d", index, len(v.cf.ConstantPool))
+		return nil, false
+	}
+	if !v.usable[index-1] {
+		v.report(path, "constant pool index %d points into the second slot of a long or double", index)
+		return nil, false
+	}
+	return v.cf.ConstantPool[index-1], true
+}
+
+// lookupUtf8 returns the text of the ConstantUtf8Info at index without reporting anything, problems with it are
+// reported where the index is validated.
+func (v *validator) lookupUtf8(index int) string {
+	if index < 1 || index > len(v.cf.ConstantPool) || !v.usable[index-1] {
+		return ""
+	}
+	utf8, ok := v.cf.ConstantPool[index-1].(ConstantUtf8Info)
+	if !ok {
+		return ""
+	}
+	if utf8.Content == nil {
+		return utf8.Text
+	}
+	text, _ := DecodeModifiedUTF8(utf8.Content)
+	return text
+}
+
+// utf8 returns the text of the ConstantUtf8Info at index.
+func (v *validator) utf8(path string, index int) (string, bool) {
+	info, ok := v.entry(path, index)
+	if !ok {
+		return "", false
+	}
+	utf8, ok := info.(ConstantUtf8Info)
+	if !ok {
+		v.report(path, "constant pool entry %d is %s instead of ConstantUtf8Info", index, reflect.TypeOf(info).Name())
+		return "", false
+	}
+	if utf8.Content == nil {
+		return utf8.Text, true
+	}
+	text, err := DecodeModifiedUTF8(utf8.Content)
+	if err != nil {
+		// already reported while validating the entry itself
+		return "", false
+	}
+	return text, true
+}
+
+// class returns the name of the ConstantClassInfo at index.
+func (v *validator) class(path string, index int) (string, bool) {
+	info, ok := v.entry(path, index)
+	if !ok {
+		return "", false
+	}
+	class, ok := info.(ConstantClassInfo)
+	if !ok {
+		v.report(path, "constant pool entry %d is %s instead of ConstantClassInfo", index, reflect.TypeOf(info).Name())
+		return "", false
+	}
+	// the name itself is reported while validating the ConstantClassInfo
+	name := v.lookupUtf8(class.NameIndex)
+	return name, name != ""
+}
+
+// nameAndType returns the name and descriptor of the ConstantNameAndTypeInfo at index.
+func (v *validator) nameAndType(path string, index int) (name string, descriptor string, ok bool) {
+	info, ok := v.entry(path, index)
+	if !ok {
+		return
+	}
+	nat, ok := info.(ConstantNameAndTypeInfo)
+	if !ok {
+		v.report(path, "constant pool entry %d is %s instead of ConstantNameAndTypeInfo", index, reflect.TypeOf(info).Name())
+		return
+	}
+	// name and descriptor themselves are reported while validating the ConstantNameAndTypeInfo
+	name, descriptor = v.lookupUtf8(nat.NameIndex), v.lookupUtf8(nat.DescriptorIndex)
+	return name, descriptor, name != "" && descriptor != ""
+}
+
+func (v *validator) validateConstant(index int) {
+	path := fmt.Sprintf("constant_pool[%d]", index)
+
+	if name, since := constantSince(v.cf.ConstantPool[index-1]); v.cf.MajorVersion < since {
+		v.report(path, "%s needs class file version %d.0 (Java %s)", name, since, JavaRelease(since))
+	}
+
+	switch t := v.cf.ConstantPool[index-1].(type) {
+	case ConstantUtf8Info:
+		if t.Content != nil {
+			if _, err := DecodeModifiedUTF8(t.Content); err != nil {
+				v.report(path, "%s", err)
+			}
+		}
+	case ConstantIntegerInfo, ConstantFloatInfo, ConstantLongInfo, ConstantDoubleInfo:
+	case ConstantClassInfo:
+		if name, ok := v.utf8(path+".name_index", t.NameIndex); ok && !validClassName(name) {
+			v.report(path, "invalid class name %q", name)
+		}
+	case ConstantStringInfo:
+		v.utf8(path+".string_index", t.StringIndex)
+	case ConstantFieldrefInfo:
+		v.class(path+".class_index", t.ClassIndex)
+		if name, descriptor, ok := v.nameAndType(path+".name_and_type_index", t.NameAndTypeIndex); ok {
+			if !validUnqualifiedName(name) {
+				v.report(path, "invalid field name %q", name)
+			}
+			if !validFieldDescriptor(descriptor) {
+				v.report(path, "invalid field descriptor %q", descriptor)
+			}
+		}
+	case ConstantMethodrefInfo:
+		v.class(path+".class_index", t.ClassIndex)
+		v.validateMethodRef(path, t.NameAndTypeIndex)
+	case ConstantInterfaceMethodrefInfo:
+		v.class(path+".class_index", t.ClassIndex)
+		if name, _, ok := v.validateMethodRef(path, t.NameAndTypeIndex); ok && name == "<init>" {
+			v.report(path, "interface method reference to <init>")
+		}
+	case ConstantNameAndTypeInfo:
+		name, okName := v.utf8(path+".name_index", t.NameIndex)
+		descriptor, okDescriptor := v.utf8(path+".descriptor_index", t.DescriptorIndex)
+		if okName && !validUnqualifiedName(name) && name != "<init>" && name != "<clinit>" {
+			v.report(path, "invalid name %q", name)
+		}
+		if okDescriptor && !validFieldDescriptor(descriptor) {
+			if _, _, ok := parseMethodDescriptorSlots(descriptor); !ok {
+				v.report(path, "invalid descriptor %q", descriptor)
+			}
+		}
+	case ConstantMethodHandleInfo:
+		v.validateMethodHandle(path, t)
+	case ConstantMethodTypeInfo:
+		if descriptor, ok := v.utf8(path+".descriptor_index", t.DescriptorIndex); ok {
+			if _, _, ok := parseMethodDescriptorSlots(descriptor); !ok {
+				v.report(path, "invalid method descriptor %q", descriptor)
+			}
+		}
+	case ConstantDynamicInfo:
+		if _, descriptor, ok := v.nameAndType(path+".name_and_type_index", t.NameAndTypeIndex); ok && !validFieldDescriptor(descriptor) {
+			v.report(path, "invalid field descriptor %q", descriptor)
+		}
+		v.validateBootstrapMethodIndex(path, t.BootstrapMethodAttrIndex)
+	case ConstantInvokeDynamicInfo:
+		if _, descriptor, ok := v.nameAndType(path+".name_and_type_index", t.NameAndTypeIndex); ok {
+			if _, _, ok := parseMethodDescriptorSlots(descriptor); !ok {
+				v.report(path, "invalid method descriptor %q", descriptor)
+			}
+		}
+		v.validateBootstrapMethodIndex(path, t.BootstrapMethodAttrIndex)
+	case ConstantModuleInfo:
+		if !v.isModule {
+			v.report(path, "CONSTANT_Module outside of a module-info class")
+		}
+		v.utf8(path+".name_index", t.NameIndex)
+	case ConstantPackageInfo:
+		if !v.isModule {
+			v.report(path, "CONSTANT_Package outside of a module-info class")
+		}
+		if name, ok := v.utf8(path+".name_index", t.NameIndex); ok && !validClassName(name) {
+			v.report(path, "invalid package name %q", name)
+		}
+	default:
+		v.report(path, "unknown constant pool entry %s", reflect.TypeOf(t))
+	}
+}
+
+// validateMethodRef checks the name and type of a CONSTANT_Methodref_info or CONSTANT_InterfaceMethodref_info.
+func (v *validator) validateMethodRef(path string, natIndex int) (name string, descriptor string, ok bool) {
+	name, descriptor, ok = v.nameAndType(path+".name_and_type_index", natIndex)
+	if !ok {
+		return
+	}
+	if !validMethodName(name) || name == "<clinit>" {
+		v.report(path, "invalid method name %q", name)
+	}
+	_, returnsVoid, okDescriptor := parseMethodDescriptorSlots(descriptor)
+	if !okDescriptor {
+		v.report(path, "invalid method descriptor %q", descriptor)
+	} else if name == "<init>" && !returnsVoid {
+		v.report(path, "<init> must return void")
+	}
 	return
 }
+
+func (v *validator) validateMethodHandle(path string, t ConstantMethodHandleInfo) {
+	info, ok := v.entry(path+".reference_index", t.ReferenceIndex)
+	if !ok {
+		return
+	}
+
+	var natIndex int
+	switch t.ReferenceKind {
+	case 1, 2, 3, 4: // REF_getField, REF_getStatic, REF_putField, REF_putStatic
+		ref, ok := info.(ConstantFieldrefInfo)
+		if !ok {
+			v.report(path, "reference kind %d needs a ConstantFieldrefInfo", t.ReferenceKind)
+			return
+		}
+		natIndex = ref.NameAndTypeIndex
+	case 5, 8: // REF_invokeVirtual, REF_newInvokeSpecial
+		ref, ok := info.(ConstantMethodrefInfo)
+		if !ok {
+			v.report(path, "reference kind %d needs a ConstantMethodrefInfo", t.ReferenceKind)
+			return
+		}
+		natIndex = ref.NameAndTypeIndex
+	case 6, 7: // REF_invokeStatic, REF_invokeSpecial
+		switch ref := info.(type) {
+		case ConstantMethodrefInfo:
+			natIndex = ref.NameAndTypeIndex
+		case ConstantInterfaceMethodrefInfo:
+			if v.cf.MajorVersion < 52 {
+				v.report(path, "reference kind %d to an interface method needs version 52.0", t.ReferenceKind)
+			}
+			natIndex = ref.NameAndTypeIndex
+		default:
+			v.report(path, "reference kind %d needs a ConstantMethodrefInfo or ConstantInterfaceMethodrefInfo", t.ReferenceKind)
+			return
+		}
+	case 9: // REF_invokeInterface
+		ref, ok := info.(ConstantInterfaceMethodrefInfo)
+		if !ok {
+			v.report(path, "reference kind %d needs a ConstantInterfaceMethodrefInfo", t.ReferenceKind)
+			return
+		}
+		natIndex = ref.NameAndTypeIndex
+	default:
+		v.report(path, "invalid reference kind %d", t.ReferenceKind)
+		return
+	}
+
+	if t.ReferenceKind <= 4 || natIndex < 1 || natIndex > len(v.cf.ConstantPool) {
+		return
+	}
+	if nat, ok := v.cf.ConstantPool[natIndex-1].(ConstantNameAndTypeInfo); ok {
+		name := v.lookupUtf8(nat.NameIndex)
+		if t.ReferenceKind == 8 && name != "<init>" {
+			v.report(path, "REF_newInvokeSpecial must reference <init>")
+		}
+		if t.ReferenceKind != 8 && (name == "<init>" || name == "<clinit>") {
+			v.report(path, "reference kind %d must not reference %s", t.ReferenceKind, name)
+		}
+	}
+}
+
+// validateBootstrapMethodIndex checks that index is valid in the BootstrapMethods attribute.
+func (v *validator) validateBootstrapMethodIndex(path string, index int) {
+	for _, attribute := range v.cf.Attributes {
+		if v.lookupUtf8(attribute.AttributeNameIndex) != "BootstrapMethods" {
+			continue
+		}
+		count, _ := (*ClassFileReader)(bytes.NewReader(attribute.Info)).ReadU2()
+		if index >= count {
+			v.report(path, "bootstrap method %d out of range 0..%d", index, count-1)
+		}
+		return
+	}
+	v.report(path, "no BootstrapMethods attribute")
+}
+
+func (v *validator) validateClass() {
+	flags := v.cf.AccessFlags
+
+	if v.isModule {
+		if v.cf.MajorVersion < 53 {
+			v.report("access_flags", "ACC_MODULE needs version 53.0")
+		}
+		if flags != ClassAccModule {
+			v.report("access_flags", "ACC_MODULE must not be combined with other flags")
+		}
+		if v.cf.SuperClass != 0 || len(v.cf.Interfaces) != 0 {
+			v.report("super_class", "module-info must not have a super class or interfaces")
+		}
+		if name, ok := v.class("this_class", v.cf.ThisClass); ok && name != "module-info" {
+			v.report("this_class", "module class must be named module-info, not %q", name)
+		}
+		return
+	}
+
+	if flags&ClassAccInterface != 0 {
+		if flags&ClassAccAbstract == 0 {
+			v.report("access_flags", "interface must be ACC_ABSTRACT")
+		}
+		if flags&(ClassAccFinal|ClassAccSuper|ClassAccEnum) != 0 {
+			v.report("access_flags", "interface must not be ACC_FINAL, ACC_SUPER or ACC_ENUM")
+		}
+	} else {
+		if flags&ClassAccAnnotation != 0 {
+			v.report("access_flags", "ACC_ANNOTATION needs ACC_INTERFACE")
+		}
+		if flags&ClassAccFinal != 0 && flags&ClassAccAbstract != 0 {
+			v.report("access_flags", "class must not be ACC_FINAL and ACC_ABSTRACT")
+		}
+	}
+
+	thisName, _ := v.class("this_class", v.cf.ThisClass)
+	if v.cf.SuperClass == 0 {
+		if thisName != "java/lang/Object" {
+			v.report("super_class", "only java/lang/Object may have no super class")
+		}
+	} else if superName, ok := v.class("super_class", v.cf.SuperClass); ok {
+		if flags&ClassAccInterface != 0 && superName != "java/lang/Object" {
+			v.report("super_class", "super class of an interface must be java/lang/Object")
+		}
+		if strings.HasPrefix(superName, "[") {
+			v.report("super_class", "super class must not be an array")
+		}
+	}
+
+	for i, index := range v.cf.Interfaces {
+		v.class(fmt.Sprintf("interfaces[%d]", i), index)
+	}
+}
+
+func (v *validator) validateField(path string, field FieldInfo) (name string, descriptor string) {
+	name, ok := v.utf8(path+".name_index", field.NameIndex)
+	if ok && !validUnqualifiedName(name) {
+		v.report(path+".name_index", "invalid field name %q", name)
+	}
+	descriptor, ok = v.utf8(path+".descriptor_index", field.DescriptorIndex)
+	if ok && !validFieldDescriptor(descriptor) {
+		v.report(path+".descriptor_index", "invalid field descriptor %q", descriptor)
+	}
+
+	flags := field.AccessFlags
+	if moreThanOne(flags, FieldAccPublic, FieldAccPrivate, FieldAccProtected) {
+		v.report(path+".access_flags", "at most one of ACC_PUBLIC, ACC_PRIVATE and ACC_PROTECTED")
+	}
+	if flags&FieldAccFinal != 0 && flags&FieldAccVolatile != 0 {
+		v.report(path+".access_flags", "field must not be ACC_FINAL and ACC_VOLATILE")
+	}
+	if v.cf.AccessFlags.IsInterface() {
+		if flags&(FieldAccPublic|FieldAccStatic|FieldAccFinal) != FieldAccPublic|FieldAccStatic|FieldAccFinal ||
+			flags&^(FieldAccPublic|FieldAccStatic|FieldAccFinal|FieldAccSynthetic) != 0 {
+			v.report(path+".access_flags", "interface fields must be ACC_PUBLIC, ACC_STATIC and ACC_FINAL only")
+		}
+	}
+
+	v.validateAttributes(path+".attributes", field.Attributes, "field")
+	v.validateConstantValue(path, field, descriptor)
+
+	return
+}
+
+// validateConstantValue checks that the ConstantValue attribute of a field matches the field type.
+func (v *validator) validateConstantValue(path string, field FieldInfo, descriptor string) {
+	for _, attribute := range field.Attributes {
+		if v.lookupUtf8(attribute.AttributeNameIndex) != "ConstantValue" || len(attribute.Info) != 2 {
+			continue
+		}
+		index := decodeBigEndian(append([]byte{}, attribute.Info...))
+		info, ok := v.entry(path+".attributes[ConstantValue]", index)
+		if !ok {
+			return
+		}
+
+		var valid bool
+		switch descriptor {
+		case "I", "S", "C", "B", "Z":
+			_, valid = info.(ConstantIntegerInfo)
+		case "J":
+			_, valid = info.(ConstantLongInfo)
+		case "F":
+			_, valid = info.(ConstantFloatInfo)
+		case "D":
+			_, valid = info.(ConstantDoubleInfo)
+		case "Ljava/lang/String;":
+			_, valid = info.(ConstantStringInfo)
+		}
+		if !valid {
+			v.report(path+".attributes[ConstantValue]", "%s can't be the value of a field of type %s", reflect.TypeOf(info).Name(), descriptor)
+		}
+	}
+}
+
+func (v *validator) validateMethod(path string, method MethodInfo) (name string, descriptor string) {
+	name, okName := v.utf8(path+".name_index", method.NameIndex)
+	if okName && !validMethodName(name) {
+		v.report(path+".name_index", "invalid method name %q", name)
+	}
+	descriptor, okDescriptor := v.utf8(path+".descriptor_index", method.DescriptorIndex)
+	slots, returnsVoid, validDescriptor := parseMethodDescriptorSlots(descriptor)
+	if okDescriptor {
+		if !method.AccessFlags.IsStatic() {
+			slots++ // this
+		}
+		switch {
+		case !validDescriptor:
+			v.report(path+".descriptor_index", "invalid method descriptor %q", descriptor)
+		case slots > 255:
+			v.report(path+".descriptor_index", "method takes more than 255 parameter slots")
+		case (name == "<init>" || name == "<clinit>") && !returnsVoid:
+			v.report(path+".descriptor_index", "%s must return void", name)
+		case name == "<clinit>" && v.cf.MajorVersion >= 51 && !strings.HasPrefix(descriptor, "()"):
+			v.report(path+".descriptor_index", "<clinit> must not take arguments")
+		}
+	}
+
+	flags := method.AccessFlags
+	isInterface := v.cf.AccessFlags.IsInterface()
+	switch {
+	case name == "<clinit>":
+		if v.cf.MajorVersion >= 51 && flags&MethodAccStatic == 0 {
+			v.report(path+".access_flags", "<clinit> must be ACC_STATIC")
+		}
+	case moreThanOne(flags, MethodAccPublic, MethodAccPrivate, MethodAccProtected):
+		v.report(path+".access_flags", "at most one of ACC_PUBLIC, ACC_PRIVATE and ACC_PROTECTED")
+	case name == "<init>":
+		if isInterface {
+			v.report(path, "interfaces must not have an <init> method")
+		}
+		if flags&^(MethodAccPublic|MethodAccPrivate|MethodAccProtected|MethodAccVarargs|MethodAccStrict|MethodAccSynthetic) != 0 {
+			v.report(path+".access_flags", "illegal flags 0x%04X for <init>", flags)
+		}
+	case isInterface && v.cf.MajorVersion < 52:
+		if flags&(MethodAccPublic|MethodAccAbstract) != MethodAccPublic|MethodAccAbstract ||
+			flags&^(MethodAccPublic|MethodAccAbstract|MethodAccVarargs|MethodAccBridge|MethodAccSynthetic) != 0 {
+			v.report(path+".access_flags", "interface methods must be ACC_PUBLIC and ACC_ABSTRACT before version 52.0")
+		}
+	case isInterface:
+		if flags&(MethodAccPublic|MethodAccPrivate) == 0 {
+			v.report(path+".access_flags", "interface methods must be ACC_PUBLIC or ACC_PRIVATE")
+		}
+		if flags&(MethodAccProtected|MethodAccFinal|MethodAccSynchronized|MethodAccNative) != 0 {
+			v.report(path+".access_flags", "interface methods must not be ACC_PROTECTED, ACC_FINAL, ACC_SYNCHRONIZED or ACC_NATIVE")
+		}
+		if flags&MethodAccAbstract != 0 && flags&(MethodAccPrivate|MethodAccStatic|MethodAccStrict) != 0 {
+			v.report(path+".access_flags", "abstract interface methods must not be ACC_PRIVATE, ACC_STATIC or ACC_STRICT")
+		}
+	case flags&MethodAccAbstract != 0:
+		if flags&(MethodAccPrivate|MethodAccStatic|MethodAccFinal|MethodAccSynchronized|MethodAccNative) != 0 ||
+			(flags&MethodAccStrict != 0 && v.cf.MajorVersion >= 46 && v.cf.MajorVersion < 61) {
+			v.report(path+".access_flags", "abstract methods must not be ACC_PRIVATE, ACC_STATIC, ACC_FINAL, ACC_SYNCHRONIZED, ACC_NATIVE or ACC_STRICT")
+		}
+	}
+
+	v.validateAttributes(path+".attributes", method.Attributes, "method")
+
+	codeCount := 0
+	for _, attribute := range method.Attributes {
+		if v.lookupUtf8(attribute.AttributeNameIndex) == "Code" {
+			codeCount++
+		}
+	}
+	switch {
+	case flags&(MethodAccAbstract|MethodAccNative) != 0 && codeCount != 0:
+		v.report(path, "abstract and native methods must not have a Code attribute")
+	case flags&(MethodAccAbstract|MethodAccNative) == 0 && codeCount != 1:
+		v.report(path, "method must have exactly one Code attribute, found %d", codeCount)
+	}
+
+	return
+}
+
+// uniqueAttributes may appear at most once in each attributes table.
+var uniqueAttributes = map[string]bool{
+	"ConstantValue":                        true,
+	"Code":                                 true,
+	"StackMapTable":                        true,
+	"Exceptions":                           true,
+	"InnerClasses":                         true,
+	"EnclosingMethod":                      true,
+	"Synthetic":                            true,
+	"Signature":                            true,
+	"SourceFile":                           true,
+	"SourceDebugExtension":                 true,
+	"Deprecated":                           true,
+	"BootstrapMethods":                     true,
+	"MethodParameters":                     true,
+	"Module":                               true,
+	"ModulePackages":                       true,
+	"ModuleMainClass":                      true,
+	"NestHost":                             true,
+	"NestMembers":                          true,
+	"Record":                               true,
+	"PermittedSubclasses":                  true,
+	"AnnotationDefault":                    true,
+	"RuntimeVisibleAnnotations":            true,
+	"RuntimeInvisibleAnnotations":          true,
+	"RuntimeVisibleParameterAnnotations":   true,
+	"RuntimeInvisibleParameterAnnotations": true,
+	"RuntimeVisibleTypeAnnotations":        true,
+	"RuntimeInvisibleTypeAnnotations":      true,
+}
+
+// attributeLocations lists where the attributes with a fixed location may appear.
+var attributeLocations = map[string]string{
+	"ConstantValue":                        "field",
+	"Code":                                 "method",
+	"Exceptions":                           "method",
+	"MethodParameters":                     "method",
+	"AnnotationDefault":                    "method",
+	"RuntimeVisibleParameterAnnotations":   "method",
+	"RuntimeInvisibleParameterAnnotations": "method",
+	"StackMapTable":                        "code",
+	"LineNumberTable":                      "code",
+	"LocalVariableTable":                   "code",
+	"LocalVariableTypeTable":               "code",
+	"SourceFile":                           "class",
+	"InnerClasses":                         "class",
+	"EnclosingMethod":                      "class",
+	"SourceDebugExtension":                 "class",
+	"BootstrapMethods":                     "class",
+	"Module":                               "class",
+	"ModulePackages":                       "class",
+	"ModuleMainClass":                      "class",
+	"NestHost":                             "class",
+	"NestMembers":                          "class",
+	"Record":                               "class",
+	"PermittedSubclasses":                  "class",
+}
+
+// validateAttributes checks the attributes of a class ("class"), field ("field"), method ("method") or
+// Code attribute ("code").
+func (v *validator) validateAttributes(path string, attributes []AttributeInfo, location string) {
+	seen := map[string]bool{}
+
+	for i, attribute := range attributes {
+		name, ok := v.utf8(fmt.Sprintf("%s[%d].attribute_name_index", path, i), attribute.AttributeNameIndex)
+		if !ok {
+			continue
+		}
+		if _, predefined := attributeSince[name]; predefined && !isAttributeRecognized(name, v.cf.MajorVersion) {
+			continue // ignored like an unknown attribute in class files older than the attribute
+		}
+		attributePath := fmt.Sprintf("%s[%s]", path, name)
+
+		if uniqueAttributes[name] && seen[name] {
+			v.report(attributePath, "attribute may only appear once")
+		}
+		seen[name] = true
+
+		if want, ok := attributeLocations[name]; ok && want != location {
+			v.report(attributePath, "attribute not allowed in a %s", location)
+			continue
+		}
+
+		v.validateAttribute(attributePath, name, attribute.Info)
+	}
+}
+
+// validateAttribute checks that the length of a known attribute matches its content.
+func (v *validator) validateAttribute(path string, name string, info []byte) {
+	r := (*ClassFileReader)(bytes.NewReader(info))
+
+	// expectLength reports if the attribute isn't exactly fixed + count*size bytes long, where count is read from
+	// the start of the attribute as an u1 or u2 depending on countSize.
+	expectLength := func(fixed int, countSize int, size int) {
+		count := 0
+		if countSize == 1 && len(info) >= 1 {
+			count = int(info[0])
+		} else if countSize == 2 && len(info) >= 2 {
+			count = int(info[0])<<8 | int(info[1])
+		}
+		if want := fixed + count*size; len(info) != want {
+			v.report(path, "attribute length %d doesn't match expected length %d", len(info), want)
+		}
+	}
+
+	switch name {
+	case "ConstantValue":
+		expectLength(2, 0, 0)
+	case "SourceFile", "Signature":
+		expectLength(2, 0, 0)
+		if len(info) == 2 {
+			v.utf8(path, decodeBigEndian(append([]byte{}, info...)))
+		}
+	case "NestHost", "ModuleMainClass":
+		expectLength(2, 0, 0)
+		if len(info) == 2 {
+			v.class(path, decodeBigEndian(append([]byte{}, info...)))
+		}
+	case "EnclosingMethod":
+		expectLength(4, 0, 0)
+		if len(info) == 4 {
+			classIndex, _ := r.ReadU2()
+			methodIndex, _ := r.ReadU2()
+			v.class(path+".class_index", classIndex)
+			if methodIndex != 0 {
+				v.nameAndType(path+".method_index", methodIndex)
+			}
+		}
+	case "Synthetic", "Deprecated":
+		expectLength(0, 0, 0)
+	case "Exceptions", "NestMembers", "PermittedSubclasses":
+		expectLength(2, 2, 2)
+		if count, _ := r.ReadU2(); len(info) == 2+count*2 {
+			for i := 0; i < count; i++ {
+				index, _ := r.ReadU2()
+				v.class(fmt.Sprintf("%s[%d]", path, i), index)
+			}
+		}
+	case "ModulePackages":
+		expectLength(2, 2, 2)
+	case "LineNumberTable":
+		expectLength(2, 2, 4)
+	case "LocalVariableTable", "LocalVariableTypeTable":
+		expectLength(2, 2, 10)
+	case "InnerClasses":
+		expectLength(2, 2, 8)
+	case "MethodParameters":
+		expectLength(1, 1, 4)
+	case "BootstrapMethods":
+		v.validateBootstrapMethods(path, r)
+	case "Code":
+		v.validateCode(path, r)
+	}
+}
+
+func (v *validator) validateBootstrapMethods(path string, r *ClassFileReader) {
+	count, err := r.ReadU2()
+	if err != nil {
+		v.report(path, "attribute too short")
+		return
+	}
+	for i := 0; i < count; i++ {
+		methodPath := fmt.Sprintf("%s.bootstrap_methods[%d]", path, i)
+		ref, err := r.ReadU2()
+		if err != nil {
+			v.report(path, "attribute too short")
+			return
+		}
+		if info, ok := v.entry(methodPath+".bootstrap_method_ref", ref); ok {
+			if _, ok := info.(ConstantMethodHandleInfo); !ok {
+				v.report(methodPath+".bootstrap_method_ref", "constant pool entry %d is %s instead of ConstantMethodHandleInfo", ref, reflect.TypeOf(info).Name())
+			}
+		}
+		argCount, err := r.ReadU2()
+		if err != nil {
+			v.report(path, "attribute too short")
+			return
+		}
+		for j := 0; j < argCount; j++ {
+			arg, err := r.ReadU2()
+			if err != nil {
+				v.report(path, "attribute too short")
+				return
+			}
+			v.entry(fmt.Sprintf("%s.bootstrap_arguments[%d]", methodPath, j), arg)
+		}
+	}
+	if r.Len() != 0 {
+		v.report(path, "attribute longer than its content")
+	}
+}
+
+func (v *validator) validateCode(path string, r *ClassFileReader) {
+	code, err := r.ReadCodeAttribute()
+	if err != nil {
+		var formatError *FormatError
+		if errors.As(err, &formatError) {
+			path, err = path+"."+formatError.Path, formatError.Err
+		}
+		v.report(path, "attribute too short: %s", err)
+		return
+	}
+	if r.Len() != 0 {
+		v.report(path, "attribute longer than its content")
+	}
+
+	if len(code.Code) == 0 || len(code.Code) > 0xFFFF {
+		v.report(path+".code_length", "code length %d out of range 1..65535", len(code.Code))
+	}
+
+	for i, entry := range code.ExceptionTable {
+		entryPath := fmt.Sprintf("%s.exception_table[%d]", path, i)
+		if entry.StartPc >= entry.EndPc || entry.EndPc > len(code.Code) {
+			v.report(entryPath, "invalid range %d..%d", entry.StartPc, entry.EndPc)
+		}
+		if entry.HandlerPc >= len(code.Code) {
+			v.report(entryPath, "handler pc %d out of range", entry.HandlerPc)
+		}
+		if entry.CatchType != 0 {
+			v.class(entryPath+".catch_type", entry.CatchType)
+		}
+	}
+
+	v.validateAttributes(path+".attributes", code.Attributes, "code")
+}
+
+// moreThanOne reports if more than one of flags is set in accessFlags.
+func moreThanOne[F ~uint16](accessFlags F, flags ...F) bool {
+	count := 0
+	for _, flag := range flags {
+		if accessFlags&flag != 0 {
+			count++
+		}
+	}
+	return count > 1
+}
+
+// validUnqualifiedName checks the rules of JVMS §4.2.2 for field names.
+func validUnqualifiedName(name string) bool {
+	return name != "" && !strings.ContainsAny(name, ".;[/")
+}
+
+// validMethodName checks the rules of JVMS §4.2.2 for method names.
+func validMethodName(name string) bool {
+	if name == "<init>" || name == "<clinit>" {
+		return true
+	}
+	return validUnqualifiedName(name) && !strings.ContainsAny(name, "<>")
+}
+
+// validClassName checks that name is a binary name in internal form (JVMS §4.2.1) or an array descriptor.
+func validClassName(name string) bool {
+	if strings.HasPrefix(name, "[") {
+		return validFieldDescriptor(name)
+	}
+	for _, part := range strings.Split(name, "/") {
+		if !validUnqualifiedName(part) {
+			return false
+		}
+	}
+	return true
+}
+
+// validFieldDescriptor checks the grammar of JVMS §4.3.2.
+func validFieldDescriptor(descriptor string) bool {
+	_, err := ParseFieldType(descriptor)
+	return err == nil
+}
+
+// parseMethodDescriptorSlots checks the grammar of JVMS §4.3.3 and returns the number of local variable slots the
+// parameters need.
+func parseMethodDescriptorSlots(descriptor string) (slots int, returnsVoid bool, ok bool) {
+	d, err := ParseMethodDescriptor(descriptor)
+	if err != nil {
+		return 0, false, false
+	}
+	return d.ParameterSlots(), d.Return.IsVoid(), true
+}
@@ -0,0 +1,108 @@
+package parse
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Indexes into the constant pool of testdata/Hello.class.
+const (
+	helloOut        = 38 // Utf8 out
+	helloPrintType  = 39 // Utf8 Ljava/io/PrintStream;
+	helloCode       = 15 // Utf8 Code
+	helloLineNumber = 16 // Utf8 LineNumberTable
+)
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(cf *ClassFile)
+		path   string // of the expected error, "" if the class is valid
+		reason string // part of the expected reason
+	}{
+		{"unmodified", func(cf *ClassFile) {}, "", ""},
+		{"bad magic", func(cf *ClassFile) { cf.Magic = 0xCAFED00D }, "magic", "incorrect magic"},
+
+		// versions
+		{"oldest version", func(cf *ClassFile) { cf.MajorVersion = MinMajorVersion }, "", ""},
+		{"newest version", func(cf *ClassFile) { cf.MajorVersion = MaxMajorVersion }, "", ""},
+		{"too old", func(cf *ClassFile) { cf.MajorVersion = MinMajorVersion - 1 }, "major_version", "unsupported"},
+		{"too new", func(cf *ClassFile) { cf.MajorVersion = MaxMajorVersion + 1 }, "major_version", "unsupported"},
+		{"minor version before 56", func(cf *ClassFile) { cf.MajorVersion, cf.MinorVersion = 50, 3 }, "", ""},
+		{"minor version since 56", func(cf *ClassFile) { cf.MajorVersion, cf.MinorVersion = 56, 3 }, "minor_version", "must be 0"},
+		{"preview", func(cf *ClassFile) { cf.MajorVersion, cf.MinorVersion = 61, 0xFFFF }, "minor_version", "preview"},
+
+		// flags
+		{"final and abstract class", func(cf *ClassFile) { cf.AccessFlags = ClassAccFinal | ClassAccAbstract },
+			"access_flags", "ACC_FINAL and ACC_ABSTRACT"},
+		{"interface without abstract", func(cf *ClassFile) { cf.AccessFlags = ClassAccInterface },
+			"access_flags", "must be ACC_ABSTRACT"},
+		{"super interface", func(cf *ClassFile) { cf.AccessFlags = ClassAccInterface | ClassAccAbstract | ClassAccSuper },
+			"access_flags", "must not be ACC_FINAL, ACC_SUPER or ACC_ENUM"},
+		{"annotation without interface", func(cf *ClassFile) { cf.AccessFlags = ClassAccAnnotation },
+			"access_flags", "needs ACC_INTERFACE"},
+		{"module with other flags", func(cf *ClassFile) { cf.MajorVersion, cf.AccessFlags = 53, ClassAccModule|ClassAccPublic },
+			"access_flags", "must not be combined"},
+		{"public and private method", func(cf *ClassFile) { cf.Methods[1].AccessFlags |= MethodAccPrivate },
+			"methods[1].access_flags", "at most one"},
+		{"abstract static method", func(cf *ClassFile) { cf.Methods[1].AccessFlags |= MethodAccAbstract },
+			"methods[1].access_flags", "abstract methods must not be"},
+		{"abstract method with code", func(cf *ClassFile) { cf.Methods[1].AccessFlags = MethodAccAbstract },
+			"methods[1]", "must not have a Code attribute"},
+		{"static <init>", func(cf *ClassFile) { cf.Methods[0].AccessFlags = MethodAccStatic },
+			"methods[0].access_flags", "illegal flags"},
+		{"final and volatile field", func(cf *ClassFile) {
+			cf.Fields = []FieldInfo{{AccessFlags: FieldAccFinal | FieldAccVolatile, NameIndex: helloOut, DescriptorIndex: helloPrintType}}
+		}, "fields[0].access_flags", "ACC_FINAL and ACC_VOLATILE"},
+
+		// attribute locations
+		{"SourceFile on a method", func(cf *ClassFile) {
+			cf.Methods[0].Attributes = append(cf.Methods[0].Attributes, cf.Attributes[0])
+		}, "methods[0].attributes[SourceFile]", "not allowed in a method"},
+		{"Code on the class", func(cf *ClassFile) {
+			cf.Attributes = append(cf.Attributes, AttributeInfo{AttributeNameIndex: helloCode})
+		}, "attributes[Code]", "not allowed in a class"},
+		{"LineNumberTable on a method", func(cf *ClassFile) {
+			cf.Methods[0].Attributes = append(cf.Methods[0].Attributes, AttributeInfo{AttributeNameIndex: helloLineNumber, Info: []byte{0, 0}})
+		}, "methods[0].attributes[LineNumberTable]", "not allowed in a method"},
+		{"Code on a field", func(cf *ClassFile) {
+			cf.Fields = []FieldInfo{{AccessFlags: FieldAccPrivate, NameIndex: helloOut, DescriptorIndex: helloPrintType,
+				Attributes: []AttributeInfo{cf.Methods[0].Attributes[0]}}}
+		}, "fields[0].attributes[Code]", "not allowed in a field"},
+		{"duplicate SourceFile", func(cf *ClassFile) { cf.Attributes = append(cf.Attributes, cf.Attributes[0]) },
+			"attributes[SourceFile]", "only appear once"},
+		{"short SourceFile", func(cf *ClassFile) { cf.Attributes[0].Info = cf.Attributes[0].Info[:1] },
+			"attributes[SourceFile]", ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cf, err := Parse(filepath.Join("testdata", "Hello.class"))
+			if err != nil {
+				t.Fatalf("Parse failed: %v", err)
+			}
+			test.modify(&cf)
+
+			_, err = Validate(cf)
+			if test.path == "" {
+				if err != nil {
+					t.Fatalf("Validate failed for a valid class: %v", err)
+				}
+				return
+			}
+
+			var errs ValidationErrors
+			if !errors.As(err, &errs) {
+				t.Fatalf("Validate returned %v, want ValidationErrors", err)
+			}
+			for _, e := range errs {
+				if e.Path == test.path && strings.Contains(e.Reason, test.reason) {
+					return
+				}
+			}
+			t.Errorf("no error at %s containing %q in: %v", test.path, test.reason, err)
+		})
+	}
+}
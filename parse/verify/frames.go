@@ -0,0 +1,313 @@
+package verify
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/PPTide/gojdk/parse"
+	"github.com/PPTide/gojdk/parse/disasm"
+)
+
+// Frames is the result of ComputeFrames.
+type Frames struct {
+	MaxStack      int
+	MaxLocals     int
+	StackMapTable []byte // content of the "StackMapTable" attribute, nil if the method doesn't need one
+}
+
+// ComputeFrames computes max_stack, max_locals and the StackMapTable of the code of info by type inference, for tools
+// that generate code. MaxStack, MaxLocals and the attributes of info.Code are ignored.
+//
+// classIndex has to return the index of the ConstantClassInfo of a class or array name in the constant pool of cf,
+// it's called for the class types in the frames. Two different classes merge to their closest common super class, or
+// to java/lang/Object if v can't load them. Unlike Method, ComputeFrames assumes that class types are assignable if
+// the classes can't be loaded; the frames are checked when the class is verified.
+//
+// A StackMapTable is only computed for class files of version 50.0 or newer.
+func (v *Verifier) ComputeFrames(cf *parse.ClassFile, info parse.MethodInfo, classIndex func(name string) int) (Frames, error) {
+	if info.Code == nil {
+		return Frames{}, nil
+	}
+
+	maxLocals, err := requiredLocals(cf, info)
+	if err != nil {
+		return Frames{}, err
+	}
+	code := *info.Code
+	code.MaxStack = 65535
+	code.MaxLocals = maxLocals
+	code.Attributes = nil
+	info.Code = &code
+
+	classes := *v.hierarchy()
+	classes.lenient = true
+	m, err := newMethod(cf, info, &classes)
+	if err != nil {
+		return Frames{}, err
+	}
+	frames, err := m.inferFrames()
+	if err != nil {
+		return Frames{}, err
+	}
+
+	res := Frames{MaxLocals: maxLocals}
+	needsFrame := map[int]bool{}
+	for pc, f := range frames {
+		current := f.copy()
+		res.MaxStack = max(res.MaxStack, len(current.stack))
+
+		if op := m.code[pc]; cf.MajorVersion >= 50 && (op == 168 || op == 169 || op == 201) {
+			return Frames{}, m.errorf(pc, "jsr and ret are not allowed in class files with a StackMapTable")
+		}
+
+		next, targets, fallsThrough, err := m.step(pc, &current)
+		if err != nil {
+			return Frames{}, m.wrap(pc, err)
+		}
+		res.MaxStack = max(res.MaxStack, len(current.stack))
+
+		for _, t := range targets {
+			needsFrame[t] = true
+		}
+		if !fallsThrough && next < len(m.code) {
+			needsFrame[next] = true
+		}
+	}
+	for _, entry := range m.exceptionTable {
+		needsFrame[entry.HandlerPc] = true
+	}
+
+	if cf.MajorVersion < 50 || len(needsFrame) == 0 {
+		return res, nil
+	}
+
+	pcs := make([]int, 0, len(needsFrame))
+	for pc := range needsFrame {
+		if frames[pc] == nil {
+			return Frames{}, m.errorf(pc, "unreachable code needs a stack map frame")
+		}
+		pcs = append(pcs, pc)
+	}
+	sort.Ints(pcs)
+
+	res.StackMapTable, err = m.writeStackMapTable(pcs, frames, classIndex)
+	if err != nil {
+		return Frames{}, err
+	}
+	return res, nil
+}
+
+// StackMapTable returns the StackMapTable computed by ComputeFrames, in the form of parse.StackMapTableFunc.
+func (v *Verifier) StackMapTable(cf *parse.ClassFile, info parse.MethodInfo, classIndex func(name string) int) ([]byte, error) {
+	frames, err := v.ComputeFrames(cf, info, classIndex)
+	return frames.StackMapTable, err
+}
+
+// ComputeFrames computes the frames of info without loading other classes, see Verifier.ComputeFrames.
+func ComputeFrames(cf *parse.ClassFile, info parse.MethodInfo, classIndex func(name string) int) (Frames, error) {
+	return new(Verifier).ComputeFrames(cf, info, classIndex)
+}
+
+// StackMapTable computes the StackMapTable of info without loading other classes, see Verifier.StackMapTable.
+func StackMapTable(cf *parse.ClassFile, info parse.MethodInfo, classIndex func(name string) int) ([]byte, error) {
+	return new(Verifier).StackMapTable(cf, info, classIndex)
+}
+
+func max(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+// requiredLocals returns the number of local variables the arguments and the instructions of info use.
+func requiredLocals(cf *parse.ClassFile, info parse.MethodInfo) (int, error) {
+	descriptor, err := (&method{cf: cf}).utf8(info.DescriptorIndex)
+	if err != nil {
+		return 0, err
+	}
+	params, _, err := parseMethodDescriptor(descriptor)
+	if err != nil {
+		return 0, err
+	}
+
+	locals := 0
+	if !info.AccessFlags.IsStatic() {
+		locals++
+	}
+	for _, param := range params {
+		t, ok := typeFromDescriptor(param)
+		if !ok {
+			return 0, fmt.Errorf("invalid parameter type %s", param)
+		}
+		locals += t.size()
+	}
+
+	instructions, err := disasm.Decode(info.Code.Code, nil)
+	if err != nil {
+		return 0, err
+	}
+	for _, instruction := range instructions {
+		op := instruction.Opcode
+		index, size := -1, 1
+		switch {
+		case op >= disasm.Iload && op <= disasm.Aload, op >= disasm.Istore && op <= disasm.Astore:
+			index = instruction.Local
+			if op == disasm.Lload || op == disasm.Dload || op == disasm.Lstore || op == disasm.Dstore {
+				size = 2
+			}
+		case op >= disasm.Iload0 && op <= disasm.Aload3, op >= disasm.Istore0 && op <= disasm.Astore3:
+			first := disasm.Iload0
+			if op >= disasm.Istore0 {
+				first = disasm.Istore0
+			}
+			index = int(op-first) % 4
+			if family := (op - first) / 4; family == 1 || family == 3 { // long, double
+				size = 2
+			}
+		case op == disasm.Iinc, op == disasm.Ret:
+			index = instruction.Local
+		}
+		locals = max(locals, index+size)
+	}
+	return locals, nil
+}
+
+// ------------------- StackMapTable ---------------------
+
+// writeStackMapTable encodes the frames at pcs, which have to be sorted, as the content of a StackMapTable
+// attribute using the smallest frame types (JVMS §4.7.4).
+func (m *method) writeStackMapTable(pcs []int, frames map[int]*frame, classIndex func(name string) int) ([]byte, error) {
+	w := new(parse.ClassFileWriter)
+	err := w.WriteU2(len(pcs))
+	if err != nil {
+		return nil, err
+	}
+
+	previousLocals := compact(trimLocals(m.initialFrame.locals))
+	previousPc := -1
+	for _, pc := range pcs {
+		locals := compact(trimLocals(frames[pc].locals))
+		stack := compact(frames[pc].stack)
+		delta := pc - previousPc - 1
+		previousPc = pc
+
+		diff := len(locals) - len(previousLocals)
+		sameLocals := diff == 0 && equalTypes(locals, previousLocals)
+		switch {
+		case sameLocals && len(stack) == 0 && delta < 64:
+			err = w.WriteByte(byte(delta)) // same_frame
+		case sameLocals && len(stack) == 0:
+			err = writeFrameHeader(w, 251, delta) // same_frame_extended
+		case sameLocals && len(stack) == 1 && delta < 64:
+			err = w.WriteByte(byte(64 + delta)) // same_locals_1_stack_item_frame
+			if err == nil {
+				err = writeVerificationTypes(w, stack, classIndex)
+			}
+		case sameLocals && len(stack) == 1:
+			err = writeFrameHeader(w, 247, delta) // same_locals_1_stack_item_frame_extended
+			if err == nil {
+				err = writeVerificationTypes(w, stack, classIndex)
+			}
+		case len(stack) == 0 && diff < 0 && diff >= -3 && equalTypes(locals, previousLocals[:len(locals)]):
+			err = writeFrameHeader(w, 251+diff, delta) // chop_frame
+		case len(stack) == 0 && diff > 0 && diff <= 3 && equalTypes(locals[:len(previousLocals)], previousLocals):
+			err = writeFrameHeader(w, 251+diff, delta) // append_frame
+			if err == nil {
+				err = writeVerificationTypes(w, locals[len(previousLocals):], classIndex)
+			}
+		default:
+			err = writeFrameHeader(w, 255, delta) // full_frame
+			if err == nil {
+				err = w.WriteU2(len(locals))
+			}
+			if err == nil {
+				err = writeVerificationTypes(w, locals, classIndex)
+			}
+			if err == nil {
+				err = w.WriteU2(len(stack))
+			}
+			if err == nil {
+				err = writeVerificationTypes(w, stack, classIndex)
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+		previousLocals = locals
+	}
+
+	return w.Bytes(), nil
+}
+
+// compact removes the top entries that are the second half of a long or double, like they are left out in a
+// StackMapTable.
+func compact(types []vtype) []vtype {
+	res := make([]vtype, 0, len(types))
+	for i := 0; i < len(types); i++ {
+		res = append(res, types[i])
+		if types[i].size() == 2 {
+			i++
+		}
+	}
+	return res
+}
+
+func equalTypes(a, b []vtype) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func writeFrameHeader(w *parse.ClassFileWriter, frameType int, delta int) error {
+	err := w.WriteByte(byte(frameType))
+	if err != nil {
+		return err
+	}
+	return w.WriteU2(delta)
+}
+
+// writeVerificationTypes writes one verification_type_info for each of types.
+func writeVerificationTypes(w *parse.ClassFileWriter, types []vtype, classIndex func(name string) int) error {
+	for _, t := range types {
+		var err error
+		switch t.kind {
+		case top:
+			err = w.WriteByte(0)
+		case integer:
+			err = w.WriteByte(1)
+		case float:
+			err = w.WriteByte(2)
+		case double:
+			err = w.WriteByte(3)
+		case long:
+			err = w.WriteByte(4)
+		case null:
+			err = w.WriteByte(5)
+		case uninitializedThis:
+			err = w.WriteByte(6)
+		case reference:
+			err = w.WriteByte(7)
+			if err == nil {
+				err = w.WriteU2(classIndex(t.name))
+			}
+		case uninitialized:
+			err = w.WriteByte(8)
+			if err == nil {
+				err = w.WriteU2(t.offset)
+			}
+		default:
+			err = fmt.Errorf("%s can't be stored in a stack map frame", t)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,260 @@
+package verify
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/PPTide/gojdk/parse"
+)
+
+// hierarchy answers questions about the class hierarchy, it loads the classes it needs with load.
+type hierarchy struct {
+	load    func(name string) (parse.ClassFile, error)
+	lenient bool // assume class types are assignable if it can't be checked, used while computing frames
+	classes map[string]*loadedClass
+}
+
+// loadedClass is what the verifier needs to know about a class.
+type loadedClass struct {
+	super       string // "" for java/lang/Object
+	isInterface bool
+}
+
+// class returns the loaded class called name.
+func (h *hierarchy) class(name string) (*loadedClass, error) {
+	if c, ok := h.classes[name]; ok {
+		return c, nil
+	}
+	if h.load == nil {
+		return nil, fmt.Errorf("no class loader to load %s", name)
+	}
+
+	cf, err := h.load(name)
+	if err != nil {
+		return nil, err
+	}
+	c := &loadedClass{isInterface: cf.AccessFlags.IsInterface()}
+	if cf.SuperClass != 0 {
+		c.super, err = (&method{cf: &cf}).className(cf.SuperClass)
+		if err != nil {
+			return nil, fmt.Errorf("super class of %s: %w", name, err)
+		}
+	}
+	h.classes[name] = c
+	return c, nil
+}
+
+// superclasses returns name followed by its super classes up to java/lang/Object. ok is false if one of them can't
+// be loaded or name is an interface.
+func (h *hierarchy) superclasses(name string) (names []string, ok bool) {
+	for name != "java/lang/Object" {
+		for _, seen := range names {
+			if seen == name {
+				return nil, false // circular, rejected when the class is loaded
+			}
+		}
+		c, err := h.class(name)
+		if err != nil || c.isInterface || c.super == "" {
+			return nil, false
+		}
+		names = append(names, name)
+		name = c.super
+	}
+	return append(names, name), true
+}
+
+// isSubclass reports if from is to or one of its subclasses. Like in JVMS §4.10.1.2 every class is a subclass of
+// an interface, interfaces are only checked when the value is used.
+func (h *hierarchy) isSubclass(from, to string) (bool, error) {
+	target, err := h.class(to)
+	if err != nil {
+		return false, err
+	}
+	if target.isInterface {
+		return true, nil
+	}
+
+	for name, depth := from, 0; name != "java/lang/Object"; depth++ {
+		if name == to {
+			return true, nil
+		}
+		if depth > 65535 {
+			return false, fmt.Errorf("circular super classes of %s", from)
+		}
+		c, err := h.class(name)
+		if err != nil {
+			return false, err
+		}
+		if c.super == "" {
+			return false, errors.New("only java/lang/Object may have no super class")
+		}
+		name = c.super
+	}
+	return false, nil
+}
+
+// isAssignable reports if a value of type from can be used where a value of type to is expected. An error is
+// returned if that depends on classes that can't be loaded.
+func (h *hierarchy) isAssignable(from, to vtype) (bool, error) {
+	if from == to || to.kind == top {
+		return true, nil
+	}
+	switch from.kind {
+	case null:
+		return to.kind == reference, nil
+	case reference:
+		if to.kind != reference {
+			return false, nil
+		}
+		return h.isAssignableReference(from.name, to.name)
+	}
+	return false, nil
+}
+
+func (h *hierarchy) isAssignableReference(from, to string) (bool, error) {
+	if from == to || to == "java/lang/Object" {
+		return true, nil
+	}
+	fromArray, toArray := strings.HasPrefix(from, "["), strings.HasPrefix(to, "[")
+	switch {
+	case fromArray && toArray:
+		fromComponent, toComponent := from[1:], to[1:]
+		fromRef := fromComponent[0] == 'L' || fromComponent[0] == '['
+		toRef := toComponent[0] == 'L' || toComponent[0] == '['
+		if !fromRef || !toRef {
+			return fromComponent == toComponent, nil
+		}
+		return h.isAssignableReference(componentName(fromComponent), componentName(toComponent))
+	case fromArray:
+		return to == cloneable || to == serializable, nil
+	case toArray:
+		return false, nil
+	}
+
+	ok, err := h.isSubclass(from, to)
+	if err != nil {
+		if h.lenient {
+			return true, nil
+		}
+		return false, fmt.Errorf("can't check that %s is assignable to %s: %w", from, to, err)
+	}
+	return ok, nil
+}
+
+// merge returns the most specific type both a and b are assignable to.
+func (h *hierarchy) merge(a, b vtype) vtype {
+	switch {
+	case a == b:
+		return a
+	case a.kind == null && b.kind == reference:
+		return b
+	case b.kind == null && a.kind == reference:
+		return a
+	case a.kind == reference && b.kind == reference:
+		return referenceType(h.mergeReference(a.name, b.name))
+	}
+	return topType
+}
+
+func (h *hierarchy) mergeReference(a, b string) string {
+	if a == b {
+		return a
+	}
+	aArray, bArray := strings.HasPrefix(a, "["), strings.HasPrefix(b, "[")
+	if aArray && bArray {
+		aComponent, bComponent := a[1:], b[1:]
+		aRef := aComponent[0] == 'L' || aComponent[0] == '['
+		bRef := bComponent[0] == 'L' || bComponent[0] == '['
+		if aRef && bRef {
+			merged := h.mergeReference(componentName(aComponent), componentName(bComponent))
+			return arrayOf(merged).name
+		}
+	}
+	if aArray || bArray {
+		return "java/lang/Object"
+	}
+	return h.commonSuperclass(a, b)
+}
+
+// commonSuperclass returns the closest class that both a and b extend. It's java/lang/Object if one of them is an
+// interface or can't be loaded, which is always a correct but maybe too general result.
+func (h *hierarchy) commonSuperclass(a, b string) string {
+	aSupers, ok := h.superclasses(a)
+	if !ok {
+		return "java/lang/Object"
+	}
+	bSupers, ok := h.superclasses(b)
+	if !ok {
+		return "java/lang/Object"
+	}
+	for _, name := range bSupers {
+		for _, super := range aSupers {
+			if name == super {
+				return name
+			}
+		}
+	}
+	return "java/lang/Object"
+}
+
+// isAssignableFrame checks that f can be used where a frame like target is expected.
+func (h *hierarchy) isAssignableFrame(f, target frame) error {
+	if len(f.stack) != len(target.stack) {
+		return fmt.Errorf("stack size %d doesn't match stack map frame size %d", len(f.stack), len(target.stack))
+	}
+	for i := range f.locals {
+		if i >= len(target.locals) {
+			break
+		}
+		ok, err := h.isAssignable(f.locals[i], target.locals[i])
+		if err != nil {
+			return fmt.Errorf("local %d: %w", i, err)
+		}
+		if !ok {
+			return fmt.Errorf("local %d: %s is not assignable to %s", i, f.locals[i], target.locals[i])
+		}
+	}
+	for i := range f.stack {
+		ok, err := h.isAssignable(f.stack[i], target.stack[i])
+		if err != nil {
+			return fmt.Errorf("stack entry %d: %w", i, err)
+		}
+		if !ok {
+			return fmt.Errorf("stack entry %d: %s is not assignable to %s", i, f.stack[i], target.stack[i])
+		}
+	}
+	return nil
+}
+
+// mergeFrame merges other into f and reports if f changed.
+func (h *hierarchy) mergeFrame(f *frame, other frame) (changed bool, err error) {
+	if len(f.stack) != len(other.stack) {
+		return false, fmt.Errorf("inconsistent stack height %d != %d", len(f.stack), len(other.stack))
+	}
+	for i := range f.locals {
+		merged := h.merge(f.locals[i], other.locals[i])
+		if merged != f.locals[i] {
+			f.locals[i] = merged
+			changed = true
+		}
+	}
+	for i := range f.stack {
+		merged := h.merge(f.stack[i], other.stack[i])
+		if merged.kind == top && (f.stack[i].kind != top || other.stack[i].kind != top) {
+			return false, fmt.Errorf("inconsistent stack entry %d: %s and %s", i, f.stack[i], other.stack[i])
+		}
+		if merged != f.stack[i] {
+			f.stack[i] = merged
+			changed = true
+		}
+	}
+	// A long or double whose second half was merged away can't be used anymore.
+	for i := range f.locals {
+		if f.locals[i].size() == 2 && (i+1 >= len(f.locals) || f.locals[i+1].kind != top) {
+			f.locals[i] = topType
+			changed = true
+		}
+	}
+	return
+}
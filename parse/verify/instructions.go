@@ -0,0 +1,986 @@
+package verify
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/PPTide/gojdk/parse"
+	"github.com/PPTide/gojdk/parse/disasm"
+)
+
+// instructionLength returns the length of the instruction at pc including its operands.
+func instructionLength(code []byte, pc int) (int, error) {
+	instruction, err := disasm.DecodeInstruction(code, pc, nil)
+	return instruction.Length, err
+}
+
+func u1(code []byte, pc int) int {
+	return int(code[pc])
+}
+
+func u2(code []byte, pc int) int {
+	return int(code[pc])<<8 | int(code[pc+1])
+}
+
+func s2(code []byte, pc int) int {
+	return int(int16(u2(code, pc)))
+}
+
+func s4(code []byte, pc int) int {
+	return int(int32(uint32(code[pc])<<24 | uint32(code[pc+1])<<16 | uint32(code[pc+2])<<8 | uint32(code[pc+3])))
+}
+
+// ------------------- Constant Pool ---------------------
+
+func (m *method) constant(index int) (parse.CpInfo, error) {
+	if index < 1 || index > len(m.cf.ConstantPool) {
+		return nil, fmt.Errorf("constant pool index %d out of range", index)
+	}
+	return m.cf.ConstantPool[index-1], nil
+}
+
+func (m *method) utf8(index int) (string, error) {
+	info, err := m.constant(index)
+	if err != nil {
+		return "", err
+	}
+	utf8, ok := info.(parse.ConstantUtf8Info)
+	if !ok {
+		return "", fmt.Errorf("constant pool entry %d is not a ConstantUtf8Info", index)
+	}
+	return utf8.Text, nil
+}
+
+func (m *method) className(index int) (string, error) {
+	info, err := m.constant(index)
+	if err != nil {
+		return "", err
+	}
+	class, ok := info.(parse.ConstantClassInfo)
+	if !ok {
+		return "", fmt.Errorf("constant pool entry %d is %s instead of ConstantClassInfo", index, reflect.TypeOf(info))
+	}
+	return m.utf8(class.NameIndex)
+}
+
+func (m *method) nameAndType(index int) (name string, descriptor string, err error) {
+	info, err := m.constant(index)
+	if err != nil {
+		return
+	}
+	nat, ok := info.(parse.ConstantNameAndTypeInfo)
+	if !ok {
+		err = fmt.Errorf("constant pool entry %d is %s instead of ConstantNameAndTypeInfo", index, reflect.TypeOf(info))
+		return
+	}
+	name, err = m.utf8(nat.NameIndex)
+	if err != nil {
+		return
+	}
+	descriptor, err = m.utf8(nat.DescriptorIndex)
+	return
+}
+
+// memberRef returns the class, name and descriptor of a field or method reference and if it references an
+// interface method.
+func (m *method) memberRef(index int) (class string, name string, descriptor string, isInterface bool, err error) {
+	info, err := m.constant(index)
+	if err != nil {
+		return
+	}
+
+	var classIndex, natIndex int
+	switch t := info.(type) {
+	case parse.ConstantFieldrefInfo:
+		classIndex, natIndex = t.ClassIndex, t.NameAndTypeIndex
+	case parse.ConstantMethodrefInfo:
+		classIndex, natIndex = t.ClassIndex, t.NameAndTypeIndex
+	case parse.ConstantInterfaceMethodrefInfo:
+		classIndex, natIndex = t.ClassIndex, t.NameAndTypeIndex
+		isInterface = true
+	default:
+		err = fmt.Errorf("constant pool entry %d is %s instead of a member reference", index, reflect.TypeOf(info))
+		return
+	}
+
+	class, err = m.className(classIndex)
+	if err != nil {
+		return
+	}
+	name, descriptor, err = m.nameAndType(natIndex)
+	return
+}
+
+// loadableType returns the type ldc, ldc_w and ldc2_w push for the constant at index.
+func (m *method) loadableType(index int) (vtype, error) {
+	info, err := m.constant(index)
+	if err != nil {
+		return vtype{}, err
+	}
+
+	version := m.cf.MajorVersion
+	switch t := info.(type) {
+	case parse.ConstantIntegerInfo:
+		return intType, nil
+	case parse.ConstantFloatInfo:
+		return floatType, nil
+	case parse.ConstantLongInfo:
+		return longType, nil
+	case parse.ConstantDoubleInfo:
+		return doubleType, nil
+	case parse.ConstantStringInfo:
+		return stringType, nil
+	case parse.ConstantClassInfo:
+		if version >= 49 {
+			return classType, nil
+		}
+	case parse.ConstantMethodTypeInfo:
+		if version >= 51 {
+			return methodType, nil
+		}
+	case parse.ConstantMethodHandleInfo:
+		if version >= 51 {
+			return handleType, nil
+		}
+	case parse.ConstantDynamicInfo:
+		if version >= 55 {
+			_, descriptor, err := m.nameAndType(t.NameAndTypeIndex)
+			if err != nil {
+				return vtype{}, err
+			}
+			dynamicType, ok := typeFromDescriptor(descriptor)
+			if !ok {
+				return vtype{}, fmt.Errorf("invalid descriptor %q of dynamic constant", descriptor)
+			}
+			return dynamicType, nil
+		}
+	}
+	return vtype{}, fmt.Errorf("constant pool entry %d (%s) is not loadable in version %d", index, reflect.TypeOf(info), version)
+}
+
+// ------------------- Frame Operations ---------------------
+
+func (m *method) push(f *frame, t vtype) error {
+	f.stack = append(f.stack, t)
+	if t.size() == 2 {
+		f.stack = append(f.stack, topType)
+	}
+	if len(f.stack) > m.maxStack {
+		return fmt.Errorf("operand stack overflow, max_stack is %d", m.maxStack)
+	}
+	return nil
+}
+
+// popValue pops one value of size slots without checking its type.
+func popValue(f *frame, size int) (vtype, error) {
+	if len(f.stack) < size {
+		return vtype{}, errors.New("operand stack underflow")
+	}
+	t := f.stack[len(f.stack)-size]
+	if t.size() != size || splits(f, len(f.stack)-size) {
+		return vtype{}, fmt.Errorf("expected a value of size %d on the stack but found %s", size, t)
+	}
+	f.stack = f.stack[:len(f.stack)-size]
+	return t, nil
+}
+
+// pop pops a value that has to be assignable to want.
+func (m *method) pop(f *frame, want vtype) (vtype, error) {
+	t, err := popValue(f, want.size())
+	if err != nil {
+		return t, err
+	}
+	ok, err := m.classes.isAssignable(t, want)
+	if err != nil {
+		return t, err
+	}
+	if !ok {
+		return t, fmt.Errorf("expected %s on the stack but found %s", want, t)
+	}
+	return t, nil
+}
+
+// popReference pops any reference, including uninitialized ones.
+func popReference(f *frame) (vtype, error) {
+	t, err := popValue(f, 1)
+	if err != nil {
+		return t, err
+	}
+	if !t.isReference() {
+		return t, fmt.Errorf("expected a reference on the stack but found %s", t)
+	}
+	return t, nil
+}
+
+// popInitialized pops an initialized reference.
+func popInitialized(f *frame) (vtype, error) {
+	t, err := popReference(f)
+	if err == nil && (t.kind == uninitialized || t.kind == uninitializedThis) {
+		err = fmt.Errorf("expected an initialized reference but found %s", t)
+	}
+	return t, err
+}
+
+// popArray pops an array whose type has to be one of types, or null.
+func popArray(f *frame, types ...string) (vtype, error) {
+	t, err := popValue(f, 1)
+	if err != nil {
+		return t, err
+	}
+	if t.kind == null {
+		return t, nil
+	}
+	if t.kind == reference {
+		for _, name := range types {
+			if t.name == name {
+				return t, nil
+			}
+		}
+	}
+	return t, fmt.Errorf("expected an array of type %s but found %s", strings.Join(types, " or "), t)
+}
+
+// popReferenceArray pops an array of references, or null.
+func popReferenceArray(f *frame) (vtype, error) {
+	t, err := popValue(f, 1)
+	if err != nil {
+		return t, err
+	}
+	if t.kind == null || (t.isArray() && (t.name[1] == 'L' || t.name[1] == '[')) {
+		return t, nil
+	}
+	return t, fmt.Errorf("expected an array of references but found %s", t)
+}
+
+// splits reports if a boundary below the stack entry at index k would split a long or double.
+func splits(f *frame, k int) bool {
+	return k > 0 && k <= len(f.stack) && f.stack[k-1].size() == 2
+}
+
+// dup duplicates the top n slots and inserts the copy depth slots further down.
+func (m *method) dup(f *frame, n int, depth int) error {
+	if len(f.stack) < n+depth {
+		return errors.New("operand stack underflow")
+	}
+	if splits(f, len(f.stack)-n) || splits(f, len(f.stack)-n-depth) {
+		return errors.New("stack manipulation splits a long or double")
+	}
+
+	values := append([]vtype{}, f.stack[len(f.stack)-n:]...)
+	insert := len(f.stack) - n - depth
+	stack := append([]vtype{}, f.stack[:insert]...)
+	stack = append(stack, values...)
+	stack = append(stack, f.stack[insert:]...)
+	f.stack = stack
+
+	if len(f.stack) > m.maxStack {
+		return fmt.Errorf("operand stack overflow, max_stack is %d", m.maxStack)
+	}
+	return nil
+}
+
+func (m *method) load(f *frame, index int, want kind) error {
+	if index < 0 || index >= m.maxLocals {
+		return fmt.Errorf("local %d out of range, max_locals is %d", index, m.maxLocals)
+	}
+	t := f.locals[index]
+	switch {
+	case want == reference && t.isReference():
+	case t.kind == want:
+	default:
+		return fmt.Errorf("expected local %d to be %s but found %s", index, vtype{kind: want, name: "reference"}, t)
+	}
+	return m.push(f, t)
+}
+
+func (m *method) store(f *frame, index int, t vtype) error {
+	if index < 0 || index+t.size() > m.maxLocals {
+		return fmt.Errorf("local %d out of range, max_locals is %d", index, m.maxLocals)
+	}
+	if index > 0 && f.locals[index-1].size() == 2 {
+		f.locals[index-1] = topType
+	}
+	f.locals[index] = t
+	if t.size() == 2 {
+		f.locals[index+1] = topType
+	}
+	return nil
+}
+
+// storeFromStack pops a value of kind want and stores it in local index.
+func (m *method) storeFromStack(f *frame, index int, want kind) error {
+	var t vtype
+	var err error
+	switch want {
+	case reference:
+		t, err = popValue(f, 1)
+		if err == nil && !t.isReference() && t.kind != returnAddress {
+			err = fmt.Errorf("expected a reference on the stack but found %s", t)
+		}
+	default:
+		t, err = m.pop(f, vtype{kind: want})
+	}
+	if err != nil {
+		return err
+	}
+	return m.store(f, index, t)
+}
+
+// initialize replaces every occurrence of the uninitialized type t with the initialized type.
+func (m *method) initialize(f *frame, t vtype) error {
+	var initialized vtype
+	switch t.kind {
+	case uninitializedThis:
+		initialized = referenceType(m.class)
+	case uninitialized:
+		if !m.isStart[t.offset] || m.code[t.offset] != 187 {
+			return fmt.Errorf("%s doesn't point to a new instruction", t)
+		}
+		name, err := m.className(u2(m.code, t.offset+1))
+		if err != nil {
+			return err
+		}
+		initialized = referenceType(name)
+	default:
+		return fmt.Errorf("expected an uninitialized reference for <init> but found %s", t)
+	}
+
+	for i := range f.locals {
+		if f.locals[i] == t {
+			f.locals[i] = initialized
+		}
+	}
+	for i := range f.stack {
+		if f.stack[i] == t {
+			f.stack[i] = initialized
+		}
+	}
+	return nil
+}
+
+// ------------------- Instructions ---------------------
+
+// primitive types of the instruction families that exist for int, long, float, double and reference
+var familyTypes = [...]vtype{intType, longType, floatType, doubleType}
+
+// step simulates the instruction at pc on f. It returns the pc of the next instruction, the branch targets and if
+// the next instruction is reached by falling through.
+func (m *method) step(pc int, f *frame) (next int, targets []int, fallsThrough bool, err error) {
+	length, err := instructionLength(m.code, pc)
+	if err != nil {
+		return
+	}
+	next = pc + length
+	fallsThrough = true
+	code := m.code
+	op := code[pc]
+
+	branch := func(target int) {
+		if !m.isStart[target] {
+			err = fmt.Errorf("branch target %d is not the start of an instruction", target)
+			return
+		}
+		targets = append(targets, target)
+	}
+
+	switch {
+	case op == 0: // nop
+	case op == 1: // aconst_null
+		err = m.push(f, nullType)
+	case op >= 2 && op <= 8: // iconst_<i>
+		err = m.push(f, intType)
+	case op == 9 || op == 10: // lconst_<l>
+		err = m.push(f, longType)
+	case op >= 11 && op <= 13: // fconst_<f>
+		err = m.push(f, floatType)
+	case op == 14 || op == 15: // dconst_<d>
+		err = m.push(f, doubleType)
+	case op == 16 || op == 17: // bipush, sipush
+		err = m.push(f, intType)
+	case op == 18 || op == 19 || op == 20: // ldc, ldc_w, ldc2_w
+		index := u1(code, pc+1)
+		if op != 18 {
+			index = u2(code, pc+1)
+		}
+		var t vtype
+		t, err = m.loadableType(index)
+		if err != nil {
+			return
+		}
+		if (op == 20) != (t.size() == 2) {
+			err = fmt.Errorf("constant of type %s can't be loaded by opcode %d", t, op)
+			return
+		}
+		err = m.push(f, t)
+	case op >= 21 && op <= 24: // iload, lload, fload, dload
+		err = m.load(f, u1(code, pc+1), familyTypes[op-21].kind)
+	case op == 25: // aload
+		err = m.load(f, u1(code, pc+1), reference)
+	case op >= 26 && op <= 41: // <t>load_<n>
+		err = m.load(f, int(op-26)%4, familyTypes[(op-26)/4].kind)
+	case op >= 42 && op <= 45: // aload_<n>
+		err = m.load(f, int(op-42), reference)
+	case op >= 46 && op <= 53: // <t>aload
+		if _, err = m.pop(f, intType); err != nil {
+			return
+		}
+		var array vtype
+		switch op {
+		case 46:
+			_, err = popArray(f, "[I")
+			array = intType
+		case 47:
+			_, err = popArray(f, "[J")
+			array = longType
+		case 48:
+			_, err = popArray(f, "[F")
+			array = floatType
+		case 49:
+			_, err = popArray(f, "[D")
+			array = doubleType
+		case 50: // aaload
+			var t vtype
+			t, err = popReferenceArray(f)
+			if err != nil {
+				return
+			}
+			array = nullType
+			if t.kind != null {
+				array, _ = t.component()
+			}
+		case 51:
+			_, err = popArray(f, "[B", "[Z")
+			array = intType
+		case 52:
+			_, err = popArray(f, "[C")
+			array = intType
+		case 53:
+			_, err = popArray(f, "[S")
+			array = intType
+		}
+		if err != nil {
+			return
+		}
+		err = m.push(f, array)
+	case op >= 54 && op <= 57: // istore, lstore, fstore, dstore
+		err = m.storeFromStack(f, u1(code, pc+1), familyTypes[op-54].kind)
+	case op == 58: // astore
+		err = m.storeFromStack(f, u1(code, pc+1), reference)
+	case op >= 59 && op <= 74: // <t>store_<n>
+		err = m.storeFromStack(f, int(op-59)%4, familyTypes[(op-59)/4].kind)
+	case op >= 75 && op <= 78: // astore_<n>
+		err = m.storeFromStack(f, int(op-75), reference)
+	case op >= 79 && op <= 86: // <t>astore
+		switch op {
+		case 79:
+			err = m.popArrayStore(f, intType, "[I")
+		case 80:
+			err = m.popArrayStore(f, longType, "[J")
+		case 81:
+			err = m.popArrayStore(f, floatType, "[F")
+		case 82:
+			err = m.popArrayStore(f, doubleType, "[D")
+		case 83: // aastore, the type of the value is checked at runtime
+			if _, err = popReference(f); err != nil {
+				return
+			}
+			if _, err = m.pop(f, intType); err != nil {
+				return
+			}
+			_, err = popReferenceArray(f)
+		case 84:
+			err = m.popArrayStore(f, intType, "[B", "[Z")
+		case 85:
+			err = m.popArrayStore(f, intType, "[C")
+		case 86:
+			err = m.popArrayStore(f, intType, "[S")
+		}
+	case op == 87: // pop
+		_, err = popValue(f, 1)
+	case op == 88: // pop2
+		if len(f.stack) < 2 || splits(f, len(f.stack)-2) {
+			err = errors.New("pop2 needs two slots that don't split a long or double")
+			return
+		}
+		f.stack = f.stack[:len(f.stack)-2]
+	case op == 89: // dup
+		err = m.dup(f, 1, 0)
+	case op == 90: // dup_x1
+		err = m.dup(f, 1, 1)
+	case op == 91: // dup_x2
+		err = m.dup(f, 1, 2)
+	case op == 92: // dup2
+		err = m.dup(f, 2, 0)
+	case op == 93: // dup2_x1
+		err = m.dup(f, 2, 1)
+	case op == 94: // dup2_x2
+		err = m.dup(f, 2, 2)
+	case op == 95: // swap
+		if len(f.stack) < 2 || splits(f, len(f.stack)-1) || splits(f, len(f.stack)-2) {
+			err = errors.New("swap needs two values of size 1")
+			return
+		}
+		n := len(f.stack)
+		f.stack[n-1], f.stack[n-2] = f.stack[n-2], f.stack[n-1]
+	case op >= 96 && op <= 115: // <t>add, <t>sub, <t>mul, <t>div, <t>rem
+		t := familyTypes[(op-96)%4]
+		err = m.binary(f, t, t, t)
+	case op >= 116 && op <= 119: // <t>neg
+		t := familyTypes[op-116]
+		if _, err = m.pop(f, t); err != nil {
+			return
+		}
+		err = m.push(f, t)
+	case op >= 120 && op <= 125: // ishl, lshl, ishr, lshr, iushr, lushr
+		t := familyTypes[(op-120)%2]
+		err = m.binary(f, t, intType, t)
+	case op >= 126 && op <= 131: // iand, land, ior, lor, ixor, lxor
+		t := familyTypes[(op-126)%2]
+		err = m.binary(f, t, t, t)
+	case op == 132: // iinc
+		index := u1(code, pc+1)
+		if index >= m.maxLocals || f.locals[index] != intType {
+			err = fmt.Errorf("iinc needs local %d to be int", index)
+		}
+	case op >= 133 && op <= 144: // i2l, i2f, i2d, l2i, l2f, l2d, f2i, f2l, f2d, d2i, d2l, d2f
+		from := familyTypes[(op-133)/3]
+		to := []vtype{longType, floatType, doubleType, intType, floatType, doubleType, intType, longType,
+			doubleType, intType, longType, floatType}[op-133]
+		if _, err = m.pop(f, from); err != nil {
+			return
+		}
+		err = m.push(f, to)
+	case op >= 145 && op <= 147: // i2b, i2c, i2s
+		if _, err = m.pop(f, intType); err != nil {
+			return
+		}
+		err = m.push(f, intType)
+	case op == 148: // lcmp
+		err = m.binary(f, longType, longType, intType)
+	case op == 149 || op == 150: // fcmpl, fcmpg
+		err = m.binary(f, floatType, floatType, intType)
+	case op == 151 || op == 152: // dcmpl, dcmpg
+		err = m.binary(f, doubleType, doubleType, intType)
+	case op >= 153 && op <= 158: // if<cond>
+		if _, err = m.pop(f, intType); err != nil {
+			return
+		}
+		branch(pc + s2(code, pc+1))
+	case op >= 159 && op <= 164: // if_icmp<cond>
+		if _, err = m.pop(f, intType); err != nil {
+			return
+		}
+		if _, err = m.pop(f, intType); err != nil {
+			return
+		}
+		branch(pc + s2(code, pc+1))
+	case op == 165 || op == 166: // if_acmpeq, if_acmpne
+		if _, err = popReference(f); err != nil {
+			return
+		}
+		if _, err = popReference(f); err != nil {
+			return
+		}
+		branch(pc + s2(code, pc+1))
+	case op == 167 || op == 200: // goto, goto_w
+		if op == 167 {
+			branch(pc + s2(code, pc+1))
+		} else {
+			branch(pc + s4(code, pc+1))
+		}
+		fallsThrough = false
+	case op == 168 || op == 201: // jsr, jsr_w
+		if err = m.push(f, vtype{kind: returnAddress}); err != nil {
+			return
+		}
+		if op == 168 {
+			branch(pc + s2(code, pc+1))
+		} else {
+			branch(pc + s4(code, pc+1))
+		}
+		fallsThrough = false
+	case op == 169: // ret
+		index := u1(code, pc+1)
+		if index >= m.maxLocals || f.locals[index].kind != returnAddress {
+			err = fmt.Errorf("ret needs local %d to be a returnAddress", index)
+			return
+		}
+		targets = append(targets, m.jsrReturns...)
+		fallsThrough = false
+	case op == 170 || op == 171: // tableswitch, lookupswitch
+		if _, err = m.pop(f, intType); err != nil {
+			return
+		}
+		start := pc + 1 + (3-pc%4)%4
+		branch(pc + s4(code, start))
+		if op == 170 {
+			low, high := s4(code, start+4), s4(code, start+8)
+			for i := 0; i <= high-low && err == nil; i++ {
+				branch(pc + s4(code, start+12+4*i))
+			}
+		} else {
+			pairs := s4(code, start+4)
+			for i := 0; i < pairs && err == nil; i++ {
+				if i > 0 && s4(code, start+8+8*i) <= s4(code, start+8+8*(i-1)) {
+					err = errors.New("lookupswitch keys not sorted")
+					return
+				}
+				branch(pc + s4(code, start+12+8*i))
+			}
+		}
+		fallsThrough = false
+	case op >= 172 && op <= 177: // <t>return, return
+		err = m.checkReturn(f, op)
+		fallsThrough = false
+	case op >= 178 && op <= 181: // getstatic, putstatic, getfield, putfield
+		err = m.fieldInstruction(f, op, u2(code, pc+1))
+	case op >= 182 && op <= 186: // invokevirtual, invokespecial, invokestatic, invokeinterface, invokedynamic
+		err = m.invoke(f, pc, op)
+	case op == 187: // new
+		var name string
+		name, err = m.className(u2(code, pc+1))
+		if err != nil {
+			return
+		}
+		if strings.HasPrefix(name, "[") {
+			err = fmt.Errorf("new can't create the array %s", name)
+			return
+		}
+		t := vtype{kind: uninitialized, offset: pc}
+		for _, local := range f.locals {
+			if local == t {
+				err = fmt.Errorf("%s is already in a local variable", t)
+				return
+			}
+		}
+		err = m.push(f, t)
+	case op == 188: // newarray
+		atype := u1(code, pc+1)
+		if atype < 4 || atype > 11 {
+			err = fmt.Errorf("invalid newarray type %d", atype)
+			return
+		}
+		if _, err = m.pop(f, intType); err != nil {
+			return
+		}
+		err = m.push(f, referenceType("["+string("ZCFDBSIJ"[atype-4])))
+	case op == 189: // anewarray
+		var name string
+		name, err = m.className(u2(code, pc+1))
+		if err != nil {
+			return
+		}
+		if _, err = m.pop(f, intType); err != nil {
+			return
+		}
+		t := arrayOf(name)
+		if strings.Count(t.name, "[") > 255 {
+			err = errors.New("array has more than 255 dimensions")
+			return
+		}
+		err = m.push(f, t)
+	case op == 190: // arraylength
+		var t vtype
+		t, err = popValue(f, 1)
+		if err != nil {
+			return
+		}
+		if t.kind != null && !t.isArray() {
+			err = fmt.Errorf("arraylength needs an array but found %s", t)
+			return
+		}
+		err = m.push(f, intType)
+	case op == 191: // athrow
+		_, err = m.pop(f, throwable)
+		fallsThrough = false
+	case op == 192: // checkcast
+		var name string
+		name, err = m.className(u2(code, pc+1))
+		if err != nil {
+			return
+		}
+		if _, err = popInitialized(f); err != nil {
+			return
+		}
+		err = m.push(f, typeFromClassName(name))
+	case op == 193: // instanceof
+		if _, err = m.className(u2(code, pc+1)); err != nil {
+			return
+		}
+		if _, err = popInitialized(f); err != nil {
+			return
+		}
+		err = m.push(f, intType)
+	case op == 194 || op == 195: // monitorenter, monitorexit
+		_, err = popInitialized(f)
+	case op == 196: // wide
+		err = m.wide(f, pc)
+	case op == 197: // multianewarray
+		var name string
+		name, err = m.className(u2(code, pc+1))
+		if err != nil {
+			return
+		}
+		dimensions := u1(code, pc+3)
+		if dimensions < 1 || dimensions > len(name)-len(strings.TrimLeft(name, "[")) {
+			err = fmt.Errorf("invalid dimensions %d for %s", dimensions, name)
+			return
+		}
+		for i := 0; i < dimensions; i++ {
+			if _, err = m.pop(f, intType); err != nil {
+				return
+			}
+		}
+		err = m.push(f, referenceType(name))
+	case op == 198 || op == 199: // ifnull, ifnonnull
+		if _, err = popReference(f); err != nil {
+			return
+		}
+		branch(pc + s2(code, pc+1))
+	default:
+		err = fmt.Errorf("illegal opcode %d", op)
+	}
+
+	return
+}
+
+// binary pops two values of type a and b (b being on top) and pushes a value of type result.
+func (m *method) binary(f *frame, a vtype, b vtype, result vtype) error {
+	if _, err := m.pop(f, b); err != nil {
+		return err
+	}
+	if _, err := m.pop(f, a); err != nil {
+		return err
+	}
+	return m.push(f, result)
+}
+
+// popArrayStore pops the value, index and array of a <t>astore instruction.
+func (m *method) popArrayStore(f *frame, value vtype, arrays ...string) error {
+	if _, err := m.pop(f, value); err != nil {
+		return err
+	}
+	if _, err := m.pop(f, intType); err != nil {
+		return err
+	}
+	_, err := popArray(f, arrays...)
+	return err
+}
+
+func (m *method) wide(f *frame, pc int) error {
+	op := m.code[pc+1]
+	index := u2(m.code, pc+2)
+
+	switch {
+	case op >= 21 && op <= 24:
+		return m.load(f, index, familyTypes[op-21].kind)
+	case op == 25:
+		return m.load(f, index, reference)
+	case op >= 54 && op <= 57:
+		return m.storeFromStack(f, index, familyTypes[op-54].kind)
+	case op == 58:
+		return m.storeFromStack(f, index, reference)
+	case op == 132:
+		if index >= m.maxLocals || f.locals[index] != intType {
+			return fmt.Errorf("iinc needs local %d to be int", index)
+		}
+		return nil
+	}
+	return fmt.Errorf("wide %d is not supported", op)
+}
+
+func (m *method) checkReturn(f *frame, op byte) error {
+	if op == 177 { // return
+		if m.returnType != "V" {
+			return fmt.Errorf("return in a method returning %s", m.returnType)
+		}
+		if m.name == "<init>" && f.flagThisUninit() {
+			return errors.New("<init> returns without calling another <init> method")
+		}
+		return nil
+	}
+
+	want, ok := typeFromDescriptor(m.returnType)
+	if !ok {
+		return fmt.Errorf("<t>return in a method returning %s", m.returnType)
+	}
+	switch op {
+	case 172: // ireturn
+		ok = want == intType
+	case 173: // lreturn
+		ok = want == longType
+	case 174: // freturn
+		ok = want == floatType
+	case 175: // dreturn
+		ok = want == doubleType
+	case 176: // areturn
+		ok = want.kind == reference
+	}
+	if !ok {
+		return fmt.Errorf("return opcode %d doesn't match return type %s", op, m.returnType)
+	}
+	_, err := m.pop(f, want)
+	return err
+}
+
+func (m *method) fieldInstruction(f *frame, op byte, index int) error {
+	info, err := m.constant(index)
+	if err != nil {
+		return err
+	}
+	if _, ok := info.(parse.ConstantFieldrefInfo); !ok {
+		return fmt.Errorf("constant pool entry %d is %s instead of ConstantFieldrefInfo", index, reflect.TypeOf(info))
+	}
+	class, _, descriptor, _, err := m.memberRef(index)
+	if err != nil {
+		return err
+	}
+	fieldType, ok := typeFromDescriptor(descriptor)
+	if !ok {
+		return fmt.Errorf("invalid field descriptor %q", descriptor)
+	}
+
+	switch op {
+	case 178: // getstatic
+		return m.push(f, fieldType)
+	case 179: // putstatic
+		_, err = m.pop(f, fieldType)
+		return err
+	case 180: // getfield
+		if _, err = m.pop(f, referenceType(class)); err != nil {
+			return err
+		}
+		return m.push(f, fieldType)
+	}
+
+	// putfield
+	if _, err = m.pop(f, fieldType); err != nil {
+		return err
+	}
+	objectref, err := popReference(f)
+	if err != nil {
+		return err
+	}
+	// fields declared in this class may be set before calling super.<init>
+	if objectref.kind == uninitializedThis && class == m.class {
+		return nil
+	}
+	assignable, err := m.classes.isAssignable(objectref, referenceType(class))
+	if err != nil {
+		return err
+	}
+	if !assignable {
+		return fmt.Errorf("expected %s on the stack but found %s", class, objectref)
+	}
+	return nil
+}
+
+func (m *method) invoke(f *frame, pc int, op byte) error {
+	index := u2(m.code, pc+1)
+
+	var class, name, descriptor string
+	var err error
+	if op == 186 { // invokedynamic
+		if m.code[pc+3] != 0 || m.code[pc+4] != 0 {
+			return errors.New("invokedynamic needs two zero bytes")
+		}
+		info, err := m.constant(index)
+		if err != nil {
+			return err
+		}
+		dynamic, ok := info.(parse.ConstantInvokeDynamicInfo)
+		if !ok {
+			return fmt.Errorf("constant pool entry %d is %s instead of ConstantInvokeDynamicInfo", index, reflect.TypeOf(info))
+		}
+		name, descriptor, err = m.nameAndType(dynamic.NameAndTypeIndex)
+		if err != nil {
+			return err
+		}
+	} else {
+		var isInterface bool
+		info, err := m.constant(index)
+		if err != nil {
+			return err
+		}
+		if _, ok := info.(parse.ConstantFieldrefInfo); ok {
+			return fmt.Errorf("constant pool entry %d is a field reference", index)
+		}
+		class, name, descriptor, isInterface, err = m.memberRef(index)
+		if err != nil {
+			return err
+		}
+		switch {
+		case op == 182 && isInterface:
+			return errors.New("invokevirtual of an interface method")
+		case op == 185 && !isInterface:
+			return errors.New("invokeinterface of a class method")
+		case (op == 183 || op == 184) && isInterface && m.cf.MajorVersion < 52:
+			return errors.New("invokespecial and invokestatic of interface methods need version 52.0")
+		}
+	}
+
+	if name == "<clinit>" || (name == "<init>" && op != 183) {
+		return fmt.Errorf("%s can't be invoked by opcode %d", name, op)
+	}
+
+	params, ret, err := parseMethodDescriptor(descriptor)
+	if err != nil {
+		return err
+	}
+
+	slots := 0
+	for i := len(params) - 1; i >= 0; i-- {
+		t, ok := typeFromDescriptor(params[i])
+		if !ok {
+			return fmt.Errorf("invalid parameter type %s", params[i])
+		}
+		if _, err = m.pop(f, t); err != nil {
+			return err
+		}
+		slots += t.size()
+	}
+
+	if op == 185 { // invokeinterface
+		if count := u1(m.code, pc+3); count != slots+1 {
+			return fmt.Errorf("invokeinterface count %d doesn't match the %d argument slots", count, slots+1)
+		}
+		if m.code[pc+4] != 0 {
+			return errors.New("invokeinterface needs a zero byte")
+		}
+	}
+
+	switch {
+	case op == 183 && name == "<init>":
+		if ret != "V" {
+			return errors.New("<init> must return void")
+		}
+		receiver, err := popReference(f)
+		if err != nil {
+			return err
+		}
+		return m.initialize(f, receiver)
+	case op != 184 && op != 186:
+		receiver, err := popInitialized(f)
+		if err != nil {
+			return err
+		}
+		ok, err := m.classes.isAssignable(receiver, referenceType(class))
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return fmt.Errorf("expected %s as receiver but found %s", class, receiver)
+		}
+	}
+
+	if ret == "V" {
+		return nil
+	}
+	t, ok := typeFromDescriptor(ret)
+	if !ok {
+		return fmt.Errorf("invalid return type %s", ret)
+	}
+	return m.push(f, t)
+}
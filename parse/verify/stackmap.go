@@ -0,0 +1,184 @@
+package verify
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/PPTide/gojdk/parse"
+)
+
+// readVerificationType reads one verification_type_info of a StackMapTable.
+func (m *method) readVerificationType(r *parse.ClassFileReader) (vtype, error) {
+	tag, err := r.ReadByte()
+	if err != nil {
+		return vtype{}, err
+	}
+
+	switch tag {
+	case 0: // ITEM_Top
+		return topType, nil
+	case 1: // ITEM_Integer
+		return intType, nil
+	case 2: // ITEM_Float
+		return floatType, nil
+	case 3: // ITEM_Double
+		return doubleType, nil
+	case 4: // ITEM_Long
+		return longType, nil
+	case 5: // ITEM_Null
+		return nullType, nil
+	case 6: // ITEM_UninitializedThis
+		return vtype{kind: uninitializedThis}, nil
+	case 7: // ITEM_Object
+		index, err := r.ReadU2()
+		if err != nil {
+			return vtype{}, err
+		}
+		name, err := m.className(index)
+		if err != nil {
+			return vtype{}, err
+		}
+		return referenceType(name), nil
+	case 8: // ITEM_Uninitialized
+		offset, err := r.ReadU2()
+		if err != nil {
+			return vtype{}, err
+		}
+		return vtype{kind: uninitialized, offset: offset}, nil
+	}
+	return vtype{}, fmt.Errorf("unknown verification type tag %d", tag)
+}
+
+// readVerificationTypes reads count verification types and expands long and double to two entries.
+func (m *method) readVerificationTypes(r *parse.ClassFileReader, count int) ([]vtype, error) {
+	types := make([]vtype, 0, count)
+	for i := 0; i < count; i++ {
+		t, err := m.readVerificationType(r)
+		if err != nil {
+			return nil, err
+		}
+		types = append(types, t)
+		if t.size() == 2 {
+			types = append(types, topType)
+		}
+	}
+	return types, nil
+}
+
+// readStackMapTable decodes the StackMapTable attribute (JVMS §4.7.4) into the frames at each pc.
+func (m *method) readStackMapTable(info []byte) (map[int]frame, error) {
+	r := (*parse.ClassFileReader)(bytes.NewReader(info))
+
+	count, err := r.ReadU2()
+	if err != nil {
+		return nil, err
+	}
+
+	frames := make(map[int]frame, count)
+	previous := m.initialFrame.copy()
+	// locals without the padding to max_locals, needed for chop and append frames
+	locals := trimLocals(previous.locals)
+	pc := -1
+
+	for i := 0; i < count; i++ {
+		frameType, err := r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+
+		var delta int
+		var stack []vtype
+		switch {
+		case frameType <= 63: // same_frame
+			delta = int(frameType)
+		case frameType <= 127: // same_locals_1_stack_item_frame
+			delta = int(frameType) - 64
+			stack, err = m.readVerificationTypes(r, 1)
+		case frameType < 247:
+			return nil, fmt.Errorf("reserved stack map frame type %d", frameType)
+		case frameType == 247: // same_locals_1_stack_item_frame_extended
+			delta, err = r.ReadU2()
+			if err != nil {
+				return nil, err
+			}
+			stack, err = m.readVerificationTypes(r, 1)
+		case frameType <= 250: // chop_frame
+			delta, err = r.ReadU2()
+			for k := 0; k < 251-int(frameType); k++ {
+				if len(locals) == 0 {
+					return nil, fmt.Errorf("chop frame removes more locals than there are")
+				}
+				locals = locals[:len(locals)-1]
+				if len(locals) > 0 && locals[len(locals)-1].size() == 2 {
+					locals = locals[:len(locals)-1]
+				}
+			}
+		case frameType == 251: // same_frame_extended
+			delta, err = r.ReadU2()
+		case frameType <= 254: // append_frame
+			delta, err = r.ReadU2()
+			if err != nil {
+				return nil, err
+			}
+			var appended []vtype
+			appended, err = m.readVerificationTypes(r, int(frameType)-251)
+			locals = append(locals, appended...)
+		default: // full_frame
+			delta, err = r.ReadU2()
+			if err != nil {
+				return nil, err
+			}
+			var n int
+			n, err = r.ReadU2()
+			if err != nil {
+				return nil, err
+			}
+			locals, err = m.readVerificationTypes(r, n)
+			if err != nil {
+				return nil, err
+			}
+			n, err = r.ReadU2()
+			if err != nil {
+				return nil, err
+			}
+			stack, err = m.readVerificationTypes(r, n)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		pc += delta + 1
+		if pc >= len(m.code) {
+			return nil, fmt.Errorf("stack map frame at pc %d after the end of the code", pc)
+		}
+		if _, ok := frames[pc]; ok {
+			return nil, fmt.Errorf("duplicate stack map frame at pc %d", pc)
+		}
+		if len(locals) > m.maxLocals {
+			return nil, fmt.Errorf("stack map frame at pc %d has more locals than max_locals", pc)
+		}
+		if len(stack) > m.maxStack {
+			return nil, fmt.Errorf("stack map frame at pc %d has a bigger stack than max_stack", pc)
+		}
+
+		f := frame{locals: make([]vtype, m.maxLocals), stack: append([]vtype{}, stack...)}
+		copy(f.locals, locals)
+		frames[pc] = f
+		locals = append([]vtype{}, locals...)
+	}
+
+	if r.Len() != 0 {
+		return nil, fmt.Errorf("StackMapTable longer than its frames")
+	}
+
+	return frames, nil
+}
+
+// trimLocals removes the trailing top entries that are not the second half of a long or double.
+func trimLocals(locals []vtype) []vtype {
+	end := len(locals)
+	for end > 0 && locals[end-1].kind == top && !(end > 1 && locals[end-2].size() == 2) {
+		end--
+	}
+	return append([]vtype{}, locals[:end]...)
+}
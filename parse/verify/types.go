@@ -0,0 +1,188 @@
+package verify
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/PPTide/gojdk/parse"
+)
+
+type kind int
+
+// Verification types as defined in JVMS §4.10.1.2.
+const (
+	top kind = iota
+	integer
+	float
+	long
+	double
+	null
+	uninitializedThis
+	uninitialized // Offset is the pc of the new instruction
+	reference     // Name is a binary class name in internal form or an array descriptor
+	returnAddress // only used by jsr/ret in class files older than version 50
+)
+
+type vtype struct {
+	kind   kind
+	name   string
+	offset int
+}
+
+var (
+	topType      = vtype{kind: top}
+	intType      = vtype{kind: integer}
+	floatType    = vtype{kind: float}
+	longType     = vtype{kind: long}
+	doubleType   = vtype{kind: double}
+	nullType     = vtype{kind: null}
+	objectType   = referenceType("java/lang/Object")
+	stringType   = referenceType("java/lang/String")
+	throwable    = referenceType("java/lang/Throwable")
+	classType    = referenceType("java/lang/Class")
+	methodType   = referenceType("java/lang/invoke/MethodType")
+	handleType   = referenceType("java/lang/invoke/MethodHandle")
+	cloneable    = "java/lang/Cloneable"
+	serializable = "java/io/Serializable"
+)
+
+func referenceType(name string) vtype {
+	return vtype{kind: reference, name: name}
+}
+
+func (t vtype) String() string {
+	switch t.kind {
+	case top:
+		return "top"
+	case integer:
+		return "int"
+	case float:
+		return "float"
+	case long:
+		return "long"
+	case double:
+		return "double"
+	case null:
+		return "null"
+	case uninitializedThis:
+		return "uninitializedThis"
+	case uninitialized:
+		return fmt.Sprintf("uninitialized(%d)", t.offset)
+	case returnAddress:
+		return "returnAddress"
+	}
+	return t.name
+}
+
+// size returns the number of slots a value of this type takes up.
+func (t vtype) size() int {
+	if t.kind == long || t.kind == double {
+		return 2
+	}
+	return 1
+}
+
+// isReference reports if the type can be used where a reference is expected.
+func (t vtype) isReference() bool {
+	return t.kind == reference || t.kind == null || t.kind == uninitialized || t.kind == uninitializedThis
+}
+
+func (t vtype) isArray() bool {
+	return t.kind == reference && strings.HasPrefix(t.name, "[")
+}
+
+// component returns the type of the elements of an array type.
+func (t vtype) component() (vtype, bool) {
+	if !t.isArray() {
+		return vtype{}, false
+	}
+	return typeFromDescriptor(t.name[1:])
+}
+
+// typeFromDescriptor converts a field descriptor into the verification type of a value of that type.
+func typeFromDescriptor(descriptor string) (vtype, bool) {
+	if descriptor == "" {
+		return vtype{}, false
+	}
+	switch descriptor[0] {
+	case 'B', 'C', 'I', 'S', 'Z':
+		return intType, len(descriptor) == 1
+	case 'F':
+		return floatType, len(descriptor) == 1
+	case 'J':
+		return longType, len(descriptor) == 1
+	case 'D':
+		return doubleType, len(descriptor) == 1
+	case 'L':
+		if !strings.HasSuffix(descriptor, ";") || len(descriptor) < 3 {
+			return vtype{}, false
+		}
+		return referenceType(descriptor[1 : len(descriptor)-1]), true
+	case '[':
+		return referenceType(descriptor), true
+	}
+	return vtype{}, false
+}
+
+// typeFromClassName converts the name of a ConstantClassInfo into a verification type.
+func typeFromClassName(name string) vtype {
+	return referenceType(name)
+}
+
+// arrayOf returns the array type with elements of the class or array called name.
+func arrayOf(name string) vtype {
+	if strings.HasPrefix(name, "[") {
+		return referenceType("[" + name)
+	}
+	return referenceType("[L" + name + ";")
+}
+
+// parseMethodDescriptor splits a method descriptor into its parameter and return descriptors.
+func parseMethodDescriptor(descriptor string) (params []string, ret string, err error) {
+	d, err := parse.ParseMethodDescriptor(descriptor)
+	if err != nil {
+		return nil, "", err
+	}
+	for _, p := range d.Parameters {
+		params = append(params, p.String())
+	}
+	return params, d.Return.String(), nil
+}
+
+// componentName converts a reference component descriptor into a class name or array descriptor.
+func componentName(descriptor string) string {
+	if strings.HasPrefix(descriptor, "L") {
+		return strings.TrimSuffix(descriptor[1:], ";")
+	}
+	return descriptor
+}
+
+// frame is the state of the local variables and operand stack at one instruction.
+//
+// Like in JVMS §4.10.1 long and double take up two entries, the second one being top.
+type frame struct {
+	locals []vtype
+	stack  []vtype
+}
+
+func (f frame) copy() frame {
+	return frame{
+		locals: append([]vtype{}, f.locals...),
+		stack:  append([]vtype{}, f.stack...),
+	}
+}
+
+// flagThisUninit reports if the frame is inside an <init> method that didn't call another <init> method yet.
+func (f frame) flagThisUninit() bool {
+	for _, t := range f.locals {
+		if t.kind == uninitializedThis {
+			return true
+		}
+	}
+	for _, t := range f.stack {
+		if t.kind == uninitializedThis {
+			return true
+		}
+	}
+	return false
+}
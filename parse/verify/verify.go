@@ -0,0 +1,396 @@
+// Package verify implements the bytecode verifier of JVMS §4.10.
+//
+// Class files with version 50.0 or newer are type checked against their StackMapTable attributes, older class files
+// are verified by type inference. Class types are checked against the class hierarchy a Verifier loads; the package
+// level functions don't load other classes and reject code whose type safety depends on them.
+package verify
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/PPTide/gojdk/parse"
+)
+
+// VerifyError is returned if the bytecode of a method isn't type safe.
+type VerifyError struct {
+	Class      string
+	Method     string
+	Descriptor string
+	PC         int // -1 if the error isn't caused by a single instruction
+	Reason     string
+}
+
+func (e *VerifyError) Error() string {
+	if e.PC < 0 {
+		return fmt.Sprintf("VerifyError in %s.%s%s: %s", e.Class, e.Method, e.Descriptor, e.Reason)
+	}
+	return fmt.Sprintf("VerifyError in %s.%s%s at pc %d: %s", e.Class, e.Method, e.Descriptor, e.PC, e.Reason)
+}
+
+// Verifier verifies classes against the class hierarchy. Whether a class type is assignable to another class type
+// depends on their super classes, a Verifier loads them with Load. Code that depends on classes which can't be
+// loaded is rejected, since the check can't be done.
+//
+// A Verifier caches the classes it loaded and must not be used concurrently.
+type Verifier struct {
+	// Load returns the class called name in internal form, e.g. java/util/ArrayList. It isn't called for
+	// java/lang/Object. If Load is nil no other classes are loaded.
+	Load func(name string) (parse.ClassFile, error)
+
+	classes *hierarchy
+}
+
+// hierarchy returns the class hierarchy of the classes loaded by v.
+func (v *Verifier) hierarchy() *hierarchy {
+	if v.classes == nil {
+		v.classes = &hierarchy{load: v.Load, classes: map[string]*loadedClass{}}
+	}
+	return v.classes
+}
+
+// Class verifies all methods of cf that have code.
+func (v *Verifier) Class(cf parse.ClassFile) error {
+	for _, info := range cf.Methods {
+		err := v.Method(cf, info)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Method verifies the code of info, which has to be a method of cf.
+func (v *Verifier) Method(cf parse.ClassFile, info parse.MethodInfo) error {
+	if info.Code == nil {
+		return nil
+	}
+
+	m, err := newMethod(&cf, info, v.hierarchy())
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case cf.MajorVersion >= 51:
+		err = m.typeCheck()
+	case cf.MajorVersion == 50:
+		// class files of version 50.0 may fall back to type inference if type checking fails (JVMS §4.10)
+		if m.typeCheck() != nil {
+			err = m.inferTypes()
+		}
+	default:
+		err = m.inferTypes()
+	}
+	return err
+}
+
+// Class verifies all methods of cf without loading other classes, see Verifier.
+func Class(cf parse.ClassFile) error {
+	return new(Verifier).Class(cf)
+}
+
+// Method verifies the code of info, which has to be a method of cf, without loading other classes, see Verifier.
+func Method(cf parse.ClassFile, info parse.MethodInfo) error {
+	return new(Verifier).Method(cf, info)
+}
+
+type method struct {
+	cf             *parse.ClassFile
+	classes        *hierarchy
+	class          string
+	name           string
+	descriptor     string
+	isStatic       bool
+	code           []byte
+	maxStack       int
+	maxLocals      int
+	exceptionTable []parse.ExceptionTableEntry
+	attributes     []parse.AttributeInfo
+	returnType     string
+	initialFrame   frame
+	starts         []int        // pc of every instruction in order
+	isStart        map[int]bool // set for every pc an instruction starts at
+	jsrReturns     []int        // pc after every jsr instruction, the possible successors of ret
+}
+
+func newMethod(cf *parse.ClassFile, info parse.MethodInfo, classes *hierarchy) (*method, error) {
+	m := &method{
+		cf:             cf,
+		classes:        classes,
+		isStatic:       info.AccessFlags.IsStatic(),
+		code:           info.Code.Code,
+		maxStack:       info.Code.MaxStack,
+		maxLocals:      info.Code.MaxLocals,
+		exceptionTable: info.Code.ExceptionTable,
+		attributes:     info.Code.Attributes,
+		isStart:        map[int]bool{},
+	}
+
+	var err error
+	m.class, err = m.className(cf.ThisClass)
+	if err != nil {
+		return nil, err
+	}
+	m.name, err = m.utf8(info.NameIndex)
+	if err != nil {
+		return nil, err
+	}
+	m.descriptor, err = m.utf8(info.DescriptorIndex)
+	if err != nil {
+		return nil, err
+	}
+
+	params, ret, err := parseMethodDescriptor(m.descriptor)
+	if err != nil {
+		return nil, m.errorf(-1, "%s", err)
+	}
+	m.returnType = ret
+
+	m.initialFrame = frame{locals: make([]vtype, m.maxLocals)}
+	local := 0
+	if !m.isStatic {
+		if m.maxLocals < 1 {
+			return nil, m.errorf(-1, "max_locals too small for the arguments")
+		}
+		if m.name == "<init>" && m.class != "java/lang/Object" {
+			m.initialFrame.locals[0] = vtype{kind: uninitializedThis}
+		} else {
+			m.initialFrame.locals[0] = referenceType(m.class)
+		}
+		local++
+	}
+	for _, param := range params {
+		t, ok := typeFromDescriptor(param)
+		if !ok {
+			return nil, m.errorf(-1, "invalid parameter type %s", param)
+		}
+		if local+t.size() > m.maxLocals {
+			return nil, m.errorf(-1, "max_locals too small for the arguments")
+		}
+		m.initialFrame.locals[local] = t
+		local += t.size()
+	}
+
+	for pc := 0; pc < len(m.code); {
+		length, err := instructionLength(m.code, pc)
+		if err != nil {
+			return nil, m.errorf(pc, "%s", err)
+		}
+		m.starts = append(m.starts, pc)
+		m.isStart[pc] = true
+		if op := m.code[pc]; op == 168 || op == 201 { // jsr, jsr_w
+			m.jsrReturns = append(m.jsrReturns, pc+length)
+		}
+		pc += length
+	}
+	if len(m.starts) == 0 {
+		return nil, m.errorf(-1, "empty code")
+	}
+
+	for _, entry := range m.exceptionTable {
+		if !m.isStart[entry.StartPc] || !(m.isStart[entry.EndPc] || entry.EndPc == len(m.code)) ||
+			entry.StartPc >= entry.EndPc || !m.isStart[entry.HandlerPc] {
+			return nil, m.errorf(-1, "invalid exception table entry %d..%d -> %d", entry.StartPc, entry.EndPc, entry.HandlerPc)
+		}
+	}
+
+	return m, nil
+}
+
+func (m *method) errorf(pc int, format string, args ...interface{}) *VerifyError {
+	return &VerifyError{
+		Class:      m.class,
+		Method:     m.name,
+		Descriptor: m.descriptor,
+		PC:         pc,
+		Reason:     fmt.Sprintf(format, args...),
+	}
+}
+
+// wrap turns err into a VerifyError at pc unless it already is one.
+func (m *method) wrap(pc int, err error) error {
+	var verifyError *VerifyError
+	if errors.As(err, &verifyError) {
+		return err
+	}
+	return m.errorf(pc, "%s", err)
+}
+
+// handlerFrame returns the frame at the start of the exception handler entry when an exception is thrown in f.
+func (m *method) handlerFrame(f frame, entry parse.ExceptionTableEntry) (frame, error) {
+	catchType := throwable
+	if entry.CatchType != 0 {
+		name, err := m.className(entry.CatchType)
+		if err != nil {
+			return frame{}, err
+		}
+		catchType = referenceType(name)
+	}
+	return frame{
+		locals: append([]vtype{}, f.locals...),
+		stack:  []vtype{catchType},
+	}, nil
+}
+
+// typeCheck verifies the method using the frames of the StackMapTable attribute (JVMS §4.10.1).
+func (m *method) typeCheck() error {
+	frames := map[int]frame{}
+	for _, attribute := range m.attributes {
+		name, err := m.utf8(attribute.AttributeNameIndex)
+		if err != nil {
+			return err
+		}
+		if name != "StackMapTable" {
+			continue
+		}
+		frames, err = m.readStackMapTable(attribute.Info)
+		if err != nil {
+			return m.errorf(-1, "invalid StackMapTable: %s", err)
+		}
+	}
+	for pc := range frames {
+		if !m.isStart[pc] {
+			return m.errorf(pc, "stack map frame not at the start of an instruction")
+		}
+	}
+
+	current := m.initialFrame.copy()
+	reachable := true
+	for _, pc := range m.starts {
+		if target, ok := frames[pc]; ok {
+			if reachable {
+				if err := m.classes.isAssignableFrame(current, target); err != nil {
+					return m.errorf(pc, "%s", err)
+				}
+			}
+			current = target.copy()
+		} else if !reachable {
+			return m.errorf(pc, "expected a stack map frame after an unconditional branch")
+		}
+
+		for _, entry := range m.exceptionTable {
+			if pc < entry.StartPc || pc >= entry.EndPc {
+				continue
+			}
+			handler, err := m.handlerFrame(current, entry)
+			if err != nil {
+				return m.wrap(pc, err)
+			}
+			target, ok := frames[entry.HandlerPc]
+			if !ok {
+				return m.errorf(pc, "no stack map frame at exception handler %d", entry.HandlerPc)
+			}
+			if err := m.classes.isAssignableFrame(handler, target); err != nil {
+				return m.errorf(pc, "exception handler %d: %s", entry.HandlerPc, err)
+			}
+		}
+
+		if op := m.code[pc]; op == 168 || op == 169 || op == 201 {
+			return m.errorf(pc, "jsr and ret are not allowed in class files with a StackMapTable")
+		}
+
+		next, targets, fallsThrough, err := m.step(pc, &current)
+		if err != nil {
+			return m.wrap(pc, err)
+		}
+
+		for _, t := range targets {
+			target, ok := frames[t]
+			if !ok {
+				return m.errorf(pc, "no stack map frame at branch target %d", t)
+			}
+			if err := m.classes.isAssignableFrame(current, target); err != nil {
+				return m.errorf(pc, "branch target %d: %s", t, err)
+			}
+		}
+
+		if fallsThrough && next >= len(m.code) {
+			return m.errorf(pc, "falling off the end of the code")
+		}
+		reachable = fallsThrough
+	}
+
+	return nil
+}
+
+// inferTypes verifies the method by computing the frame at every instruction with data-flow analysis
+// (JVMS §4.10.2).
+func (m *method) inferTypes() error {
+	_, err := m.inferFrames()
+	return err
+}
+
+// inferFrames computes the frame at the start of every reachable instruction with data-flow analysis.
+func (m *method) inferFrames() (map[int]*frame, error) {
+	frames := map[int]*frame{}
+	initial := m.initialFrame.copy()
+	frames[0] = &initial
+
+	queued := map[int]bool{0: true}
+	worklist := []int{0}
+
+	mergeInto := func(from int, pc int, f frame) error {
+		existing, ok := frames[pc]
+		if !ok {
+			copied := f.copy()
+			frames[pc] = &copied
+		} else {
+			changed, err := m.classes.mergeFrame(existing, f)
+			if err != nil {
+				return m.errorf(from, "merging into %d: %s", pc, err)
+			}
+			if !changed {
+				return nil
+			}
+		}
+		if !queued[pc] {
+			queued[pc] = true
+			worklist = append(worklist, pc)
+		}
+		return nil
+	}
+
+	for len(worklist) > 0 {
+		// always continue with the lowest pc to keep the number of iterations small
+		sort.Ints(worklist)
+		pc := worklist[0]
+		worklist = worklist[1:]
+		queued[pc] = false
+
+		current := frames[pc].copy()
+
+		for _, entry := range m.exceptionTable {
+			if pc < entry.StartPc || pc >= entry.EndPc {
+				continue
+			}
+			handler, err := m.handlerFrame(current, entry)
+			if err != nil {
+				return nil, m.wrap(pc, err)
+			}
+			if err := mergeInto(pc, entry.HandlerPc, handler); err != nil {
+				return nil, err
+			}
+		}
+
+		next, targets, fallsThrough, err := m.step(pc, &current)
+		if err != nil {
+			return nil, m.wrap(pc, err)
+		}
+
+		if fallsThrough {
+			if next >= len(m.code) {
+				return nil, m.errorf(pc, "falling off the end of the code")
+			}
+			targets = append(targets, next)
+		}
+		for _, t := range targets {
+			if err := mergeInto(pc, t, current); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return frames, nil
+}
@@ -0,0 +1,208 @@
+package verify
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/PPTide/gojdk/parse"
+)
+
+const static = parse.MethodAccPublic | parse.MethodAccStatic
+
+// stackMapTable returns a parse.StackMapTableFunc that always returns table.
+func stackMapTable(table ...byte) parse.StackMapTableFunc {
+	return func(*parse.ClassFile, parse.MethodInfo, func(string) int) ([]byte, error) {
+		if len(table) == 0 {
+			return nil, nil
+		}
+		return table, nil
+	}
+}
+
+// branch is a method with the frames ifeq needs: iload_0, ifeq 5, return, 5: return.
+func branch(c *parse.CodeBuilder) {
+	target := c.NewLabel()
+	c.ILoad(0)
+	c.IfEq(target)
+	c.Return()
+	c.Mark(target)
+	c.Return()
+}
+
+// testClasses are the classes the loader of the tests knows: B and C extend A and I is an interface.
+var testClasses = map[string]*parse.ClassBuilder{
+	"A": parse.NewClassBuilder("A"),
+	"B": parse.NewClassBuilder("B").Super("A"),
+	"C": parse.NewClassBuilder("C").Super("A"),
+	"I": parse.NewClassBuilder("I").Access(parse.ClassAccPublic | parse.ClassAccInterface | parse.ClassAccAbstract),
+}
+
+func loadTestClass(name string) (parse.ClassFile, error) {
+	b, ok := testClasses[name]
+	if !ok {
+		return parse.ClassFile{}, fmt.Errorf("class %s not found", name)
+	}
+	return b.Build()
+}
+
+func TestMethod(t *testing.T) {
+	tests := []struct {
+		name   string
+		class  *parse.ClassBuilder
+		load   bool   // use loadTestClass
+		reason string // part of the expected VerifyError, "" if the class is valid
+	}{
+		{"branch", parse.NewClassBuilder("T").Version(52, 0).StackMapTables(StackMapTable).
+			Method(static, "m", "(I)V").Code(branch), false, ""},
+		{"old class without frames", parse.NewClassBuilder("T").
+			Method(static, "m", "(I)V").Code(branch), false, ""},
+
+		// bad stack map frames
+		{"missing frame", parse.NewClassBuilder("T").Version(52, 0).StackMapTables(stackMapTable()).
+			Method(static, "m", "(I)V").Code(branch), false, "no stack map frame at branch target 5"},
+		{"frame inside an instruction", parse.NewClassBuilder("T").Version(52, 0).StackMapTables(stackMapTable(0, 1, 3)).
+			Method(static, "m", "(I)V").Code(branch), false, "not at the start of an instruction"},
+		{"frame with a bigger stack", parse.NewClassBuilder("T").Version(52, 0).StackMapTables(stackMapTable(0, 1, 64+5, 1)).
+			Method(static, "m", "(I)V").Code(branch), false, "doesn't match stack map frame size"},
+		{"frame with a wrong local", parse.NewClassBuilder("T").Version(52, 0).
+			StackMapTables(stackMapTable(0, 1, 255, 0, 5, 0, 1, 2, 0, 0)).
+			Method(static, "m", "(I)V").Code(branch), false, "int is not assignable to float"},
+		{"reserved frame type", parse.NewClassBuilder("T").Version(52, 0).StackMapTables(stackMapTable(0, 1, 200)).
+			Method(static, "m", "(I)V").Code(branch), false, "reserved stack map frame type"},
+
+		// type mismatches
+		{"int returned as reference", parse.NewClassBuilder("T").
+			Method(static, "m", "(I)Ljava/lang/Object;").Code(func(c *parse.CodeBuilder) {
+			c.ILoad(0)
+			c.Insn(0xb0) // areturn
+		}), false, "expected java/lang/Object on the stack but found int"},
+		{"reference added to int", parse.NewClassBuilder("T").
+			Method(static, "m", "(Ljava/lang/String;)I").Code(func(c *parse.CodeBuilder) {
+			c.ALoad(0)
+			c.IConst(1)
+			c.IAdd()
+			c.IReturn()
+		}), false, "expected int on the stack but found java/lang/String"},
+		{"subclass returned", parse.NewClassBuilder("T").
+			Method(static, "m", "(LB;)LA;").Code(func(c *parse.CodeBuilder) {
+			c.ALoad(0)
+			c.AReturn()
+		}), true, ""},
+		{"sibling class returned", parse.NewClassBuilder("T").
+			Method(static, "m", "(LB;)LC;").Code(func(c *parse.CodeBuilder) {
+			c.ALoad(0)
+			c.AReturn()
+		}), true, "expected C on the stack but found B"},
+		{"class passed as interface", parse.NewClassBuilder("T").
+			Method(static, "m", "(LB;)LI;").Code(func(c *parse.CodeBuilder) {
+			c.ALoad(0)
+			c.AReturn()
+		}), true, ""},
+		{"wrong receiver", parse.NewClassBuilder("T").
+			Method(static, "m", "(LA;)V").Code(func(c *parse.CodeBuilder) {
+			c.ALoad(0)
+			c.InvokeVirtual("B", "run", "()V")
+			c.Return()
+		}), true, "expected B as receiver but found A"},
+		{"wrong field owner", parse.NewClassBuilder("T").
+			Method(static, "m", "(LC;)V").Code(func(c *parse.CodeBuilder) {
+			c.ALoad(0)
+			c.IConst(1)
+			c.PutField("B", "x", "I")
+			c.Return()
+		}), true, "expected B on the stack but found C"},
+		{"unknown class without loader", parse.NewClassBuilder("T").
+			Method(static, "m", "(LB;)LA;").Code(func(c *parse.CodeBuilder) {
+			c.ALoad(0)
+			c.AReturn()
+		}), false, "can't check that B is assignable to A"},
+		{"merged subclasses", parse.NewClassBuilder("T").
+			Method(static, "m", "(ILB;LC;)LA;").Code(func(c *parse.CodeBuilder) {
+			other, end := c.NewLabel(), c.NewLabel()
+			c.ILoad(0)
+			c.IfEq(other)
+			c.ALoad(1)
+			c.Goto(end)
+			c.Mark(other)
+			c.ALoad(2)
+			c.Mark(end)
+			c.AReturn()
+		}), true, ""},
+
+		// uninitialized this
+		{"<init> without super call", parse.NewClassBuilder("T").
+			Method(parse.MethodAccPublic, "<init>", "()V").Code(func(c *parse.CodeBuilder) {
+			c.Return()
+		}), false, "<init> returns without calling another <init> method"},
+		{"this used before super call", parse.NewClassBuilder("T").
+			Method(parse.MethodAccPublic, "<init>", "()V").Code(func(c *parse.CodeBuilder) {
+			c.ALoad(0)
+			c.InvokeVirtual("T", "run", "()V")
+			c.ALoad(0)
+			c.InvokeSpecial("java/lang/Object", "<init>", "()V")
+			c.Return()
+		}), false, "expected an initialized reference but found uninitializedThis"},
+		{"this returned before super call", parse.NewClassBuilder("T").
+			Method(parse.MethodAccPublic, "<init>", "()V").Code(func(c *parse.CodeBuilder) {
+			c.ALoad(0)
+			c.AStore(1)
+			c.Return()
+		}), false, "<init> returns without calling another <init> method"},
+		{"<init> with super call", parse.NewClassBuilder("T").
+			Method(parse.MethodAccPublic, "<init>", "()V").Code(func(c *parse.CodeBuilder) {
+			c.ALoad(0)
+			c.InvokeSpecial("java/lang/Object", "<init>", "()V")
+			c.Return()
+		}), false, ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cf, err := test.class.Build()
+			if err != nil {
+				t.Fatalf("Build failed: %v", err)
+			}
+
+			v := new(Verifier)
+			if test.load {
+				v.Load = loadTestClass
+			}
+			err = v.Class(cf)
+			if test.reason == "" {
+				if err != nil {
+					t.Fatalf("Class failed for valid code: %v", err)
+				}
+				return
+			}
+
+			var verifyError *VerifyError
+			if !errors.As(err, &verifyError) {
+				t.Fatalf("Class returned %v, want a VerifyError", err)
+			}
+			if !strings.Contains(verifyError.Reason, test.reason) {
+				t.Errorf("Class returned %q, want a reason containing %q", verifyError.Reason, test.reason)
+			}
+		})
+	}
+}
+
+func TestMerge(t *testing.T) {
+	h := &hierarchy{load: loadTestClass, classes: map[string]*loadedClass{}}
+	tests := []struct {
+		a, b, want string
+	}{
+		{"B", "C", "A"},
+		{"B", "A", "A"},
+		{"B", "I", "java/lang/Object"},
+		{"B", "Unknown", "java/lang/Object"},
+		{"[LB;", "[LC;", "[LA;"},
+		{"[LB;", "[I", "java/lang/Object"},
+	}
+	for _, test := range tests {
+		if got := h.mergeReference(test.a, test.b); got != test.want {
+			t.Errorf("mergeReference(%s, %s) = %s, want %s", test.a, test.b, got, test.want)
+		}
+	}
+}
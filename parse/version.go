@@ -0,0 +1,85 @@
+package parse
+
+import "strconv"
+
+// The range of class file versions the parser understands, 45.0 (JDK 1.1) to 65.0 (Java 21).
+const (
+	MinMajorVersion = 45
+	MaxMajorVersion = 65
+)
+
+// previewMinorVersion marks class files that depend on the preview features of their Java release (JVMS §4.1).
+const previewMinorVersion = 0xFFFF
+
+// constantSince returns the name of the constant pool entry info and the first class file version it may appear in
+// (JVMS §4.4, table 4.4-C). Entries that are valid in every version return 0.
+func constantSince(info CpInfo) (name string, majorVersion int) {
+	switch info.(type) {
+	case ConstantMethodHandleInfo:
+		return "CONSTANT_MethodHandle", 51
+	case ConstantMethodTypeInfo:
+		return "CONSTANT_MethodType", 51
+	case ConstantDynamicInfo:
+		return "CONSTANT_Dynamic", 55
+	case ConstantInvokeDynamicInfo:
+		return "CONSTANT_InvokeDynamic", 51
+	case ConstantModuleInfo:
+		return "CONSTANT_Module", 53
+	case ConstantPackageInfo:
+		return "CONSTANT_Package", 53
+	}
+	return "", 0
+}
+
+// attributeSince is the first class file version each predefined attribute is recognized in (JVMS §4.7, table 4.7-B).
+// In older class files these attributes are ignored like unknown attributes.
+var attributeSince = map[string]int{
+	"ConstantValue":                        45,
+	"Code":                                 45,
+	"Exceptions":                           45,
+	"SourceFile":                           45,
+	"LineNumberTable":                      45,
+	"LocalVariableTable":                   45,
+	"InnerClasses":                         45,
+	"Synthetic":                            45,
+	"Deprecated":                           45,
+	"EnclosingMethod":                      49,
+	"Signature":                            49,
+	"SourceDebugExtension":                 49,
+	"LocalVariableTypeTable":               49,
+	"RuntimeVisibleAnnotations":            49,
+	"RuntimeInvisibleAnnotations":          49,
+	"RuntimeVisibleParameterAnnotations":   49,
+	"RuntimeInvisibleParameterAnnotations": 49,
+	"AnnotationDefault":                    49,
+	"StackMapTable":                        50,
+	"BootstrapMethods":                     51,
+	"RuntimeVisibleTypeAnnotations":        52,
+	"RuntimeInvisibleTypeAnnotations":      52,
+	"MethodParameters":                     52,
+	"Module":                               53,
+	"ModulePackages":                       53,
+	"ModuleMainClass":                      53,
+	"NestHost":                             55,
+	"NestMembers":                          55,
+	"Record":                               60,
+	"PermittedSubclasses":                  61,
+}
+
+// JavaRelease returns the Java SE release that introduced the class file version majorVersion, e.g. "17" for 61.
+func JavaRelease(majorVersion int) string {
+	switch {
+	case majorVersion <= 45:
+		return "1.1"
+	case majorVersion < 49:
+		return "1." + strconv.Itoa(majorVersion-44)
+	}
+	return strconv.Itoa(majorVersion - 44)
+}
+
+// isAttributeRecognized reports if the attribute called name is predefined in class files of version majorVersion.
+// Unknown attributes are never recognized.
+func isAttributeRecognized(name string, majorVersion int) bool {
+	since, ok := attributeSince[name]
+	return ok && majorVersion >= since
+}
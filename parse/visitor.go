@@ -0,0 +1,298 @@
+package parse
+
+// ClassVisitor receives the parts of a class while a ClassReader walks it, in this order:
+//
+//	VisitClass (VisitField | VisitMethod)* VisitSource? VisitAttribute* VisitEnd
+//
+// Visitors are chained by passing the calls on to the next visitor, ClassAdapter does that for all methods it
+// doesn't override. A ClassWriter at the end of the chain writes the class again.
+type ClassVisitor interface {
+	// VisitClass visits the header of the class. superName is "" for java/lang/Object and module-info.
+	VisitClass(majorVersion, minorVersion int, accessFlags ClassAccessFlags, name, superName string, interfaces []string)
+	// VisitField visits a field and returns the visitor for its attributes, or nil to skip them.
+	VisitField(accessFlags FieldAccessFlags, name, descriptor string) FieldVisitor
+	// VisitMethod visits a method and returns the visitor for its attributes and code, or nil to skip them.
+	// exceptions are the classes of the "Exceptions" attribute.
+	VisitMethod(accessFlags MethodAccessFlags, name, descriptor string, exceptions []string) MethodVisitor
+	// VisitSource visits the "SourceFile" attribute.
+	VisitSource(file string)
+	// VisitAttribute visits any other class attribute with its raw content.
+	VisitAttribute(name string, info []byte)
+	VisitEnd()
+}
+
+// FieldVisitor receives the attributes of a field, see ClassVisitor.VisitField.
+type FieldVisitor interface {
+	// VisitAttribute visits an attribute with its raw content, including "ConstantValue".
+	VisitAttribute(name string, info []byte)
+	VisitEnd()
+}
+
+// MethodVisitor receives the attributes and the code of a method, in this order:
+//
+//	VisitAttribute* (VisitCode VisitTryCatchBlock* (VisitLabel | VisitLineNumber | Visit...Insn)*
+//		VisitLocalVariable* VisitLocalVariableType* VisitCodeAttribute* VisitMaxs)? VisitEnd
+//
+// Instructions are visited with their opcode in the general form: the loads and stores of the first four local
+// variables like iload_1 are visited as iload 1, wide is left out and goto_w and jsr_w are visited as goto and jsr.
+// Branch targets, exception handler ranges, line numbers and local variables refer to labels, which are visited
+// before the instruction they mark, so an adapter may add and remove instructions.
+type MethodVisitor interface {
+	// VisitAttribute visits a method attribute other than "Code" and "Exceptions" with its raw content.
+	VisitAttribute(name string, info []byte)
+	// VisitCode starts the code of methods that have a "Code" attribute.
+	VisitCode()
+	// VisitTryCatchBlock visits an exception handler for the code from start to end, class is "" if it catches
+	// everything.
+	VisitTryCatchBlock(start, end, handler *Label, class string)
+	// VisitLabel visits a label that marks the following instruction.
+	VisitLabel(label *Label)
+	// VisitLineNumber visits the source line of the code starting at start.
+	VisitLineNumber(line int, start *Label)
+	// VisitInsn visits an instruction without operands, like iadd.
+	VisitInsn(opcode byte)
+	// VisitIntInsn visits bipush, sipush or newarray, whose operand is the atype.
+	VisitIntInsn(opcode byte, operand int)
+	// VisitVarInsn visits a load, store or ret of the local variable at index.
+	VisitVarInsn(opcode byte, index int)
+	// VisitTypeInsn visits new, anewarray, checkcast or instanceof of the class or array type called class.
+	VisitTypeInsn(opcode byte, class string)
+	// VisitFieldInsn visits getstatic, putstatic, getfield or putfield.
+	VisitFieldInsn(opcode byte, class, name, descriptor string)
+	// VisitMethodInsn visits invokevirtual, invokespecial, invokestatic or invokeinterface.
+	VisitMethodInsn(opcode byte, class, name, descriptor string)
+	// VisitInvokeDynamicInsn visits invokedynamic with the index of its bootstrap method in the "BootstrapMethods"
+	// attribute.
+	VisitInvokeDynamicInsn(bootstrapMethod int, name, descriptor string)
+	// VisitJumpInsn visits a conditional branch, goto or jsr.
+	VisitJumpInsn(opcode byte, target *Label)
+	// VisitLdcInsn visits ldc, ldc_w or ldc2_w with the constant pool entry they load.
+	VisitLdcInsn(constant CpInfo)
+	// VisitIincInsn visits iinc.
+	VisitIincInsn(index int, increment int)
+	// VisitTableSwitchInsn visits tableswitch, targets are the labels of the keys from low on.
+	VisitTableSwitchInsn(low int32, defaultTarget *Label, targets []*Label)
+	// VisitLookupSwitchInsn visits lookupswitch.
+	VisitLookupSwitchInsn(defaultTarget *Label, keys []int32, targets []*Label)
+	// VisitMultiANewArrayInsn visits multianewarray.
+	VisitMultiANewArrayInsn(descriptor string, dimensions int)
+	// VisitLocalVariable visits an entry of the "LocalVariableTable" attribute.
+	VisitLocalVariable(name, descriptor string, start, end *Label, index int)
+	// VisitLocalVariableType visits an entry of the "LocalVariableTypeTable" attribute with the generic signature of
+	// the variable.
+	VisitLocalVariableType(name, signature string, start, end *Label, index int)
+	// VisitCodeAttribute visits any other attribute of the code with its raw content, except "StackMapTable". Program
+	// counters in it, like the targets of "RuntimeVisibleTypeAnnotations", are only valid as long as the
+	// instructions aren't changed.
+	VisitCodeAttribute(name string, info []byte)
+	// VisitMaxs visits max_stack and max_locals of the code.
+	VisitMaxs(maxStack, maxLocals int)
+	VisitEnd()
+}
+
+// ------------------- Adapters ---------------------
+
+// ClassAdapter passes all calls on to Next, or ignores them if Next is nil. Embed it in visitors that only need some
+// of the methods.
+type ClassAdapter struct {
+	Next ClassVisitor
+}
+
+func (a ClassAdapter) VisitClass(majorVersion, minorVersion int, accessFlags ClassAccessFlags, name, superName string, interfaces []string) {
+	if a.Next != nil {
+		a.Next.VisitClass(majorVersion, minorVersion, accessFlags, name, superName, interfaces)
+	}
+}
+
+func (a ClassAdapter) VisitField(accessFlags FieldAccessFlags, name, descriptor string) FieldVisitor {
+	if a.Next == nil {
+		return nil
+	}
+	return a.Next.VisitField(accessFlags, name, descriptor)
+}
+
+func (a ClassAdapter) VisitMethod(accessFlags MethodAccessFlags, name, descriptor string, exceptions []string) MethodVisitor {
+	if a.Next == nil {
+		return nil
+	}
+	return a.Next.VisitMethod(accessFlags, name, descriptor, exceptions)
+}
+
+func (a ClassAdapter) VisitSource(file string) {
+	if a.Next != nil {
+		a.Next.VisitSource(file)
+	}
+}
+
+func (a ClassAdapter) VisitAttribute(name string, info []byte) {
+	if a.Next != nil {
+		a.Next.VisitAttribute(name, info)
+	}
+}
+
+func (a ClassAdapter) VisitEnd() {
+	if a.Next != nil {
+		a.Next.VisitEnd()
+	}
+}
+
+// FieldAdapter passes all calls on to Next, or ignores them if Next is nil.
+type FieldAdapter struct {
+	Next FieldVisitor
+}
+
+func (a FieldAdapter) VisitAttribute(name string, info []byte) {
+	if a.Next != nil {
+		a.Next.VisitAttribute(name, info)
+	}
+}
+
+func (a FieldAdapter) VisitEnd() {
+	if a.Next != nil {
+		a.Next.VisitEnd()
+	}
+}
+
+// MethodAdapter passes all calls on to Next, or ignores them if Next is nil. Embed it in visitors that rewrite some
+// of the instructions.
+type MethodAdapter struct {
+	Next MethodVisitor
+}
+
+func (a MethodAdapter) VisitAttribute(name string, info []byte) {
+	if a.Next != nil {
+		a.Next.VisitAttribute(name, info)
+	}
+}
+
+func (a MethodAdapter) VisitCode() {
+	if a.Next != nil {
+		a.Next.VisitCode()
+	}
+}
+
+func (a MethodAdapter) VisitTryCatchBlock(start, end, handler *Label, class string) {
+	if a.Next != nil {
+		a.Next.VisitTryCatchBlock(start, end, handler, class)
+	}
+}
+
+func (a MethodAdapter) VisitLabel(label *Label) {
+	if a.Next != nil {
+		a.Next.VisitLabel(label)
+	}
+}
+
+func (a MethodAdapter) VisitLineNumber(line int, start *Label) {
+	if a.Next != nil {
+		a.Next.VisitLineNumber(line, start)
+	}
+}
+
+func (a MethodAdapter) VisitInsn(opcode byte) {
+	if a.Next != nil {
+		a.Next.VisitInsn(opcode)
+	}
+}
+
+func (a MethodAdapter) VisitIntInsn(opcode byte, operand int) {
+	if a.Next != nil {
+		a.Next.VisitIntInsn(opcode, operand)
+	}
+}
+
+func (a MethodAdapter) VisitVarInsn(opcode byte, index int) {
+	if a.Next != nil {
+		a.Next.VisitVarInsn(opcode, index)
+	}
+}
+
+func (a MethodAdapter) VisitTypeInsn(opcode byte, class string) {
+	if a.Next != nil {
+		a.Next.VisitTypeInsn(opcode, class)
+	}
+}
+
+func (a MethodAdapter) VisitFieldInsn(opcode byte, class, name, descriptor string) {
+	if a.Next != nil {
+		a.Next.VisitFieldInsn(opcode, class, name, descriptor)
+	}
+}
+
+func (a MethodAdapter) VisitMethodInsn(opcode byte, class, name, descriptor string) {
+	if a.Next != nil {
+		a.Next.VisitMethodInsn(opcode, class, name, descriptor)
+	}
+}
+
+func (a MethodAdapter) VisitInvokeDynamicInsn(bootstrapMethod int, name, descriptor string) {
+	if a.Next != nil {
+		a.Next.VisitInvokeDynamicInsn(bootstrapMethod, name, descriptor)
+	}
+}
+
+func (a MethodAdapter) VisitJumpInsn(opcode byte, target *Label) {
+	if a.Next != nil {
+		a.Next.VisitJumpInsn(opcode, target)
+	}
+}
+
+func (a MethodAdapter) VisitLdcInsn(constant CpInfo) {
+	if a.Next != nil {
+		a.Next.VisitLdcInsn(constant)
+	}
+}
+
+func (a MethodAdapter) VisitIincInsn(index int, increment int) {
+	if a.Next != nil {
+		a.Next.VisitIincInsn(index, increment)
+	}
+}
+
+func (a MethodAdapter) VisitTableSwitchInsn(low int32, defaultTarget *Label, targets []*Label) {
+	if a.Next != nil {
+		a.Next.VisitTableSwitchInsn(low, defaultTarget, targets)
+	}
+}
+
+func (a MethodAdapter) VisitLookupSwitchInsn(defaultTarget *Label, keys []int32, targets []*Label) {
+	if a.Next != nil {
+		a.Next.VisitLookupSwitchInsn(defaultTarget, keys, targets)
+	}
+}
+
+func (a MethodAdapter) VisitMultiANewArrayInsn(descriptor string, dimensions int) {
+	if a.Next != nil {
+		a.Next.VisitMultiANewArrayInsn(descriptor, dimensions)
+	}
+}
+
+func (a MethodAdapter) VisitLocalVariable(name, descriptor string, start, end *Label, index int) {
+	if a.Next != nil {
+		a.Next.VisitLocalVariable(name, descriptor, start, end, index)
+	}
+}
+
+func (a MethodAdapter) VisitLocalVariableType(name, signature string, start, end *Label, index int) {
+	if a.Next != nil {
+		a.Next.VisitLocalVariableType(name, signature, start, end, index)
+	}
+}
+
+func (a MethodAdapter) VisitCodeAttribute(name string, info []byte) {
+	if a.Next != nil {
+		a.Next.VisitCodeAttribute(name, info)
+	}
+}
+
+func (a MethodAdapter) VisitMaxs(maxStack, maxLocals int) {
+	if a.Next != nil {
+		a.Next.VisitMaxs(maxStack, maxLocals)
+	}
+}
+
+func (a MethodAdapter) VisitEnd() {
+	if a.Next != nil {
+		a.Next.VisitEnd()
+	}
+}
@@ -0,0 +1,287 @@
+package parse
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// rewrite reads content and writes it again with a ClassWriter behind the visitor adapt returns for it.
+func rewrite(content []byte, adapt func(next ClassVisitor) ClassVisitor) (ClassFile, error) {
+	reader, err := NewClassReader(content)
+	if err != nil {
+		return ClassFile{}, err
+	}
+	writer := NewClassWriter(reader)
+	if err := reader.Accept(adapt(writer)); err != nil {
+		return ClassFile{}, err
+	}
+	return writer.Build()
+}
+
+// codeAttributes returns the names of the attributes of code other than "StackMapTable", which the writer drops.
+func codeAttributes(cf ClassFile, code *CodeAttribute) []string {
+	var names []string
+	for _, attribute := range code.Attributes {
+		if name, _ := cf.utf8Text(attribute.AttributeNameIndex); name != "StackMapTable" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// compareMethods reports the differences between the methods of got and the methods of want that the writer is
+// expected to keep.
+func compareMethods(t *testing.T, got, want ClassFile) {
+	if len(got.Methods) != len(want.Methods) {
+		t.Fatalf("%d methods, want %d", len(got.Methods), len(want.Methods))
+	}
+	for i, method := range want.Methods {
+		gotMethod := got.Methods[i]
+		name, _ := want.utf8Text(method.NameIndex)
+		descriptor, _ := want.utf8Text(method.DescriptorIndex)
+		t.Run(name+descriptor, func(t *testing.T) {
+			gotName, _ := got.utf8Text(gotMethod.NameIndex)
+			gotDescriptor, _ := got.utf8Text(gotMethod.DescriptorIndex)
+			if gotName != name || gotDescriptor != descriptor {
+				t.Fatalf("method %s%s, want %s%s", gotName, gotDescriptor, name, descriptor)
+			}
+			if method.Code == nil {
+				return
+			}
+			if !bytes.Equal(gotMethod.Code.Code, method.Code.Code) {
+				t.Errorf("code differs:\n got % x\nwant % x", gotMethod.Code.Code, method.Code.Code)
+			}
+			if gotMethod.Code.MaxStack != method.Code.MaxStack || gotMethod.Code.MaxLocals != method.Code.MaxLocals {
+				t.Errorf("max_stack %d and max_locals %d, want %d and %d", gotMethod.Code.MaxStack,
+					gotMethod.Code.MaxLocals, method.Code.MaxStack, method.Code.MaxLocals)
+			}
+			if !reflect.DeepEqual(gotMethod.Code.ExceptionTable, method.Code.ExceptionTable) {
+				t.Errorf("exception table %v, want %v", gotMethod.Code.ExceptionTable, method.Code.ExceptionTable)
+			}
+			if g, w := codeAttributes(got, gotMethod.Code), codeAttributes(want, method.Code); !reflect.DeepEqual(g, w) {
+				t.Errorf("code attributes %v, want %v", g, w)
+			}
+		})
+	}
+}
+
+func TestClassWriterRoundTrip(t *testing.T) {
+	for _, name := range testClasses(t) {
+		t.Run(filepath.Base(name), func(t *testing.T) {
+			content, err := os.ReadFile(name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want, err := ParseBytes(content)
+			if err != nil {
+				t.Fatalf("ParseBytes failed: %v", err)
+			}
+			got, err := rewrite(content, func(next ClassVisitor) ClassVisitor { return next })
+			if err != nil {
+				t.Fatalf("rewriting failed: %v", err)
+			}
+
+			gotName, _ := got.className(got.ThisClass)
+			wantName, _ := want.className(want.ThisClass)
+			if gotName != wantName || got.AccessFlags != want.AccessFlags || got.MajorVersion != want.MajorVersion ||
+				len(got.Fields) != len(want.Fields) || len(got.Attributes) != len(want.Attributes) {
+				t.Errorf("header, fields or attributes differ")
+			}
+			compareMethods(t, got, want)
+		})
+	}
+}
+
+func TestClassWriterRoundTripBuiltCode(t *testing.T) {
+	content, err := NewClassBuilder("T").
+		Method(MethodAccStatic, "f", "(I)I").Code(func(c *CodeBuilder) {
+		start, end, handler := c.NewLabel(), c.NewLabel(), c.NewLabel()
+		one, two, other, done := c.NewLabel(), c.NewLabel(), c.NewLabel(), c.NewLabel()
+		c.TryCatch(start, end, handler, "java/lang/RuntimeException")
+		c.Line(1)
+		c.Mark(start)
+		c.ILoad(0)
+		c.TableSwitch(1, other, one, two)
+		c.Mark(one)
+		c.Line(2)
+		c.IInc(0, 1000)
+		c.ILoad(0)
+		c.LookupSwitch(other, []int32{-3, 70000}, []*Label{done, other})
+		c.Mark(two)
+		c.IConst(300)
+		c.IStore(300)
+		c.Mark(other)
+		c.Mark(end)
+		c.ILoad(0)
+		c.IfNe(done)
+		c.IConst(0)
+		c.IReturn()
+		c.Mark(handler)
+		c.AStore(1)
+		c.Mark(done)
+		c.IConst(-1)
+		c.IReturn()
+		c.LocalVariable("x", "I", start, end, 0)
+	}).
+		Bytes()
+	if err != nil {
+		t.Fatalf("building the class failed: %v", err)
+	}
+	want, err := ParseBytes(content)
+	if err != nil {
+		t.Fatalf("ParseBytes failed: %v", err)
+	}
+	got, err := rewrite(content, func(next ClassVisitor) ClassVisitor { return next })
+	if err != nil {
+		t.Fatalf("rewriting failed: %v", err)
+	}
+	compareMethods(t, got, want)
+}
+
+// stringReplacer replaces the string from that ldc loads by to, which it adds to pool.
+type stringReplacer struct {
+	ClassAdapter
+	pool     *ConstantPoolBuilder
+	from, to string
+}
+
+func (r stringReplacer) VisitMethod(accessFlags MethodAccessFlags, name, descriptor string, exceptions []string) MethodVisitor {
+	return stringMethodReplacer{MethodAdapter{r.ClassAdapter.VisitMethod(accessFlags, name, descriptor, exceptions)}, r}
+}
+
+type stringMethodReplacer struct {
+	MethodAdapter
+	class stringReplacer
+}
+
+func (r stringMethodReplacer) VisitLdcInsn(constant CpInfo) {
+	if s, ok := constant.(ConstantStringInfo); ok && s.String != nil {
+		if text, _ := (*s.String).(ConstantUtf8Info); text.Text == r.class.from {
+			constant = r.class.pool.Lookup(r.class.pool.String(r.class.to))
+		}
+	}
+	r.MethodAdapter.VisitLdcInsn(constant)
+}
+
+// loadedStrings returns the strings the ldc instructions of code load.
+func loadedStrings(t *testing.T, cf ClassFile, code *CodeAttribute) []string {
+	var strings []string
+	for pc := 0; pc < len(code.Code); {
+		ins, next, err := decodeInstruction(code.Code, pc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ins.opcode == 0x12 || ins.opcode == 0x13 { // ldc, ldc_w
+			if s, ok := cf.ConstantPool[ins.operand-1].(ConstantStringInfo); ok {
+				text, _ := cf.utf8Text(s.StringIndex)
+				strings = append(strings, text)
+			}
+		}
+		pc = next
+	}
+	return strings
+}
+
+func TestClassWriterAdapter(t *testing.T) {
+	content, err := os.ReadFile(filepath.Join("testdata", "HelloWorld.class"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cf, err := rewrite(content, func(next ClassVisitor) ClassVisitor {
+		return stringReplacer{ClassAdapter{next}, next.(*ClassWriter).Pool(), "Hi 3^2 = ", "Hello, "}
+	})
+	if err != nil {
+		t.Fatalf("rewriting failed: %v", err)
+	}
+
+	var got []string
+	for _, method := range cf.Methods {
+		if method.Code != nil {
+			got = append(got, loadedStrings(t, cf, method.Code)...)
+		}
+	}
+	if !reflect.DeepEqual(got, []string{"Hello, "}) {
+		t.Errorf("ldc loads %q, want the replaced string", got)
+	}
+}
+
+// nopInserter adds a nop at the start of the code of every method.
+type nopInserter struct {
+	ClassAdapter
+}
+
+func (a nopInserter) VisitMethod(accessFlags MethodAccessFlags, name, descriptor string, exceptions []string) MethodVisitor {
+	return nopMethodInserter{MethodAdapter{a.ClassAdapter.VisitMethod(accessFlags, name, descriptor, exceptions)}}
+}
+
+type nopMethodInserter struct {
+	MethodAdapter
+}
+
+func (a nopMethodInserter) VisitCode() {
+	a.MethodAdapter.VisitCode()
+	a.MethodAdapter.VisitInsn(0x00)
+}
+
+func TestClassWriterCodeAttributes(t *testing.T) {
+	content, err := NewClassBuilder("T").
+		Method(MethodAccStatic, "f", "(Ljava/util/List;)Ljava/util/List;").Code(func(c *CodeBuilder) {
+		start, end := c.NewLabel(), c.NewLabel()
+		c.Mark(start)
+		c.ALoad(0)
+		c.Mark(end)
+		c.AReturn()
+		c.LocalVariable("list", "Ljava/util/List;", start, end, 0)
+		c.LocalVariableType("list", "Ljava/util/List<Ljava/lang/String;>;", start, end, 0)
+		c.Attribute("Custom", []byte{1, 2, 3})
+	}).
+		Bytes()
+	if err != nil {
+		t.Fatalf("building the class failed: %v", err)
+	}
+	want, err := ParseBytes(content)
+	if err != nil {
+		t.Fatalf("ParseBytes failed: %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		adapt func(next ClassVisitor) ClassVisitor
+		shift int // of the code
+	}{
+		{"unchanged", func(next ClassVisitor) ClassVisitor { return next }, 0},
+		{"nop inserted", func(next ClassVisitor) ClassVisitor { return nopInserter{ClassAdapter{next}} }, 1},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := rewrite(content, test.adapt)
+			if err != nil {
+				t.Fatalf("rewriting failed: %v", err)
+			}
+			code, wantCode := got.Methods[0].Code, want.Methods[0].Code
+			if g, w := codeAttributes(got, code), codeAttributes(want, wantCode); !reflect.DeepEqual(g, w) {
+				t.Errorf("code attributes %v, want %v", g, w)
+			}
+
+			if len(code.LocalVariableTypeTable) != 1 {
+				t.Fatalf("LocalVariableTypeTable %+v, want one entry", code.LocalVariableTypeTable)
+			}
+			entry, wantEntry := code.LocalVariableTypeTable[0], wantCode.LocalVariableTypeTable[0]
+			if entry.Name != wantEntry.Name || entry.Descriptor != wantEntry.Descriptor || entry.Index != wantEntry.Index ||
+				entry.StartPc != wantEntry.StartPc+test.shift || entry.Length != wantEntry.Length {
+				t.Errorf("LocalVariableTypeTable entry %+v, want %+v moved by %d", entry, wantEntry, test.shift)
+			}
+
+			for _, attribute := range code.Attributes {
+				if name, _ := got.utf8Text(attribute.AttributeNameIndex); name == "Custom" &&
+					!bytes.Equal(attribute.Info, []byte{1, 2, 3}) {
+					t.Errorf("Custom attribute % x, want 01 02 03", attribute.Info)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,412 @@
+package parse
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+)
+
+// ClassFileWriter extends bytes.Buffer to allow java specific writers.
+type ClassFileWriter bytes.Buffer
+
+// Write calls *bytes.Buffer.Write()
+func (w *ClassFileWriter) Write(p []byte) (n int, err error) {
+	return ((*bytes.Buffer)(w)).Write(p)
+}
+
+// WriteByte calls *bytes.Buffer.WriteByte()
+func (w *ClassFileWriter) WriteByte(c byte) error {
+	return ((*bytes.Buffer)(w)).WriteByte(c)
+}
+
+// Bytes calls *bytes.Buffer.Bytes()
+func (w *ClassFileWriter) Bytes() []byte {
+	return ((*bytes.Buffer)(w)).Bytes()
+}
+
+// WriteU4 writes v as 4 big endian bytes.
+func (w *ClassFileWriter) WriteU4(v int) error {
+	if v < 0 || v > math.MaxUint32 {
+		return fmt.Errorf("%d doesn't fit into 4 bytes", v)
+	}
+	_, err := w.Write([]byte{byte(v >> 24), byte(v >> 16), byte(v >> 8), byte(v)})
+	return err
+}
+
+// WriteU2 writes v as 2 big endian bytes.
+func (w *ClassFileWriter) WriteU2(v int) error {
+	if v < 0 || v > math.MaxUint16 {
+		return fmt.Errorf("%d doesn't fit into 2 bytes", v)
+	}
+	_, err := w.Write([]byte{byte(v >> 8), byte(v)})
+	return err
+}
+
+// writeU8 writes v as 8 big endian bytes.
+func (w *ClassFileWriter) writeU8(v uint64) error {
+	err := w.WriteU4(int(v >> 32))
+	if err != nil {
+		return err
+	}
+	return w.WriteU4(int(v & math.MaxUint32))
+}
+
+// Write serializes cf as a .class file to w.
+//
+// All counts and attribute lengths are recomputed from the slices they describe. The only attribute that is encoded
+// from its typed representation is "Code": MethodInfo.Code provides max_stack, max_locals, the code and the exception
+// table. Every other attribute, including the attributes of the Code attribute, is written from its raw
+// AttributeInfo.Info, so edits to the decoded fields (ConstantValue, Signature, annotations, InnerClasses,
+// LineNumberTable, ...) are not written. Change the raw attribute, or generate the class with a ClassWriter, to change
+// them.
+func Write(w io.Writer, cf ClassFile) error {
+	out := new(ClassFileWriter)
+
+	err := out.WriteU4(cf.Magic)
+	if err != nil {
+		return err
+	}
+
+	err = out.WriteU2(cf.MinorVersion)
+	if err != nil {
+		return err
+	}
+
+	err = out.WriteU2(cf.MajorVersion)
+	if err != nil {
+		return err
+	}
+
+	err = out.WriteConstantPool(cf.ConstantPool)
+	if err != nil {
+		return err
+	}
+
+	err = out.WriteU2(int(cf.AccessFlags))
+	if err != nil {
+		return err
+	}
+
+	err = out.WriteU2(cf.ThisClass)
+	if err != nil {
+		return err
+	}
+
+	err = out.WriteU2(cf.SuperClass)
+	if err != nil {
+		return err
+	}
+
+	err = out.WriteU2(len(cf.Interfaces))
+	if err != nil {
+		return err
+	}
+	for _, index := range cf.Interfaces {
+		err = out.WriteU2(index)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = out.WriteFields(cf.Fields)
+	if err != nil {
+		return err
+	}
+
+	err = out.WriteMethods(cf.Methods, &cf)
+	if err != nil {
+		return err
+	}
+
+	err = out.WriteAttributes(cf.Attributes)
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(out.Bytes())
+	return err
+}
+
+// WriteConstantPool writes the constant pool count followed by all entries.
+//
+// Like in ReadConstantPool CONSTANT_Long_info and CONSTANT_Double_info are expected to take up two entries.
+func (w *ClassFileWriter) WriteConstantPool(entries []CpInfo) error {
+	err := w.WriteU2(len(entries) + 1)
+	if err != nil {
+		return err
+	}
+
+	for i := 0; i < len(entries); i++ {
+		err = w.WriteCPInfo(entries[i])
+		if err != nil {
+			return fmt.Errorf("constant pool entry %d: %w", i+1, err)
+		}
+		switch entries[i].(type) {
+		case ConstantLongInfo, ConstantDoubleInfo:
+			i++
+		}
+	}
+
+	return nil
+}
+
+// WriteCPInfo writes one constant pool entry.
+func (w *ClassFileWriter) WriteCPInfo(info CpInfo) (err error) {
+	switch t := info.(type) {
+	case ConstantUtf8Info:
+		content := t.Content
+		if content == nil {
+			content = EncodeModifiedUTF8(t.Text)
+		}
+		err = w.writeTagAndU2s(1, len(content))
+		if err != nil {
+			return
+		}
+		_, err = w.Write(content)
+	case ConstantIntegerInfo:
+		err = w.WriteByte(3)
+		if err != nil {
+			return
+		}
+		err = w.WriteU4(int(uint32(t.Integer)))
+	case ConstantFloatInfo:
+		err = w.WriteByte(4)
+		if err != nil {
+			return
+		}
+		err = w.WriteU4(int(math.Float32bits(t.Float)))
+	case ConstantLongInfo:
+		err = w.WriteByte(5)
+		if err != nil {
+			return
+		}
+		err = w.writeU8(uint64(t.Long))
+	case ConstantDoubleInfo:
+		err = w.WriteByte(6)
+		if err != nil {
+			return
+		}
+		err = w.writeU8(math.Float64bits(t.Double))
+	case ConstantClassInfo:
+		err = w.writeTagAndU2s(7, t.NameIndex)
+	case ConstantStringInfo:
+		err = w.writeTagAndU2s(8, t.StringIndex)
+	case ConstantFieldrefInfo:
+		err = w.writeTagAndU2s(9, t.ClassIndex, t.NameAndTypeIndex)
+	case ConstantMethodrefInfo:
+		err = w.writeTagAndU2s(10, t.ClassIndex, t.NameAndTypeIndex)
+	case ConstantInterfaceMethodrefInfo:
+		err = w.writeTagAndU2s(11, t.ClassIndex, t.NameAndTypeIndex)
+	case ConstantNameAndTypeInfo:
+		err = w.writeTagAndU2s(12, t.NameIndex, t.DescriptorIndex)
+	case ConstantMethodHandleInfo:
+		_, err = w.Write([]byte{15, t.ReferenceKind})
+		if err != nil {
+			return
+		}
+		err = w.WriteU2(t.ReferenceIndex)
+	case ConstantMethodTypeInfo:
+		err = w.writeTagAndU2s(16, t.DescriptorIndex)
+	case ConstantDynamicInfo:
+		err = w.writeTagAndU2s(17, t.BootstrapMethodAttrIndex, t.NameAndTypeIndex)
+	case ConstantInvokeDynamicInfo:
+		err = w.writeTagAndU2s(18, t.BootstrapMethodAttrIndex, t.NameAndTypeIndex)
+	case ConstantModuleInfo:
+		err = w.writeTagAndU2s(19, t.NameIndex)
+	case ConstantPackageInfo:
+		err = w.writeTagAndU2s(20, t.NameIndex)
+	default:
+		err = fmt.Errorf("unkown constant pool type %s", reflect.TypeOf(t))
+	}
+
+	return
+}
+
+// writeTagAndU2s writes a constant pool tag followed by u2 values.
+func (w *ClassFileWriter) writeTagAndU2s(tag byte, values ...int) error {
+	err := w.WriteByte(tag)
+	if err != nil {
+		return err
+	}
+	for _, v := range values {
+		err = w.WriteU2(v)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteFields writes the fields count followed by all fields.
+func (w *ClassFileWriter) WriteFields(entries []FieldInfo) error {
+	err := w.WriteU2(len(entries))
+	if err != nil {
+		return err
+	}
+
+	for i, entry := range entries {
+		err = w.WriteFieldInfo(entry)
+		if err != nil {
+			return fmt.Errorf("field %d: %w", i, err)
+		}
+	}
+
+	return nil
+}
+
+// WriteFieldInfo writes all information for one field.
+func (w *ClassFileWriter) WriteFieldInfo(info FieldInfo) error {
+	err := w.writeMemberHeader(int(info.AccessFlags), info.NameIndex, info.DescriptorIndex)
+	if err != nil {
+		return err
+	}
+
+	return w.WriteAttributes(info.Attributes)
+}
+
+// WriteMethods writes the methods count followed by all methods.
+//
+// cf is needed to find the attributes that have to be encoded from their typed representation.
+func (w *ClassFileWriter) WriteMethods(entries []MethodInfo, cf *ClassFile) error {
+	err := w.WriteU2(len(entries))
+	if err != nil {
+		return err
+	}
+
+	for i, entry := range entries {
+		err = w.WriteMethodInfo(entry, cf)
+		if err != nil {
+			return fmt.Errorf("method %d: %w", i, err)
+		}
+	}
+
+	return nil
+}
+
+// WriteMethodInfo writes all information for one method.
+func (w *ClassFileWriter) WriteMethodInfo(info MethodInfo, cf *ClassFile) error {
+	err := w.writeMemberHeader(int(info.AccessFlags), info.NameIndex, info.DescriptorIndex)
+	if err != nil {
+		return err
+	}
+
+	attributes := info.Attributes
+	if info.Code != nil {
+		attributes = make([]AttributeInfo, len(info.Attributes))
+		copy(attributes, info.Attributes)
+
+		found := false
+		for i, attribute := range attributes {
+			name, err := cf.utf8Text(attribute.AttributeNameIndex)
+			if err != nil {
+				return err
+			}
+			if name != "Code" {
+				continue
+			}
+
+			code := new(ClassFileWriter)
+			err = code.WriteCodeAttribute(*info.Code)
+			if err != nil {
+				return err
+			}
+			attributes[i].Info = code.Bytes()
+			found = true
+		}
+		if !found {
+			return fmt.Errorf("method has a decoded Code but no Code attribute")
+		}
+	}
+
+	return w.WriteAttributes(attributes)
+}
+
+// writeMemberHeader writes the access flags, name and descriptor shared by fields and methods.
+func (w *ClassFileWriter) writeMemberHeader(accessFlags, nameIndex, descriptorIndex int) error {
+	err := w.WriteU2(accessFlags)
+	if err != nil {
+		return err
+	}
+
+	err = w.WriteU2(nameIndex)
+	if err != nil {
+		return err
+	}
+
+	return w.WriteU2(descriptorIndex)
+}
+
+// WriteAttributes writes the attributes count followed by all attributes.
+func (w *ClassFileWriter) WriteAttributes(entries []AttributeInfo) error {
+	err := w.WriteU2(len(entries))
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		err = w.WriteAttributeInfo(entry)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// WriteAttributeInfo writes all information for one attribute using the length of Info as attribute_length.
+func (w *ClassFileWriter) WriteAttributeInfo(info AttributeInfo) error {
+	err := w.WriteU2(info.AttributeNameIndex)
+	if err != nil {
+		return err
+	}
+
+	err = w.WriteU4(len(info.Info))
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(info.Info)
+	return err
+}
+
+// WriteCodeAttribute writes the content of a "Code" attribute. Its attributes are written from their raw Info, see
+// Write.
+func (w *ClassFileWriter) WriteCodeAttribute(code CodeAttribute) error {
+	err := w.WriteU2(code.MaxStack)
+	if err != nil {
+		return err
+	}
+
+	err = w.WriteU2(code.MaxLocals)
+	if err != nil {
+		return err
+	}
+
+	err = w.WriteU4(len(code.Code))
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(code.Code)
+	if err != nil {
+		return err
+	}
+
+	err = w.WriteU2(len(code.ExceptionTable))
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range code.ExceptionTable {
+		for _, v := range []int{entry.StartPc, entry.EndPc, entry.HandlerPc, entry.CatchType} {
+			err = w.WriteU2(v)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return w.WriteAttributes(code.Attributes)
+}
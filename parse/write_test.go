@@ -0,0 +1,79 @@
+package parse
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// testClasses returns the names of the class files in testdata.
+func testClasses(t testing.TB) []string {
+	names, err := filepath.Glob(filepath.Join("testdata", "*.class"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(names) == 0 {
+		t.Fatal("no class files in testdata")
+	}
+	return names
+}
+
+func TestWriteRoundTrip(t *testing.T) {
+	for _, name := range testClasses(t) {
+		t.Run(filepath.Base(name), func(t *testing.T) {
+			content, err := os.ReadFile(name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			cf, err := Parse(name)
+			if err != nil {
+				t.Fatalf("Parse failed: %v", err)
+			}
+
+			var out bytes.Buffer
+			if err := Write(&out, cf); err != nil {
+				t.Fatalf("Write failed: %v", err)
+			}
+			if !bytes.Equal(out.Bytes(), content) {
+				t.Errorf("written class file differs from %s: got %d bytes, want %d", name, out.Len(), len(content))
+			}
+		})
+	}
+}
+
+func TestWriteEncodesCode(t *testing.T) {
+	for _, name := range testClasses(t) {
+		t.Run(filepath.Base(name), func(t *testing.T) {
+			cf, err := Parse(name)
+			if err != nil {
+				t.Fatalf("Parse failed: %v", err)
+			}
+			for _, method := range cf.Methods {
+				if method.Code != nil {
+					method.Code.MaxStack += 3
+					method.Code.ExceptionTable = append(method.Code.ExceptionTable, ExceptionTableEntry{EndPc: 1})
+				}
+			}
+
+			var out bytes.Buffer
+			if err := Write(&out, cf); err != nil {
+				t.Fatalf("Write failed: %v", err)
+			}
+			written, err := ParseBytes(out.Bytes())
+			if err != nil {
+				t.Fatalf("written class file doesn't parse: %v", err)
+			}
+			for i, method := range written.Methods {
+				if method.Code == nil {
+					continue
+				}
+				want := cf.Methods[i].Code
+				if method.Code.MaxStack != want.MaxStack || len(method.Code.ExceptionTable) != len(want.ExceptionTable) {
+					t.Errorf("method %d: max_stack %d and %d exception handlers, want %d and %d", i,
+						method.Code.MaxStack, len(method.Code.ExceptionTable), want.MaxStack, len(want.ExceptionTable))
+				}
+			}
+		})
+	}
+}